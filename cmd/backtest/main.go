@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	stdlog "log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/backtest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/registry"
+
+	// Strategies register themselves in the registry.
+	_ "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/bulls-and-bears-mon"
+	_ "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/spread-parasite"
+)
+
+var (
+	configPath = flag.String("config", "configs/backtest.toml", "Path to backtest config file")
+	outDir     = flag.String("out", "backtest-report", "Directory to write the report to")
+)
+
+func init() {
+	flag.Parse()
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	cfg, err := loadConfig(*configPath)
+	mustNil(err)
+
+	lvl, err := zerolog.ParseLevel(strings.ToLower(cfg.Log.Level))
+	mustNil(err)
+	zerolog.SetGlobalLevel(lvl)
+
+	opts := engineOptions(cfg)
+	engine := backtest.New(opts)
+
+	instances, err := registry.Decode(opts.Account, cfg.Strategies)
+	mustNil(err)
+	for _, inst := range instances {
+		mustNil(engine.Add(inst))
+	}
+
+	data, err := os.Open(cfg.Data)
+	mustNil(err)
+	defer data.Close()
+
+	report, err := engine.Run(ctx, backtest.NewReader(data, opts.Tools))
+	mustNil(err)
+	mustNil(writeReport(*outDir, report))
+
+	// The summary is written regardless of the log level.
+	s := report.Summary
+	log.Log().
+		Int("events", s.Events).
+		Int("trades", s.Trades).
+		Str("pnl", s.PnL.String()).
+		Str("realized_pnl", s.RealizedPnL.String()).
+		Str("max_drawdown", s.MaxDrawdown.String()).
+		Float64("win_rate", s.WinRate).
+		Str("turnover", s.Turnover.String()).
+		Str("out", *outDir).
+		Msg("backtest finished")
+}
+
+func loadConfig(path string) (config.BacktestConfig, error) {
+	cfg, err := config.ParseBacktest(path)
+	if err != nil {
+		return cfg, fmt.Errorf("parse: %w", err)
+	}
+	if err := validator.New().Struct(cfg); err != nil {
+		return cfg, fmt.Errorf("validate: %w", err)
+	}
+	return cfg, nil
+}
+
+func engineOptions(cfg config.BacktestConfig) backtest.Options {
+	balance := make(map[string]decimal.Decimal, len(cfg.Balance))
+	for cur, m := range cfg.Balance {
+		balance[cur] = decimal.NewFromFloat(m)
+	}
+
+	tools := make([]toolscache.Tool, len(cfg.Instruments))
+	for i, ins := range cfg.Instruments {
+		typ := tinkoffinvest.InstrumentType(ins.Type)
+		if typ == "" {
+			typ = tinkoffinvest.InstrumentTypeShare
+		}
+		tools[i] = toolscache.Tool{
+			FIGI:         tinkoffinvest.FIGI(ins.FIGI),
+			Type:         typ,
+			Currency:     ins.Currency,
+			StocksPerLot: ins.StocksPerLot,
+			MinPriceInc:  decimal.NewFromFloat(ins.MinPriceInc),
+			Nominal:      decimal.NewFromFloat(ins.Nominal),
+			PointValue:   decimal.NewFromFloat(ins.PointValue),
+		}
+	}
+
+	return backtest.Options{
+		Account:    tinkoffinvest.AccountID(cfg.Account),
+		Balance:    balance,
+		Commission: decimal.NewFromFloat(cfg.Commission),
+		AllowShort: cfg.AllowShort,
+		Tools:      tools,
+	}
+}
+
+// writeReport writes report.json and trades.csv, equity.csv, summary.csv into the dir.
+func writeReport(dir string, report *backtest.Report) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:gosec
+		return fmt.Errorf("create report dir: %w", err)
+	}
+
+	for name, write := range map[string]func(f *os.File) error{
+		"report.json": func(f *os.File) error { return report.WriteJSON(f) },
+		"trades.csv":  func(f *os.File) error { return report.WriteTradesCSV(f) },
+		"equity.csv":  func(f *os.File) error { return report.WriteEquityCSV(f) },
+		"summary.csv": func(f *os.File) error { return report.WriteSummaryCSV(f) },
+	} {
+		if err := writeFile(filepath.Join(dir, name), write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func mustNil(err error) {
+	if err != nil {
+		stdlog.Panic(err)
+	}
+}
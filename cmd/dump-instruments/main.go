@@ -19,7 +19,10 @@ import (
 	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
 )
 
-var configPath = flag.String("config", "configs/config.toml", "Path to config file")
+var (
+	configPath     = flag.String("config", "configs/config.toml", "Path to config file")
+	instrumentType = flag.String("type", "share", "Instrument type: share, bond, etf, futures or currency")
+)
 
 func init() {
 	flag.Parse()
@@ -42,13 +45,13 @@ func main() {
 
 	tInvest, err := tinkoffinvest.NewClient(
 		conn,
-		cfg.Clients.TinkoffInvest.Token,
+		cfg.Clients.TinkoffInvest.Token.Reveal(),
 		cfg.Clients.TinkoffInvest.AppName,
 		true,
 	)
 	mustNil(err)
 
-	instruments, err := tInvest.GetTradeAvailableShares(ctx)
+	instruments, err := tInvest.GetTradeAvailableInstruments(ctx, tinkoffinvest.InstrumentType(*instrumentType))
 	mustNil(err)
 
 	sort.Slice(instruments, func(i, j int) bool {
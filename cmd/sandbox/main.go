@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	stdlog "log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+)
+
+const usage = `Usage: sandbox [flags] <command>
+
+Commands:
+  open    open new account and top it up with -amount if specified
+  list    list accounts
+  pay-in  top up -account with -amount
+  close   close -account
+
+Flags:
+`
+
+var (
+	configPath = flag.String("config", "configs/config.toml", "Path to config file")
+	accountID  = flag.String("account", "", "Sandbox account ID, config account number by default")
+	amount     = flag.String("amount", "", "Amount of RUB to pay in")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+}
+
+func main() {
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// Full validation is skipped to allow running without an account number.
+	cfg, err := config.Parse(*configPath)
+	mustNil(err)
+
+	addr := cfg.Clients.TinkoffInvest.Address
+	creds := insecure.NewCredentials()
+	if strings.HasSuffix(addr, ":443") {
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: true}) //nolint:gosec
+	}
+
+	conn, err := grpc.DialContext(ctx, addr,
+		grpc.WithBlock(),
+		grpc.WithUserAgent(cfg.Clients.TinkoffInvest.AppName),
+		grpc.WithTransportCredentials(creds),
+	)
+	mustNil(err)
+
+	tInvest, err := tinkoffinvest.NewClient(
+		conn,
+		cfg.Clients.TinkoffInvest.Token.Reveal(),
+		cfg.Clients.TinkoffInvest.AppName,
+		true,
+	)
+	mustNil(err)
+
+	account := tinkoffinvest.AccountID(*accountID)
+	if account == "" {
+		account = tinkoffinvest.AccountID(cfg.Account.Number)
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "open":
+		account, err = tInvest.OpenSandboxAccount(ctx)
+		mustNil(err)
+		fmt.Println(account)
+
+		if *amount != "" {
+			payIn(ctx, tInvest, account)
+		}
+
+	case "list":
+		accounts, err := tInvest.GetSandboxAccounts(ctx)
+		mustNil(err)
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		mustNil(enc.Encode(accounts))
+
+	case "pay-in":
+		mustAccount(account)
+		payIn(ctx, tInvest, account)
+
+	case "close":
+		mustAccount(account)
+		mustNil(tInvest.CloseSandboxAccount(ctx, account))
+
+	default:
+		stdlog.Panicf("unknown command %q", cmd)
+	}
+}
+
+func payIn(ctx context.Context, tInvest *tinkoffinvest.Client, account tinkoffinvest.AccountID) {
+	a, err := decimal.NewFromString(*amount)
+	mustNil(err)
+
+	balance, err := tInvest.SandboxPayIn(ctx, account, a)
+	mustNil(err)
+	fmt.Printf("%s balance: %s RUB\n", account, balance)
+}
+
+func mustAccount(account tinkoffinvest.AccountID) {
+	if account == "" {
+		stdlog.Panic("no -account specified")
+	}
+}
+
+func mustNil(err error) {
+	if err != nil {
+		stdlog.Panic(err)
+	}
+}
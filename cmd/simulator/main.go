@@ -1,8 +1,10 @@
 package main
 
 import (
+	"flag"
 	stdlog "log"
 	"net"
+	"strings"
 
 	"google.golang.org/grpc"
 
@@ -11,13 +13,24 @@ import (
 
 const addr = ":7171"
 
+var accounts = flag.String("accounts", "", "Comma-separated IDs of sandbox accounts to open at the start")
+
 func main() {
+	flag.Parse()
+
 	srv := grpc.NewServer()
 	sim := NewSimulator()
+	for _, id := range strings.Split(*accounts, ",") {
+		if id != "" {
+			sim.openAccount(id)
+		}
+	}
 
 	investpb.RegisterInstrumentsServiceServer(srv, sim)
+	investpb.RegisterMarketDataServiceServer(srv, sim)
 	investpb.RegisterMarketDataStreamServiceServer(srv, sim)
 	investpb.RegisterSandboxServiceServer(srv, sim)
+	investpb.RegisterUsersServiceServer(srv, sim)
 
 	lsn, err := net.Listen("tcp", addr)
 	mustNil(err)
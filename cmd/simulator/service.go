@@ -2,6 +2,7 @@ package main
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 
 	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
@@ -13,10 +14,22 @@ func init() {
 
 type Simulator struct {
 	investpb.UnimplementedInstrumentsServiceServer
+	investpb.UnimplementedMarketDataServiceServer
 	investpb.UnimplementedMarketDataStreamServiceServer
 	investpb.UnimplementedSandboxServiceServer
+	investpb.UnimplementedUsersServiceServer
+
+	ordersMu sync.Mutex
+	orders   map[string]*investpb.PostOrderRequest // By order ID.
+	orderIDs map[string]string                     // By idempotency key.
+
+	accountsMu sync.Mutex
+	accounts   []*investpb.Account
 }
 
 func NewSimulator() *Simulator {
-	return new(Simulator)
+	return &Simulator{
+		orders:   make(map[string]*investpb.PostOrderRequest),
+		orderIDs: make(map[string]string),
+	}
 }
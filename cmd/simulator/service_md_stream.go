@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io"
 	stdlog "log"
 	"math/rand"
 	"sync"
@@ -15,88 +18,136 @@ import (
 )
 
 func (s *Simulator) MarketDataStream(srv investpb.MarketDataStreamService_MarketDataStreamServer) error {
-	initialReq, err := srv.Recv()
-	if err != nil {
-		return err
-	}
-
-	obReq, ok := initialReq.Payload.(*investpb.MarketDataRequest_SubscribeOrderBookRequest)
-	if !ok {
-		return status.Error(codes.Unimplemented, "simulator supports order book only")
-	}
+	ctx := srv.Context()
 
-	req := obReq.SubscribeOrderBookRequest
-	if req.SubscriptionAction != investpb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE {
-		return status.Error(codes.Unimplemented, "simulator supports simple subscription only")
-	}
+	var (
+		sendMu sync.Mutex
+		wg     sync.WaitGroup
+	)
+	defer wg.Wait()
 
-	if len(req.Instruments) == 0 {
-		return status.Error(codes.InvalidArgument, "no instruments in request")
+	send := func(resp *investpb.MarketDataResponse) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return srv.Send(resp)
 	}
 
-	subs := make([]*investpb.OrderBookSubscription, len(req.Instruments))
-	for i, tool := range req.Instruments {
-		subStatus := investpb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS
-
-		switch tool.Depth {
-		case 1, 10, 20, 30, 40, 50:
-		default:
-			subStatus = investpb.SubscriptionStatus_SUBSCRIPTION_STATUS_DEPTH_IS_INVALID
+	// Order book generators by figi.
+	orderBooks := make(map[string]context.CancelFunc)
+	defer func() {
+		for _, cancel := range orderBooks {
+			cancel()
 		}
+	}()
 
-		subs[i] = &investpb.OrderBookSubscription{
-			Figi:               tool.Figi,
-			Depth:              tool.Depth,
-			SubscriptionStatus: subStatus,
+	for {
+		req, err := srv.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return err
 		}
-	}
 
-	initialResp := &investpb.MarketDataResponse{
-		Payload: &investpb.MarketDataResponse_SubscribeOrderBookResponse{
-			SubscribeOrderBookResponse: &investpb.SubscribeOrderBookResponse{
-				TrackingId:             uuid.NewString(),
-				OrderBookSubscriptions: subs,
-			},
-		},
-	}
-	if err := srv.Send(initialResp); err != nil {
-		return err
-	}
-
-	ctx := srv.Context()
+		switch v := req.Payload.(type) {
+		case *investpb.MarketDataRequest_SubscribeInfoRequest:
+			if err := send(tradingStatusSubscriptionResponse(v.SubscribeInfoRequest)); err != nil {
+				return err
+			}
 
-	var wg sync.WaitGroup
-	wg.Add(len(req.Instruments))
+		case *investpb.MarketDataRequest_SubscribeOrderBookRequest:
+			obReq := v.SubscribeOrderBookRequest
+			if len(obReq.Instruments) == 0 {
+				return status.Error(codes.InvalidArgument, "no instruments in request")
+			}
 
-	for _, tool := range req.Instruments {
-		tool := tool
+			subs := make([]*investpb.OrderBookSubscription, len(obReq.Instruments))
+			for i, tool := range obReq.Instruments {
+				subStatus := investpb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS
 
-		go func() {
-			defer wg.Done()
+				switch tool.Depth {
+				case 1, 10, 20, 30, 40, 50:
+				default:
+					subStatus = investpb.SubscriptionStatus_SUBSCRIPTION_STATUS_DEPTH_IS_INVALID
+				}
 
-			for {
-				sleep := time.Duration(500+rand.Intn(2000)) * time.Millisecond
+				subs[i] = &investpb.OrderBookSubscription{
+					Figi:               tool.Figi,
+					Depth:              tool.Depth,
+					SubscriptionStatus: subStatus,
+				}
 
-				select {
-				case <-ctx.Done():
-					return
+				if subStatus != investpb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
+					continue
+				}
 
-				case <-time.After(sleep):
-					resp := &investpb.MarketDataResponse{
-						Payload: newRandomOrderBook(tool.Figi, tool.Depth),
+				switch obReq.SubscriptionAction {
+				case investpb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE:
+					if _, ok := orderBooks[tool.Figi]; ok {
+						continue
 					}
-					if err := srv.Send(resp); err != nil {
-						stdlog.Printf("send order book for %v: %v", tool.Figi, err)
-					} else {
-						stdlog.Printf("send order book for %v", tool.Figi)
+
+					toolCtx, cancel := context.WithCancel(ctx)
+					orderBooks[tool.Figi] = cancel
+
+					tool := tool
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						generateOrderBooks(toolCtx, send, tool)
+					}()
+
+				case investpb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE:
+					if cancel, ok := orderBooks[tool.Figi]; ok {
+						cancel()
+						delete(orderBooks, tool.Figi)
 					}
+
+				case investpb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSPECIFIED:
+					subs[i].SubscriptionStatus = investpb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUBSCRIPTION_ACTION_IS_INVALID
 				}
 			}
-		}()
+
+			if err := send(&investpb.MarketDataResponse{
+				Payload: &investpb.MarketDataResponse_SubscribeOrderBookResponse{
+					SubscribeOrderBookResponse: &investpb.SubscribeOrderBookResponse{
+						TrackingId:             uuid.NewString(),
+						OrderBookSubscriptions: subs,
+					},
+				},
+			}); err != nil {
+				return err
+			}
+
+		default:
+			return status.Error(codes.Unimplemented, "simulator supports order book and trading statuses only")
+		}
 	}
+}
 
-	wg.Wait()
-	return nil
+func generateOrderBooks(
+	ctx context.Context,
+	send func(resp *investpb.MarketDataResponse) error,
+	tool *investpb.OrderBookInstrument,
+) {
+	for {
+		sleep := time.Duration(500+rand.Intn(2000)) * time.Millisecond
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-time.After(sleep):
+			resp := &investpb.MarketDataResponse{
+				Payload: newRandomOrderBook(tool.Figi, tool.Depth),
+			}
+			if err := send(resp); err != nil {
+				stdlog.Printf("send order book for %v: %v", tool.Figi, err)
+			} else {
+				stdlog.Printf("send order book for %v", tool.Figi)
+			}
+		}
+	}
 }
 
 const (
@@ -135,11 +186,11 @@ func newRandomOrderBook(figi string, depth int32) *investpb.MarketDataResponse_O
 			}},
 			Time: timestamppb.Now(),
 			LimitUp: &investpb.Quotation{
-				Units: baseBidUnits - 50,
+				Units: baseBidUnits + 50,
 				Nano:  newRandNano(),
 			},
 			LimitDown: &investpb.Quotation{
-				Units: baseBidUnits + 50,
+				Units: baseBidUnits - 50,
 				Nano:  newRandNano(),
 			},
 		},
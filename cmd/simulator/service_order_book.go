@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+func (s *Simulator) GetOrderBook(_ context.Context, req *investpb.GetOrderBookRequest) (*investpb.GetOrderBookResponse, error) {
+	ob := newRandomOrderBook(req.Figi, req.Depth).Orderbook
+
+	return &investpb.GetOrderBookResponse{
+		Figi:      ob.Figi,
+		Depth:     ob.Depth,
+		Bids:      ob.Bids,
+		Asks:      ob.Asks,
+		LastPrice: ob.Bids[0].Price,
+		LimitUp:   ob.LimitUp,
+		LimitDown: ob.LimitDown,
+	}, nil
+}
@@ -9,9 +9,23 @@ import (
 	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
 )
 
-func (s *Simulator) PostSandboxOrder(context.Context, *investpb.PostOrderRequest) (*investpb.PostOrderResponse, error) {
+// PostSandboxOrder generates the order ID like the exchange does.
+// Repeated requests with the same idempotency key refer to the same order.
+func (s *Simulator) PostSandboxOrder(_ context.Context, req *investpb.PostOrderRequest) (*investpb.PostOrderResponse, error) {
+	s.ordersMu.Lock()
+	defer s.ordersMu.Unlock()
+
+	orderID, ok := s.orderIDs[req.OrderId]
+	if !ok || req.OrderId == "" {
+		orderID = uuid.NewString()
+		s.orders[orderID] = req
+		if req.OrderId != "" {
+			s.orderIDs[req.OrderId] = orderID
+		}
+	}
+
 	return &investpb.PostOrderResponse{
-		OrderId: uuid.NewString(),
+		OrderId: orderID,
 	}, nil
 }
 
@@ -21,12 +35,44 @@ func (s *Simulator) CancelSandboxOrder(context.Context, *investpb.CancelOrderReq
 	}, nil
 }
 
+// GetSandboxOrderState reports every order as executed at once.
 func (s *Simulator) GetSandboxOrderState(ctx context.Context, req *investpb.GetOrderStateRequest) (*investpb.OrderState, error) {
-	return &investpb.OrderState{
+	state := &investpb.OrderState{
 		OrderId:               req.OrderId,
 		ExecutionReportStatus: investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL,
 		ExecutedOrderPrice:    &investpb.MoneyValue{Currency: "rub", Units: 122, Nano: 330000000},
-	}, nil
+	}
+
+	s.ordersMu.Lock()
+	order, ok := s.orders[req.OrderId]
+	s.ordersMu.Unlock()
+
+	if ok {
+		price := &investpb.MoneyValue{Currency: "rub", Units: 122, Nano: 330000000}
+		if order.OrderType == investpb.OrderType_ORDER_TYPE_LIMIT {
+			price = &investpb.MoneyValue{Currency: "rub", Units: order.Price.Units, Nano: order.Price.Nano}
+		}
+
+		state.Figi = order.Figi
+		state.Direction = order.Direction
+		state.LotsRequested = order.Quantity
+		state.LotsExecuted = order.Quantity
+		state.OrderType = order.OrderType
+		state.Currency = "rub"
+		state.AveragePositionPrice = price
+		state.ExecutedCommission = &investpb.MoneyValue{Currency: "rub"}
+		state.Stages = []*investpb.OrderStage{{Price: price, Quantity: order.Quantity, TradeId: uuid.NewString()}}
+	}
+	return state, nil
+}
+
+// GetSandboxOrders reports no active orders, because every order is executed at once.
+func (s *Simulator) GetSandboxOrders(context.Context, *investpb.GetOrdersRequest) (*investpb.GetOrdersResponse, error) {
+	return &investpb.GetOrdersResponse{}, nil
+}
+
+func (s *Simulator) GetSandboxOperations(context.Context, *investpb.OperationsRequest) (*investpb.OperationsResponse, error) {
+	return &investpb.OperationsResponse{}, nil
 }
 
 func (s *Simulator) GetSandboxPortfolio(context.Context, *investpb.PortfolioRequest) (*investpb.PortfolioResponse, error) {
@@ -36,6 +82,12 @@ func (s *Simulator) GetSandboxPortfolio(context.Context, *investpb.PortfolioRequ
 	}, nil
 }
 
+func (s *Simulator) GetSandboxPositions(context.Context, *investpb.PositionsRequest) (*investpb.PositionsResponse, error) {
+	return &investpb.PositionsResponse{
+		Money: []*investpb.MoneyValue{{Currency: "rub", Units: 1000, Nano: 0}},
+	}, nil
+}
+
 func (s *Simulator) SandboxPayIn(context.Context, *investpb.SandboxPayInRequest) (*investpb.SandboxPayInResponse, error) {
 	return &investpb.SandboxPayInResponse{
 		Balance: &investpb.MoneyValue{Currency: "rub", Units: 1000, Nano: 0},
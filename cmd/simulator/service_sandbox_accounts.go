@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+func (s *Simulator) OpenSandboxAccount(
+	context.Context,
+	*investpb.OpenSandboxAccountRequest,
+) (*investpb.OpenSandboxAccountResponse, error) {
+	id := uuid.NewString()
+	s.openAccount(id)
+	return &investpb.OpenSandboxAccountResponse{AccountId: id}, nil
+}
+
+func (s *Simulator) openAccount(id string) {
+	account := &investpb.Account{
+		Id:          id,
+		Type:        investpb.AccountType_ACCOUNT_TYPE_TINKOFF,
+		Name:        "simulator",
+		Status:      investpb.AccountStatus_ACCOUNT_STATUS_OPEN,
+		OpenedDate:  timestamppb.Now(),
+		AccessLevel: investpb.AccessLevel_ACCOUNT_ACCESS_LEVEL_FULL_ACCESS,
+	}
+
+	s.accountsMu.Lock()
+	s.accounts = append(s.accounts, account)
+	s.accountsMu.Unlock()
+}
+
+func (s *Simulator) GetSandboxAccounts(context.Context, *investpb.GetAccountsRequest) (*investpb.GetAccountsResponse, error) {
+	s.accountsMu.Lock()
+	defer s.accountsMu.Unlock()
+
+	accounts := make([]*investpb.Account, len(s.accounts))
+	for i, a := range s.accounts {
+		accounts[i] = proto.Clone(a).(*investpb.Account)
+	}
+	return &investpb.GetAccountsResponse{Accounts: accounts}, nil
+}
+
+func (s *Simulator) CloseSandboxAccount(
+	_ context.Context,
+	req *investpb.CloseSandboxAccountRequest,
+) (*investpb.CloseSandboxAccountResponse, error) {
+	s.accountsMu.Lock()
+	defer s.accountsMu.Unlock()
+
+	for _, a := range s.accounts {
+		if a.Id == req.AccountId {
+			a.Status = investpb.AccountStatus_ACCOUNT_STATUS_CLOSED
+			a.ClosedDate = timestamppb.Now()
+		}
+	}
+	return &investpb.CloseSandboxAccountResponse{}, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+const simulatorExchange = "SIMULATOR"
+
+// TradingSchedules reports every day as trading around the clock.
+func (s *Simulator) TradingSchedules(
+	_ context.Context,
+	req *investpb.TradingSchedulesRequest,
+) (*investpb.TradingSchedulesResponse, error) {
+	const day = 24 * time.Hour
+
+	var days []*investpb.TradingDay
+	for d := req.From.AsTime().Truncate(day); d.Before(req.To.AsTime()); d = d.Add(day) {
+		days = append(days, &investpb.TradingDay{
+			Date:         timestamppb.New(d),
+			IsTradingDay: true,
+			StartTime:    timestamppb.New(d),
+			EndTime:      timestamppb.New(d.Add(day)),
+		})
+	}
+
+	return &investpb.TradingSchedulesResponse{
+		Exchanges: []*investpb.TradingSchedule{{
+			Exchange: simulatorExchange,
+			Days:     days,
+		}},
+	}, nil
+}
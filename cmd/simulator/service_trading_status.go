@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+func (s *Simulator) GetTradingStatus(_ context.Context, req *investpb.GetTradingStatusRequest) (*investpb.GetTradingStatusResponse, error) { //nolint:lll
+	return &investpb.GetTradingStatusResponse{
+		Figi:                     req.Figi,
+		TradingStatus:            investpb.SecurityTradingStatus_SECURITY_TRADING_STATUS_NORMAL_TRADING,
+		LimitOrderAvailableFlag:  true,
+		MarketOrderAvailableFlag: true,
+		ApiTradeAvailableFlag:    true,
+	}, nil
+}
+
+// tradingStatusSubscriptionResponse confirms the subscription. The simulator never changes the statuses.
+func tradingStatusSubscriptionResponse(req *investpb.SubscribeInfoRequest) *investpb.MarketDataResponse {
+	subs := make([]*investpb.InfoSubscription, len(req.Instruments))
+	for i, tool := range req.Instruments {
+		subs[i] = &investpb.InfoSubscription{
+			Figi:               tool.Figi,
+			SubscriptionStatus: investpb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS,
+		}
+	}
+
+	return &investpb.MarketDataResponse{
+		Payload: &investpb.MarketDataResponse_SubscribeInfoResponse{
+			SubscribeInfoResponse: &investpb.SubscribeInfoResponse{
+				TrackingId:        uuid.NewString(),
+				InfoSubscriptions: subs,
+			},
+		},
+	}
+}
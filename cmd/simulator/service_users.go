@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+const servicePrefix = "tinkoff.public.invest.api.contract.v1."
+
+// GetUserTariff reports the limits similar to the default API tariff.
+func (s *Simulator) GetUserTariff(context.Context, *investpb.GetUserTariffRequest) (*investpb.GetUserTariffResponse, error) {
+	return &investpb.GetUserTariffResponse{
+		UnaryLimits: []*investpb.UnaryLimit{
+			{
+				LimitPerMinute: 300,
+				Methods: []string{
+					servicePrefix + "MarketDataService/GetOrderBook",
+					servicePrefix + "MarketDataService/GetTradingStatus",
+				},
+			},
+			{
+				LimitPerMinute: 200,
+				Methods: []string{
+					servicePrefix + "InstrumentsService/GetInstrumentBy",
+					servicePrefix + "InstrumentsService/TradingSchedules",
+				},
+			},
+			{
+				LimitPerMinute: 100,
+				Methods: []string{
+					servicePrefix + "SandboxService/PostSandboxOrder",
+					servicePrefix + "SandboxService/CancelSandboxOrder",
+					servicePrefix + "SandboxService/GetSandboxOrderState",
+					servicePrefix + "SandboxService/GetSandboxOrders",
+					servicePrefix + "SandboxService/GetSandboxPositions",
+					servicePrefix + "SandboxService/GetSandboxPortfolio",
+					servicePrefix + "SandboxService/GetSandboxOperations",
+				},
+			},
+		},
+		StreamLimits: []*investpb.StreamLimit{{
+			Limit:   16,
+			Streams: []string{servicePrefix + "MarketDataStreamService/MarketDataStream"},
+		}},
+	}, nil
+}
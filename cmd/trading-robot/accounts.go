@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+)
+
+var errNoAccounts = errors.New("no account to trade with: set account.number, account.bootstrap or accounts")
+
+type tradingAccount struct {
+	ID         tinkoffinvest.AccountID
+	Strategies config.StrategiesConfig
+}
+
+// resolveAccounts finds the configured accounts among the ones available for the token
+// and checks that the robot can trade with them.
+func resolveAccounts(
+	ctx context.Context,
+	client *tinkoffinvest.Client,
+	configs []config.TradingAccountConfig,
+) ([]tradingAccount, error) {
+	available, err := client.GetAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get accounts: %w", err)
+	}
+
+	accounts := make([]tradingAccount, 0, len(configs))
+	seen := make(map[tinkoffinvest.AccountID]struct{}, len(configs))
+
+	for _, c := range configs {
+		a, err := findAccount(available, c)
+		if err != nil {
+			return nil, err
+		}
+
+		if !a.CanTrade() {
+			return nil, fmt.Errorf("account %s cannot be traded: status %s, access level %s", a.ID, a.Status, a.AccessLevel)
+		}
+
+		if _, ok := seen[a.ID]; ok {
+			return nil, fmt.Errorf("account %s is configured twice", a.ID)
+		}
+		seen[a.ID] = struct{}{}
+
+		log.Info().Str("account", a.ID.S()).Str("name", a.Name).Msg("account is ready for trading")
+		accounts = append(accounts, tradingAccount{ID: a.ID, Strategies: c.Strategies})
+	}
+	return accounts, nil
+}
+
+// findAccount looks for the account by number or by unique name if the number is empty.
+func findAccount(accounts []tinkoffinvest.Account, c config.TradingAccountConfig) (tinkoffinvest.Account, error) {
+	if c.Number != "" {
+		for _, a := range accounts {
+			if a.ID.S() == c.Number {
+				return a, nil
+			}
+		}
+		return tinkoffinvest.Account{}, fmt.Errorf("account %s not found", c.Number)
+	}
+
+	var (
+		found tinkoffinvest.Account
+		n     int
+	)
+	for _, a := range accounts {
+		if a.Name == c.Name {
+			found = a
+			n++
+		}
+	}
+
+	switch n {
+	case 0:
+		return tinkoffinvest.Account{}, fmt.Errorf("account %q not found", c.Name)
+	case 1:
+		return found, nil
+	default:
+		return tinkoffinvest.Account{}, fmt.Errorf("account name %q is ambiguous, use number", c.Name)
+	}
+}
@@ -6,23 +6,26 @@ import (
 	"errors"
 	"flag"
 	stdlog "log"
+	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
-	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
-	portfoliowatcher "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/portfolio-watcher"
+	exchangecalendar "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/exchange-calendar"
+	marketdatahub "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/market-data-hub"
+	orderreconciler "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/order-reconciler"
+	papertrader "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/paper-trader"
+	riskmanager "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/risk-manager"
 	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
-	bullsbearsmon "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/bulls-and-bears-mon"
-	spreadparasite "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/spread-parasite"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/registry"
 )
 
 var configPath = flag.String("config", "configs/config.toml", "Path to config file")
@@ -31,22 +34,17 @@ func init() {
 	flag.Parse()
 }
 
-type Strategy interface {
-	Name() string
-	Run(ctx context.Context) error
-}
-
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	cfg, err := config.Parse(*configPath)
+	cfg, err := loadConfig(*configPath)
 	mustNil(err)
-	mustNil(validator.New().Struct(cfg))
 
 	lvl, err := zerolog.ParseLevel(strings.ToLower(cfg.Log.Level))
 	mustNil(err)
 	zerolog.SetGlobalLevel(lvl)
+	log.Debug().Interface("config", cfg).Msg("config loaded") // Secrets are redacted.
 
 	addr := cfg.Clients.TinkoffInvest.Address
 	log.Info().Str("addr", addr).Msg("connect to tinkoff invest api")
@@ -64,16 +62,54 @@ func main() {
 	)
 	mustNil(err)
 
+	tInvestOpts := []tinkoffinvest.Option{
+		tinkoffinvest.WithDefaultTimeout(cfg.Clients.TinkoffInvest.DefaultTimeout.D()),
+		tinkoffinvest.WithOrderRetries(
+			cfg.Clients.TinkoffInvest.OrderRetries,
+			cfg.Clients.TinkoffInvest.OrderRetryBackoff.D(),
+		),
+	}
+	for _, t := range cfg.Clients.TinkoffInvest.Timeouts {
+		tInvestOpts = append(tInvestOpts, tinkoffinvest.WithMethodTimeout(t.Method, t.Timeout.D()))
+	}
+
 	tInvestClient, err := tinkoffinvest.NewClient(
 		conn,
-		cfg.Clients.TinkoffInvest.Token,
+		cfg.Clients.TinkoffInvest.Token.Reveal(),
 		cfg.Clients.TinkoffInvest.AppName,
 		cfg.Account.Sandbox,
+		tInvestOpts...,
 	)
 	mustNil(err)
 
+	// Strategies rely on the client throttling, so an unthrottled robot must not start.
+	tariff, err := tInvestClient.SetupRateLimits(ctx)
+	mustNil(err)
+	log.Info().
+		Int("unary_limits", len(tariff.UnaryLimits)).
+		Int("stream_limits", len(tariff.StreamLimits)).
+		Msg("rate limits are set up")
+
 	toolsCache := toolscache.New(tInvestClient)
-	portfolioWatcher := portfoliowatcher.New(0, tinkoffinvest.AccountID(cfg.Account.Number), tInvestClient)
+	marketDataHub, err := marketdatahub.New(
+		0,
+		marketdatahub.BackpressurePolicy(cfg.MarketData.OrderBookBackpressure),
+		tInvestClient,
+	)
+	mustNil(err)
+
+	if cfg.Account.Number == "" && len(cfg.Accounts) == 0 {
+		if !cfg.Account.Bootstrap {
+			mustNil(errNoAccounts)
+		}
+		if !cfg.Account.Sandbox {
+			mustNil(errBootstrapNotSandbox)
+		}
+
+		accountID, err := bootstrapSandboxAccount(ctx, tInvestClient, decimal.NewFromFloat(cfg.Account.BootstrapPayIn))
+		mustNil(err)
+		cfg.Account.Number = accountID.S()
+	}
 
 	if !cfg.Account.Sandbox {
 		_, err = tInvestClient.GetUserInfo(ctx)
@@ -84,79 +120,95 @@ func main() {
 		mustNil(err)
 	}
 
+	accounts, err := resolveAccounts(ctx, tInvestClient, cfg.TradingAccounts())
+	mustNil(err)
+
 	var wg Waiter
-	errCh := make(chan error, 4)
+	errCh := make(chan error, 5)
 
-	wg.Go(func() { errCh <- portfolioWatcher.Run(ctx) })
+	wg.Go(func() { errCh <- marketDataHub.Run(ctx) })
 
 	if cfg.Metrics.Enabled {
-		wg.Go(func() { errCh <- runMetrics(ctx, cfg.Metrics.Addr) })
+		addr := cfg.Metrics.Addr // The config could be reloaded.
+		wg.Go(func() { errCh <- runMetrics(ctx, addr) })
 	}
 
-	var strategies []Strategy
+	var (
+		accountsData accountsData         = tInvestClient
+		orders       registry.OrderPlacer = tInvestClient
+		riskClient   riskmanager.Client   = tInvestClient
+		risk         *riskmanager.Manager
+	)
+	if cfg.Paper.Enabled {
+		paper := papertrader.New(paperOptions(cfg.Paper), tInvestClient, marketDataHub, toolsCache)
+		accountsData, orders, riskClient = paper, paper, paper
+		wg.Go(func() { errCh <- paper.Run(ctx) })
+		log.Warn().Msg("paper trading, orders are not sent to the exchange")
+	}
+	if cfg.Risk.Enabled {
+		risk = riskmanager.New(riskLimits(cfg.Risk), cfg.Risk.SyncInterval.D(), riskClient, toolsCache)
+		orders = risk
+		wg.Go(func() { errCh <- risk.Run(ctx) })
+	}
 
-	if bbMonCfg := cfg.Strategies.BullsAndBearsMonitoring; bbMonCfg.Enabled {
-		toolConfs := make([]bullsbearsmon.ToolConfig, len(bbMonCfg.Instruments))
-		for i, ins := range bbMonCfg.Instruments {
-			toolConfs[i] = bullsbearsmon.ToolConfig{
-				FIGI:             tinkoffinvest.FIGI(ins.FIGI),
-				Depth:            ins.Depth,
-				DominanceRatio:   ins.DominanceRatio,
-				ProfitPercentage: ins.ProfitPercentage,
-			}
-		}
+	sup := newSupervisor(ctx, registry.Deps{
+		Orders:      orders,
+		MarketData:  marketDataHub,
+		ToolsCache:  toolsCache,
+		Calendar:    exchangecalendar.New(tInvestClient),
+		SessionOpts: sessionOptions(cfg.Sessions),
+	}, accountsData, risk, errCh)
+	plan, err := sup.plan(accounts, sessionOptions(cfg.Sessions))
+	mustNil(err)
+	mustNil(sup.apply(ctx, plan, orderreconciler.Policy(cfg.Reconciliation.OrphanedOrders)))
 
-		strategy, err := bullsbearsmon.New(
-			tinkoffinvest.AccountID(cfg.Account.Number),
-			bbMonCfg.IgnoreInconsistent,
-			toolConfs,
-			tInvestClient,
-			toolsCache,
-		)
-		mustNil(err)
+	if len(sup.running()) == 0 {
+		log.Warn().Msg("no strategies enabled")
+		cancel()
+	}
+
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	defer signal.Stop(reloads)
 
-		strategies = append(strategies, strategy)
+	var fileChanges <-chan struct{}
+	if cfg.Reload.WatchFile {
+		fileChanges = watchFile(ctx, *configPath, cfg.Reload.WatchInterval.D())
 	}
 
-	if spCfg := cfg.Strategies.SpreadParasite; spCfg.Enabled {
-		figis := make([]tinkoffinvest.FIGI, len(spCfg.Figis))
-		for i, f := range spCfg.Figis {
-			figis[i] = tinkoffinvest.FIGI(f)
+	reload := func(reason string) {
+		logger := log.With().Str("reason", reason).Logger()
+		if err := reloadConfig(ctx, *configPath, &cfg, tInvestClient, sup); err != nil {
+			logger.Err(err).Msg("config reload rejected")
+			return
 		}
+		logger.Info().Strs("strategies", sup.running()).Msg("config reloaded")
+	}
 
-		strategy, err := spreadparasite.New(
-			tinkoffinvest.AccountID(cfg.Account.Number),
-			spCfg.IgnoreInconsistent,
-			spCfg.MinSpreadPercentage,
-			figis,
-			tInvestClient,
-			toolsCache,
-		)
-		mustNil(err)
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
 
-		strategies = append(strategies, strategy)
-	}
+		case err := <-errCh:
+			if err != nil {
+				log.Err(err).Msg("error on startup")
+			}
+			break loop
 
-	if len(strategies) == 0 {
-		log.Warn().Msg("no strategies enabled")
-		cancel()
-	}
-	for _, s := range strategies {
-		s := s
-		wg.Go(func() { errCh <- s.Run(ctx) })
-	}
+		case <-reloads:
+			reload("signal")
 
-	select {
-	case <-ctx.Done():
-	case err := <-errCh:
-		if err != nil {
-			log.Err(err).Msg("error on startup")
-			cancel()
+		case <-fileChanges:
+			reload("file change")
 		}
 	}
 
 	log.Info().Msg("shutdown")
+	cancel()
 	wg.Wait()
+	sup.wait()
 }
 
 func mustNil(err error) {
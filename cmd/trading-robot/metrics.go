@@ -24,7 +24,7 @@ func runMetrics(ctx context.Context, addr string) error {
 	}()
 
 	if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		return fmt.Errorf("run metrics exposure server: %v", err)
+		return fmt.Errorf("run metrics exposure server: %w", err)
 	}
 	return nil
 }
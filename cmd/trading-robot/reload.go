@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+	orderreconciler "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/order-reconciler"
+	papertrader "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/paper-trader"
+	riskmanager "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/risk-manager"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/common"
+)
+
+const defaultWatchInterval = 5 * time.Second
+
+func loadConfig(path string) (config.Config, error) {
+	cfg, err := config.Parse(path)
+	if err != nil {
+		return cfg, fmt.Errorf("parse: %w", err)
+	}
+	if err := validator.New().Struct(cfg); err != nil {
+		return cfg, fmt.Errorf("validate: %w", err)
+	}
+	if err := validateStrategies(cfg); err != nil {
+		return cfg, fmt.Errorf("validate: %w", err)
+	}
+	return cfg, nil
+}
+
+func riskLimits(cfg config.RiskConfig) riskmanager.Limits {
+	reasons := make([]riskmanager.Reason, len(cfg.KillSwitchOn))
+	for i, r := range cfg.KillSwitchOn {
+		reasons[i] = riskmanager.Reason(r)
+	}
+
+	return riskmanager.Limits{
+		MaxPositionLots:    cfg.MaxPositionLots,
+		MaxNotional:        decimal.NewFromFloat(cfg.MaxNotional),
+		MaxOpenOrders:      cfg.MaxOpenOrders,
+		MaxOrdersPerMinute: cfg.MaxOrdersPerMinute,
+		MaxDailyLoss:       decimal.NewFromFloat(cfg.MaxDailyLoss),
+		KillSwitchOn:       reasons,
+	}
+}
+
+func paperOptions(cfg config.PaperConfig) papertrader.Options {
+	balance := make(map[string]decimal.Decimal, len(cfg.Balance))
+	for cur, m := range cfg.Balance {
+		balance[cur] = decimal.NewFromFloat(m)
+	}
+
+	return papertrader.Options{
+		Balance:    balance,
+		Commission: decimal.NewFromFloat(cfg.Commission),
+		Depth:      cfg.Depth,
+		AllowShort: cfg.AllowShort,
+	}
+}
+
+func sessionOptions(cfg config.SessionsConfig) common.SessionOptions {
+	return common.SessionOptions{
+		PauseBeforeClose:    cfg.PauseBeforeClose.D(),
+		CancelOrdersOnClose: cfg.CancelOrdersOnClose,
+	}
+}
+
+// reloadConfig re-reads the config and applies it to the running strategies.
+// The invalid config is rejected, leaving the running one untouched.
+// Changes of sections that are used only at the start are ignored with a warning.
+func reloadConfig(
+	ctx context.Context,
+	path string,
+	current *config.Config,
+	client *tinkoffinvest.Client,
+	sup *supervisor,
+) error {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	lvl, err := zerolog.ParseLevel(strings.ToLower(cfg.Log.Level))
+	if err != nil {
+		return fmt.Errorf("parse log level: %w", err)
+	}
+
+	if cfg.Account.Number == "" && len(cfg.Accounts) == 0 {
+		if !cfg.Account.Bootstrap {
+			return errNoAccounts
+		}
+		// Keep the account bootstrapped at the start.
+		cfg.Account.Number = current.Account.Number
+	}
+
+	accounts, err := resolveAccounts(ctx, client, cfg.TradingAccounts())
+	if err != nil {
+		return fmt.Errorf("resolve accounts: %w", err)
+	}
+
+	plan, err := sup.plan(accounts, sessionOptions(cfg.Sessions))
+	if err != nil {
+		return fmt.Errorf("build strategies: %w", err)
+	}
+
+	for name, changed := range map[string]bool{
+		"account.sandbox":    cfg.Account.Sandbox != current.Account.Sandbox,
+		"metrics":            !reflect.DeepEqual(cfg.Metrics, current.Metrics),
+		"clients":            !reflect.DeepEqual(cfg.Clients, current.Clients),
+		"market_data":        !reflect.DeepEqual(cfg.MarketData, current.MarketData),
+		"reload":             !reflect.DeepEqual(cfg.Reload, current.Reload),
+		"risk.enabled":       cfg.Risk.Enabled != current.Risk.Enabled,
+		"risk.sync_interval": cfg.Risk.SyncInterval != current.Risk.SyncInterval,
+		"paper":              !reflect.DeepEqual(cfg.Paper, current.Paper),
+	} {
+		if changed {
+			log.Warn().Str("section", name).Msg("config section change requires restart, ignored")
+		}
+	}
+
+	zerolog.SetGlobalLevel(lvl)
+	if sup.risk != nil {
+		sup.risk.SetLimits(riskLimits(cfg.Risk))
+	}
+	*current = cfg
+
+	// Failed start of some strategies does not revert the config, the rest of changes are applied anyway.
+	if err := sup.apply(ctx, plan, orderreconciler.Policy(cfg.Reconciliation.OrphanedOrders)); err != nil {
+		log.Err(err).Msg("config is applied partially")
+	}
+	return nil
+}
+
+// watchFile notifies about the file modifications by polling its size and modification time.
+func watchFile(ctx context.Context, path string, interval time.Duration) <-chan struct{} {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last, _ := os.Stat(path)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Warn().Err(err).Str("path", path).Msg("cannot stat config file")
+				continue
+			}
+
+			if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			last = info
+
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return changes
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+)
+
+var errBootstrapNotSandbox = errors.New("account bootstrap is supported for sandbox only")
+
+// bootstrapSandboxAccount returns the first open sandbox account or opens and funds new one.
+func bootstrapSandboxAccount(
+	ctx context.Context,
+	client *tinkoffinvest.Client,
+	payIn decimal.Decimal,
+) (tinkoffinvest.AccountID, error) {
+	accounts, err := client.GetSandboxAccounts(ctx)
+	if err != nil {
+		return "", fmt.Errorf("get sandbox accounts: %w", err)
+	}
+
+	for _, a := range accounts {
+		if a.Status == tinkoffinvest.AccountStatusOpen {
+			log.Info().Str("account", a.ID.S()).Msg("use existing sandbox account")
+			return a.ID, nil
+		}
+	}
+
+	accountID, err := client.OpenSandboxAccount(ctx)
+	if err != nil {
+		return "", fmt.Errorf("open sandbox account: %w", err)
+	}
+	log.Info().Str("account", accountID.S()).Msg("sandbox account opened")
+
+	if payIn.IsPositive() {
+		balance, err := client.SandboxPayIn(ctx, accountID, payIn)
+		if err != nil {
+			return "", fmt.Errorf("pay in sandbox account: %w", err)
+		}
+		log.Info().Str("account", accountID.S()).Str("balance", balance.String()).Msg("sandbox account funded")
+	}
+
+	return accountID, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/registry"
+
+	// Strategies register themselves in the registry.
+	_ "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/bulls-and-bears-mon"
+	_ "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/spread-parasite"
+)
+
+// strategy is the instance built from the config.
+type strategy struct {
+	instance registry.Instance
+	registry.Strategy
+}
+
+// newStrategies creates the strategies enabled for the account.
+func newStrategies(account tinkoffinvest.AccountID, cfg config.StrategiesConfig, deps registry.Deps) ([]strategy, error) {
+	instances, err := registry.Decode(account, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	strategies := make([]strategy, len(instances))
+	for i, inst := range instances {
+		s, err := inst.New(deps)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s: %w", inst.Name, err)
+		}
+		strategies[i] = strategy{instance: inst, Strategy: s}
+	}
+	return strategies, nil
+}
+
+// validateStrategies checks the strategies of all accounts before their creation.
+func validateStrategies(cfg config.Config) error {
+	for _, acc := range cfg.TradingAccounts() {
+		if _, err := registry.Decode("", acc.Strategies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
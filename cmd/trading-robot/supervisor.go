@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	orderreconciler "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/order-reconciler"
+	portfoliowatcher "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/portfolio-watcher"
+	riskmanager "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/risk-manager"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/common"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/registry"
+)
+
+// reconfigureTimeout limits the waiting for the strategy busy with applying the market data.
+const reconfigureTimeout = 10 * time.Second
+
+type strategyKey struct {
+	account tinkoffinvest.AccountID
+	name    string
+}
+
+// task is the goroutine that can be stopped.
+type task struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (t *task) stop() {
+	t.cancel()
+	<-t.done
+}
+
+type runningWatcher struct {
+	task
+	*portfoliowatcher.Watcher
+}
+
+type runningStrategy struct {
+	task
+	strategy
+	// figis are taken before the start, because the strategy could change them in Run.
+	figis []tinkoffinvest.FIGI
+}
+
+// accountsData provides the orders and portfolio of the accounts, the virtual ones in paper trading mode.
+type accountsData interface {
+	orderreconciler.Client
+	portfoliowatcher.PortfolioDataProvider
+}
+
+// supervisor runs the strategies and portfolio watchers of the accounts and applies config reloads to them.
+// It is not safe for concurrent use.
+type supervisor struct {
+	ctx  context.Context //nolint:containedctx // Parent of the running tasks.
+	deps registry.Deps
+	// accounts are reconciled and watched via it.
+	accounts accountsData
+	// risk tracks the accounts of the running strategies, nil if disabled.
+	risk *riskmanager.Manager
+	// errs receives the first error of the strategies.
+	errs chan<- error
+
+	wg         Waiter
+	strategies map[strategyKey]*runningStrategy
+	watchers   map[tinkoffinvest.AccountID]*runningWatcher
+}
+
+func newSupervisor(
+	ctx context.Context,
+	deps registry.Deps,
+	accounts accountsData,
+	risk *riskmanager.Manager,
+	errs chan<- error,
+) *supervisor {
+	return &supervisor{
+		ctx:        ctx,
+		deps:       deps,
+		accounts:   accounts,
+		risk:       risk,
+		errs:       errs,
+		strategies: make(map[strategyKey]*runningStrategy),
+		watchers:   make(map[tinkoffinvest.AccountID]*runningWatcher),
+	}
+}
+
+// strategiesPlan is the set of strategies built from the config.
+type strategiesPlan struct {
+	accounts []tradingAccount
+	deps     registry.Deps
+	keys     []strategyKey
+	built    map[strategyKey]strategy
+}
+
+// plan builds the strategies of the accounts without any changes of the running ones,
+// so the invalid config can be rejected as a whole.
+func (s *supervisor) plan(accounts []tradingAccount, sessionOpts common.SessionOptions) (*strategiesPlan, error) {
+	p := &strategiesPlan{
+		accounts: accounts,
+		deps:     s.deps,
+		built:    make(map[strategyKey]strategy),
+	}
+	p.deps.SessionOpts = sessionOpts
+
+	for _, acc := range accounts {
+		strategies, err := newStrategies(acc.ID, acc.Strategies, p.deps)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", acc.ID, err)
+		}
+
+		for _, st := range strategies {
+			k := strategyKey{account: acc.ID, name: st.instance.Name}
+			p.built[k] = st
+			p.keys = append(p.keys, k)
+		}
+	}
+	return p, nil
+}
+
+// apply brings the running strategies in line with the plan:
+// new strategies are started, removed ones are stopped and the rest are reconfigured
+// or restarted if the parameters cannot be applied on the fly.
+func (s *supervisor) apply(ctx context.Context, p *strategiesPlan, policy orderreconciler.Policy) error {
+	restartAll := p.deps.SessionOpts != s.deps.SessionOpts
+	s.deps = p.deps
+	built, keys, accounts := p.built, p.keys, p.accounts
+
+	for k, r := range s.strategies {
+		if _, ok := built[k]; !ok {
+			r.stop()
+			delete(s.strategies, k)
+			log.Info().Str("account", k.account.S()).Str("strategy", k.name).Msg("strategy stopped")
+		}
+	}
+
+	toStart := make(map[tinkoffinvest.AccountID][]strategy)
+	for _, k := range keys {
+		r, ok := s.strategies[k]
+		if !ok {
+			toStart[k.account] = append(toStart[k.account], built[k])
+			continue
+		}
+
+		if inst := built[k].instance; !restartAll && inst.Strategy == r.instance.Strategy {
+			err := func() error {
+				ctx, cancel := context.WithTimeout(ctx, reconfigureTimeout)
+				defer cancel()
+				return inst.Reconfigure(ctx, r.Strategy)
+			}()
+			if err == nil {
+				r.instance = inst
+				continue
+			}
+			if !errors.Is(err, common.ErrRestartRequired) {
+				log.Err(err).Str("account", k.account.S()).Str("strategy", k.name).Msg("cannot reconfigure strategy")
+				continue
+			}
+		}
+
+		r.stop()
+		delete(s.strategies, k)
+		log.Info().Str("account", k.account.S()).Str("strategy", k.name).Msg("strategy stopped for restart")
+		toStart[k.account] = append(toStart[k.account], built[k])
+	}
+
+	var errs []error
+	for _, acc := range accounts {
+		if err := s.start(ctx, acc.ID, toStart[acc.ID], policy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	s.updateWatchers(accounts)
+	if s.risk != nil {
+		ids := make([]tinkoffinvest.AccountID, len(accounts))
+		for i, acc := range accounts {
+			ids[i] = acc.ID
+		}
+		s.risk.Track(ids)
+	}
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// start reconciles the orders of the account with the strategies and runs them.
+// Orders for instruments traded by the running strategies of the account are left untouched.
+func (s *supervisor) start(
+	ctx context.Context,
+	account tinkoffinvest.AccountID,
+	strategies []strategy,
+	policy orderreconciler.Policy,
+) error {
+	if len(strategies) == 0 {
+		return nil
+	}
+
+	busy := make(map[tinkoffinvest.FIGI]struct{})
+	for k, r := range s.strategies {
+		if k.account != account {
+			continue
+		}
+		for _, f := range r.figis {
+			busy[f] = struct{}{}
+		}
+	}
+
+	reconciler, err := orderreconciler.New(policy, account, busyOrdersFilter{Client: s.accounts, busy: busy})
+	if err != nil {
+		return err
+	}
+
+	adopters := make([]orderreconciler.Adopter, len(strategies))
+	for i, st := range strategies {
+		adopters[i] = st
+	}
+	if err := reconciler.Reconcile(ctx, adopters); err != nil {
+		return fmt.Errorf("reconcile orders of account %s: %w", account, err)
+	}
+
+	for _, st := range strategies {
+		s.run(account, st)
+	}
+	return nil
+}
+
+func (s *supervisor) run(account tinkoffinvest.AccountID, st strategy) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	r := &runningStrategy{
+		task:     task{cancel: cancel, done: make(chan struct{})},
+		strategy: st,
+		figis:    st.FIGIs(),
+	}
+	s.strategies[strategyKey{account: account, name: st.instance.Name}] = r
+
+	s.wg.Go(func() {
+		defer close(r.done)
+
+		if err := st.Run(ctx); err != nil && ctx.Err() == nil {
+			s.report(fmt.Errorf("strategy %s of account %s: %w", st.Name(), account, err))
+		}
+	})
+	log.Info().Str("account", account.S()).Str("strategy", st.Name()).Msg("strategy started")
+}
+
+// updateWatchers runs the portfolio watchers of the accounts with the actual instruments of the strategies.
+// The running watchers are kept, so the operations seen by them are not collected again.
+func (s *supervisor) updateWatchers(accounts []tradingAccount) {
+	actual := make(map[tinkoffinvest.AccountID]struct{}, len(accounts))
+	for _, acc := range accounts {
+		actual[acc.ID] = struct{}{}
+	}
+	for id, w := range s.watchers {
+		if _, ok := actual[id]; !ok {
+			w.stop()
+			delete(s.watchers, id)
+		}
+	}
+
+	for _, acc := range accounts {
+		account := acc.ID
+
+		figiStrategies := make(map[tinkoffinvest.FIGI]string)
+		for k, r := range s.strategies {
+			if k.account != account {
+				continue
+			}
+			for _, f := range r.figis {
+				figiStrategies[f] = k.name
+			}
+		}
+
+		if w, ok := s.watchers[account]; ok {
+			w.SetStrategies(figiStrategies)
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(s.ctx)
+		w := &runningWatcher{
+			task:    task{cancel: cancel, done: make(chan struct{})},
+			Watcher: portfoliowatcher.New(0, account, figiStrategies, s.accounts),
+		}
+		s.watchers[account] = w
+
+		s.wg.Go(func() {
+			defer close(w.done)
+
+			if err := w.Run(ctx); err != nil && ctx.Err() == nil {
+				s.report(fmt.Errorf("portfolio watcher of account %s: %w", account, err))
+			}
+		})
+	}
+}
+
+// running returns the names of running strategies, e.g. for logging.
+func (s *supervisor) running() []string {
+	names := make([]string, 0, len(s.strategies))
+	for k := range s.strategies {
+		names = append(names, k.account.S()+"/"+k.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *supervisor) report(err error) {
+	select {
+	case s.errs <- err:
+	default:
+	}
+}
+
+// wait blocks until all the tasks are finished after the context cancellation.
+func (s *supervisor) wait() {
+	s.wg.Wait()
+}
+
+// busyOrdersFilter hides orders for the instruments traded by running strategies from the reconciler.
+type busyOrdersFilter struct {
+	orderreconciler.Client
+	busy map[tinkoffinvest.FIGI]struct{}
+}
+
+func (f busyOrdersFilter) GetOrders(ctx context.Context, account tinkoffinvest.AccountID) ([]tinkoffinvest.ActiveOrder, error) {
+	orders, err := f.Client.GetOrders(ctx, account)
+	if err != nil || len(f.busy) == 0 {
+		return orders, err
+	}
+
+	free := orders[:0]
+	for _, o := range orders {
+		if _, ok := f.busy[o.FIGI]; !ok {
+			free = append(free, o)
+		}
+	}
+	return free, nil
+}
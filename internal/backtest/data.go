@@ -0,0 +1,238 @@
+package backtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+)
+
+const (
+	maxRecordSize = 1 << 20
+	// tradesWindow is the period of trades making the volumes of the synthesized book sides.
+	tradesWindow = time.Minute
+)
+
+var (
+	ErrInvalidRecord = errors.New("invalid record")
+	ErrUnorderedData = errors.New("records are not ordered by time")
+)
+
+// Event is the market state replayed at its time.
+type Event struct {
+	Time time.Time
+	// OrderBook is recorded or synthesized from the trade or candle.
+	OrderBook tinkoffinvest.OrderBook
+}
+
+// record is the line of the data file, exactly one of OrderBook, Trade or Candle is set:
+//
+//	{"time":"2022-05-20T10:00:00Z","figi":"BBG004730N88","order_book":{"bids":[{"price":"125.1","lots":10}],"asks":[...]}}
+//	{"time":"2022-05-20T10:00:01Z","figi":"BBG004730N88","trade":{"price":"125.2","lots":3,"direction":"buy"}}
+//	{"time":"2022-05-20T10:01:00Z","figi":"BBG004730N88","candle":{"open":"125","close":"125.5","volume":120,...}}
+type record struct {
+	Time      time.Time        `json:"time"`
+	FIGI      string           `json:"figi"`
+	OrderBook *orderBookRecord `json:"order_book"`
+	Trade     *tradeRecord     `json:"trade"`
+	Candle    *candleRecord    `json:"candle"`
+}
+
+type orderBookRecord struct {
+	Bids      []levelRecord   `json:"bids"`
+	Asks      []levelRecord   `json:"asks"`
+	LimitUp   decimal.Decimal `json:"limit_up"`
+	LimitDown decimal.Decimal `json:"limit_down"`
+}
+
+type levelRecord struct {
+	Price decimal.Decimal `json:"price"`
+	Lots  int             `json:"lots"`
+}
+
+type tradeRecord struct {
+	Price decimal.Decimal `json:"price"`
+	Lots  int             `json:"lots"`
+	// Direction is the aggressor side, "buy" or "sell".
+	Direction string `json:"direction"`
+}
+
+type candleRecord struct {
+	Open   decimal.Decimal `json:"open"`
+	High   decimal.Decimal `json:"high"`
+	Low    decimal.Decimal `json:"low"`
+	Close  decimal.Decimal `json:"close"`
+	Volume int             `json:"volume"`
+}
+
+// Reader reads the JSON lines of recorded market data ordered by time.
+//
+// Trades and candles are turned into the one level books, because strategies react on order books only.
+// The trade price becomes the ask for the buy aggressor and the bid for the sell one,
+// the other side is one min price increment away. The candle close price becomes the bid.
+//
+// The book sides keep the pressure of the market: the bid lots are the volume of buy aggressors
+// of the instrument trades during the last tradesWindow and the ask lots are the volume of sell ones.
+// The candle volume is split by the close position in its range: the close at the high is all bought.
+// Each side has one lot at least.
+type Reader struct {
+	scanner *bufio.Scanner
+	// minPriceIncs are the increments of the replayed instruments.
+	minPriceIncs map[tinkoffinvest.FIGI]decimal.Decimal
+	// trades are the trades of instruments during the last tradesWindow.
+	trades map[tinkoffinvest.FIGI][]timedTrade
+	line   int
+	last   time.Time
+}
+
+type timedTrade struct {
+	time time.Time
+	tradeRecord
+}
+
+func NewReader(r io.Reader, tools []toolscache.Tool) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+
+	incs := make(map[tinkoffinvest.FIGI]decimal.Decimal, len(tools))
+	for _, t := range tools {
+		incs[t.FIGI] = t.MinPriceInc
+	}
+	return &Reader{
+		scanner:      scanner,
+		minPriceIncs: incs,
+		trades:       make(map[tinkoffinvest.FIGI][]timedTrade),
+	}
+}
+
+// Next returns the next event or io.EOF at the end of the data.
+func (r *Reader) Next() (Event, error) {
+	for r.scanner.Scan() {
+		r.line++
+		if len(r.scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec record
+		if err := json.Unmarshal(r.scanner.Bytes(), &rec); err != nil {
+			return Event{}, fmt.Errorf("line %d: %w: %v", r.line, ErrInvalidRecord, err)
+		}
+
+		ev, err := r.event(rec)
+		if err != nil {
+			return Event{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		if ev.Time.Before(r.last) {
+			return Event{}, fmt.Errorf("line %d: %w", r.line, ErrUnorderedData)
+		}
+		r.last = ev.Time
+		return ev, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return Event{}, fmt.Errorf("read data: %w", err)
+	}
+	return Event{}, io.EOF
+}
+
+func (r *Reader) event(rec record) (Event, error) {
+	if rec.Time.IsZero() || rec.FIGI == "" {
+		return Event{}, fmt.Errorf("%w: time and figi are required", ErrInvalidRecord)
+	}
+
+	figi := tinkoffinvest.FIGI(rec.FIGI)
+	ev := Event{Time: rec.Time, OrderBook: tinkoffinvest.OrderBook{FIGI: figi}}
+
+	switch {
+	case rec.OrderBook != nil && rec.Trade == nil && rec.Candle == nil:
+		ev.OrderBook.Bids = levels(rec.OrderBook.Bids)
+		ev.OrderBook.Asks = levels(rec.OrderBook.Asks)
+		ev.OrderBook.LimitUp = rec.OrderBook.LimitUp
+		ev.OrderBook.LimitDown = rec.OrderBook.LimitDown
+
+	case rec.Trade != nil && rec.OrderBook == nil && rec.Candle == nil:
+		inc, ok := r.minPriceIncs[figi]
+		if !ok {
+			return Event{}, fmt.Errorf("%w: unknown instrument %s", ErrInvalidRecord, figi)
+		}
+
+		bid, ask := rec.Trade.Price, rec.Trade.Price.Add(inc)
+		if rec.Trade.Direction == tinkoffinvest.TradeDirectionBuy.String() {
+			bid, ask = rec.Trade.Price.Sub(inc), rec.Trade.Price
+		}
+		buys, sells := r.tradedLots(figi, rec.Time, *rec.Trade)
+		ev.OrderBook.Bids = []tinkoffinvest.Order{{Price: bid, Lots: atLeastOne(buys)}}
+		ev.OrderBook.Asks = []tinkoffinvest.Order{{Price: ask, Lots: atLeastOne(sells)}}
+
+	case rec.Candle != nil && rec.OrderBook == nil && rec.Trade == nil:
+		inc, ok := r.minPriceIncs[figi]
+		if !ok {
+			return Event{}, fmt.Errorf("%w: unknown instrument %s", ErrInvalidRecord, figi)
+		}
+
+		buys, sells := rec.Candle.split()
+		ev.OrderBook.Bids = []tinkoffinvest.Order{{Price: rec.Candle.Close, Lots: atLeastOne(buys)}}
+		ev.OrderBook.Asks = []tinkoffinvest.Order{{Price: rec.Candle.Close.Add(inc), Lots: atLeastOne(sells)}}
+
+	default:
+		return Event{}, fmt.Errorf("%w: exactly one of order_book, trade or candle expected", ErrInvalidRecord)
+	}
+	return ev, nil
+}
+
+// tradedLots adds the trade to the window of the instrument and returns the lots of buy and sell aggressors in it.
+func (r *Reader) tradedLots(figi tinkoffinvest.FIGI, t time.Time, trade tradeRecord) (buys, sells int) {
+	trades := append(r.trades[figi], timedTrade{time: t, tradeRecord: trade})
+
+	i := 0
+	for i < len(trades) && t.Sub(trades[i].time) >= tradesWindow {
+		i++
+	}
+	trades = trades[i:]
+	r.trades[figi] = trades
+
+	for _, tr := range trades {
+		switch tr.Direction {
+		case tinkoffinvest.TradeDirectionBuy.String():
+			buys += tr.Lots
+		case tinkoffinvest.TradeDirectionSell.String():
+			sells += tr.Lots
+		}
+	}
+	return buys, sells
+}
+
+// split estimates the lots of buy and sell aggressors by the close position in the candle range,
+// the volume is split equally if the range is unknown.
+func (c candleRecord) split() (buys, sells int) {
+	rng := c.High.Sub(c.Low)
+	if !rng.IsPositive() || c.Close.LessThan(c.Low) || c.Close.GreaterThan(c.High) {
+		buys = c.Volume / 2
+		return buys, c.Volume - buys
+	}
+
+	buys = int(c.Close.Sub(c.Low).Mul(decimal.NewFromInt(int64(c.Volume))).Div(rng).Round(0).IntPart())
+	return buys, c.Volume - buys
+}
+
+func atLeastOne(lots int) int {
+	if lots < 1 {
+		return 1
+	}
+	return lots
+}
+
+func levels(recs []levelRecord) []tinkoffinvest.Order {
+	orders := make([]tinkoffinvest.Order, len(recs))
+	for i, l := range recs {
+		orders[i] = tinkoffinvest.Order{Price: l.Price, Lots: l.Lots}
+	}
+	return orders
+}
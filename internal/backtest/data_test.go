@@ -0,0 +1,163 @@
+package backtest_test
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/backtest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+)
+
+const figi = tinkoffinvest.FIGI("BBG004730N88")
+
+var tools = []toolscache.Tool{{
+	FIGI:         figi,
+	Currency:     "rub",
+	StocksPerLot: 10,
+	MinPriceInc:  decimal.RequireFromString("0.01"),
+}}
+
+func readAll(t *testing.T, data string) ([]backtest.Event, error) {
+	t.Helper()
+
+	r := backtest.NewReader(strings.NewReader(data), tools)
+
+	var events []backtest.Event
+	for {
+		ev, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, ev)
+	}
+}
+
+func prices(levels []tinkoffinvest.Order) []string {
+	p := make([]string, len(levels))
+	for i, l := range levels {
+		p[i] = l.Price.String() + "x" + strconv.Itoa(l.Lots)
+	}
+	return p
+}
+
+func TestReader(t *testing.T) {
+	events, err := readAll(t, strings.Join([]string{
+		`{"time":"2022-05-20T10:00:00Z","figi":"BBG004730N88","order_book":{` +
+			`"bids":[{"price":"99.9","lots":3},{"price":"99.8","lots":5}],"asks":[{"price":100,"lots":2}],` +
+			`"limit_up":"110","limit_down":"90"}}`,
+		`{"time":"2022-05-20T10:00:01Z","figi":"BBG004730N88","trade":{"price":"100.1","lots":4,"direction":"buy"}}`,
+		`{"time":"2022-05-20T10:00:01Z","figi":"BBG004730N88","trade":{"price":"100","lots":1,"direction":"sell"}}`,
+		``,
+		`{"time":"2022-05-20T10:01:00Z","figi":"BBG004730N88","candle":{"close":"100.5","volume":120}}`,
+	}, "\n"))
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	book := events[0].OrderBook
+	assert.Equal(t, time.Date(2022, 5, 20, 10, 0, 0, 0, time.UTC), events[0].Time)
+	assert.Equal(t, figi, book.FIGI)
+	assert.Equal(t, []string{"99.9x3", "99.8x5"}, prices(book.Bids))
+	assert.Equal(t, []string{"100x2"}, prices(book.Asks))
+	assert.Equal(t, "110", book.LimitUp.String())
+	assert.Equal(t, "90", book.LimitDown.String())
+
+	t.Run("trade of buy aggressor is the ask", func(t *testing.T) {
+		// No sells yet, the side has one lot.
+		assert.Equal(t, []string{"100.09x4"}, prices(events[1].OrderBook.Bids))
+		assert.Equal(t, []string{"100.1x1"}, prices(events[1].OrderBook.Asks))
+	})
+
+	t.Run("trade of sell aggressor is the bid", func(t *testing.T) {
+		// The buy of the window is kept.
+		assert.Equal(t, []string{"100x4"}, prices(events[2].OrderBook.Bids))
+		assert.Equal(t, []string{"100.01x1"}, prices(events[2].OrderBook.Asks))
+	})
+
+	t.Run("candle close is the bid", func(t *testing.T) {
+		// The range is unknown, the volume is split equally.
+		assert.Equal(t, []string{"100.5x60"}, prices(events[3].OrderBook.Bids))
+		assert.Equal(t, []string{"100.51x60"}, prices(events[3].OrderBook.Asks))
+		assert.True(t, events[3].OrderBook.LimitUp.IsZero())
+	})
+}
+
+func TestReader_Volumes(t *testing.T) {
+	events, err := readAll(t, strings.Join([]string{
+		`{"time":"2022-05-20T10:00:00Z","figi":"BBG004730N88","trade":{"price":"100","lots":4,"direction":"buy"}}`,
+		`{"time":"2022-05-20T10:00:30Z","figi":"BBG004730N88","trade":{"price":"100","lots":3,"direction":"sell"}}`,
+		`{"time":"2022-05-20T10:01:00Z","figi":"BBG004730N88","trade":{"price":"100","lots":2,"direction":"sell"}}`,
+		`{"time":"2022-05-20T10:02:00Z","figi":"BBG004730N88","candle":{"open":"100","high":"101","low":"99","close":"100.5","volume":100}}`, //nolint:lll
+	}, "\n"))
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	t.Run("trades are summed up during the window", func(t *testing.T) {
+		assert.Equal(t, 4, events[1].OrderBook.Bids[0].Lots)
+		assert.Equal(t, 3, events[1].OrderBook.Asks[0].Lots)
+	})
+
+	t.Run("trades out of the window are forgotten", func(t *testing.T) {
+		assert.Equal(t, 1, events[2].OrderBook.Bids[0].Lots)
+		assert.Equal(t, 5, events[2].OrderBook.Asks[0].Lots)
+	})
+
+	t.Run("candle volume is split by close", func(t *testing.T) {
+		assert.Equal(t, 75, events[3].OrderBook.Bids[0].Lots)
+		assert.Equal(t, 25, events[3].OrderBook.Asks[0].Lots)
+	})
+}
+
+func TestReader_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{
+			name: "unordered",
+			data: `{"time":"2022-05-20T10:00:01Z","figi":"BBG004730N88","trade":{"price":"100","lots":1}}
+{"time":"2022-05-20T10:00:00Z","figi":"BBG004730N88","trade":{"price":"100","lots":1}}`,
+			err: backtest.ErrUnorderedData,
+		},
+		{
+			name: "invalid json",
+			data: `{"time":`,
+			err:  backtest.ErrInvalidRecord,
+		},
+		{
+			name: "no figi",
+			data: `{"time":"2022-05-20T10:00:00Z","trade":{"price":"100","lots":1}}`,
+			err:  backtest.ErrInvalidRecord,
+		},
+		{
+			name: "several kinds",
+			data: `{"time":"2022-05-20T10:00:00Z","figi":"BBG004730N88","trade":{"price":"100","lots":1},"candle":{"close":"100"}}`, //nolint:lll
+			err:  backtest.ErrInvalidRecord,
+		},
+		{
+			name: "unknown instrument",
+			data: `{"time":"2022-05-20T10:00:00Z","figi":"BBG000000000","trade":{"price":"100","lots":1}}`,
+			err:  backtest.ErrInvalidRecord,
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readAll(t, tt.data)
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
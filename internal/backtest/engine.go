@@ -0,0 +1,273 @@
+// Package backtest replays the recorded market data through the strategies using a virtual clock
+// and the paper trader as the execution venue.
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	papertrader "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/paper-trader"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/registry"
+)
+
+var (
+	ErrNotReplayable = errors.New("strategy does not support backtest")
+	ErrNoData        = errors.New("no market data")
+)
+
+// Strategy is implemented by the registered strategies.
+type Strategy interface {
+	FIGIs() []tinkoffinvest.FIGI
+	Prepare(ctx context.Context) error
+	Apply(ctx context.Context, change tinkoffinvest.OrderBookChange) error
+	ApplyFill(ctx context.Context, fill tinkoffinvest.OrderFill) error
+}
+
+type Options struct {
+	Account tinkoffinvest.AccountID
+	// Balance is the initial money by currency, e.g. "rub".
+	Balance map[string]decimal.Decimal
+	// Commission is the part of the trade amount charged for every fill.
+	Commission decimal.Decimal
+	// AllowShort lets strategies sell the instruments not held.
+	AllowShort bool
+	// Tools are the replayed instruments.
+	Tools []toolscache.Tool
+}
+
+// Engine feeds the events to the paper trader and the strategies in order, moving the virtual clock.
+// Fills are applied by the strategy that placed the order before the next event.
+// It is not safe for concurrent use.
+type Engine struct {
+	opts   Options
+	clock  *clock
+	market *market
+	trader *papertrader.Trader
+	logger zerolog.Logger
+
+	strategies []*replayed
+	// owners are the strategies of the placed orders.
+	owners map[tinkoffinvest.OrderID]*replayed
+	// pending are the fills not applied by strategies yet.
+	pending []tinkoffinvest.OrderFill
+	report  *reportBuilder
+}
+
+type replayed struct {
+	name string
+	Strategy
+	figis map[tinkoffinvest.FIGI]struct{}
+}
+
+func New(opts Options) *Engine {
+	c := new(clock)
+	m := newMarket(c, opts.Tools)
+
+	e := &Engine{
+		opts:   opts,
+		clock:  c,
+		market: m,
+		logger: log.With().Str("service", "backtest").Logger(),
+		owners: make(map[tinkoffinvest.OrderID]*replayed),
+	}
+	e.trader = papertrader.New(papertrader.Options{
+		Balance:    opts.Balance,
+		Commission: opts.Commission,
+		AllowShort: opts.AllowShort,
+		Now:        c.Now,
+		OnFill:     func(f tinkoffinvest.OrderFill) { e.pending = append(e.pending, f) },
+	}, m, m, m)
+	e.report = newReportBuilder(opts.Commission, m.tools)
+
+	return e
+}
+
+// Add builds the strategy of the instance. Its orders are attributed to the instance name in the report.
+func (e *Engine) Add(instance registry.Instance) error {
+	r := &replayed{name: instance.Name}
+
+	s, err := instance.New(registry.Deps{
+		Orders:     &orderPlacer{Trader: e.trader, engine: e, owner: r},
+		MarketData: e.market,
+		ToolsCache: e.market,
+		Calendar:   e.market,
+		Clock:      e.clock,
+	})
+	if err != nil {
+		return fmt.Errorf("strategy %s: %w", instance.Name, err)
+	}
+
+	bs, ok := s.(Strategy)
+	if !ok {
+		return fmt.Errorf("strategy %s: %w", instance.Name, ErrNotReplayable)
+	}
+	r.Strategy = bs
+	e.strategies = append(e.strategies, r)
+
+	return nil
+}
+
+// Run replays the data till the end and returns the report.
+func (e *Engine) Run(ctx context.Context, data *Reader) (*Report, error) {
+	var events int
+	for {
+		ev, err := data.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		e.clock.now = ev.Time
+		if events == 0 {
+			// Strategies are prepared at the time of the first event, e.g. to check the futures expiration.
+			if err := e.prepare(ctx); err != nil {
+				return nil, err
+			}
+		}
+		events++
+
+		e.apply(ctx, ev)
+		e.report.addEquity(ev.Time, e.equity(ctx))
+	}
+
+	if events == 0 {
+		return nil, ErrNoData
+	}
+	return e.report.build(events), nil
+}
+
+func (e *Engine) prepare(ctx context.Context) error {
+	for _, s := range e.strategies {
+		if err := s.Prepare(ctx); err != nil {
+			return fmt.Errorf("prepare strategy %s: %w", s.name, err)
+		}
+
+		s.figis = make(map[tinkoffinvest.FIGI]struct{})
+		for _, f := range s.FIGIs() {
+			s.figis[f] = struct{}{}
+		}
+	}
+	e.report.initialEquity = e.equity(ctx)
+	return nil
+}
+
+func (e *Engine) apply(ctx context.Context, ev Event) {
+	book := ev.OrderBook
+	e.market.books[book.FIGI] = book
+
+	// Resting orders are filled by the new book before the strategies see it.
+	e.trader.ApplyOrderBook(book)
+	e.applyFills(ctx)
+
+	change := tinkoffinvest.OrderBookChange{
+		OrderBook:    book,
+		Depth:        len(book.Bids),
+		IsConsistent: true,
+		FormedAt:     ev.Time,
+	}
+	for _, s := range e.strategies {
+		if _, ok := s.figis[book.FIGI]; !ok {
+			continue
+		}
+
+		if err := s.Apply(ctx, change); err != nil {
+			e.logger.Warn().Str("strategy", s.name).Time("time", ev.Time).Err(err).Msg("cannot apply order book change")
+			e.report.errors++
+		}
+		e.applyFills(ctx)
+	}
+}
+
+// applyFills passes the fills to the strategies placed the orders, until they stop causing new fills.
+func (e *Engine) applyFills(ctx context.Context) {
+	for len(e.pending) > 0 {
+		fills := e.pending
+		e.pending = nil
+
+		for _, f := range fills {
+			owner, ok := e.owners[f.OrderID]
+			if !ok {
+				e.logger.Warn().Str("order_id", f.OrderID.S()).Msg("fill of unknown order")
+				continue
+			}
+			e.report.addFill(owner.name, f)
+
+			if err := owner.ApplyFill(ctx, f); err != nil {
+				e.logger.Warn().Str("strategy", owner.name).Time("time", f.Time).Err(err).Msg("cannot apply order fill")
+				e.report.errors++
+			}
+		}
+	}
+}
+
+// equity is the money including the blocked one and the positions valued at the mid prices.
+// Money in different currencies is summed up as is.
+func (e *Engine) equity(ctx context.Context) decimal.Decimal {
+	equity := decimal.Zero
+
+	positions, err := e.trader.GetPositions(ctx, e.opts.Account)
+	if err != nil {
+		e.logger.Warn().Err(err).Msg("get positions")
+		return equity
+	}
+	for cur, m := range positions.Money {
+		equity = equity.Add(m).Add(positions.BlockedMoney[cur])
+	}
+
+	portfolio, err := e.trader.GetPortfolio(ctx, e.opts.Account)
+	if err != nil {
+		e.logger.Warn().Err(err).Msg("get portfolio")
+		return equity
+	}
+	for _, p := range portfolio.Positions {
+		price := p.AvgPrice
+		if mid, ok := midPrice(e.market.books[p.FIGI]); ok {
+			price = mid
+		}
+		equity = equity.Add(e.market.tools[p.FIGI].Amount(price, p.Quantity))
+	}
+	return equity
+}
+
+// orderPlacer remembers the strategy of the placed orders.
+type orderPlacer struct {
+	*papertrader.Trader
+	engine *Engine
+	owner  *replayed
+}
+
+func (p *orderPlacer) PlaceMarketSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return p.own(p.Trader.PlaceMarketSellOrder(ctx, request))
+}
+
+func (p *orderPlacer) PlaceMarketBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return p.own(p.Trader.PlaceMarketBuyOrder(ctx, request))
+}
+
+func (p *orderPlacer) PlaceLimitSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return p.own(p.Trader.PlaceLimitSellOrder(ctx, request))
+}
+
+func (p *orderPlacer) PlaceLimitBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return p.own(p.Trader.PlaceLimitBuyOrder(ctx, request))
+}
+
+func (p *orderPlacer) own(id tinkoffinvest.OrderID, err error) (tinkoffinvest.OrderID, error) {
+	if err == nil {
+		p.engine.owners[id] = p.owner
+	}
+	return id, err
+}
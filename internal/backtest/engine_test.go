@@ -0,0 +1,339 @@
+package backtest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/backtest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+	bullsbearsmon "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/bulls-and-bears-mon"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/registry"
+)
+
+const (
+	accountID = tinkoffinvest.AccountID("backtest")
+
+	scriptedStrategy = "backtest-scripted"
+	liveOnlyStrategy = "backtest-live-only"
+)
+
+var errScripted = errors.New("scripted error")
+
+// scripted buys by market on the first book, places the counter limit sell at 102
+// and fails to apply the second book.
+type scripted struct {
+	orders registry.OrderPlacer
+	clock  interface{ Now() time.Time }
+
+	prepared   bool
+	books      int
+	appliedAt  []time.Time
+	fills      []tinkoffinvest.OrderFill
+	sellID     tinkoffinvest.OrderID
+	prepareErr error
+}
+
+func (s *scripted) Name() string                                                          { return scriptedStrategy }
+func (s *scripted) FIGIs() []tinkoffinvest.FIGI                                           { return []tinkoffinvest.FIGI{figi} }
+func (s *scripted) AdoptOrders(o []tinkoffinvest.ActiveOrder) []tinkoffinvest.ActiveOrder { return o }
+func (s *scripted) Run(_ context.Context) error                                           { return nil }
+
+func (s *scripted) Prepare(_ context.Context) error {
+	s.prepared = true
+	return s.prepareErr
+}
+
+func (s *scripted) Apply(ctx context.Context, _ tinkoffinvest.OrderBookChange) error {
+	s.books++
+	s.appliedAt = append(s.appliedAt, s.clock.Now())
+
+	switch s.books {
+	case 1:
+		_, err := s.orders.PlaceMarketBuyOrder(ctx, tinkoffinvest.PlaceOrderRequest{AccountID: accountID, FIGI: figi, Lots: 1})
+		return err
+	case 2:
+		return errScripted
+	}
+	return nil
+}
+
+func (s *scripted) ApplyFill(ctx context.Context, fill tinkoffinvest.OrderFill) error {
+	s.fills = append(s.fills, fill)
+	if fill.Direction != tinkoffinvest.TradeDirectionBuy {
+		return nil
+	}
+
+	id, err := s.orders.PlaceLimitSellOrder(ctx, tinkoffinvest.PlaceOrderRequest{
+		AccountID: accountID,
+		FIGI:      figi,
+		Lots:      1,
+		Price:     decimal.NewFromInt(102),
+	})
+	s.sellID = id
+	return err
+}
+
+type liveOnly struct{}
+
+func (liveOnly) Name() string                                                          { return liveOnlyStrategy }
+func (liveOnly) FIGIs() []tinkoffinvest.FIGI                                           { return nil }
+func (liveOnly) AdoptOrders(o []tinkoffinvest.ActiveOrder) []tinkoffinvest.ActiveOrder { return o }
+func (liveOnly) Run(_ context.Context) error                                           { return nil }
+
+var lastScripted *scripted
+
+func init() {
+	decode := func(func(v interface{}) error) (interface{}, error) { return nil, nil }
+
+	registry.Register(scriptedStrategy, registry.Definition{
+		DecodeConfig: decode,
+		New: func(_ registry.Instance, deps registry.Deps) (registry.Strategy, error) {
+			lastScripted = &scripted{orders: deps.Orders, clock: deps.Clock}
+			return lastScripted, nil
+		},
+	})
+	registry.Register(liveOnlyStrategy, registry.Definition{
+		DecodeConfig: decode,
+		New: func(registry.Instance, registry.Deps) (registry.Strategy, error) {
+			return liveOnly{}, nil
+		},
+	})
+}
+
+func newEngine(t *testing.T, strategy string) *backtest.Engine {
+	t.Helper()
+
+	e := backtest.New(backtest.Options{
+		Account:    accountID,
+		Balance:    map[string]decimal.Decimal{"rub": decimal.NewFromInt(10000)},
+		Commission: decimal.RequireFromString("0.001"),
+		Tools:      tools,
+	})
+
+	instances, err := registry.Decode(accountID, config.StrategiesConfig{{Strategy: strategy, Enabled: true}})
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	require.NoError(t, e.Add(instances[0]))
+
+	return e
+}
+
+func book(ts, bid, ask string) string {
+	return bookWithLots(ts, bid, 5, ask, 5)
+}
+
+func bookWithLots(ts, bid string, bidLots int, ask string, askLots int) string {
+	return `{"time":"` + ts + `","figi":"BBG004730N88","order_book":{` +
+		`"bids":[{"price":"` + bid + `","lots":` + strconv.Itoa(bidLots) + `}],` +
+		`"asks":[{"price":"` + ask + `","lots":` + strconv.Itoa(askLots) + `}]}}`
+}
+
+// newBullsAndBears returns the engine with bulls-and-bears trading one lot on the dominance of 2 for 1% of profit.
+func newBullsAndBears(t *testing.T, allowShort bool) *backtest.Engine {
+	t.Helper()
+
+	var cfg struct {
+		Strategies config.StrategiesConfig `toml:"strategies"`
+	}
+	_, err := toml.Decode(`
+[[strategies]]
+strategy = "`+bullsbearsmon.Name+`"
+[[strategies.instruments]]
+figi = "BBG004730N88"
+depth = 10
+dominance_ratio = 2.0
+profit_percentage = 0.01
+`, &cfg)
+	require.NoError(t, err)
+
+	e := backtest.New(backtest.Options{
+		Account:    accountID,
+		Balance:    map[string]decimal.Decimal{"rub": decimal.NewFromInt(10000)},
+		Commission: decimal.RequireFromString("0.001"),
+		AllowShort: allowShort,
+		Tools:      tools,
+	})
+
+	instances, err := registry.Decode(accountID, cfg.Strategies)
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	require.NoError(t, e.Add(instances[0]))
+
+	return e
+}
+
+func TestEngine_Run(t *testing.T) {
+	e := newEngine(t, scriptedStrategy)
+	s := lastScripted
+
+	data := strings.Join([]string{
+		book("2022-05-20T10:00:00Z", "99", "100"),
+		book("2022-05-20T10:00:05Z", "98", "99"),
+		book("2022-05-20T10:00:10Z", "102", "103"),
+	}, "\n")
+
+	report, err := e.Run(context.Background(), backtest.NewReader(strings.NewReader(data), tools))
+	require.NoError(t, err)
+
+	assert.True(t, s.prepared)
+	require.Len(t, s.appliedAt, 3)
+	assert.Equal(t, time.Date(2022, 5, 20, 10, 0, 5, 0, time.UTC), s.appliedAt[1])
+
+	// The resting sell is filled by the third book before the strategy sees it.
+	require.Len(t, s.fills, 2)
+	assert.Equal(t, s.sellID, s.fills[1].OrderID)
+	assert.Equal(t, time.Date(2022, 5, 20, 10, 0, 10, 0, time.UTC), s.fills[1].Time)
+
+	require.Len(t, report.Trades, 2)
+	buy, sell := report.Trades[0], report.Trades[1]
+	assert.Equal(t, scriptedStrategy, buy.Strategy)
+	assert.Equal(t, "buy", buy.Direction)
+	assert.Equal(t, 10, buy.Quantity)
+	assert.Equal(t, "1000", buy.Amount.String())
+	assert.Equal(t, "1", buy.Commission.String())
+	assert.True(t, buy.PnL.IsZero())
+	// 1020 - 1.02 of commission - 1001 of the cost with commission.
+	assert.Equal(t, "17.98", sell.PnL.String())
+
+	// 8999 of money and 10 shares at the mid 98.5 make the lowest equity.
+	require.Len(t, report.Equity, 3)
+	assert.Equal(t, "9984", report.Equity[1].Equity.String())
+
+	summary := report.Summary
+	assert.Equal(t, 3, summary.Events)
+	assert.Equal(t, "10000", summary.InitialEquity.String())
+	assert.Equal(t, "10017.98", summary.FinalEquity.String())
+	assert.Equal(t, "17.98", summary.PnL.String())
+	assert.Equal(t, "17.98", summary.RealizedPnL.String())
+	assert.Equal(t, "2.02", summary.Commission.String())
+	assert.Equal(t, "16", summary.MaxDrawdown.String())
+	assert.InDelta(t, 0.0016, summary.MaxDrawdownPercentage, 1e-9)
+	assert.Equal(t, 2, summary.Trades)
+	assert.Equal(t, 1, summary.ClosingTrades)
+	assert.InDelta(t, 1., summary.WinRate, 1e-9)
+	assert.Equal(t, "2020", summary.Turnover.String())
+	assert.Equal(t, 1, summary.Errors)
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, report.WriteTradesCSV(&buf))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 3)
+		assert.Equal(t, "time,strategy,order_id,figi,direction,price,lots,quantity,amount,commission,pnl", lines[0])
+		assert.Equal(t, "2022-05-20T10:00:10Z,backtest-scripted,"+sell.OrderID+",BBG004730N88,sell,102,1,10,1020,1.02,17.98", lines[2])
+
+		buf.Reset()
+		require.NoError(t, report.WriteEquityCSV(&buf))
+		assert.True(t, strings.HasPrefix(buf.String(), "time,equity\n2022-05-20T10:00:00Z,9994\n"), buf.String())
+	})
+}
+
+func TestEngine_BearSignal(t *testing.T) {
+	data := strings.Join([]string{
+		// Bears dominate, the short is opened by market at 99 and the counter buy is placed at 98.01.
+		bookWithLots("2022-05-20T10:00:00Z", "99", 1, "100", 5),
+		// The counter buy is filled, the book is balanced.
+		bookWithLots("2022-05-20T10:00:05Z", "97", 5, "98", 5),
+	}, "\n")
+
+	e := newBullsAndBears(t, true)
+	report, err := e.Run(context.Background(), backtest.NewReader(strings.NewReader(data), tools))
+	require.NoError(t, err)
+
+	require.Len(t, report.Trades, 2)
+	sell, buy := report.Trades[0], report.Trades[1]
+	assert.Equal(t, "sell", sell.Direction)
+	assert.Equal(t, "99", sell.Price.String())
+	assert.True(t, sell.PnL.IsZero())
+	assert.Equal(t, "buy", buy.Direction)
+	assert.Equal(t, "98.01", buy.Price.String())
+	// 990 - 0.99 of commission received by the sell minus 980.1 + 0.9801 paid by the buy.
+	assert.Equal(t, "7.9299", buy.PnL.String())
+
+	summary := report.Summary
+	assert.Equal(t, 1, summary.ClosingTrades)
+	assert.InDelta(t, 1., summary.WinRate, 1e-9)
+	assert.Equal(t, "10007.9299", summary.FinalEquity.String())
+
+	t.Run("short is increased", func(t *testing.T) {
+		data := strings.Join([]string{
+			bookWithLots("2022-05-20T10:00:00Z", "99", 1, "100", 5),
+			bookWithLots("2022-05-20T10:00:05Z", "99", 1, "100", 5),
+			// Both counter buys are filled.
+			bookWithLots("2022-05-20T10:00:10Z", "97", 5, "98", 5),
+		}, "\n")
+
+		e := newBullsAndBears(t, true)
+		report, err := e.Run(context.Background(), backtest.NewReader(strings.NewReader(data), tools))
+		require.NoError(t, err)
+
+		require.Len(t, report.Trades, 4)
+		assert.Equal(t, 2, report.Summary.ClosingTrades)
+		assert.Equal(t, "15.8598", report.Summary.RealizedPnL.String())
+		assert.Equal(t, "10015.8598", report.Summary.FinalEquity.String())
+	})
+
+	t.Run("short is not allowed", func(t *testing.T) {
+		e := newBullsAndBears(t, false)
+		report, err := e.Run(context.Background(), backtest.NewReader(strings.NewReader(data), tools))
+		require.NoError(t, err)
+		assert.Empty(t, report.Trades)
+	})
+}
+
+func TestEngine_Trades(t *testing.T) {
+	data := strings.Join([]string{
+		// Sell aggressors dominate, the short is opened by market at the bid 99.
+		`{"time":"2022-05-20T10:00:00Z","figi":"BBG004730N88","trade":{"price":"99","lots":3,"direction":"sell"}}`,
+		// The counter buy at 98.01 is crossed by the ask 98 of buy aggressor, 5 buys to 3 sells is not the signal.
+		`{"time":"2022-05-20T10:00:10Z","figi":"BBG004730N88","trade":{"price":"98","lots":5,"direction":"buy"}}`,
+	}, "\n")
+
+	e := newBullsAndBears(t, true)
+	report, err := e.Run(context.Background(), backtest.NewReader(strings.NewReader(data), tools))
+	require.NoError(t, err)
+
+	require.Len(t, report.Trades, 2)
+	assert.Equal(t, "sell", report.Trades[0].Direction)
+	assert.Equal(t, "99", report.Trades[0].Price.String())
+	assert.Equal(t, "buy", report.Trades[1].Direction)
+	assert.Equal(t, "98.01", report.Trades[1].Price.String())
+	assert.Equal(t, "7.9299", report.Summary.RealizedPnL.String())
+}
+
+func TestEngine_Errors(t *testing.T) {
+	t.Run("strategy does not support backtest", func(t *testing.T) {
+		e := backtest.New(backtest.Options{Account: accountID, Tools: tools})
+
+		instances, err := registry.Decode(accountID, config.StrategiesConfig{{Strategy: liveOnlyStrategy, Enabled: true}})
+		require.NoError(t, err)
+		require.ErrorIs(t, e.Add(instances[0]), backtest.ErrNotReplayable)
+	})
+
+	t.Run("no data", func(t *testing.T) {
+		e := newEngine(t, scriptedStrategy)
+
+		_, err := e.Run(context.Background(), backtest.NewReader(strings.NewReader(""), tools))
+		require.ErrorIs(t, err, backtest.ErrNoData)
+	})
+
+	t.Run("prepare failed", func(t *testing.T) {
+		e := newEngine(t, scriptedStrategy)
+		lastScripted.prepareErr = errScripted
+
+		data := backtest.NewReader(strings.NewReader(book("2022-05-20T10:00:00Z", "99", "100")), tools)
+		_, err := e.Run(context.Background(), data)
+		require.ErrorIs(t, err, errScripted)
+	})
+}
@@ -0,0 +1,305 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+)
+
+type Report struct {
+	Summary Summary       `json:"summary"`
+	Trades  []Trade       `json:"trades"`
+	Equity  []EquityPoint `json:"equity"`
+}
+
+type Summary struct {
+	Start         time.Time       `json:"start"`
+	End           time.Time       `json:"end"`
+	Events        int             `json:"events"`
+	InitialEquity decimal.Decimal `json:"initial_equity"`
+	FinalEquity   decimal.Decimal `json:"final_equity"`
+	// PnL is the equity change including the unrealized PnL of open positions.
+	PnL         decimal.Decimal `json:"pnl"`
+	RealizedPnL decimal.Decimal `json:"realized_pnl"`
+	Commission  decimal.Decimal `json:"commission"`
+	// MaxDrawdown is the largest equity fall from the previous peak.
+	MaxDrawdown decimal.Decimal `json:"max_drawdown"`
+	// MaxDrawdownPercentage is MaxDrawdown relative to its peak, e.g. 0.05.
+	MaxDrawdownPercentage float64 `json:"max_drawdown_percentage"`
+	Trades                int     `json:"trades"`
+	// ClosingTrades are the trades reducing the positions, the win rate is counted by them.
+	ClosingTrades int     `json:"closing_trades"`
+	WinRate       float64 `json:"win_rate"`
+	// Turnover is the total amount of trades.
+	Turnover decimal.Decimal `json:"turnover"`
+	// Errors are the failures of strategies to apply order book changes or fills.
+	Errors int `json:"errors"`
+}
+
+// Trade is the fill of the strategy order.
+type Trade struct {
+	Time      time.Time       `json:"time"`
+	Strategy  string          `json:"strategy"`
+	OrderID   string          `json:"order_id"`
+	FIGI      string          `json:"figi"`
+	Direction string          `json:"direction"`
+	Price     decimal.Decimal `json:"price"`
+	Lots      int             `json:"lots"`
+	// Quantity is the number of instruments.
+	Quantity   int             `json:"quantity"`
+	Amount     decimal.Decimal `json:"amount"`
+	Commission decimal.Decimal `json:"commission"`
+	// PnL is realized by the trade reducing the position against its average cost including commissions,
+	// zero for the trades opening the position.
+	PnL decimal.Decimal `json:"pnl"`
+}
+
+type EquityPoint struct {
+	Time   time.Time       `json:"time"`
+	Equity decimal.Decimal `json:"equity"`
+}
+
+// WriteJSON writes the whole report.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteTradesCSV writes the trades with the header.
+func (r *Report) WriteTradesCSV(w io.Writer) error {
+	rows := [][]string{{
+		"time", "strategy", "order_id", "figi", "direction", "price", "lots", "quantity", "amount", "commission", "pnl",
+	}}
+	for _, t := range r.Trades {
+		rows = append(rows, []string{
+			formatTime(t.Time),
+			t.Strategy,
+			t.OrderID,
+			t.FIGI,
+			t.Direction,
+			t.Price.String(),
+			strconv.Itoa(t.Lots),
+			strconv.Itoa(t.Quantity),
+			t.Amount.String(),
+			t.Commission.String(),
+			t.PnL.String(),
+		})
+	}
+	return writeCSV(w, rows)
+}
+
+// WriteEquityCSV writes the equity curve with the header.
+func (r *Report) WriteEquityCSV(w io.Writer) error {
+	rows := [][]string{{"time", "equity"}}
+	for _, p := range r.Equity {
+		rows = append(rows, []string{formatTime(p.Time), p.Equity.String()})
+	}
+	return writeCSV(w, rows)
+}
+
+// WriteSummaryCSV writes the summary as metric-value pairs.
+func (r *Report) WriteSummaryCSV(w io.Writer) error {
+	s := r.Summary
+	return writeCSV(w, [][]string{
+		{"metric", "value"},
+		{"start", formatTime(s.Start)},
+		{"end", formatTime(s.End)},
+		{"events", strconv.Itoa(s.Events)},
+		{"initial_equity", s.InitialEquity.String()},
+		{"final_equity", s.FinalEquity.String()},
+		{"pnl", s.PnL.String()},
+		{"realized_pnl", s.RealizedPnL.String()},
+		{"commission", s.Commission.String()},
+		{"max_drawdown", s.MaxDrawdown.String()},
+		{"max_drawdown_percentage", formatFloat(s.MaxDrawdownPercentage)},
+		{"trades", strconv.Itoa(s.Trades)},
+		{"closing_trades", strconv.Itoa(s.ClosingTrades)},
+		{"win_rate", formatFloat(s.WinRate)},
+		{"turnover", s.Turnover.String()},
+		{"errors", strconv.Itoa(s.Errors)},
+	})
+}
+
+func writeCSV(w io.Writer, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("write csv: %w", err)
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// costPrecision is the number of decimal places of the sold part of the position cost.
+const costPrecision = 8
+
+// reportBuilder collects the trades and the equity curve during the replay.
+type reportBuilder struct {
+	commission decimal.Decimal
+	tools      map[tinkoffinvest.FIGI]toolscache.Tool
+	// positions are tracked by the fills to realize PnL against their average cost.
+	positions map[tinkoffinvest.FIGI]*costBasis
+
+	initialEquity decimal.Decimal
+	trades        []Trade
+	equity        []EquityPoint
+	errors        int
+	closingTrades int
+	wins          int
+}
+
+type costBasis struct {
+	// quantity is negative for the short position.
+	quantity int
+	// cost is paid for the long position or received for the short one, net of commissions.
+	cost decimal.Decimal
+}
+
+func newReportBuilder(commission decimal.Decimal, tools map[tinkoffinvest.FIGI]toolscache.Tool) *reportBuilder {
+	return &reportBuilder{
+		commission: commission,
+		tools:      tools,
+		positions:  make(map[tinkoffinvest.FIGI]*costBasis),
+	}
+}
+
+func (b *reportBuilder) addFill(strategy string, f tinkoffinvest.OrderFill) {
+	tool := b.tools[f.FIGI]
+	qty := f.Lots * tool.StocksPerLot
+	amount := tool.Amount(f.Price, qty)
+	commission := amount.Mul(b.commission)
+
+	p, ok := b.positions[f.FIGI]
+	if !ok {
+		p = &costBasis{cost: decimal.Zero}
+		b.positions[f.FIGI] = p
+	}
+
+	sign := 1
+	if f.Direction == tinkoffinvest.TradeDirectionSell {
+		sign = -1
+	}
+
+	// The trade closes the opposite position first, the rest of it opens the new one.
+	closing := -sign * p.quantity
+	switch {
+	case closing < 0:
+		closing = 0
+	case closing > qty:
+		closing = qty
+	}
+
+	pnl, openingAmount, openingCommission := decimal.Zero, amount, commission
+	if closing > 0 {
+		part := func(d decimal.Decimal, of int) decimal.Decimal {
+			return d.Mul(decimal.NewFromInt(int64(closing))).DivRound(decimal.NewFromInt(int64(of)), costPrecision)
+		}
+		cost := part(p.cost, -sign*p.quantity)
+		closedAmount, closedCommission := part(amount, qty), part(commission, qty)
+		openingAmount, openingCommission = amount.Sub(closedAmount), commission.Sub(closedCommission)
+
+		if sign < 0 {
+			pnl = closedAmount.Sub(closedCommission).Sub(cost)
+		} else {
+			pnl = cost.Sub(closedAmount).Sub(closedCommission)
+		}
+		p.cost = p.cost.Sub(cost)
+		p.quantity += sign * closing
+
+		b.closingTrades++
+		if pnl.IsPositive() {
+			b.wins++
+		}
+	}
+
+	if opening := qty - closing; opening > 0 {
+		if sign > 0 {
+			p.cost = p.cost.Add(openingAmount).Add(openingCommission)
+		} else {
+			p.cost = p.cost.Add(openingAmount).Sub(openingCommission)
+		}
+		p.quantity += sign * opening
+	}
+
+	b.trades = append(b.trades, Trade{
+		Time:       f.Time,
+		Strategy:   strategy,
+		OrderID:    f.OrderID.S(),
+		FIGI:       f.FIGI.S(),
+		Direction:  f.Direction.String(),
+		Price:      f.Price,
+		Lots:       f.Lots,
+		Quantity:   qty,
+		Amount:     amount,
+		Commission: commission,
+		PnL:        pnl,
+	})
+}
+
+func (b *reportBuilder) addEquity(t time.Time, equity decimal.Decimal) {
+	b.equity = append(b.equity, EquityPoint{Time: t, Equity: equity})
+}
+
+func (b *reportBuilder) build(events int) *Report {
+	s := Summary{
+		Events:        events,
+		InitialEquity: b.initialEquity,
+		FinalEquity:   b.initialEquity,
+		RealizedPnL:   decimal.Zero,
+		Commission:    decimal.Zero,
+		MaxDrawdown:   decimal.Zero,
+		Turnover:      decimal.Zero,
+		Trades:        len(b.trades),
+		Errors:        b.errors,
+	}
+
+	if len(b.equity) > 0 {
+		s.Start = b.equity[0].Time
+		s.End = b.equity[len(b.equity)-1].Time
+		s.FinalEquity = b.equity[len(b.equity)-1].Equity
+	}
+	s.PnL = s.FinalEquity.Sub(s.InitialEquity)
+
+	peak := b.initialEquity
+	for _, p := range b.equity {
+		if p.Equity.GreaterThan(peak) {
+			peak = p.Equity
+		}
+		if dd := peak.Sub(p.Equity); dd.GreaterThan(s.MaxDrawdown) {
+			s.MaxDrawdown = dd
+			if peak.IsPositive() {
+				s.MaxDrawdownPercentage = dd.Div(peak).InexactFloat64()
+			}
+		}
+	}
+
+	for _, t := range b.trades {
+		s.Commission = s.Commission.Add(t.Commission)
+		s.Turnover = s.Turnover.Add(t.Amount)
+		s.RealizedPnL = s.RealizedPnL.Add(t.PnL)
+	}
+	if s.ClosingTrades = b.closingTrades; s.ClosingTrades > 0 {
+		s.WinRate = float64(b.wins) / float64(s.ClosingTrades)
+	}
+
+	trades := b.trades
+	if trades == nil {
+		trades = []Trade{}
+	}
+	return &Report{Summary: s, Trades: trades, Equity: b.equity}
+}
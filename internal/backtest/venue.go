@@ -0,0 +1,122 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	exchangecalendar "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/exchange-calendar"
+	marketdatahub "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/market-data-hub"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+)
+
+var errNotReplayed = errors.New("not available in backtest")
+
+// clock is the virtual time moved by the replayed events.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+// market answers the requests of strategies and the paper trader by the replayed data instead of the exchange.
+// Instruments are always in normal trading, because the trading statuses are not recorded.
+type market struct {
+	clock *clock
+	tools map[tinkoffinvest.FIGI]toolscache.Tool
+	books map[tinkoffinvest.FIGI]tinkoffinvest.OrderBook
+}
+
+func newMarket(c *clock, tools []toolscache.Tool) *market {
+	m := &market{
+		clock: c,
+		tools: make(map[tinkoffinvest.FIGI]toolscache.Tool, len(tools)),
+		books: make(map[tinkoffinvest.FIGI]tinkoffinvest.OrderBook),
+	}
+	for _, t := range tools {
+		m.tools[t.FIGI] = t
+	}
+	return m
+}
+
+// Get implements the tools cache by the configured instruments.
+func (m *market) Get(_ context.Context, figi tinkoffinvest.FIGI) (toolscache.Tool, error) {
+	t, ok := m.tools[figi]
+	if !ok {
+		return toolscache.Tool{}, fmt.Errorf("instrument %s is not configured: %w", figi, tinkoffinvest.ErrNotFound)
+	}
+	return t, nil
+}
+
+func (m *market) GetTradeAvailableInstruments(
+	_ context.Context,
+	_ tinkoffinvest.InstrumentType,
+) ([]tinkoffinvest.Instrument, error) {
+	return nil, fmt.Errorf("instruments choice: %w, configure the instruments", errNotReplayed)
+}
+
+func (m *market) GetOrderBook(_ context.Context, req tinkoffinvest.OrderBookRequest) (*tinkoffinvest.OrderBookResponse, error) { //nolint:lll
+	book, ok := m.books[req.FIGI]
+	if !ok {
+		return nil, fmt.Errorf("order book %s: %w", req.FIGI, tinkoffinvest.ErrNotFound)
+	}
+	return &tinkoffinvest.OrderBookResponse{OrderBook: book}, nil
+}
+
+func (m *market) GetTradingStatus(_ context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.TradingStatus, error) {
+	return &tinkoffinvest.TradingStatus{
+		FIGI:                 figi,
+		Code:                 tinkoffinvest.TradingStatusNormalTrading,
+		LimitOrderAvailable:  true,
+		MarketOrderAvailable: true,
+	}, nil
+}
+
+// GetLastPrices returns the mid prices of the last replayed books.
+func (m *market) GetLastPrices(_ context.Context, figis []tinkoffinvest.FIGI) ([]tinkoffinvest.LastPrice, error) {
+	prices := make([]tinkoffinvest.LastPrice, 0, len(figis))
+	for _, f := range figis {
+		if p, ok := midPrice(m.books[f]); ok {
+			prices = append(prices, tinkoffinvest.LastPrice{FIGI: f, Price: p, Time: m.clock.Now()})
+		}
+	}
+	return prices, nil
+}
+
+// SubscribeForOrderBookChanges is not used, the engine feeds the books itself.
+func (m *market) SubscribeForOrderBookChanges(
+	_ context.Context,
+	_ []tinkoffinvest.OrderBookRequest,
+) (<-chan tinkoffinvest.OrderBookChange, error) {
+	return nil, fmt.Errorf("order book stream: %w", errNotReplayed)
+}
+
+func (m *market) SubscribeForTradingStatuses(
+	_ context.Context,
+	_ []tinkoffinvest.FIGI,
+) (<-chan tinkoffinvest.TradingStatus, error) {
+	return nil, fmt.Errorf("trading statuses stream: %w", errNotReplayed)
+}
+
+func (m *market) SubscribeForGaps(_ context.Context) (<-chan marketdatahub.Gap, error) {
+	return nil, fmt.Errorf("gaps stream: %w", errNotReplayed)
+}
+
+// CurrentOrNextSession is never called for the configured instruments, because their exchange is unknown
+// and they are considered as traded all the time.
+func (m *market) CurrentOrNextSession(_ context.Context, exchange string, _ time.Time) (exchangecalendar.Session, error) {
+	return exchangecalendar.Session{}, fmt.Errorf("exchange %q calendar: %w", exchange, errNotReplayed)
+}
+
+func midPrice(book tinkoffinvest.OrderBook) (decimal.Decimal, bool) {
+	if len(book.Bids) == 0 || len(book.Asks) == 0 {
+		return decimal.Zero, false
+	}
+	return book.Bids[0].Price.Add(book.Asks[0].Price).Div(decimal.NewFromInt(2)), true
+}
@@ -1,7 +1,10 @@
 package tinkoffinvest
 
 import (
+	"time"
+
 	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
 )
@@ -15,6 +18,13 @@ func adaptPbQuotationToDecimal(q *investpb.Quotation) decimal.Decimal {
 	return newDecimal(q.Units, q.Nano)
 }
 
+func adaptPbMoneyValueToDecimal(m *investpb.MoneyValue) decimal.Decimal {
+	if m == nil {
+		return decimal.Zero
+	}
+	return newDecimal(m.Units, m.Nano)
+}
+
 func newDecimal(units int64, nano int32) decimal.Decimal {
 	if units == 0 && nano == 0 {
 		return decimal.Zero
@@ -37,6 +47,15 @@ func adaptDecimalToPbQuotation(d decimal.Decimal) *investpb.Quotation {
 	}
 }
 
+func adaptDecimalToPbMoneyValue(d decimal.Decimal, cur currency) *investpb.MoneyValue {
+	q := adaptDecimalToPbQuotation(d)
+	return &investpb.MoneyValue{
+		Currency: string(cur),
+		Units:    q.Units,
+		Nano:     q.Nano,
+	}
+}
+
 func adaptPbOrderbook(ob *investpb.OrderBook) OrderBookChange {
 	return OrderBookChange{
 		OrderBook: OrderBook{
@@ -46,6 +65,7 @@ func adaptPbOrderbook(ob *investpb.OrderBook) OrderBookChange {
 			LimitUp:   adaptPbQuotationToDecimal(ob.LimitUp),
 			LimitDown: adaptPbQuotationToDecimal(ob.LimitDown),
 		},
+		Depth:        int(ob.Depth),
 		IsConsistent: ob.IsConsistent,
 		FormedAt:     ob.Time.AsTime(),
 	}
@@ -66,12 +86,509 @@ func adaptPbOrder(o *investpb.Order) Order {
 	}
 }
 
+func adaptPbTradingSchedule(s *investpb.TradingSchedule) TradingSchedule {
+	days := make([]TradingDay, len(s.Days))
+	for i, d := range s.Days {
+		days[i] = TradingDay{
+			Date:             adaptPbTimestamp(d.Date),
+			IsTradingDay:     d.IsTradingDay,
+			StartTime:        adaptPbTimestamp(d.StartTime),
+			EndTime:          adaptPbTimestamp(d.EndTime),
+			EveningStartTime: adaptPbTimestamp(d.EveningStartTime),
+			EveningEndTime:   adaptPbTimestamp(d.EveningEndTime),
+		}
+	}
+	return TradingSchedule{Exchange: s.Exchange, Days: days}
+}
+
+// adaptPbTimestamp returns zero time for nil or zero timestamp.
+func adaptPbTimestamp(t *timestamppb.Timestamp) time.Time {
+	if t == nil || (t.Seconds == 0 && t.Nanos == 0) {
+		return time.Time{}
+	}
+	return t.AsTime()
+}
+
+func adaptPbInstrument(i *investpb.Instrument) Instrument {
+	return Instrument{
+		FIGI:              FIGI(i.Figi),
+		Type:              InstrumentType(i.InstrumentType),
+		ISIN:              i.Isin,
+		Name:              i.Name,
+		Exchange:          i.Exchange,
+		Currency:          i.Currency,
+		Lot:               int(i.Lot),
+		MinPriceIncrement: adaptPbQuotationToDecimal(i.MinPriceIncrement),
+	}
+}
+
 func adaptPbShareToInstrument(share *investpb.Share) Instrument {
 	return Instrument{
 		FIGI:              FIGI(share.Figi),
+		Type:              InstrumentTypeShare,
 		ISIN:              share.Isin,
 		Name:              share.Name,
+		Exchange:          share.Exchange,
+		Currency:          share.Currency,
 		Lot:               int(share.Lot),
 		MinPriceIncrement: adaptPbQuotationToDecimal(share.MinPriceIncrement),
 	}
 }
+
+func adaptPbBondToInstrument(bond *investpb.Bond) Instrument {
+	return Instrument{
+		FIGI:              FIGI(bond.Figi),
+		Type:              InstrumentTypeBond,
+		ISIN:              bond.Isin,
+		Name:              bond.Name,
+		Exchange:          bond.Exchange,
+		Currency:          bond.Currency,
+		Lot:               int(bond.Lot),
+		MinPriceIncrement: adaptPbQuotationToDecimal(bond.MinPriceIncrement),
+		Nominal:           adaptPbMoneyValueToDecimal(bond.Nominal),
+	}
+}
+
+func adaptPbEtfToInstrument(etf *investpb.Etf) Instrument {
+	return Instrument{
+		FIGI:              FIGI(etf.Figi),
+		Type:              InstrumentTypeETF,
+		ISIN:              etf.Isin,
+		Name:              etf.Name,
+		Exchange:          etf.Exchange,
+		Currency:          etf.Currency,
+		Lot:               int(etf.Lot),
+		MinPriceIncrement: adaptPbQuotationToDecimal(etf.MinPriceIncrement),
+	}
+}
+
+func adaptPbFutureToInstrument(future *investpb.Future) Instrument {
+	var expiration time.Time
+	if future.ExpirationDate != nil {
+		expiration = future.ExpirationDate.AsTime()
+	}
+
+	return Instrument{
+		FIGI:              FIGI(future.Figi),
+		Type:              InstrumentTypeFutures,
+		Name:              future.Name,
+		Exchange:          future.Exchange,
+		Currency:          future.Currency,
+		Lot:               int(future.Lot),
+		MinPriceIncrement: adaptPbQuotationToDecimal(future.MinPriceIncrement),
+		ExpirationDate:    expiration,
+	}
+}
+
+func adaptPbCurrencyToInstrument(cur *investpb.Currency) Instrument {
+	return Instrument{
+		FIGI:              FIGI(cur.Figi),
+		Type:              InstrumentTypeCurrency,
+		ISIN:              cur.Isin,
+		Name:              cur.Name,
+		Exchange:          cur.Exchange,
+		Currency:          cur.Currency,
+		Lot:               int(cur.Lot),
+		MinPriceIncrement: adaptPbQuotationToDecimal(cur.MinPriceIncrement),
+		Nominal:           adaptPbMoneyValueToDecimal(cur.Nominal),
+		ISOCurrencyName:   cur.IsoCurrencyName,
+	}
+}
+
+// adaptPbFuturesMarginToPointValue returns the cost of one price point.
+func adaptPbFuturesMarginToPointValue(m *investpb.GetFuturesMarginResponse) decimal.Decimal {
+	inc := adaptPbQuotationToDecimal(m.MinPriceIncrement)
+	if inc.IsZero() {
+		return decimal.Zero
+	}
+	return adaptPbQuotationToDecimal(m.MinPriceIncrementAmount).Div(inc)
+}
+
+func adaptPbHistoricCandle(figi FIGI, interval CandleInterval, c *investpb.HistoricCandle) Candle {
+	return Candle{
+		FIGI:       figi,
+		Interval:   interval,
+		Open:       adaptPbQuotationToDecimal(c.Open),
+		High:       adaptPbQuotationToDecimal(c.High),
+		Low:        adaptPbQuotationToDecimal(c.Low),
+		Close:      adaptPbQuotationToDecimal(c.Close),
+		Volume:     int(c.Volume), // Possible overflow.
+		Time:       c.Time.AsTime(),
+		IsComplete: c.IsComplete,
+	}
+}
+
+func adaptPbCandle(c *investpb.Candle) Candle {
+	var interval CandleInterval
+	switch c.Interval {
+	case investpb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE:
+		interval = CandleInterval1Min
+	case investpb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_FIVE_MINUTES:
+		interval = CandleInterval5Min
+	case investpb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_UNSPECIFIED:
+	}
+
+	return Candle{
+		FIGI:     FIGI(c.Figi),
+		Interval: interval,
+		Open:     adaptPbQuotationToDecimal(c.Open),
+		High:     adaptPbQuotationToDecimal(c.High),
+		Low:      adaptPbQuotationToDecimal(c.Low),
+		Close:    adaptPbQuotationToDecimal(c.Close),
+		Volume:   int(c.Volume), // Possible overflow.
+		Time:     c.Time.AsTime(),
+	}
+}
+
+func adaptPbOrderTrades(t *investpb.OrderTrades) []OrderFill {
+	fills := make([]OrderFill, len(t.Trades))
+	for i, tr := range t.Trades {
+		fills[i] = OrderFill{
+			AccountID: AccountID(t.AccountId),
+			OrderID:   OrderID(t.OrderId),
+			FIGI:      FIGI(t.Figi),
+			Direction: adaptPbOrderDirection(t.Direction),
+			Price:     adaptPbQuotationToDecimal(tr.Price),
+			Lots:      int(tr.Quantity), // Possible overflow.
+			Time:      tr.DateTime.AsTime(),
+		}
+	}
+	return fills
+}
+
+// adaptPbOrderStateToFills returns fills beyond the already reported lots.
+// Order stages have no time, so the fills are marked with the observation time.
+func adaptPbOrderStateToFills(account AccountID, s *investpb.OrderState, reportedLots int, now time.Time) []OrderFill {
+	newFill := func(price decimal.Decimal, lots int) OrderFill {
+		return OrderFill{
+			AccountID: account,
+			OrderID:   OrderID(s.OrderId),
+			FIGI:      FIGI(s.Figi),
+			Direction: adaptPbOrderDirection(s.Direction),
+			Price:     price,
+			Lots:      lots,
+			Time:      now,
+		}
+	}
+
+	executed := int(s.LotsExecuted) // Possible overflow.
+	if executed <= reportedLots {
+		return nil
+	}
+
+	if len(s.Stages) == 0 {
+		return []OrderFill{newFill(adaptPbMoneyValueToDecimal(s.AveragePositionPrice), executed-reportedLots)}
+	}
+
+	var fills []OrderFill
+	skip := reportedLots
+	for _, st := range s.Stages {
+		lots := int(st.Quantity) // Possible overflow.
+		if lots <= skip {
+			skip -= lots
+			continue
+		}
+
+		lots, skip = lots-skip, 0
+		fills = append(fills, newFill(adaptPbMoneyValueToDecimal(st.Price), lots))
+	}
+	return fills
+}
+
+func adaptPbActiveOrder(o *investpb.OrderState) ActiveOrder {
+	return ActiveOrder{
+		ID:            OrderID(o.OrderId),
+		FIGI:          FIGI(o.Figi),
+		Type:          adaptPbOrderType(o.OrderType),
+		Direction:     adaptPbOrderDirection(o.Direction),
+		LotsRequested: int(o.LotsRequested), // Possible overflow.
+		LotsExecuted:  int(o.LotsExecuted),  // Possible overflow.
+		Price:         adaptPbMoneyValueToDecimal(o.InitialSecurityPrice),
+		CreatedAt:     adaptPbTimestamp(o.OrderDate),
+	}
+}
+
+func adaptPbOrderState(o *investpb.OrderState) OrderState {
+	stages := make([]OrderStage, len(o.Stages))
+	for i, st := range o.Stages {
+		stages[i] = OrderStage{
+			TradeID: st.TradeId,
+			Price:   adaptPbMoneyValueToDecimal(st.Price),
+			Lots:    int(st.Quantity), // Possible overflow.
+		}
+	}
+
+	return OrderState{
+		ID:                 OrderID(o.OrderId),
+		FIGI:               FIGI(o.Figi),
+		Type:               adaptPbOrderType(o.OrderType),
+		Direction:          adaptPbOrderDirection(o.Direction),
+		Status:             adaptPbOrderStatus(o.ExecutionReportStatus),
+		LotsRequested:      int(o.LotsRequested), // Possible overflow.
+		LotsExecuted:       int(o.LotsExecuted),  // Possible overflow.
+		InitialPrice:       adaptPbMoneyValueToDecimal(o.InitialSecurityPrice),
+		AveragePrice:       adaptPbMoneyValueToDecimal(o.AveragePositionPrice),
+		ExecutedAmount:     adaptPbMoneyValueToDecimal(o.ExecutedOrderPrice),
+		ExecutedCommission: adaptPbMoneyValueToDecimal(o.ExecutedCommission),
+		Currency:           o.Currency,
+		Stages:             stages,
+		CreatedAt:          adaptPbTimestamp(o.OrderDate),
+	}
+}
+
+func adaptPbOrderType(t investpb.OrderType) OrderType {
+	switch t {
+	case investpb.OrderType_ORDER_TYPE_LIMIT:
+		return OrderTypeLimit
+	case investpb.OrderType_ORDER_TYPE_MARKET:
+		return OrderTypeMarket
+	case investpb.OrderType_ORDER_TYPE_UNSPECIFIED:
+	}
+	return OrderTypeUnspecified
+}
+
+func adaptPbOrderStatus(s investpb.OrderExecutionReportStatus) OrderStatus {
+	switch s {
+	case investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW:
+		return OrderStatusNew
+	case investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL:
+		return OrderStatusPartiallyFilled
+	case investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL:
+		return OrderStatusFilled
+	case investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED:
+		return OrderStatusRejected
+	case investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED:
+		return OrderStatusCancelled
+	case investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_UNSPECIFIED:
+	}
+	return OrderStatusUnspecified
+}
+
+func adaptPbOrderDirection(d investpb.OrderDirection) TradeDirection {
+	switch d {
+	case investpb.OrderDirection_ORDER_DIRECTION_BUY:
+		return TradeDirectionBuy
+	case investpb.OrderDirection_ORDER_DIRECTION_SELL:
+		return TradeDirectionSell
+	case investpb.OrderDirection_ORDER_DIRECTION_UNSPECIFIED:
+	}
+	return TradeDirectionUnspecified
+}
+
+func adaptPbTariff(t *investpb.GetUserTariffResponse) *Tariff {
+	tariff := &Tariff{
+		UnaryLimits:  make([]UnaryLimit, len(t.UnaryLimits)),
+		StreamLimits: make([]StreamLimit, len(t.StreamLimits)),
+	}
+	for i, l := range t.UnaryLimits {
+		tariff.UnaryLimits[i] = UnaryLimit{LimitPerMinute: int(l.LimitPerMinute), Methods: l.Methods}
+	}
+	for i, l := range t.StreamLimits {
+		tariff.StreamLimits[i] = StreamLimit{Limit: int(l.Limit), Streams: l.Streams}
+	}
+	return tariff
+}
+
+func adaptPbAccount(a *investpb.Account) Account {
+	return Account{
+		ID:          AccountID(a.Id),
+		Name:        a.Name,
+		Status:      adaptPbAccountStatus(a.Status),
+		AccessLevel: adaptPbAccessLevel(a.AccessLevel),
+		OpenedAt:    adaptPbTimestamp(a.OpenedDate),
+		ClosedAt:    adaptPbTimestamp(a.ClosedDate),
+	}
+}
+
+func adaptPbAccountStatus(s investpb.AccountStatus) AccountStatus {
+	switch s {
+	case investpb.AccountStatus_ACCOUNT_STATUS_NEW:
+		return AccountStatusNew
+	case investpb.AccountStatus_ACCOUNT_STATUS_OPEN:
+		return AccountStatusOpen
+	case investpb.AccountStatus_ACCOUNT_STATUS_CLOSED:
+		return AccountStatusClosed
+	case investpb.AccountStatus_ACCOUNT_STATUS_UNSPECIFIED:
+	}
+	return AccountStatusUnknown
+}
+
+func adaptPbAccessLevel(l investpb.AccessLevel) AccessLevel {
+	switch l {
+	case investpb.AccessLevel_ACCOUNT_ACCESS_LEVEL_FULL_ACCESS:
+		return AccessLevelFull
+	case investpb.AccessLevel_ACCOUNT_ACCESS_LEVEL_READ_ONLY:
+		return AccessLevelReadOnly
+	case investpb.AccessLevel_ACCOUNT_ACCESS_LEVEL_NO_ACCESS:
+		return AccessLevelNone
+	case investpb.AccessLevel_ACCOUNT_ACCESS_LEVEL_UNSPECIFIED:
+	}
+	return AccessLevelUnknown
+}
+
+func adaptPbPositions(p *investpb.PositionsResponse) *Positions {
+	positions := &Positions{
+		Money:        make(map[string]decimal.Decimal, len(p.Money)),
+		BlockedMoney: make(map[string]decimal.Decimal, len(p.Blocked)),
+		Securities:   make([]SecurityPosition, len(p.Securities)),
+	}
+	for _, m := range p.Money {
+		positions.Money[m.Currency] = positions.Money[m.Currency].Add(adaptPbMoneyValueToDecimal(m))
+	}
+	for _, m := range p.Blocked {
+		positions.BlockedMoney[m.Currency] = positions.BlockedMoney[m.Currency].Add(adaptPbMoneyValueToDecimal(m))
+	}
+	for i, s := range p.Securities {
+		positions.Securities[i] = SecurityPosition{
+			FIGI:    FIGI(s.Figi),
+			Balance: int(s.Balance), // Possible overflow.
+			Blocked: int(s.Blocked), // Possible overflow.
+		}
+	}
+	return positions
+}
+
+func adaptPbOperation(o *investpb.Operation) Operation {
+	kind, direction := adaptPbOperationType(o.OperationType)
+
+	var t time.Time
+	if o.Date != nil {
+		t = o.Date.AsTime()
+	}
+
+	return Operation{
+		ID:          o.Id,
+		ParentID:    o.ParentOperationId,
+		Kind:        kind,
+		Description: o.Type,
+		FIGI:        FIGI(o.Figi),
+		Direction:   direction,
+		Payment:     adaptPbMoneyValueToDecimal(o.Payment),
+		Currency:    o.Currency,
+		Price:       adaptPbMoneyValueToDecimal(o.Price),
+		Quantity:    int(o.Quantity), // Possible overflow.
+		Time:        t,
+	}
+}
+
+func adaptPbOperationType(t investpb.OperationType) (OperationKind, TradeDirection) {
+	switch t {
+	case investpb.OperationType_OPERATION_TYPE_BUY,
+		investpb.OperationType_OPERATION_TYPE_BUY_CARD,
+		investpb.OperationType_OPERATION_TYPE_BUY_MARGIN,
+		investpb.OperationType_OPERATION_TYPE_DELIVERY_BUY:
+		return OperationKindTrade, TradeDirectionBuy
+
+	case investpb.OperationType_OPERATION_TYPE_SELL,
+		investpb.OperationType_OPERATION_TYPE_SELL_CARD,
+		investpb.OperationType_OPERATION_TYPE_SELL_MARGIN,
+		investpb.OperationType_OPERATION_TYPE_DELIVERY_SELL:
+		return OperationKindTrade, TradeDirectionSell
+
+	case investpb.OperationType_OPERATION_TYPE_BROKER_FEE,
+		investpb.OperationType_OPERATION_TYPE_SERVICE_FEE,
+		investpb.OperationType_OPERATION_TYPE_MARGIN_FEE,
+		investpb.OperationType_OPERATION_TYPE_SUCCESS_FEE,
+		investpb.OperationType_OPERATION_TYPE_TRACK_MFEE,
+		investpb.OperationType_OPERATION_TYPE_TRACK_PFEE:
+		return OperationKindCommission, TradeDirectionUnspecified
+
+	case investpb.OperationType_OPERATION_TYPE_DIVIDEND,
+		investpb.OperationType_OPERATION_TYPE_DIV_EXT,
+		investpb.OperationType_OPERATION_TYPE_DIVIDEND_TRANSFER,
+		investpb.OperationType_OPERATION_TYPE_COUPON:
+		return OperationKindDividend, TradeDirectionUnspecified
+
+	case investpb.OperationType_OPERATION_TYPE_TAX,
+		investpb.OperationType_OPERATION_TYPE_BOND_TAX,
+		investpb.OperationType_OPERATION_TYPE_DIVIDEND_TAX,
+		investpb.OperationType_OPERATION_TYPE_BENEFIT_TAX,
+		investpb.OperationType_OPERATION_TYPE_TAX_CORRECTION,
+		investpb.OperationType_OPERATION_TYPE_TAX_PROGRESSIVE,
+		investpb.OperationType_OPERATION_TYPE_BOND_TAX_PROGRESSIVE,
+		investpb.OperationType_OPERATION_TYPE_DIVIDEND_TAX_PROGRESSIVE,
+		investpb.OperationType_OPERATION_TYPE_BENEFIT_TAX_PROGRESSIVE,
+		investpb.OperationType_OPERATION_TYPE_TAX_CORRECTION_PROGRESSIVE,
+		investpb.OperationType_OPERATION_TYPE_TAX_REPO_PROGRESSIVE,
+		investpb.OperationType_OPERATION_TYPE_TAX_REPO,
+		investpb.OperationType_OPERATION_TYPE_TAX_REPO_HOLD,
+		investpb.OperationType_OPERATION_TYPE_TAX_REPO_REFUND,
+		investpb.OperationType_OPERATION_TYPE_TAX_REPO_HOLD_PROGRESSIVE,
+		investpb.OperationType_OPERATION_TYPE_TAX_REPO_REFUND_PROGRESSIVE,
+		investpb.OperationType_OPERATION_TYPE_TAX_CORRECTION_COUPON:
+		return OperationKindTax, TradeDirectionUnspecified
+
+	case investpb.OperationType_OPERATION_TYPE_INPUT:
+		return OperationKindPayIn, TradeDirectionUnspecified
+
+	default:
+		return OperationKindOther, TradeDirectionUnspecified
+	}
+}
+
+func adaptPbStopOrder(o *investpb.StopOrder) StopOrder {
+	var direction TradeDirection
+	switch o.Direction {
+	case investpb.StopOrderDirection_STOP_ORDER_DIRECTION_BUY:
+		direction = TradeDirectionBuy
+	case investpb.StopOrderDirection_STOP_ORDER_DIRECTION_SELL:
+		direction = TradeDirectionSell
+	case investpb.StopOrderDirection_STOP_ORDER_DIRECTION_UNSPECIFIED:
+	}
+
+	var expireAt time.Time
+	if o.ExpirationTime != nil {
+		expireAt = o.ExpirationTime.AsTime()
+	}
+
+	return StopOrder{
+		ID:        StopOrderID(o.StopOrderId),
+		FIGI:      FIGI(o.Figi),
+		Type:      StopOrderType(o.OrderType), // Values are the same.
+		Direction: direction,
+		Lots:      int(o.LotsRequested), // Possible overflow.
+		Currency:  o.Currency,
+		Price:     adaptPbMoneyValueToDecimal(o.Price),
+		StopPrice: adaptPbMoneyValueToDecimal(o.StopPrice),
+		CreatedAt: o.CreateDate.AsTime(),
+		ExpireAt:  expireAt,
+	}
+}
+
+func adaptPbTrade(t *investpb.Trade) Trade {
+	var direction TradeDirection
+	switch t.Direction {
+	case investpb.TradeDirection_TRADE_DIRECTION_BUY:
+		direction = TradeDirectionBuy
+	case investpb.TradeDirection_TRADE_DIRECTION_SELL:
+		direction = TradeDirectionSell
+	case investpb.TradeDirection_TRADE_DIRECTION_UNSPECIFIED:
+		direction = TradeDirectionUnspecified
+	}
+
+	return Trade{
+		FIGI:      FIGI(t.Figi),
+		Direction: direction,
+		Price:     adaptPbQuotationToDecimal(t.Price),
+		Lots:      int(t.Quantity), // Possible overflow.
+		Time:      t.Time.AsTime(),
+	}
+}
+
+func adaptPbLastPrice(p *investpb.LastPrice) LastPrice {
+	return LastPrice{
+		FIGI:  FIGI(p.Figi),
+		Price: adaptPbQuotationToDecimal(p.Price),
+		Time:  p.Time.AsTime(),
+	}
+}
+
+func adaptPbTradingStatus(s *investpb.TradingStatus) TradingStatus {
+	return TradingStatus{
+		FIGI:                 FIGI(s.Figi),
+		Code:                 TradingStatusCode(s.TradingStatus),
+		LimitOrderAvailable:  s.LimitOrderAvailableFlag,
+		MarketOrderAvailable: s.MarketOrderAvailableFlag,
+		Time:                 s.Time.AsTime(),
+	}
+}
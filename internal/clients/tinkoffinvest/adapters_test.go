@@ -1,11 +1,14 @@
 package tinkoffinvest //nolint:testpackage
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
@@ -114,7 +117,579 @@ func Test_adaptPbOrderbook(t *testing.T) {
 			LimitUp:   decimal.RequireFromString("200.220000000"),
 			LimitDown: decimal.RequireFromString("95.320000000"),
 		},
+		Depth:        10,
 		IsConsistent: true,
 		FormedAt:     time.Unix(1, 1).UTC(),
 	}, orderBook)
 }
+
+func Test_adaptPbCandle(t *testing.T) {
+	c := &investpb.Candle{
+		Figi:        "BBG004730N88",
+		Interval:    investpb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_FIVE_MINUTES,
+		Open:        &investpb.Quotation{Units: 120, Nano: 330000000},
+		High:        &investpb.Quotation{Units: 121, Nano: 0},
+		Low:         &investpb.Quotation{Units: 119, Nano: 990000000},
+		Close:       &investpb.Quotation{Units: 120, Nano: 500000000},
+		Volume:      1500,
+		Time:        timestamppb.New(time.Unix(300, 0).UTC()),
+		LastTradeTs: timestamppb.New(time.Unix(310, 0).UTC()),
+	}
+
+	candle := adaptPbCandle(c)
+	assert.Equal(t, Candle{
+		FIGI:     "BBG004730N88",
+		Interval: CandleInterval5Min,
+		Open:     decimal.RequireFromString("120.330000000"),
+		High:     decimal.RequireFromString("121.000000000"),
+		Low:      decimal.RequireFromString("119.990000000"),
+		Close:    decimal.RequireFromString("120.500000000"),
+		Volume:   1500,
+		Time:     time.Unix(300, 0).UTC(),
+	}, candle)
+}
+
+func Test_adaptPbHistoricCandle(t *testing.T) {
+	c := &investpb.HistoricCandle{
+		Open:       &investpb.Quotation{Units: 120, Nano: 330000000},
+		High:       &investpb.Quotation{Units: 121, Nano: 0},
+		Low:        &investpb.Quotation{Units: 119, Nano: 990000000},
+		Close:      &investpb.Quotation{Units: 120, Nano: 500000000},
+		Volume:     1500,
+		Time:       timestamppb.New(time.Unix(3600, 0).UTC()),
+		IsComplete: true,
+	}
+
+	candle := adaptPbHistoricCandle("BBG004730N88", CandleIntervalHour, c)
+	assert.Equal(t, Candle{
+		FIGI:       "BBG004730N88",
+		Interval:   CandleIntervalHour,
+		Open:       decimal.RequireFromString("120.330000000"),
+		High:       decimal.RequireFromString("121.000000000"),
+		Low:        decimal.RequireFromString("119.990000000"),
+		Close:      decimal.RequireFromString("120.500000000"),
+		Volume:     1500,
+		Time:       time.Unix(3600, 0).UTC(),
+		IsComplete: true,
+	}, candle)
+}
+
+func Test_adaptPbTrade(t *testing.T) {
+	cases := []struct {
+		in       investpb.TradeDirection
+		expected TradeDirection
+	}{
+		{in: investpb.TradeDirection_TRADE_DIRECTION_UNSPECIFIED, expected: TradeDirectionUnspecified},
+		{in: investpb.TradeDirection_TRADE_DIRECTION_BUY, expected: TradeDirectionBuy},
+		{in: investpb.TradeDirection_TRADE_DIRECTION_SELL, expected: TradeDirectionSell},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.expected.String(), func(t *testing.T) {
+			trade := adaptPbTrade(&investpb.Trade{
+				Figi:      "BBG004730N88",
+				Direction: tt.in,
+				Price:     &investpb.Quotation{Units: 180, Nano: 620000000},
+				Quantity:  7,
+				Time:      timestamppb.New(time.Unix(1, 1).UTC()),
+			})
+			assert.Equal(t, Trade{
+				FIGI:      "BBG004730N88",
+				Direction: tt.expected,
+				Price:     decimal.RequireFromString("180.620000000"),
+				Lots:      7,
+				Time:      time.Unix(1, 1).UTC(),
+			}, trade)
+		})
+	}
+}
+
+func Test_adaptPbOrderTrades(t *testing.T) {
+	fills := adaptPbOrderTrades(&investpb.OrderTrades{
+		OrderId:   "order-1",
+		Direction: investpb.OrderDirection_ORDER_DIRECTION_SELL,
+		Figi:      "BBG004730N88",
+		AccountId: "account-1",
+		Trades: []*investpb.OrderTrade{
+			{DateTime: timestamppb.New(time.Unix(1, 0).UTC()), Price: &investpb.Quotation{Units: 120, Nano: 500000000}, Quantity: 2},
+			{DateTime: timestamppb.New(time.Unix(2, 0).UTC()), Price: &investpb.Quotation{Units: 120, Nano: 400000000}, Quantity: 1},
+		},
+	})
+	assert.Equal(t, []OrderFill{
+		{
+			AccountID: "account-1",
+			OrderID:   "order-1",
+			FIGI:      "BBG004730N88",
+			Direction: TradeDirectionSell,
+			Price:     decimal.RequireFromString("120.500000000"),
+			Lots:      2,
+			Time:      time.Unix(1, 0).UTC(),
+		},
+		{
+			AccountID: "account-1",
+			OrderID:   "order-1",
+			FIGI:      "BBG004730N88",
+			Direction: TradeDirectionSell,
+			Price:     decimal.RequireFromString("120.400000000"),
+			Lots:      1,
+			Time:      time.Unix(2, 0).UTC(),
+		},
+	}, fills)
+}
+
+func Test_adaptPbOrderStateToFills(t *testing.T) {
+	now := time.Unix(10, 0)
+
+	state := &investpb.OrderState{
+		OrderId:      "order-1",
+		LotsExecuted: 5,
+		Figi:         "BBG004730N88",
+		Direction:    investpb.OrderDirection_ORDER_DIRECTION_BUY,
+		Stages: []*investpb.OrderStage{
+			{Price: &investpb.MoneyValue{Units: 100}, Quantity: 2},
+			{Price: &investpb.MoneyValue{Units: 101}, Quantity: 3},
+		},
+		AveragePositionPrice: &investpb.MoneyValue{Units: 102},
+	}
+
+	fill := func(price int64, lots int) OrderFill {
+		return OrderFill{
+			AccountID: "account-1",
+			OrderID:   "order-1",
+			FIGI:      "BBG004730N88",
+			Direction: TradeDirectionBuy,
+			Price:     decimal.New(price*1_000_000_000, -9),
+			Lots:      lots,
+			Time:      now,
+		}
+	}
+
+	t.Run("nothing reported", func(t *testing.T) {
+		fills := adaptPbOrderStateToFills("account-1", state, 0, now)
+		assert.Equal(t, []OrderFill{fill(100, 2), fill(101, 3)}, fills)
+	})
+
+	t.Run("stage is partially reported", func(t *testing.T) {
+		fills := adaptPbOrderStateToFills("account-1", state, 3, now)
+		assert.Equal(t, []OrderFill{fill(101, 2)}, fills)
+	})
+
+	t.Run("all reported", func(t *testing.T) {
+		fills := adaptPbOrderStateToFills("account-1", state, 5, now)
+		assert.Empty(t, fills)
+	})
+
+	t.Run("no stages", func(t *testing.T) {
+		noStages := proto.Clone(state).(*investpb.OrderState)
+		noStages.Stages = nil
+
+		fills := adaptPbOrderStateToFills("account-1", noStages, 1, now)
+		assert.Equal(t, []OrderFill{fill(102, 4)}, fills)
+	})
+}
+
+func Test_adaptStopOrderRequestToPb(t *testing.T) {
+	valid := StopOrderRequest{
+		AccountID: "account-1",
+		FIGI:      "BBG004730N88",
+		Direction: TradeDirectionSell,
+		Lots:      2,
+		StopPrice: decimal.RequireFromString("110.5"),
+	}
+
+	t.Run("good till cancel", func(t *testing.T) {
+		req, err := adaptStopOrderRequestToPb(valid, investpb.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS)
+		require.NoError(t, err)
+
+		assert.Equal(t, "BBG004730N88", req.Figi)
+		assert.Equal(t, "account-1", req.AccountId)
+		assert.Equal(t, int64(2), req.Quantity)
+		assert.Equal(t, int64(110), req.StopPrice.Units)
+		assert.Equal(t, int32(500000000), req.StopPrice.Nano)
+		assert.Equal(t, investpb.StopOrderDirection_STOP_ORDER_DIRECTION_SELL, req.Direction)
+		assert.Equal(t, investpb.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS, req.StopOrderType)
+		assert.Equal(t, investpb.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_CANCEL, req.ExpirationType)
+		assert.Nil(t, req.ExpireDate)
+	})
+
+	t.Run("good till date", func(t *testing.T) {
+		r := valid
+		r.Expiration = StopOrderGoodTillDate
+		r.ExpireAt = time.Unix(100, 0).UTC()
+
+		req, err := adaptStopOrderRequestToPb(r, investpb.StopOrderType_STOP_ORDER_TYPE_TAKE_PROFIT)
+		require.NoError(t, err)
+		assert.Equal(t, investpb.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_DATE, req.ExpirationType)
+		assert.Equal(t, r.ExpireAt, req.ExpireDate.AsTime())
+	})
+
+	invalid := []struct {
+		name   string
+		modify func(r *StopOrderRequest)
+	}{
+		{name: "no lots", modify: func(r *StopOrderRequest) { r.Lots = 0 }},
+		{name: "no stop price", modify: func(r *StopOrderRequest) { r.StopPrice = decimal.Zero }},
+		{name: "no direction", modify: func(r *StopOrderRequest) { r.Direction = TradeDirectionUnspecified }},
+		{name: "no expiration time", modify: func(r *StopOrderRequest) { r.Expiration = StopOrderGoodTillDate }},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.modify(&r)
+
+			_, err := adaptStopOrderRequestToPb(r, investpb.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_adaptPbStopOrder(t *testing.T) {
+	o := adaptPbStopOrder(&investpb.StopOrder{
+		StopOrderId:   "stop-1",
+		LotsRequested: 3,
+		Figi:          "BBG004730N88",
+		Direction:     investpb.StopOrderDirection_STOP_ORDER_DIRECTION_BUY,
+		Currency:      "rub",
+		OrderType:     investpb.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT,
+		CreateDate:    timestamppb.New(time.Unix(1, 0).UTC()),
+		Price:         &investpb.MoneyValue{Currency: "rub", Units: 101},
+		StopPrice:     &investpb.MoneyValue{Currency: "rub", Units: 100, Nano: 500000000},
+	})
+	assert.Equal(t, StopOrder{
+		ID:        "stop-1",
+		FIGI:      "BBG004730N88",
+		Type:      StopOrderTypeStopLimit,
+		Direction: TradeDirectionBuy,
+		Lots:      3,
+		Currency:  "rub",
+		Price:     decimal.RequireFromString("101.000000000"),
+		StopPrice: decimal.RequireFromString("100.500000000"),
+		CreatedAt: time.Unix(1, 0).UTC(),
+	}, o)
+}
+
+func Test_adaptPbFutureToInstrument(t *testing.T) {
+	i := adaptPbFutureToInstrument(&investpb.Future{
+		Figi:              "FUTSI0622000",
+		Lot:               1,
+		Currency:          "rub",
+		Name:              "Si-6.22 Курс доллар - рубль",
+		MinPriceIncrement: &investpb.Quotation{Units: 1},
+		ExpirationDate:    timestamppb.New(time.Unix(1, 0).UTC()),
+	})
+	assert.Equal(t, Instrument{
+		FIGI:              "FUTSI0622000",
+		Type:              InstrumentTypeFutures,
+		Name:              "Si-6.22 Курс доллар - рубль",
+		Currency:          "rub",
+		Lot:               1,
+		MinPriceIncrement: decimal.RequireFromString("1.000000000"),
+		ExpirationDate:    time.Unix(1, 0).UTC(),
+	}, i)
+}
+
+func Test_adaptPbBondToInstrument(t *testing.T) {
+	i := adaptPbBondToInstrument(&investpb.Bond{
+		Figi:              "BBG00T22WKV5",
+		Isin:              "SU29013RMFS8",
+		Lot:               1,
+		Currency:          "rub",
+		Name:              "ОФЗ 29013",
+		Nominal:           &investpb.MoneyValue{Currency: "rub", Units: 1000},
+		MinPriceIncrement: &investpb.Quotation{Nano: 10000000},
+	})
+	assert.Equal(t, InstrumentTypeBond, i.Type)
+	assert.Equal(t, "1000", i.Nominal.String())
+	assert.Equal(t, "0.01", i.MinPriceIncrement.String())
+}
+
+func Test_adaptPbCurrencyToInstrument(t *testing.T) {
+	i := adaptPbCurrencyToInstrument(&investpb.Currency{
+		Figi:            "BBG0013HGFT4",
+		Lot:             1000,
+		Currency:        "rub",
+		Name:            "Доллар США",
+		Nominal:         &investpb.MoneyValue{Currency: "usd", Units: 1},
+		IsoCurrencyName: "usd",
+	})
+	assert.Equal(t, InstrumentTypeCurrency, i.Type)
+	assert.Equal(t, "1", i.Nominal.String())
+	assert.Equal(t, "usd", i.ISOCurrencyName)
+}
+
+func Test_adaptPbFuturesMarginToPointValue(t *testing.T) {
+	v := adaptPbFuturesMarginToPointValue(&investpb.GetFuturesMarginResponse{
+		MinPriceIncrement:       &investpb.Quotation{Nano: 10000000},
+		MinPriceIncrementAmount: &investpb.Quotation{Nano: 740000000},
+	})
+	assert.Equal(t, "74", v.String())
+
+	v = adaptPbFuturesMarginToPointValue(new(investpb.GetFuturesMarginResponse))
+	assert.True(t, v.IsZero())
+}
+
+func Test_adaptPbTradingSchedule(t *testing.T) {
+	day := time.Date(2022, 5, 20, 0, 0, 0, 0, time.UTC)
+
+	s := adaptPbTradingSchedule(&investpb.TradingSchedule{
+		Exchange: "MOEX",
+		Days: []*investpb.TradingDay{
+			{
+				Date:             timestamppb.New(day),
+				IsTradingDay:     true,
+				StartTime:        timestamppb.New(day.Add(7 * time.Hour)),
+				EndTime:          timestamppb.New(day.Add(15*time.Hour + 45*time.Minute)),
+				EveningStartTime: timestamppb.New(day.Add(16 * time.Hour)),
+				EveningEndTime:   timestamppb.New(day.Add(20*time.Hour + 50*time.Minute)),
+			},
+			{
+				Date:      timestamppb.New(day.Add(24 * time.Hour)),
+				StartTime: new(timestamppb.Timestamp),
+			},
+		},
+	})
+	assert.Equal(t, TradingSchedule{
+		Exchange: "MOEX",
+		Days: []TradingDay{
+			{
+				Date:             day,
+				IsTradingDay:     true,
+				StartTime:        day.Add(7 * time.Hour),
+				EndTime:          day.Add(15*time.Hour + 45*time.Minute),
+				EveningStartTime: day.Add(16 * time.Hour),
+				EveningEndTime:   day.Add(20*time.Hour + 50*time.Minute),
+			},
+			{
+				Date: day.Add(24 * time.Hour),
+			},
+		},
+	}, s)
+}
+
+func Test_adaptDecimalToPbMoneyValue(t *testing.T) {
+	m := adaptDecimalToPbMoneyValue(decimal.RequireFromString("100000.5"), currencyRUB)
+	assert.Equal(t, &investpb.MoneyValue{Currency: "rub", Units: 100000, Nano: 500000000}, m)
+}
+
+func Test_adaptPbAccount(t *testing.T) {
+	opened := time.Date(2022, 5, 20, 10, 0, 0, 0, time.UTC)
+
+	a := adaptPbAccount(&investpb.Account{
+		Id:          "sandbox-007",
+		Name:        "sandbox",
+		Status:      investpb.AccountStatus_ACCOUNT_STATUS_OPEN,
+		AccessLevel: investpb.AccessLevel_ACCOUNT_ACCESS_LEVEL_READ_ONLY,
+		OpenedDate:  timestamppb.New(opened),
+		ClosedDate:  new(timestamppb.Timestamp),
+	})
+	assert.Equal(t, Account{
+		ID:          "sandbox-007",
+		Name:        "sandbox",
+		Status:      AccountStatusOpen,
+		AccessLevel: AccessLevelReadOnly,
+		OpenedAt:    opened,
+	}, a)
+	assert.False(t, a.CanTrade())
+}
+
+func Test_adaptPbPositions(t *testing.T) {
+	p := adaptPbPositions(&investpb.PositionsResponse{
+		Money: []*investpb.MoneyValue{
+			{Currency: "rub", Units: 1000, Nano: 500000000},
+			{Currency: "usd", Units: 10},
+		},
+		Blocked: []*investpb.MoneyValue{
+			{Currency: "rub", Units: 120},
+		},
+		Securities: []*investpb.PositionsSecurities{
+			{Figi: "BBG004730N88", Balance: 10, Blocked: 1},
+		},
+	})
+	assert.Equal(t, &Positions{
+		Money: map[string]decimal.Decimal{
+			"rub": decimal.RequireFromString("1000.500000000"),
+			"usd": decimal.RequireFromString("10.000000000"),
+		},
+		BlockedMoney: map[string]decimal.Decimal{
+			"rub": decimal.RequireFromString("120.000000000"),
+		},
+		Securities: []SecurityPosition{
+			{FIGI: "BBG004730N88", Balance: 10, Blocked: 1},
+		},
+	}, p)
+}
+
+func Test_adaptPbOperation(t *testing.T) {
+	o := adaptPbOperation(&investpb.Operation{
+		Id:                "op-2",
+		ParentOperationId: "op-1",
+		Currency:          "rub",
+		Payment:           &investpb.MoneyValue{Currency: "rub", Units: -1, Nano: -500000000},
+		State:             investpb.OperationState_OPERATION_STATE_EXECUTED,
+		Figi:              "BBG004730N88",
+		Date:              timestamppb.New(time.Unix(1, 0).UTC()),
+		Type:              "Удержание комиссии за операцию",
+		OperationType:     investpb.OperationType_OPERATION_TYPE_BROKER_FEE,
+	})
+	assert.Equal(t, Operation{
+		ID:          "op-2",
+		ParentID:    "op-1",
+		Kind:        OperationKindCommission,
+		Description: "Удержание комиссии за операцию",
+		FIGI:        "BBG004730N88",
+		Payment:     decimal.RequireFromString("-1.500000000"),
+		Currency:    "rub",
+		Price:       decimal.Zero,
+		Time:        time.Unix(1, 0).UTC(),
+	}, o)
+}
+
+func Test_adaptPbOperationType(t *testing.T) {
+	cases := []struct {
+		in        investpb.OperationType
+		kind      OperationKind
+		direction TradeDirection
+	}{
+		{investpb.OperationType_OPERATION_TYPE_BUY, OperationKindTrade, TradeDirectionBuy},
+		{investpb.OperationType_OPERATION_TYPE_SELL, OperationKindTrade, TradeDirectionSell},
+		{investpb.OperationType_OPERATION_TYPE_BROKER_FEE, OperationKindCommission, TradeDirectionUnspecified},
+		{investpb.OperationType_OPERATION_TYPE_DIVIDEND, OperationKindDividend, TradeDirectionUnspecified},
+		{investpb.OperationType_OPERATION_TYPE_DIVIDEND_TAX, OperationKindTax, TradeDirectionUnspecified},
+		{investpb.OperationType_OPERATION_TYPE_INPUT, OperationKindPayIn, TradeDirectionUnspecified},
+		{investpb.OperationType_OPERATION_TYPE_OUTPUT, OperationKindOther, TradeDirectionUnspecified},
+		{investpb.OperationType_OPERATION_TYPE_UNSPECIFIED, OperationKindOther, TradeDirectionUnspecified},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.in.String(), func(t *testing.T) {
+			kind, direction := adaptPbOperationType(tt.in)
+			assert.Equal(t, tt.kind, kind)
+			assert.Equal(t, tt.direction, direction)
+		})
+	}
+}
+
+func Test_adaptPbActiveOrder(t *testing.T) {
+	o := adaptPbActiveOrder(&investpb.OrderState{
+		OrderId:              "order-1",
+		LotsRequested:        5,
+		LotsExecuted:         2,
+		Figi:                 "BBG004730N88",
+		Direction:            investpb.OrderDirection_ORDER_DIRECTION_SELL,
+		InitialSecurityPrice: &investpb.MoneyValue{Currency: "rub", Units: 120, Nano: 10000000},
+		OrderType:            investpb.OrderType_ORDER_TYPE_LIMIT,
+		OrderDate:            timestamppb.New(time.Unix(1, 0).UTC()),
+	})
+	assert.Equal(t, ActiveOrder{
+		ID:            "order-1",
+		FIGI:          "BBG004730N88",
+		Type:          OrderTypeLimit,
+		Direction:     TradeDirectionSell,
+		LotsRequested: 5,
+		LotsExecuted:  2,
+		Price:         decimal.RequireFromString("120.010000000"),
+		CreatedAt:     time.Unix(1, 0).UTC(),
+	}, o)
+}
+
+func Test_adaptPbOrderState(t *testing.T) {
+	s := adaptPbOrderState(&investpb.OrderState{
+		OrderId:               "order-1",
+		ExecutionReportStatus: investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL,
+		LotsRequested:         5,
+		LotsExecuted:          2,
+		InitialSecurityPrice:  &investpb.MoneyValue{Currency: "rub", Units: 120},
+		ExecutedOrderPrice:    &investpb.MoneyValue{Currency: "rub", Units: 2401},
+		AveragePositionPrice:  &investpb.MoneyValue{Currency: "rub", Units: 120, Nano: 50000000},
+		ExecutedCommission:    &investpb.MoneyValue{Currency: "rub", Units: 1, Nano: 200000000},
+		Figi:                  "BBG004730N88",
+		Direction:             investpb.OrderDirection_ORDER_DIRECTION_BUY,
+		Stages: []*investpb.OrderStage{
+			{Price: &investpb.MoneyValue{Currency: "rub", Units: 120}, Quantity: 1, TradeId: "trade-1"},
+			{Price: &investpb.MoneyValue{Currency: "rub", Units: 120, Nano: 100000000}, Quantity: 1, TradeId: "trade-2"},
+		},
+		Currency:  "rub",
+		OrderType: investpb.OrderType_ORDER_TYPE_LIMIT,
+		OrderDate: timestamppb.New(time.Unix(1, 0).UTC()),
+	})
+	assert.Equal(t, OrderState{
+		ID:                 "order-1",
+		FIGI:               "BBG004730N88",
+		Type:               OrderTypeLimit,
+		Direction:          TradeDirectionBuy,
+		Status:             OrderStatusPartiallyFilled,
+		LotsRequested:      5,
+		LotsExecuted:       2,
+		InitialPrice:       decimal.RequireFromString("120.000000000"),
+		AveragePrice:       decimal.RequireFromString("120.050000000"),
+		ExecutedAmount:     decimal.RequireFromString("2401.000000000"),
+		ExecutedCommission: decimal.RequireFromString("1.200000000"),
+		Currency:           "rub",
+		Stages: []OrderStage{
+			{TradeID: "trade-1", Price: decimal.RequireFromString("120.000000000"), Lots: 1},
+			{TradeID: "trade-2", Price: decimal.RequireFromString("120.100000000"), Lots: 1},
+		},
+		CreatedAt: time.Unix(1, 0).UTC(),
+	}, s)
+	assert.Equal(t, 3, s.LotsLeft())
+	assert.False(t, s.Status.IsFinal())
+}
+
+func Test_adaptPbOrderStatus(t *testing.T) {
+	for code, name := range investpb.OrderExecutionReportStatus_name {
+		t.Run(name, func(t *testing.T) {
+			s := adaptPbOrderStatus(investpb.OrderExecutionReportStatus(code))
+			if code == int32(investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_UNSPECIFIED) {
+				assert.Equal(t, OrderStatusUnspecified, s)
+			} else {
+				assert.NotEqual(t, OrderStatusUnspecified, s)
+			}
+		})
+	}
+}
+
+func Test_adaptPbTradingStatus(t *testing.T) {
+	for code, name := range investpb.SecurityTradingStatus_name {
+		t.Run(name, func(t *testing.T) {
+			s := adaptPbTradingStatus(&investpb.TradingStatus{
+				Figi:                     "BBG004730N88",
+				TradingStatus:            investpb.SecurityTradingStatus(code),
+				Time:                     timestamppb.New(time.Unix(1, 1).UTC()),
+				LimitOrderAvailableFlag:  true,
+				MarketOrderAvailableFlag: false,
+			})
+			assert.Equal(t, FIGI("BBG004730N88"), s.FIGI)
+			assert.Equal(t, strings.ToLower(strings.TrimPrefix(name, "SECURITY_TRADING_STATUS_")), s.Code.String())
+			assert.Equal(t, code == int32(investpb.SecurityTradingStatus_SECURITY_TRADING_STATUS_NORMAL_TRADING), s.IsNormalTrading())
+			assert.True(t, s.LimitOrderAvailable)
+			assert.False(t, s.MarketOrderAvailable)
+			assert.Equal(t, time.Unix(1, 1).UTC(), s.Time)
+		})
+	}
+}
+
+func Test_adaptMarketDataSubscriptionToPb(t *testing.T) {
+	reqs, err := adaptMarketDataSubscriptionToPb(MarketDataSubscription{
+		OrderBooks:      []OrderBookRequest{{FIGI: "f1", Depth: 10}},
+		TradingStatuses: []FIGI{"f1", "f2"},
+	}, investpb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE)
+	require.NoError(t, err)
+	require.Len(t, reqs, 2)
+
+	obReq := reqs[0].GetSubscribeOrderBookRequest()
+	require.NotNil(t, obReq)
+	assert.Equal(t, investpb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE, obReq.SubscriptionAction)
+	require.Len(t, obReq.Instruments, 1)
+	assert.Equal(t, "f1", obReq.Instruments[0].Figi)
+	assert.Equal(t, int32(10), obReq.Instruments[0].Depth)
+
+	infoReq := reqs[1].GetSubscribeInfoRequest()
+	require.NotNil(t, infoReq)
+	assert.Equal(t, investpb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE, infoReq.SubscriptionAction)
+	assert.Len(t, infoReq.Instruments, 2)
+
+	_, err = adaptMarketDataSubscriptionToPb(MarketDataSubscription{
+		Candles: []CandleRequest{{FIGI: "f1", Interval: CandleIntervalDay}},
+	}, investpb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE)
+	assert.Error(t, err)
+}
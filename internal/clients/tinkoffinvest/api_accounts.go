@@ -0,0 +1,60 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+type AccountStatus string
+
+const (
+	AccountStatusUnknown AccountStatus = "unknown"
+	AccountStatusNew     AccountStatus = "new"
+	AccountStatusOpen    AccountStatus = "open"
+	AccountStatusClosed  AccountStatus = "closed"
+)
+
+type AccessLevel string
+
+const (
+	AccessLevelUnknown  AccessLevel = "unknown"
+	AccessLevelFull     AccessLevel = "full"
+	AccessLevelReadOnly AccessLevel = "read_only"
+	AccessLevelNone     AccessLevel = "none"
+)
+
+type Account struct {
+	ID          AccountID
+	Name        string
+	Status      AccountStatus
+	AccessLevel AccessLevel
+	OpenedAt    time.Time
+	// ClosedAt is zero for not closed accounts.
+	ClosedAt time.Time
+}
+
+// CanTrade reports whether the orders can be placed for the account.
+func (a Account) CanTrade() bool {
+	return a.Status == AccountStatusOpen && a.AccessLevel == AccessLevelFull
+}
+
+// GetAccounts returns the accounts available for the token (sandbox ones in the sandbox mode).
+func (c *Client) GetAccounts(ctx context.Context) ([]Account, error) {
+	if c.useSandbox {
+		return c.GetSandboxAccounts(ctx)
+	}
+
+	resp, err := c.users.GetAccounts(c.auth(ctx), new(investpb.GetAccountsRequest))
+	if err != nil {
+		return nil, fmt.Errorf("grpc get accounts call: %w", err)
+	}
+
+	accounts := make([]Account, len(resp.Accounts))
+	for i, a := range resp.Accounts {
+		accounts[i] = adaptPbAccount(a)
+	}
+	return accounts, nil
+}
@@ -0,0 +1,196 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+type CandleInterval int
+
+const (
+	CandleInterval1Min CandleInterval = iota + 1
+	CandleInterval5Min
+	CandleInterval15Min
+	CandleIntervalHour
+	CandleIntervalDay
+)
+
+func (i CandleInterval) String() string {
+	switch i {
+	case CandleInterval1Min:
+		return "1m"
+	case CandleInterval5Min:
+		return "5m"
+	case CandleInterval15Min:
+		return "15m"
+	case CandleIntervalHour:
+		return "1h"
+	case CandleIntervalDay:
+		return "1d"
+	}
+	return fmt.Sprintf("CandleInterval(%d)", int(i))
+}
+
+// Candle is a price aggregation for the interval. Prices are per one instrument, not per lot.
+type Candle struct {
+	FIGI     FIGI
+	Interval CandleInterval
+	Open     decimal.Decimal
+	High     decimal.Decimal
+	Low      decimal.Decimal
+	Close    decimal.Decimal
+	// Volume in lots.
+	Volume int
+	// Time is the interval start.
+	Time time.Time
+	// IsComplete is false if the candle is still being formed.
+	IsComplete bool
+}
+
+// GetCandles returns historical candles for the [from, to) period.
+// Note that the API limits the period depending on interval (e.g. one day for minute candles).
+func (c *Client) GetCandles(
+	ctx context.Context,
+	figi FIGI,
+	from, to time.Time,
+	interval CandleInterval,
+) ([]Candle, error) {
+	pbInterval, err := adaptCandleIntervalToPb(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.marketData.GetCandles(c.auth(ctx), &investpb.GetCandlesRequest{
+		Figi:     figi.S(),
+		From:     timestamppb.New(from),
+		To:       timestamppb.New(to),
+		Interval: pbInterval,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("grpc get candles call: %w", err)
+	}
+
+	result := make([]Candle, 0, len(resp.Candles))
+	for _, candle := range resp.Candles {
+		result = append(result, adaptPbHistoricCandle(figi, interval, candle))
+	}
+	return result, nil
+}
+
+type CandleRequest struct {
+	FIGI FIGI
+	// Interval supports CandleInterval1Min and CandleInterval5Min only.
+	Interval CandleInterval
+}
+
+// SubscribeForCandles streams forming candles. Each update of the current interval is sent as a separate Candle.
+// Candles are not dropped, the stream waits for the slow consumer once the buffer is full.
+func (c *Client) SubscribeForCandles(ctx context.Context, reqs []CandleRequest) (<-chan Candle, error) {
+	instruments := make([]*investpb.CandleInstrument, len(reqs))
+	for i, req := range reqs {
+		interval, err := adaptCandleIntervalToPbSubscription(req.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("figi %v: %w", req.FIGI, err)
+		}
+
+		instruments[i] = &investpb.CandleInstrument{
+			Figi:     req.FIGI.S(),
+			Interval: interval,
+		}
+	}
+
+	stream, logger, err := c.subscribeMarketData(ctx, &investpb.MarketDataRequest{
+		Payload: &investpb.MarketDataRequest_SubscribeCandlesRequest{
+			SubscribeCandlesRequest: &investpb.SubscribeCandlesRequest{
+				SubscriptionAction: investpb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE,
+				Instruments:        instruments,
+			},
+		},
+	}, func(mdResp *investpb.MarketDataResponse) (string, error) {
+		candlesResp, ok := mdResp.Payload.(*investpb.MarketDataResponse_SubscribeCandlesResponse)
+		if !ok {
+			return "", fmt.Errorf("unexpected response type: %T", mdResp.Payload)
+		}
+
+		resp := candlesResp.SubscribeCandlesResponse
+
+		type key struct {
+			figi     string
+			interval investpb.SubscriptionInterval
+		}
+		subsMap := make(map[key]*investpb.CandleSubscription)
+		for _, s := range resp.CandlesSubscriptions {
+			subsMap[key{s.Figi, s.Interval}] = s
+		}
+
+		for _, instrument := range instruments {
+			s, ok := subsMap[key{instrument.Figi, instrument.Interval}]
+			if !ok {
+				return "", fmt.Errorf(
+					"tid %v: figi: %v: no response for requested instrument", resp.TrackingId, instrument.Figi)
+			}
+			if err := checkSubscriptionStatus(resp.TrackingId, instrument.Figi, s.SubscriptionStatus); err != nil {
+				return "", err
+			}
+		}
+		return resp.TrackingId, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Listen candles.
+
+	candles := make(chan Candle, mdEventsBufferSize)
+	go func() {
+		defer close(candles)
+
+		listenMarketData(stream, logger, func(resp *investpb.MarketDataResponse) bool {
+			v, ok := resp.Payload.(*investpb.MarketDataResponse_Candle)
+			if !ok {
+				return true
+			}
+
+			select {
+			case <-ctx.Done():
+				return false
+			case candles <- adaptPbCandle(v.Candle):
+			}
+			return true
+		})
+	}()
+	return candles, nil
+}
+
+func adaptCandleIntervalToPb(i CandleInterval) (investpb.CandleInterval, error) {
+	switch i {
+	case CandleInterval1Min:
+		return investpb.CandleInterval_CANDLE_INTERVAL_1_MIN, nil
+	case CandleInterval5Min:
+		return investpb.CandleInterval_CANDLE_INTERVAL_5_MIN, nil
+	case CandleInterval15Min:
+		return investpb.CandleInterval_CANDLE_INTERVAL_15_MIN, nil
+	case CandleIntervalHour:
+		return investpb.CandleInterval_CANDLE_INTERVAL_HOUR, nil
+	case CandleIntervalDay:
+		return investpb.CandleInterval_CANDLE_INTERVAL_DAY, nil
+	}
+	return investpb.CandleInterval_CANDLE_INTERVAL_UNSPECIFIED, fmt.Errorf("unsupported candle interval: %v", i)
+}
+
+func adaptCandleIntervalToPbSubscription(i CandleInterval) (investpb.SubscriptionInterval, error) {
+	switch i { //nolint:exhaustive
+	case CandleInterval1Min:
+		return investpb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE, nil
+	case CandleInterval5Min:
+		return investpb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_FIVE_MINUTES, nil
+	}
+	return investpb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_UNSPECIFIED,
+		fmt.Errorf("unsupported candle subscription interval: %v", i)
+}
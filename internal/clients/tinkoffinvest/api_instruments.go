@@ -3,53 +3,161 @@ package tinkoffinvest
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/shopspring/decimal"
 
 	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
 )
 
+type InstrumentType string
+
+const (
+	InstrumentTypeShare    InstrumentType = "share"
+	InstrumentTypeBond     InstrumentType = "bond"
+	InstrumentTypeETF      InstrumentType = "etf"
+	InstrumentTypeFutures  InstrumentType = "futures"
+	InstrumentTypeCurrency InstrumentType = "currency"
+)
+
 type Instrument struct {
 	FIGI              FIGI
+	Type              InstrumentType
 	ISIN              string
 	Name              string
+	Exchange          string
+	Currency          string
 	Lot               int
 	MinPriceIncrement decimal.Decimal
+
+	// Nominal is specified for bonds and currencies.
+	Nominal decimal.Decimal
+	// PointValue is the cost of one price point in Currency, specified for futures.
+	// Filled by GetInstrumentByFIGI only.
+	PointValue decimal.Decimal
+	// ExpirationDate is specified for futures.
+	ExpirationDate time.Time
+	// ISOCurrencyName is specified for currencies.
+	ISOCurrencyName string
 }
 
-func (c *Client) GetTradeAvailableShares(ctx context.Context) ([]Instrument, error) {
-	resp, err := c.instruments.Shares(c.auth(ctx), &investpb.InstrumentsRequest{
+// GetTradeAvailableInstruments returns RUB instruments of the type available for trading via API.
+func (c *Client) GetTradeAvailableInstruments(ctx context.Context, typ InstrumentType) ([]Instrument, error) {
+	ctx = c.auth(ctx)
+
+	req := &investpb.InstrumentsRequest{
 		InstrumentStatus: investpb.InstrumentStatus_INSTRUMENT_STATUS_BASE,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("grpc call: %v", err)
 	}
 
-	result := make([]Instrument, 0, len(resp.Instruments))
-	for _, share := range resp.Instruments {
-		av := share.ApiTradeAvailableFlag && share.BuyAvailableFlag && share.SellAvailableFlag
-		if !av {
-			continue
+	var result []Instrument
+	add := func(i Instrument, apiAvailable, buyAvailable, sellAvailable bool) {
+		if apiAvailable && buyAvailable && sellAvailable && currency(i.Currency) == currencyRUB {
+			result = append(result, i)
+		}
+	}
+
+	switch typ {
+	case InstrumentTypeShare:
+		resp, err := c.instruments.Shares(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("grpc shares call: %w", err)
+		}
+		for _, i := range resp.Instruments {
+			add(adaptPbShareToInstrument(i), i.ApiTradeAvailableFlag, i.BuyAvailableFlag, i.SellAvailableFlag)
 		}
 
-		if currency(share.Currency) != currencyRUB {
-			continue
+	case InstrumentTypeBond:
+		resp, err := c.instruments.Bonds(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("grpc bonds call: %w", err)
+		}
+		for _, i := range resp.Instruments {
+			add(adaptPbBondToInstrument(i), i.ApiTradeAvailableFlag, i.BuyAvailableFlag, i.SellAvailableFlag)
+		}
+
+	case InstrumentTypeETF:
+		resp, err := c.instruments.Etfs(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("grpc etfs call: %w", err)
+		}
+		for _, i := range resp.Instruments {
+			add(adaptPbEtfToInstrument(i), i.ApiTradeAvailableFlag, i.BuyAvailableFlag, i.SellAvailableFlag)
 		}
 
-		result = append(result, adaptPbShareToInstrument(share))
+	case InstrumentTypeFutures:
+		resp, err := c.instruments.Futures(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("grpc futures call: %w", err)
+		}
+		for _, i := range resp.Instruments {
+			add(adaptPbFutureToInstrument(i), i.ApiTradeAvailableFlag, i.BuyAvailableFlag, i.SellAvailableFlag)
+		}
+
+	case InstrumentTypeCurrency:
+		resp, err := c.instruments.Currencies(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("grpc currencies call: %w", err)
+		}
+		for _, i := range resp.Instruments {
+			add(adaptPbCurrencyToInstrument(i), i.ApiTradeAvailableFlag, i.BuyAvailableFlag, i.SellAvailableFlag)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported instrument type: %q", typ)
 	}
+
 	return result, nil
 }
 
-func (c *Client) GetShareByFIGI(ctx context.Context, figi FIGI) (*Instrument, error) {
-	resp, err := c.instruments.ShareBy(c.auth(ctx), &investpb.InstrumentRequest{
+// GetInstrumentByFIGI returns the instrument of any supported type with its type-specific fields.
+func (c *Client) GetInstrumentByFIGI(ctx context.Context, figi FIGI) (*Instrument, error) {
+	ctx = c.auth(ctx)
+
+	req := &investpb.InstrumentRequest{
 		IdType: investpb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI,
 		Id:     figi.S(),
-	})
+	}
+
+	resp, err := c.instruments.GetInstrumentBy(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("grcp share by call: %v", err)
+		return nil, fmt.Errorf("grpc get instrument by call: %w", err)
+	}
+
+	var i Instrument
+	switch typ := InstrumentType(resp.Instrument.InstrumentType); typ {
+	case InstrumentTypeShare, InstrumentTypeETF:
+		i = adaptPbInstrument(resp.Instrument)
+
+	case InstrumentTypeBond:
+		resp, err := c.instruments.BondBy(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("grpc bond by call: %w", err)
+		}
+		i = adaptPbBondToInstrument(resp.Instrument)
+
+	case InstrumentTypeFutures:
+		resp, err := c.instruments.FutureBy(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("grpc future by call: %w", err)
+		}
+		i = adaptPbFutureToInstrument(resp.Instrument)
+
+		margin, err := c.instruments.GetFuturesMargin(ctx, &investpb.GetFuturesMarginRequest{Figi: figi.S()})
+		if err != nil {
+			return nil, fmt.Errorf("grpc get futures margin call: %w", err)
+		}
+		i.PointValue = adaptPbFuturesMarginToPointValue(margin)
+
+	case InstrumentTypeCurrency:
+		resp, err := c.instruments.CurrencyBy(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("grpc currency by call: %w", err)
+		}
+		i = adaptPbCurrencyToInstrument(resp.Instrument)
+
+	default:
+		return nil, fmt.Errorf("unsupported instrument type: %q", typ)
 	}
 
-	i := adaptPbShareToInstrument(resp.Instrument)
 	return &i, nil
 }
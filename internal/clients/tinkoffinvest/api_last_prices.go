@@ -0,0 +1,97 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+type LastPrice struct {
+	FIGI FIGI
+	// Price is per one instrument, not per lot.
+	Price decimal.Decimal
+	Time  time.Time
+}
+
+func (c *Client) GetLastPrices(ctx context.Context, figis []FIGI) ([]LastPrice, error) {
+	req := &investpb.GetLastPricesRequest{Figi: make([]string, len(figis))}
+	for i, f := range figis {
+		req.Figi[i] = f.S()
+	}
+
+	resp, err := c.marketData.GetLastPrices(c.auth(ctx), req)
+	if err != nil {
+		return nil, fmt.Errorf("grpc get last prices call: %w", err)
+	}
+
+	result := make([]LastPrice, 0, len(resp.LastPrices))
+	for _, p := range resp.LastPrices {
+		result = append(result, adaptPbLastPrice(p))
+	}
+	return result, nil
+}
+
+// SubscribeForLastPrices streams last prices of the instruments.
+// Prices are not dropped, the stream waits for the slow consumer once the buffer is full.
+func (c *Client) SubscribeForLastPrices(ctx context.Context, figis []FIGI) (<-chan LastPrice, error) {
+	instruments := make([]*investpb.LastPriceInstrument, len(figis))
+	requested := make([]string, len(figis))
+	for i, f := range figis {
+		instruments[i] = &investpb.LastPriceInstrument{Figi: f.S()}
+		requested[i] = f.S()
+	}
+
+	stream, logger, err := c.subscribeMarketData(ctx, &investpb.MarketDataRequest{
+		Payload: &investpb.MarketDataRequest_SubscribeLastPriceRequest{
+			SubscribeLastPriceRequest: &investpb.SubscribeLastPriceRequest{
+				SubscriptionAction: investpb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE,
+				Instruments:        instruments,
+			},
+		},
+	}, func(mdResp *investpb.MarketDataResponse) (string, error) {
+		lastPriceResp, ok := mdResp.Payload.(*investpb.MarketDataResponse_SubscribeLastPriceResponse)
+		if !ok {
+			return "", fmt.Errorf("unexpected response type: %T", mdResp.Payload)
+		}
+
+		resp := lastPriceResp.SubscribeLastPriceResponse
+
+		statuses := make(map[string]investpb.SubscriptionStatus, len(resp.LastPriceSubscriptions))
+		for _, s := range resp.LastPriceSubscriptions {
+			statuses[s.Figi] = s.SubscriptionStatus
+		}
+		if err := checkSubscriptionStatuses(resp.TrackingId, requested, statuses); err != nil {
+			return "", err
+		}
+		return resp.TrackingId, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Listen prices.
+
+	prices := make(chan LastPrice, mdEventsBufferSize)
+	go func() {
+		defer close(prices)
+
+		listenMarketData(stream, logger, func(resp *investpb.MarketDataResponse) bool {
+			v, ok := resp.Payload.(*investpb.MarketDataResponse_LastPrice)
+			if !ok {
+				return true
+			}
+
+			select {
+			case <-ctx.Done():
+				return false
+			case prices <- adaptPbLastPrice(v.LastPrice):
+			}
+			return true
+		})
+	}()
+	return prices, nil
+}
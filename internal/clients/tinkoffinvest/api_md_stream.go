@@ -0,0 +1,296 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+const mdEventsBufferSize = 128
+
+// MarketDataStream is a single market data stream with dynamic subscriptions management.
+type MarketDataStream interface {
+	// Subscribe asynchronously subscribes for the instruments.
+	// Failed subscriptions are logged only.
+	Subscribe(s MarketDataSubscription) error
+	// Unsubscribe asynchronously unsubscribes from the instruments.
+	Unsubscribe(s MarketDataSubscription) error
+	// Events returns market data flow. The channel is closed on stream error or context cancellation.
+	Events() <-chan MarketDataEvent
+	// Err returns the reason of the stream closing. Valid after the Events channel is closed.
+	Err() error
+}
+
+// MarketDataSubscription describes instruments for the stream subscription. Empty lists are ignored.
+type MarketDataSubscription struct {
+	OrderBooks      []OrderBookRequest
+	Candles         []CandleRequest
+	Trades          []FIGI
+	LastPrices      []FIGI
+	TradingStatuses []FIGI
+}
+
+func (s MarketDataSubscription) IsEmpty() bool {
+	return len(s.OrderBooks) == 0 &&
+		len(s.Candles) == 0 &&
+		len(s.Trades) == 0 &&
+		len(s.LastPrices) == 0 &&
+		len(s.TradingStatuses) == 0
+}
+
+// MarketDataEvent holds exactly one of the non-nil fields.
+type MarketDataEvent struct {
+	OrderBook     *OrderBookChange
+	Candle        *Candle
+	Trade         *Trade
+	LastPrice     *LastPrice
+	TradingStatus *TradingStatus
+}
+
+// OpenMarketDataStream opens a new stream without subscriptions.
+// The stream lives until the ctx is cancelled or the connection is broken.
+func (c *Client) OpenMarketDataStream(ctx context.Context) (MarketDataStream, error) {
+	stream, err := c.marketDataStream.MarketDataStream(c.auth(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("start grpc stream: %w", err)
+	}
+
+	s := &mdStream{
+		stream: stream,
+		events: make(chan MarketDataEvent, mdEventsBufferSize),
+		logger: log.With().Str("component", "market-data-stream").Logger(),
+	}
+	go s.listen(ctx)
+	return s, nil
+}
+
+type mdStream struct {
+	stream marketDataStream
+	sendMu sync.Mutex
+
+	events chan MarketDataEvent
+	err    error
+	logger zerolog.Logger
+}
+
+func (s *mdStream) Subscribe(sub MarketDataSubscription) error {
+	return s.send(sub, investpb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE)
+}
+
+func (s *mdStream) Unsubscribe(sub MarketDataSubscription) error {
+	return s.send(sub, investpb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE)
+}
+
+func (s *mdStream) Events() <-chan MarketDataEvent {
+	return s.events
+}
+
+func (s *mdStream) Err() error {
+	return s.err
+}
+
+func (s *mdStream) send(sub MarketDataSubscription, action investpb.SubscriptionAction) error {
+	reqs, err := adaptMarketDataSubscriptionToPb(sub, action)
+	if err != nil {
+		return err
+	}
+
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	for _, req := range reqs {
+		if err := s.stream.Send(req); err != nil {
+			return fmt.Errorf("send %v request: %w", action, err)
+		}
+	}
+	return nil
+}
+
+func (s *mdStream) listen(ctx context.Context) {
+	defer close(s.events)
+
+	for {
+		resp, err := s.stream.Recv()
+		if err != nil {
+			if ctx.Err() == nil {
+				s.err = fmt.Errorf("recv market data: %w", err)
+			} else {
+				s.err = ctx.Err()
+			}
+			return
+		}
+
+		var event MarketDataEvent
+
+		switch v := resp.Payload.(type) {
+		case *investpb.MarketDataResponse_Ping:
+			s.logger.Debug().Msg("market data stream ping")
+			continue
+
+		case *investpb.MarketDataResponse_SubscribeOrderBookResponse:
+			for _, sub := range v.SubscribeOrderBookResponse.OrderBookSubscriptions {
+				s.logSubscriptionStatus(v.SubscribeOrderBookResponse.TrackingId, "order_book", sub.Figi, sub.SubscriptionStatus)
+			}
+			continue
+
+		case *investpb.MarketDataResponse_SubscribeCandlesResponse:
+			for _, sub := range v.SubscribeCandlesResponse.CandlesSubscriptions {
+				s.logSubscriptionStatus(v.SubscribeCandlesResponse.TrackingId, "candles", sub.Figi, sub.SubscriptionStatus)
+			}
+			continue
+
+		case *investpb.MarketDataResponse_SubscribeTradesResponse:
+			for _, sub := range v.SubscribeTradesResponse.TradeSubscriptions {
+				s.logSubscriptionStatus(v.SubscribeTradesResponse.TrackingId, "trades", sub.Figi, sub.SubscriptionStatus)
+			}
+			continue
+
+		case *investpb.MarketDataResponse_SubscribeLastPriceResponse:
+			for _, sub := range v.SubscribeLastPriceResponse.LastPriceSubscriptions {
+				s.logSubscriptionStatus(v.SubscribeLastPriceResponse.TrackingId, "last_price", sub.Figi, sub.SubscriptionStatus)
+			}
+			continue
+
+		case *investpb.MarketDataResponse_SubscribeInfoResponse:
+			for _, sub := range v.SubscribeInfoResponse.InfoSubscriptions {
+				s.logSubscriptionStatus(v.SubscribeInfoResponse.TrackingId, "trading_status", sub.Figi, sub.SubscriptionStatus)
+			}
+			continue
+
+		case *investpb.MarketDataResponse_Orderbook:
+			ob := adaptPbOrderbook(v.Orderbook)
+			event.OrderBook = &ob
+
+		case *investpb.MarketDataResponse_Candle:
+			c := adaptPbCandle(v.Candle)
+			event.Candle = &c
+
+		case *investpb.MarketDataResponse_Trade:
+			t := adaptPbTrade(v.Trade)
+			event.Trade = &t
+
+		case *investpb.MarketDataResponse_LastPrice:
+			p := adaptPbLastPrice(v.LastPrice)
+			event.LastPrice = &p
+
+		case *investpb.MarketDataResponse_TradingStatus:
+			ts := adaptPbTradingStatus(v.TradingStatus)
+			event.TradingStatus = &ts
+
+		default:
+			s.logger.Warn().Msgf("unexpected market data payload: %T", resp.Payload)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			s.err = ctx.Err()
+			return
+		case s.events <- event:
+		}
+	}
+}
+
+func (s *mdStream) logSubscriptionStatus(trackingID, kind, figi string, status investpb.SubscriptionStatus) {
+	if err := checkSubscriptionStatus(trackingID, figi, status); err != nil {
+		s.logger.Warn().Str("kind", kind).Err(err).Msg("subscription failed")
+		return
+	}
+	s.logger.Debug().Str("tracking_id", trackingID).Str("kind", kind).Str("figi", figi).Msg("subscription changed")
+}
+
+func adaptMarketDataSubscriptionToPb(
+	sub MarketDataSubscription,
+	action investpb.SubscriptionAction,
+) ([]*investpb.MarketDataRequest, error) {
+	var reqs []*investpb.MarketDataRequest
+
+	if len(sub.OrderBooks) > 0 {
+		instruments := make([]*investpb.OrderBookInstrument, len(sub.OrderBooks))
+		for i, req := range sub.OrderBooks {
+			instruments[i] = &investpb.OrderBookInstrument{
+				Figi:  req.FIGI.S(),
+				Depth: int32(req.Depth), // Overflow impossible.
+			}
+		}
+		reqs = append(reqs, &investpb.MarketDataRequest{
+			Payload: &investpb.MarketDataRequest_SubscribeOrderBookRequest{
+				SubscribeOrderBookRequest: &investpb.SubscribeOrderBookRequest{
+					SubscriptionAction: action,
+					Instruments:        instruments,
+				},
+			},
+		})
+	}
+
+	if len(sub.Candles) > 0 {
+		instruments := make([]*investpb.CandleInstrument, len(sub.Candles))
+		for i, req := range sub.Candles {
+			interval, err := adaptCandleIntervalToPbSubscription(req.Interval)
+			if err != nil {
+				return nil, fmt.Errorf("figi %v: %w", req.FIGI, err)
+			}
+			instruments[i] = &investpb.CandleInstrument{Figi: req.FIGI.S(), Interval: interval}
+		}
+		reqs = append(reqs, &investpb.MarketDataRequest{
+			Payload: &investpb.MarketDataRequest_SubscribeCandlesRequest{
+				SubscribeCandlesRequest: &investpb.SubscribeCandlesRequest{
+					SubscriptionAction: action,
+					Instruments:        instruments,
+				},
+			},
+		})
+	}
+
+	if len(sub.Trades) > 0 {
+		instruments := make([]*investpb.TradeInstrument, len(sub.Trades))
+		for i, f := range sub.Trades {
+			instruments[i] = &investpb.TradeInstrument{Figi: f.S()}
+		}
+		reqs = append(reqs, &investpb.MarketDataRequest{
+			Payload: &investpb.MarketDataRequest_SubscribeTradesRequest{
+				SubscribeTradesRequest: &investpb.SubscribeTradesRequest{
+					SubscriptionAction: action,
+					Instruments:        instruments,
+				},
+			},
+		})
+	}
+
+	if len(sub.LastPrices) > 0 {
+		instruments := make([]*investpb.LastPriceInstrument, len(sub.LastPrices))
+		for i, f := range sub.LastPrices {
+			instruments[i] = &investpb.LastPriceInstrument{Figi: f.S()}
+		}
+		reqs = append(reqs, &investpb.MarketDataRequest{
+			Payload: &investpb.MarketDataRequest_SubscribeLastPriceRequest{
+				SubscribeLastPriceRequest: &investpb.SubscribeLastPriceRequest{
+					SubscriptionAction: action,
+					Instruments:        instruments,
+				},
+			},
+		})
+	}
+
+	if len(sub.TradingStatuses) > 0 {
+		instruments := make([]*investpb.InfoInstrument, len(sub.TradingStatuses))
+		for i, f := range sub.TradingStatuses {
+			instruments[i] = &investpb.InfoInstrument{Figi: f.S()}
+		}
+		reqs = append(reqs, &investpb.MarketDataRequest{
+			Payload: &investpb.MarketDataRequest_SubscribeInfoRequest{
+				SubscribeInfoRequest: &investpb.SubscribeInfoRequest{
+					SubscriptionAction: action,
+					Instruments:        instruments,
+				},
+			},
+		})
+	}
+
+	return reqs, nil
+}
@@ -0,0 +1,132 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+// operationsPageSpan limits the time range of one request, because the API returns the whole range at once.
+const operationsPageSpan = 7 * 24 * time.Hour
+
+type OperationKind int
+
+const (
+	OperationKindOther OperationKind = iota
+	OperationKindTrade
+	OperationKindCommission
+	OperationKindDividend
+	OperationKindTax
+	OperationKindPayIn
+)
+
+func (k OperationKind) String() string {
+	switch k {
+	case OperationKindTrade:
+		return "trade"
+	case OperationKindCommission:
+		return "commission"
+	case OperationKindDividend:
+		return "dividend"
+	case OperationKindTax:
+		return "tax"
+	case OperationKindPayIn:
+		return "pay_in"
+	case OperationKindOther:
+	}
+	return "other"
+}
+
+// Operation is an executed operation on the account.
+type Operation struct {
+	ID string
+	// ParentID is the trade operation for commissions.
+	ParentID string
+	Kind     OperationKind
+	// Description is the human-readable operation type from the broker.
+	Description string
+	FIGI        FIGI
+	// Direction is specified for trades only.
+	Direction TradeDirection
+	// Payment is negative for write-offs.
+	Payment  decimal.Decimal
+	Currency string
+	// Price is per one instrument, not per lot.
+	Price    decimal.Decimal
+	Quantity int
+	Time     time.Time
+}
+
+type OperationsRequest struct {
+	AccountID AccountID
+	From      time.Time
+	// To is now if zero.
+	To time.Time
+	// FIGI is optional filter.
+	FIGI FIGI
+}
+
+// GetOperations returns executed operations of the account for the time range sorted by time.
+// Long ranges are requested page by page.
+func (c *Client) GetOperations(ctx context.Context, request OperationsRequest) ([]Operation, error) {
+	to := request.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if !request.From.Before(to) {
+		return nil, errors.New("empty time range")
+	}
+
+	var operations []Operation
+	for from := request.From; from.Before(to); from = from.Add(operationsPageSpan) {
+		pageTo := from.Add(operationsPageSpan)
+		if pageTo.After(to) {
+			pageTo = to
+		}
+
+		page, err := c.getOperationsPage(ctx, &investpb.OperationsRequest{
+			AccountId: request.AccountID.S(),
+			From:      timestamppb.New(from),
+			To:        timestamppb.New(pageTo),
+			State:     investpb.OperationState_OPERATION_STATE_EXECUTED,
+			Figi:      request.FIGI.S(),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range page {
+			operations = append(operations, adaptPbOperation(o))
+		}
+	}
+
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].Time.Before(operations[j].Time)
+	})
+	return operations, nil
+}
+
+func (c *Client) getOperationsPage(ctx context.Context, req *investpb.OperationsRequest) ([]*investpb.Operation, error) {
+	ctx = c.auth(ctx)
+
+	var (
+		resp *investpb.OperationsResponse
+		err  error
+	)
+	if c.useSandbox {
+		resp, err = c.sandbox.GetSandboxOperations(ctx, req)
+	} else {
+		resp, err = c.operations.GetOperations(ctx, req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("grpc get operations call: %w", err)
+	}
+	return resp.Operations, nil
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/rs/zerolog/log"
 	"github.com/shopspring/decimal"
 
 	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
@@ -39,7 +38,7 @@ func (c *Client) GetOrderBook(ctx context.Context, req OrderBookRequest) (*Order
 		Depth: int32(req.Depth),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("grpc get order book call: %v", err)
+		return nil, fmt.Errorf("grpc get order book call: %w", err)
 	}
 
 	return &OrderBookResponse{
@@ -56,6 +55,8 @@ func (c *Client) GetOrderBook(ctx context.Context, req OrderBookRequest) (*Order
 
 type OrderBookChange struct {
 	OrderBook
+	// Depth is the subscription depth.
+	Depth        int
 	IsConsistent bool
 	FormedAt     time.Time
 }
@@ -66,13 +67,6 @@ type Order struct {
 }
 
 func (c *Client) SubscribeForOrderBookChanges(ctx context.Context, reqs []OrderBookRequest) (<-chan OrderBookChange, error) {
-	stream, err := c.marketDataStream.MarketDataStream(c.auth(ctx))
-	if err != nil {
-		return nil, fmt.Errorf("start grpc stream: %v", err)
-	}
-
-	// Send initial request.
-
 	instruments := make([]*investpb.OrderBookInstrument, len(reqs))
 	for i, req := range reqs {
 		instruments[i] = &investpb.OrderBookInstrument{
@@ -82,48 +76,37 @@ func (c *Client) SubscribeForOrderBookChanges(ctx context.Context, reqs []OrderB
 	}
 
 	// TODO(a.telyshev): Send unsubscribe request in defer?
-	if err := stream.Send(&investpb.MarketDataRequest{
+	stream, logger, err := c.subscribeMarketData(ctx, &investpb.MarketDataRequest{
 		Payload: &investpb.MarketDataRequest_SubscribeOrderBookRequest{
 			SubscribeOrderBookRequest: &investpb.SubscribeOrderBookRequest{
 				SubscriptionAction: investpb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE,
 				Instruments:        instruments,
 			},
 		},
-	}); err != nil {
-		return nil, fmt.Errorf("send initial request: %v", err)
-	}
-
-	// Receive and validate initial response.
-
-	mdResp, err := stream.Recv()
-	if err != nil {
-		return nil, fmt.Errorf("recv initial response: %v", err)
-	}
-
-	orderBookResp, ok := mdResp.Payload.(*investpb.MarketDataResponse_SubscribeOrderBookResponse)
-	if !ok {
-		return nil, fmt.Errorf("unexpected response type: %T", mdResp.Payload)
-	}
-
-	resp := orderBookResp.SubscribeOrderBookResponse
-	logger := log.With().Str("tracking_id", resp.TrackingId).Logger()
+	}, func(mdResp *investpb.MarketDataResponse) (string, error) {
+		orderBookResp, ok := mdResp.Payload.(*investpb.MarketDataResponse_SubscribeOrderBookResponse)
+		if !ok {
+			return "", fmt.Errorf("unexpected response type: %T", mdResp.Payload)
+		}
 
-	subsMap := make(map[string]*investpb.OrderBookSubscription)
-	for _, s := range resp.OrderBookSubscriptions {
-		subsMap[s.Figi] = s
-	}
+		resp := orderBookResp.SubscribeOrderBookResponse
 
-	for _, instrument := range instruments {
-		s, ok := subsMap[instrument.Figi]
-		if !ok {
-			return nil, fmt.Errorf(
-				"tid %v: figi: %v: no response for requested instrument", resp.TrackingId, instrument.Figi)
+		statuses := make(map[string]investpb.SubscriptionStatus, len(resp.OrderBookSubscriptions))
+		for _, s := range resp.OrderBookSubscriptions {
+			statuses[s.Figi] = s.SubscriptionStatus
 		}
 
-		if status := s.SubscriptionStatus; status != investpb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
-			return nil, fmt.Errorf(
-				"tid %v: figi: %v: unexpected subscription status: %v", resp.TrackingId, instrument.Figi, status)
+		figis := make([]string, len(instruments))
+		for i, instrument := range instruments {
+			figis[i] = instrument.Figi
+		}
+		if err := checkSubscriptionStatuses(resp.TrackingId, figis, statuses); err != nil {
+			return "", err
 		}
+		return resp.TrackingId, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Listen changes.
@@ -132,29 +115,21 @@ func (c *Client) SubscribeForOrderBookChanges(ctx context.Context, reqs []OrderB
 	go func() {
 		defer close(changes)
 
-		for {
-			resp, err := stream.Recv()
-			if err != nil {
-				if stream.Context().Err() == nil {
-					logger.Err(err).Msg("recv order book change error")
-				}
-				return
+		listenMarketData(stream, logger, func(resp *investpb.MarketDataResponse) bool {
+			v, ok := resp.Payload.(*investpb.MarketDataResponse_Orderbook)
+			if !ok {
+				return true
 			}
 
-			switch v := resp.Payload.(type) {
-			case *investpb.MarketDataResponse_Ping:
-				logger.Debug().Msg("order book stream ping")
-
-			case *investpb.MarketDataResponse_Orderbook:
-				select {
-				case <-ctx.Done():
-					return
-				case changes <- adaptPbOrderbook(v.Orderbook):
-				default:
-					// Clients may not have time to process the queue.
-				}
+			select {
+			case <-ctx.Done():
+				return false
+			case changes <- adaptPbOrderbook(v.Orderbook):
+			default:
+				// Clients may not have time to process the queue.
 			}
-		}
+			return true
+		})
 	}()
 	return changes, nil
 }
@@ -22,7 +22,7 @@ func (c *Client) CancelOrder(ctx context.Context, accountID AccountID, orderID O
 		_, err = c.orders.CancelOrder(ctx, req)
 	}
 	if err != nil {
-		return fmt.Errorf("grpc cancel order call: %v", err)
+		return fmt.Errorf("grpc cancel order call: %w", err)
 	}
 	return nil
 }
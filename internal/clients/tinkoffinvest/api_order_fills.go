@@ -0,0 +1,396 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+const (
+	pollOrderFillsInterval     = 500 * time.Millisecond
+	tradesStreamReconnectDelay = time.Second
+	// maxOrderStateFailures stops polling of the order whose state cannot be got for 10 seconds.
+	maxOrderStateFailures = 20
+)
+
+// OrderFill is a trade (possibly partial) executed by the account order.
+type OrderFill struct {
+	AccountID AccountID
+	OrderID   OrderID
+	FIGI      FIGI
+	Direction TradeDirection
+	// Price is per one instrument, not per lot.
+	Price decimal.Decimal
+	Lots  int
+	Time  time.Time
+}
+
+// SubscribeForOrderFills streams trades of the accounts orders.
+//
+// OrdersStreamService is not working in sandbox, so there the fills are found by polling
+// the state of orders active at the subscription (e.g. adopted after restart)
+// and orders placed via this client after it.
+// In the real mode the broken stream is reopened, then the fills of orders placed via this client
+// during reconnection are found by their state. The stream has no trade IDs, so its fills are limited
+// by the lots left in the order, but a partial fill executed while the stream is reopened could be reported twice.
+func (c *Client) SubscribeForOrderFills(ctx context.Context, accounts []AccountID) (<-chan OrderFill, error) {
+	if len(accounts) == 0 {
+		return nil, errors.New("no accounts to subscribe")
+	}
+
+	fills := make(chan OrderFill)
+
+	if c.useSandbox {
+		p := newOrderFillsPoller(accounts)
+		c.addFillsPoller(p)
+
+		go func() {
+			defer close(fills)
+			defer c.removeFillsPoller(p)
+
+			c.pollOrderFills(ctx, p, fills)
+		}()
+		return fills, nil
+	}
+
+	stream, err := c.openTradesStream(ctx, accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newOrderFillsPoller(accounts)
+	p.forgetRecovered()
+	c.addFillsPoller(p)
+
+	go func() {
+		defer close(fills)
+		defer c.removeFillsPoller(p)
+
+		logger := log.With().Str("component", "trades-stream").Logger()
+		for {
+			err := listenTradesStream(ctx, stream, p, logger, fills)
+			if ctx.Err() != nil {
+				return
+			}
+
+			for {
+				logger.Err(err).Msg("trades stream is lost, reconnect")
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(tradesStreamReconnectDelay):
+				}
+
+				if stream, err = c.openTradesStream(ctx, accounts); err == nil {
+					break
+				}
+			}
+
+			// The new stream has no trades executed during reconnection.
+			p.forgetRecovered()
+			if !c.pollTrackedOrders(ctx, p, logger, fills) {
+				return
+			}
+		}
+	}()
+	return fills, nil
+}
+
+func (c *Client) openTradesStream(
+	ctx context.Context,
+	accounts []AccountID,
+) (investpb.OrdersStreamService_TradesStreamClient, error) {
+	ids := make([]string, len(accounts))
+	for i, a := range accounts {
+		ids[i] = a.S()
+	}
+
+	stream, err := c.ordersStream.TradesStream(c.auth(ctx), &investpb.TradesStreamRequest{Accounts: ids})
+	if err != nil {
+		return nil, fmt.Errorf("start grpc trades stream: %w", err)
+	}
+	return stream, nil
+}
+
+func listenTradesStream(
+	ctx context.Context,
+	stream investpb.OrdersStreamService_TradesStreamClient,
+	p *orderFillsPoller,
+	logger zerolog.Logger,
+	fills chan<- OrderFill,
+) error {
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("recv order trades: %w", err)
+		}
+
+		switch v := resp.Payload.(type) {
+		case *investpb.TradesStreamResponse_Ping:
+			logger.Debug().Msg("trades stream ping")
+
+		case *investpb.TradesStreamResponse_OrderTrades:
+			for _, f := range adaptPbOrderTrades(v.OrderTrades) {
+				if f.Lots = p.report(f); f.Lots == 0 {
+					logger.Warn().Str("order_id", f.OrderID.S()).Msg("skip the trade reported after reconnection")
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case fills <- f:
+				}
+			}
+
+		default:
+			logger.Warn().Msgf("unexpected trades stream payload: %T", resp.Payload)
+		}
+	}
+}
+
+// orderFillsPoller tracks orders placed for the accounts and the lots already reported as filled.
+type orderFillsPoller struct {
+	accounts map[AccountID]struct{}
+	// undiscovered are the accounts whose active orders are not tracked yet.
+	undiscovered []AccountID
+
+	mu     sync.Mutex
+	orders map[orderKey]*trackedOrder
+	// recovered are the finished orders whose last lots were found by the state after the stream reconnection,
+	// their trades could still come from the new stream. Nil if the fills are not streamed.
+	recovered map[orderKey]struct{}
+}
+
+type orderKey struct {
+	account AccountID
+	order   OrderID
+}
+
+type trackedOrder struct {
+	reportedLots int
+	// requestedLots are unknown for the orders active at the subscription.
+	requestedLots int
+	// failures are the consecutive failed requests of the order state.
+	failures int
+}
+
+func newOrderFillsPoller(accounts []AccountID) *orderFillsPoller {
+	p := &orderFillsPoller{
+		accounts:     make(map[AccountID]struct{}, len(accounts)),
+		undiscovered: append([]AccountID(nil), accounts...),
+		orders:       make(map[orderKey]*trackedOrder),
+	}
+	for _, a := range accounts {
+		p.accounts[a] = struct{}{}
+	}
+	return p
+}
+
+func (p *orderFillsPoller) track(account AccountID, order OrderID, lots int) {
+	if _, ok := p.accounts[account]; !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.orders[orderKey{account, order}]; !ok {
+		p.orders[orderKey{account, order}] = &trackedOrder{requestedLots: lots}
+	}
+}
+
+// trackActive tracks the order existing before the subscription, its executed lots are considered reported.
+func (p *orderFillsPoller) trackActive(account AccountID, order ActiveOrder) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.orders[orderKey{account, order.ID}]; !ok {
+		p.orders[orderKey{account, order.ID}] = &trackedOrder{
+			reportedLots:  order.LotsExecuted,
+			requestedLots: order.LotsRequested,
+		}
+	}
+}
+
+// tracked returns the reported lots of the tracked orders.
+func (p *orderFillsPoller) tracked() map[orderKey]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	orders := make(map[orderKey]int, len(p.orders))
+	for k, o := range p.orders {
+		orders[k] = o.reportedLots
+	}
+	return orders
+}
+
+func (p *orderFillsPoller) update(k orderKey, reportedLots int, done bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o, ok := p.orders[k]
+	if !ok {
+		return
+	}
+	if done {
+		if p.recovered != nil && reportedLots > o.reportedLots {
+			p.recovered[k] = struct{}{}
+		}
+		delete(p.orders, k)
+		return
+	}
+	o.reportedLots, o.failures = reportedLots, 0
+}
+
+// forgetRecovered is called before the next recovery, the trades of the previous one are received already.
+func (p *orderFillsPoller) forgetRecovered() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.recovered = make(map[orderKey]struct{})
+}
+
+// fail counts the failed request of the order state and stops tracking the order
+// if it is not found or the state cannot be got for long.
+func (p *orderFillsPoller) fail(k orderKey, err error) (forgotten bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o, ok := p.orders[k]
+	if !ok {
+		return false
+	}
+	if o.failures++; o.failures >= maxOrderStateFailures || errors.Is(err, ErrNotFound) {
+		delete(p.orders, k)
+		return true
+	}
+	return false
+}
+
+// report counts the lots of the streamed fill and returns the lots to send, that are limited by the lots left in the order.
+// The fills of untracked orders are sent as is.
+func (p *orderFillsPoller) report(f OrderFill) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k := orderKey{f.AccountID, f.OrderID}
+	if _, ok := p.recovered[k]; ok {
+		return 0
+	}
+	o, ok := p.orders[k]
+	if !ok {
+		return f.Lots
+	}
+
+	lots := f.Lots
+	if o.requestedLots > 0 {
+		if left := o.requestedLots - o.reportedLots; lots > left {
+			lots = left
+		}
+	}
+	if o.reportedLots += lots; o.requestedLots > 0 && o.reportedLots >= o.requestedLots {
+		delete(p.orders, k)
+	}
+	return lots
+}
+
+func (c *Client) addFillsPoller(p *orderFillsPoller) {
+	c.fillsPollersMu.Lock()
+	defer c.fillsPollersMu.Unlock()
+
+	c.fillsPollers[p] = struct{}{}
+}
+
+func (c *Client) removeFillsPoller(p *orderFillsPoller) {
+	c.fillsPollersMu.Lock()
+	defer c.fillsPollersMu.Unlock()
+
+	delete(c.fillsPollers, p)
+}
+
+// trackPlacedOrder makes the order visible for the fills polling.
+func (c *Client) trackPlacedOrder(account AccountID, order OrderID, lots int) {
+	c.fillsPollersMu.Lock()
+	defer c.fillsPollersMu.Unlock()
+
+	for p := range c.fillsPollers {
+		p.track(account, order, lots)
+	}
+}
+
+func (c *Client) pollOrderFills(ctx context.Context, p *orderFillsPoller, fills chan<- OrderFill) {
+	logger := log.With().Str("component", "order-fills-poller").Logger()
+
+	ticker := time.NewTicker(pollOrderFillsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c.discoverActiveOrders(ctx, p, logger)
+		if !c.pollTrackedOrders(ctx, p, logger, fills) {
+			return
+		}
+	}
+}
+
+// pollTrackedOrders sends the lots executed since the last report and forgets the final orders.
+// It returns false if the context is done.
+func (c *Client) pollTrackedOrders(ctx context.Context, p *orderFillsPoller, logger zerolog.Logger, fills chan<- OrderFill) bool {
+	for k, reported := range p.tracked() {
+		state, err := c.getPbOrderState(ctx, k.account, k.order)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
+			}
+			logger.Warn().Str("order_id", k.order.S()).Err(err).Msg("cannot get order state")
+			if p.fail(k, err) {
+				logger.Warn().Str("order_id", k.order.S()).Msg("stop tracking the order fills")
+			}
+			continue
+		}
+
+		for _, f := range adaptPbOrderStateToFills(k.account, state, reported, time.Now()) {
+			select {
+			case <-ctx.Done():
+				return false
+			case fills <- f:
+			}
+		}
+		p.update(k, int(state.LotsExecuted), adaptPbOrderStatus(state.ExecutionReportStatus).IsFinal())
+	}
+	return true
+}
+
+// discoverActiveOrders tracks the orders active at the subscription, retrying for the failed accounts.
+// The poller is added before, so the orders placed in the meantime are tracked from the first lot.
+func (c *Client) discoverActiveOrders(ctx context.Context, p *orderFillsPoller, logger zerolog.Logger) {
+	var failed []AccountID
+	for _, account := range p.undiscovered {
+		orders, err := c.GetOrders(ctx, account)
+		if err != nil {
+			logger.Warn().Str("account", account.S()).Err(err).Msg("cannot get active orders")
+			failed = append(failed, account)
+			continue
+		}
+
+		for _, o := range orders {
+			p.trackActive(account, o)
+		}
+	}
+	p.undiscovered = failed
+}
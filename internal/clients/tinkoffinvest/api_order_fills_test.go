@@ -0,0 +1,279 @@
+package tinkoffinvest //nolint:testpackage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+func TestClient_SubscribeForOrderFills_Sandbox(t *testing.T) {
+	const account = AccountID("account")
+
+	stage := func(units int64, lots int64) *investpb.OrderStage {
+		return &investpb.OrderStage{Price: &investpb.MoneyValue{Currency: "rub", Units: units}, Quantity: lots}
+	}
+
+	sandbox := &sandboxStub{
+		// The order left by the previous run is executed partially.
+		activeOrders: []*investpb.OrderState{{
+			OrderId:       "adopted-order-id",
+			Figi:          "BBG004730N88",
+			Direction:     investpb.OrderDirection_ORDER_DIRECTION_SELL,
+			LotsRequested: 3,
+			LotsExecuted:  1,
+		}},
+		states: map[string]*investpb.OrderState{
+			"adopted-order-id": {
+				OrderId:               "adopted-order-id",
+				Figi:                  "BBG004730N88",
+				Direction:             investpb.OrderDirection_ORDER_DIRECTION_SELL,
+				ExecutionReportStatus: investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL,
+				LotsRequested:         3,
+				LotsExecuted:          3,
+				Stages:                []*investpb.OrderStage{stage(100, 1), stage(101, 2)},
+			},
+			"exchange-order-id": {
+				OrderId:               "exchange-order-id",
+				Figi:                  "BBG004730N88",
+				Direction:             investpb.OrderDirection_ORDER_DIRECTION_BUY,
+				ExecutionReportStatus: investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL,
+				LotsRequested:         2,
+				LotsExecuted:          1,
+				Stages:                []*investpb.OrderStage{stage(99, 1)},
+			},
+		},
+	}
+	c := &Client{
+		useSandbox:   true,
+		sandbox:      sandbox,
+		fillsPollers: make(map[*orderFillsPoller]struct{}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fills, err := c.SubscribeForOrderFills(ctx, []AccountID{account})
+	require.NoError(t, err)
+
+	orderID, err := c.PlaceLimitBuyOrder(ctx, PlaceOrderRequest{
+		AccountID: account,
+		FIGI:      "BBG004730N88",
+		Lots:      2,
+		Price:     decimal.NewFromInt(99),
+	})
+	require.NoError(t, err)
+	require.Equal(t, OrderID("exchange-order-id"), orderID)
+
+	byOrder := make(map[OrderID]OrderFill)
+	for len(byOrder) < 2 {
+		select {
+		case f := <-fills:
+			byOrder[f.OrderID] = f
+		case <-ctx.Done():
+			t.Fatalf("fills are not received: %v", byOrder)
+		}
+	}
+
+	// Only the lots executed after the subscription are reported.
+	adopted := byOrder["adopted-order-id"]
+	assert.Equal(t, TradeDirectionSell, adopted.Direction)
+	assert.Equal(t, 2, adopted.Lots)
+	assert.Equal(t, "101", adopted.Price.String())
+
+	placed := byOrder["exchange-order-id"]
+	assert.Equal(t, TradeDirectionBuy, placed.Direction)
+	assert.Equal(t, 1, placed.Lots)
+	assert.Equal(t, "99", placed.Price.String())
+
+	assert.Equal(t, []string{account.S()}, sandbox.ordersQueried)
+}
+
+func TestClient_pollTrackedOrders_Failures(t *testing.T) {
+	const account = AccountID("account")
+	k := orderKey{account: account, order: "missing-order-id"}
+
+	t.Run("order not found", func(t *testing.T) {
+		c := &Client{useSandbox: true, sandbox: &sandboxStub{}}
+		p := newOrderFillsPoller([]AccountID{account})
+		p.track(account, k.order, 1)
+
+		require.True(t, c.pollTrackedOrders(context.Background(), p, log.Logger, nil))
+		assert.Empty(t, p.tracked())
+	})
+
+	t.Run("state is unavailable for long", func(t *testing.T) {
+		p := newOrderFillsPoller([]AccountID{account})
+		p.track(account, k.order, 1)
+
+		unavailable := newAPIError(status.Error(codes.Unavailable, ""))
+		for i := 1; i < maxOrderStateFailures; i++ {
+			require.False(t, p.fail(k, unavailable))
+		}
+		p.update(k, 0, false) // Success resets the failures.
+		for i := 1; i < maxOrderStateFailures; i++ {
+			require.False(t, p.fail(k, unavailable))
+		}
+		require.True(t, p.fail(k, unavailable))
+		assert.Empty(t, p.tracked())
+	})
+}
+
+func TestClient_SubscribeForOrderFills_Reconnect(t *testing.T) {
+	const account = AccountID("account")
+
+	trade := func(orderID string, units int64, lots int64) *investpb.TradesStreamResponse {
+		return &investpb.TradesStreamResponse{Payload: &investpb.TradesStreamResponse_OrderTrades{
+			OrderTrades: &investpb.OrderTrades{
+				OrderId:   orderID,
+				Direction: investpb.OrderDirection_ORDER_DIRECTION_BUY,
+				Figi:      "BBG004730N88",
+				AccountId: account.S(),
+				Trades: []*investpb.OrderTrade{{
+					DateTime: timestamppb.Now(),
+					Price:    &investpb.Quotation{Units: units},
+					Quantity: lots,
+				}},
+			},
+		}}
+	}
+	stage := func(units int64, lots int64) *investpb.OrderStage {
+		return &investpb.OrderStage{Price: &investpb.MoneyValue{Currency: "rub", Units: units}, Quantity: lots}
+	}
+
+	first, second := make(chan *investpb.TradesStreamResponse, 1), make(chan *investpb.TradesStreamResponse, 2)
+	orders := &ordersStub{states: map[string]*investpb.OrderState{
+		// The last lots are executed while the stream is lost.
+		"exchange-order-id": {
+			OrderId:               "exchange-order-id",
+			Figi:                  "BBG004730N88",
+			Direction:             investpb.OrderDirection_ORDER_DIRECTION_BUY,
+			ExecutionReportStatus: investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL,
+			LotsRequested:         3,
+			LotsExecuted:          3,
+			Stages:                []*investpb.OrderStage{stage(99, 1), stage(100, 1), stage(102, 1)},
+		},
+	}}
+	c := &Client{
+		orders:       orders,
+		ordersStream: &ordersStreamStub{streams: []chan *investpb.TradesStreamResponse{first, second}},
+		fillsPollers: make(map[*orderFillsPoller]struct{}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fills, err := c.SubscribeForOrderFills(ctx, []AccountID{account})
+	require.NoError(t, err)
+
+	orderID, err := c.PlaceLimitBuyOrder(ctx, PlaceOrderRequest{
+		AccountID: account,
+		FIGI:      "BBG004730N88",
+		Lots:      3,
+		Price:     decimal.NewFromInt(100),
+	})
+	require.NoError(t, err)
+
+	first <- trade(orderID.S(), 99, 1)
+	close(first)
+	// The new stream sends the trade executed while it was opened, then the trade of other order.
+	second <- trade(orderID.S(), 102, 1)
+	second <- trade("other-order-id", 101, 1)
+
+	var received []OrderFill
+	for len(received) < 4 {
+		select {
+		case f := <-fills:
+			received = append(received, f)
+		case <-ctx.Done():
+			t.Fatalf("fills are not received: %v", received)
+		}
+	}
+
+	for i, expected := range []struct {
+		orderID OrderID
+		price   string
+		lots    int
+	}{
+		{orderID: orderID, price: "99", lots: 1},
+		// Found by the order state, the same trade from the new stream is skipped.
+		{orderID: orderID, price: "100", lots: 1},
+		{orderID: orderID, price: "102", lots: 1},
+		{orderID: "other-order-id", price: "101", lots: 1},
+	} {
+		assert.Equal(t, expected.orderID, received[i].OrderID)
+		assert.Equal(t, expected.price, received[i].Price.String())
+		assert.Equal(t, expected.lots, received[i].Lots)
+	}
+}
+
+type ordersStub struct {
+	investpb.OrdersServiceClient
+
+	states map[string]*investpb.OrderState // By order ID.
+}
+
+func (s *ordersStub) PostOrder(
+	_ context.Context,
+	_ *investpb.PostOrderRequest,
+	_ ...grpc.CallOption,
+) (*investpb.PostOrderResponse, error) {
+	return &investpb.PostOrderResponse{OrderId: "exchange-order-id"}, nil
+}
+
+func (s *ordersStub) GetOrderState(
+	_ context.Context,
+	req *investpb.GetOrderStateRequest,
+	_ ...grpc.CallOption,
+) (*investpb.OrderState, error) {
+	state, ok := s.states[req.OrderId]
+	if !ok {
+		return nil, newAPIError(status.Error(codes.NotFound, ""))
+	}
+	return state, nil
+}
+
+// ordersStreamStub opens the streams one by one, the stream is broken when its channel is closed.
+type ordersStreamStub struct {
+	investpb.OrdersStreamServiceClient
+
+	streams []chan *investpb.TradesStreamResponse
+}
+
+func (s *ordersStreamStub) TradesStream(
+	_ context.Context,
+	_ *investpb.TradesStreamRequest,
+	_ ...grpc.CallOption,
+) (investpb.OrdersStreamService_TradesStreamClient, error) {
+	if len(s.streams) == 0 {
+		return nil, newAPIError(status.Error(codes.Unavailable, ""))
+	}
+
+	stream := tradesStreamStub{responses: s.streams[0]}
+	s.streams = s.streams[1:]
+	return stream, nil
+}
+
+type tradesStreamStub struct {
+	grpc.ClientStream
+
+	responses chan *investpb.TradesStreamResponse
+}
+
+func (s tradesStreamStub) Recv() (*investpb.TradesStreamResponse, error) {
+	resp, ok := <-s.responses
+	if !ok {
+		return nil, newAPIError(status.Error(codes.Unavailable, ""))
+	}
+	return resp, nil
+}
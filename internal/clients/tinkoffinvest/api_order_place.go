@@ -4,22 +4,61 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"github.com/shopspring/decimal"
-	"google.golang.org/grpc/codes"
 
 	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
 )
 
-var ErrNotEnoughStocks = errors.New("not enough stocks (shares or money)")
+const (
+	orderLookupTimeout = 5 * time.Second
+	// orderLookupClockSkew allows the exchange clock to be behind the local one.
+	orderLookupClockSkew = time.Minute
+)
+
+var errOrderNotFound = errors.New("order not found")
+
+var (
+	// ErrOrderPlacementUnknown means the order could be placed despite the error:
+	// the retries have failed and the order is not found among the active ones.
+	// It could be executed already, so check the positions before placing it again.
+	ErrOrderPlacementUnknown = errors.New("order placement result is unknown")
+)
+
+// placementUnknownError keeps the API error of the placement, it matches ErrOrderPlacementUnknown.
+type placementUnknownError struct {
+	err error
+}
+
+func (e *placementUnknownError) Error() string {
+	return fmt.Sprintf("%v: %v", e.err, ErrOrderPlacementUnknown)
+}
+
+func (e *placementUnknownError) Unwrap() error {
+	return e.err
+}
+
+func (e *placementUnknownError) Is(target error) bool {
+	return target == ErrOrderPlacementUnknown
+}
 
 type PlaceOrderRequest struct {
 	AccountID AccountID
 	FIGI      FIGI
 	Lots      int
 	Price     decimal.Decimal // For limit orders only.
+	// IdempotencyKey identifies the order placement, generated if empty. Max length is 36 symbols.
+	IdempotencyKey string
+}
+
+func (r PlaceOrderRequest) idempotencyKey() string {
+	if r.IdempotencyKey != "" {
+		return r.IdempotencyKey
+	}
+	return uuid.New().String()
 }
 
 func (c *Client) PlaceMarketSellOrder(ctx context.Context, request PlaceOrderRequest) (OrderID, error) {
@@ -29,7 +68,7 @@ func (c *Client) PlaceMarketSellOrder(ctx context.Context, request PlaceOrderReq
 		Direction: investpb.OrderDirection_ORDER_DIRECTION_SELL,
 		AccountId: request.AccountID.S(),
 		OrderType: investpb.OrderType_ORDER_TYPE_MARKET,
-		OrderId:   uuid.New().String(),
+		OrderId:   request.idempotencyKey(),
 	}
 
 	resp, err := c.postPbOrder(ctx, req)
@@ -47,7 +86,7 @@ func (c *Client) PlaceMarketBuyOrder(ctx context.Context, request PlaceOrderRequ
 		Direction: investpb.OrderDirection_ORDER_DIRECTION_BUY,
 		AccountId: request.AccountID.S(),
 		OrderType: investpb.OrderType_ORDER_TYPE_MARKET,
-		OrderId:   uuid.New().String(),
+		OrderId:   request.idempotencyKey(),
 	}
 
 	resp, err := c.postPbOrder(ctx, req)
@@ -70,7 +109,7 @@ func (c *Client) PlaceLimitSellOrder(ctx context.Context, request PlaceOrderRequ
 		Direction: investpb.OrderDirection_ORDER_DIRECTION_SELL,
 		AccountId: request.AccountID.S(),
 		OrderType: investpb.OrderType_ORDER_TYPE_LIMIT,
-		OrderId:   uuid.New().String(),
+		OrderId:   request.idempotencyKey(),
 	}
 	resp, err := c.postPbOrder(ctx, req)
 	if err != nil {
@@ -92,7 +131,7 @@ func (c *Client) PlaceLimitBuyOrder(ctx context.Context, request PlaceOrderReque
 		Direction: investpb.OrderDirection_ORDER_DIRECTION_BUY,
 		AccountId: request.AccountID.S(),
 		OrderType: investpb.OrderType_ORDER_TYPE_LIMIT,
-		OrderId:   uuid.New().String(),
+		OrderId:   request.idempotencyKey(),
 	}
 
 	resp, err := c.postPbOrder(ctx, req)
@@ -103,24 +142,121 @@ func (c *Client) PlaceLimitBuyOrder(ctx context.Context, request PlaceOrderReque
 	return OrderID(resp.OrderId), nil
 }
 
+// postPbOrder retries transient errors with the same idempotency key
+// and looks the order up before giving up.
 func (c *Client) postPbOrder(ctx context.Context, req *investpb.PostOrderRequest) (*investpb.PostOrderResponse, error) {
-	ctx = c.auth(ctx)
-
 	var (
-		resp *investpb.PostOrderResponse
-		err  error
+		resp    *investpb.PostOrderResponse
+		err     error
+		started = time.Now()
 	)
-	if c.useSandbox {
-		resp, err = c.sandbox.PostSandboxOrder(ctx, req)
-	} else {
-		resp, err = c.orders.PostOrder(ctx, req)
+	for attempt := 1; ; attempt++ {
+		resp, err = c.callPostOrder(ctx, req)
+		if err == nil || !isTransientError(err) || ctx.Err() != nil || attempt > c.orderRetries {
+			break
+		}
+
+		orderRetries.Inc()
+		log.Warn().Err(err).
+			Str("idempotency_key", req.OrderId).
+			Int("attempt", attempt).
+			Msg("retry order placement")
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(attempt) * c.orderRetryBackoff):
+		}
+	}
+
+	if err != nil && isTransientError(err) {
+		// The order could reach the exchange despite the error.
+		order, lookupErr := c.findPlacedOrder(req, started)
+		if lookupErr != nil {
+			return nil, &placementUnknownError{err: err}
+		}
+
+		log.Info().
+			Str("idempotency_key", req.OrderId).
+			Str("order_id", order.ID.S()).
+			Msg("order is found after failed placement")
+		resp, err = &investpb.PostOrderResponse{
+			OrderId:               order.ID.S(),
+			ExecutionReportStatus: investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW,
+			LotsRequested:         int64(order.LotsRequested),
+			LotsExecuted:          int64(order.LotsExecuted),
+			Figi:                  order.FIGI.S(),
+			Direction:             req.Direction,
+			OrderType:             req.OrderType,
+		}, nil
 	}
 	if err != nil {
-		if isStatusError(err, codes.InvalidArgument, codeNotEnoughAssetsForMarginTrade) {
+		if errors.Is(err, ErrNotEnoughStocks) {
 			log.Warn().Msg("no money no honey")
-			return nil, fmt.Errorf("%v: %w", err, ErrNotEnoughStocks)
 		}
 		return nil, err
 	}
+
+	c.trackPlacedOrder(AccountID(req.AccountId), OrderID(resp.OrderId), int(req.Quantity))
 	return resp, nil
 }
+
+// findPlacedOrder looks for the active order of the request placed since the start of the placement.
+// The exchange order ID is unknown without the response, so the order is matched by its parameters.
+// A fresh context is used, because the caller's one could be expired already.
+// Executed orders are not active anymore and are not found.
+func (c *Client) findPlacedOrder(req *investpb.PostOrderRequest, started time.Time) (ActiveOrder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), orderLookupTimeout)
+	defer cancel()
+
+	orders, err := c.GetOrders(ctx, AccountID(req.AccountId))
+	if err != nil {
+		return ActiveOrder{}, err
+	}
+
+	var (
+		found   ActiveOrder
+		matches int
+	)
+	for _, o := range orders {
+		if matchesPostOrderRequest(o, req, started.Add(-orderLookupClockSkew)) {
+			found = o
+			matches++
+		}
+	}
+
+	switch matches {
+	case 0:
+		return ActiveOrder{}, errOrderNotFound
+	case 1:
+		return found, nil
+	}
+	return ActiveOrder{}, fmt.Errorf("%d orders match the request", matches)
+}
+
+func matchesPostOrderRequest(o ActiveOrder, req *investpb.PostOrderRequest, since time.Time) bool {
+	if o.FIGI.S() != req.Figi ||
+		o.Direction != adaptPbOrderDirection(req.Direction) ||
+		o.Type != adaptPbOrderType(req.OrderType) ||
+		int64(o.LotsRequested) != req.Quantity ||
+		o.CreatedAt.Before(since) {
+		return false
+	}
+	if req.OrderType == investpb.OrderType_ORDER_TYPE_LIMIT {
+		return o.Price.Equal(adaptPbQuotationToDecimal(req.Price))
+	}
+	return true
+}
+
+func (c *Client) callPostOrder(ctx context.Context, req *investpb.PostOrderRequest) (*investpb.PostOrderResponse, error) {
+	ctx = c.auth(ctx)
+
+	if c.useSandbox {
+		return c.sandbox.PostSandboxOrder(ctx, req)
+	}
+	return c.orders.PostOrder(ctx, req)
+}
+
+// isTransientError reports whether the call could succeed if repeated.
+func isTransientError(err error) bool {
+	return errors.Is(err, ErrUnavailable)
+}
@@ -0,0 +1,188 @@
+package tinkoffinvest //nolint:testpackage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+func TestClient_PlaceLimitBuyOrder_Retries(t *testing.T) {
+	request := PlaceOrderRequest{
+		AccountID:      "account",
+		FIGI:           "BBG004730N88",
+		Lots:           1,
+		Price:          decimal.RequireFromString("120.5"),
+		IdempotencyKey: "key",
+	}
+
+	resting := &investpb.OrderState{
+		OrderId:              "exchange-order-id-from-orders",
+		Figi:                 "BBG004730N88",
+		Direction:            investpb.OrderDirection_ORDER_DIRECTION_BUY,
+		OrderType:            investpb.OrderType_ORDER_TYPE_LIMIT,
+		LotsRequested:        1,
+		InitialSecurityPrice: &investpb.MoneyValue{Currency: "rub", Units: 120, Nano: 500000000},
+		OrderDate:            timestamppb.Now(),
+	}
+	other := proto.Clone(resting).(*investpb.OrderState) //nolint:forcetypeassert
+	other.OrderId = "other-exchange-order-id"
+	other.InitialSecurityPrice = &investpb.MoneyValue{Currency: "rub", Units: 121}
+
+	cases := []struct {
+		name            string
+		postErrs        []error
+		activeOrders    []*investpb.OrderState
+		ordersErr       error
+		expectedID      OrderID
+		expectedErr     error
+		expectedPosts   int
+		expectedLookups []string
+	}{
+		{
+			name:          "success after retries",
+			postErrs:      []error{status.Error(codes.Unavailable, ""), status.Error(codes.DeadlineExceeded, "")},
+			expectedID:    "exchange-order-id",
+			expectedPosts: 3,
+		},
+		{
+			name: "order found after retries",
+			postErrs: []error{
+				status.Error(codes.Unavailable, ""),
+				status.Error(codes.Unavailable, ""),
+				status.Error(codes.Unavailable, ""),
+			},
+			activeOrders:    []*investpb.OrderState{other, resting},
+			expectedID:      "exchange-order-id-from-orders",
+			expectedPosts:   3,
+			expectedLookups: []string{"account"},
+		},
+		{
+			name: "order not found after retries",
+			postErrs: []error{
+				status.Error(codes.Unavailable, ""),
+				status.Error(codes.Unavailable, ""),
+				status.Error(codes.Unavailable, ""),
+			},
+			activeOrders:    []*investpb.OrderState{other},
+			expectedErr:     ErrOrderPlacementUnknown,
+			expectedPosts:   3,
+			expectedLookups: []string{"account"},
+		},
+		{
+			name: "orders lookup failed after retries",
+			postErrs: []error{
+				status.Error(codes.Unavailable, ""),
+				status.Error(codes.Unavailable, ""),
+				status.Error(codes.Unavailable, ""),
+			},
+			ordersErr:       status.Error(codes.Unavailable, ""),
+			expectedErr:     ErrOrderPlacementUnknown,
+			expectedPosts:   3,
+			expectedLookups: []string{"account"},
+		},
+		{
+			name:          "not transient error",
+			postErrs:      []error{status.Error(codes.InvalidArgument, "30042")},
+			expectedErr:   ErrNotEnoughStocks,
+			expectedPosts: 1,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			sandbox := &sandboxStub{postErrs: tt.postErrs, activeOrders: tt.activeOrders, ordersErr: tt.ordersErr}
+			c := &Client{
+				useSandbox:   true,
+				sandbox:      sandbox,
+				orderRetries: 2,
+			}
+
+			orderID, err := c.PlaceLimitBuyOrder(context.Background(), request)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+
+				var apiErr *APIError
+				require.ErrorAs(t, err, &apiErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedID, orderID)
+
+			require.Len(t, sandbox.posted, tt.expectedPosts)
+			for _, req := range sandbox.posted {
+				assert.Equal(t, "key", req.OrderId)
+			}
+			// The idempotency key is not the exchange order ID, so the orders are looked up by the account.
+			assert.Equal(t, tt.expectedLookups, sandbox.ordersQueried)
+		})
+	}
+}
+
+func TestPlaceOrderRequest_idempotencyKey(t *testing.T) {
+	assert.Equal(t, "key", PlaceOrderRequest{IdempotencyKey: "key"}.idempotencyKey())
+
+	k1, k2 := PlaceOrderRequest{}.idempotencyKey(), PlaceOrderRequest{}.idempotencyKey()
+	assert.NotEmpty(t, k1)
+	assert.NotEqual(t, k1, k2)
+}
+
+type sandboxStub struct {
+	investpb.SandboxServiceClient
+
+	postErrs      []error
+	posted        []*investpb.PostOrderRequest
+	activeOrders  []*investpb.OrderState
+	ordersErr     error
+	ordersQueried []string
+	states        map[string]*investpb.OrderState // By order ID.
+}
+
+func (s *sandboxStub) PostSandboxOrder(
+	_ context.Context,
+	req *investpb.PostOrderRequest,
+	_ ...grpc.CallOption,
+) (*investpb.PostOrderResponse, error) {
+	s.posted = append(s.posted, req)
+	if i := len(s.posted) - 1; i < len(s.postErrs) {
+		return nil, newAPIError(s.postErrs[i])
+	}
+	return &investpb.PostOrderResponse{OrderId: "exchange-order-id"}, nil
+}
+
+func (s *sandboxStub) GetSandboxOrders(
+	ctx context.Context,
+	req *investpb.GetOrdersRequest,
+	_ ...grpc.CallOption,
+) (*investpb.GetOrdersResponse, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		panic("orders lookup without deadline")
+	}
+
+	s.ordersQueried = append(s.ordersQueried, req.AccountId)
+	if s.ordersErr != nil {
+		return nil, newAPIError(s.ordersErr)
+	}
+	return &investpb.GetOrdersResponse{Orders: s.activeOrders}, nil
+}
+
+func (s *sandboxStub) GetSandboxOrderState(
+	_ context.Context,
+	req *investpb.GetOrderStateRequest,
+	_ ...grpc.CallOption,
+) (*investpb.OrderState, error) {
+	state, ok := s.states[req.OrderId]
+	if !ok {
+		return nil, newAPIError(status.Error(codes.NotFound, ""))
+	}
+	return state, nil
+}
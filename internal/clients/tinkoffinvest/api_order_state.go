@@ -14,37 +14,127 @@ import (
 const pollOrderStateInterval = 100 * time.Millisecond
 
 var (
-	ErrOrderWaitExecution = errors.New("order is waiting for execution")
-	ErrOrderRejected      = errors.New("order rejected")
-	ErrOrderCancelled     = errors.New("order cancelled by user")
+	ErrOrderRejected  = errors.New("order rejected")
+	ErrOrderCancelled = errors.New("order cancelled by user")
 )
 
+type OrderStatus int
+
+const (
+	OrderStatusUnspecified OrderStatus = iota
+	OrderStatusNew
+	OrderStatusPartiallyFilled
+	OrderStatusFilled
+	OrderStatusRejected
+	OrderStatusCancelled
+)
+
+func (s OrderStatus) String() string {
+	switch s {
+	case OrderStatusNew:
+		return "new"
+	case OrderStatusPartiallyFilled:
+		return "partially_filled"
+	case OrderStatusFilled:
+		return "filled"
+	case OrderStatusRejected:
+		return "rejected"
+	case OrderStatusCancelled:
+		return "cancelled"
+	case OrderStatusUnspecified:
+	}
+	return "unspecified"
+}
+
+// IsFinal reports whether the order will not be executed anymore.
+func (s OrderStatus) IsFinal() bool {
+	switch s {
+	case OrderStatusFilled, OrderStatusRejected, OrderStatusCancelled:
+		return true
+	case OrderStatusUnspecified, OrderStatusNew, OrderStatusPartiallyFilled:
+	}
+	return false
+}
+
+type OrderState struct {
+	ID            OrderID
+	FIGI          FIGI
+	Type          OrderType
+	Direction     TradeDirection
+	Status        OrderStatus
+	LotsRequested int
+	LotsExecuted  int
+	// InitialPrice is the requested price of one instrument.
+	InitialPrice decimal.Decimal
+	// AveragePrice is the average executed price of one instrument, zero if nothing is executed.
+	AveragePrice decimal.Decimal
+	// ExecutedAmount is the cost of executed lots.
+	ExecutedAmount decimal.Decimal
+	// ExecutedCommission is zero until the order is executed.
+	ExecutedCommission decimal.Decimal
+	Currency           string
+	Stages             []OrderStage
+	CreatedAt          time.Time
+}
+
+// OrderStage is a trade executed by the order.
+type OrderStage struct {
+	TradeID string
+	// Price is per one instrument.
+	Price decimal.Decimal
+	Lots  int
+}
+
+// LotsLeft returns the lots not executed yet.
+func (s *OrderState) LotsLeft() int {
+	if left := s.LotsRequested - s.LotsExecuted; left > 0 {
+		return left
+	}
+	return 0
+}
+
 // WaitForOrderExecution allows waiting order execution via simple polling with GetOrderState method.
 // Useful because investpb.OrdersStreamServiceClient is not working in sandbox.
-func (c *Client) WaitForOrderExecution(ctx context.Context, accountID AccountID, orderID OrderID) (decimal.Decimal, error) {
+// ErrOrderRejected or ErrOrderCancelled is returned along with the state if the order is not filled completely.
+func (c *Client) WaitForOrderExecution(ctx context.Context, accountID AccountID, orderID OrderID) (*OrderState, error) {
 	for {
 		select {
 		case <-ctx.Done():
-			return decimal.Zero, ctx.Err()
+			return nil, ctx.Err()
 
 		case <-time.After(pollOrderStateInterval):
-			price, err := c.GetOrderState(ctx, accountID, orderID)
+			state, err := c.GetOrderState(ctx, accountID, orderID)
 			if err != nil {
-				if errors.Is(err, ErrOrderWaitExecution) {
-					continue
-				}
-				return decimal.Zero, err
+				return nil, err
+			}
+
+			switch state.Status {
+			case OrderStatusFilled:
+				return state, nil
+			case OrderStatusRejected:
+				return state, ErrOrderRejected
+			case OrderStatusCancelled:
+				return state, ErrOrderCancelled
+			case OrderStatusUnspecified, OrderStatusNew, OrderStatusPartiallyFilled:
 			}
-			return price, nil
 		}
 	}
 }
 
-// GetOrderState returns executed price if the order was executed and one of errors otherwise:
-// - ErrOrderWaitExecution
-// - ErrOrderRejected
-// - ErrOrderCancelled.
-func (c *Client) GetOrderState(ctx context.Context, accountID AccountID, orderID OrderID) (decimal.Decimal, error) {
+func (c *Client) GetOrderState(ctx context.Context, accountID AccountID, orderID OrderID) (*OrderState, error) {
+	resp, err := c.getPbOrderState(ctx, accountID, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	state := adaptPbOrderState(resp)
+	if state.Status == OrderStatusUnspecified {
+		return nil, fmt.Errorf("unexpected order status: %d", resp.ExecutionReportStatus)
+	}
+	return &state, nil
+}
+
+func (c *Client) getPbOrderState(ctx context.Context, accountID AccountID, orderID OrderID) (*investpb.OrderState, error) {
 	ctx = c.auth(ctx)
 
 	req := &investpb.GetOrderStateRequest{
@@ -62,27 +152,7 @@ func (c *Client) GetOrderState(ctx context.Context, accountID AccountID, orderID
 		resp, err = c.orders.GetOrderState(ctx, req)
 	}
 	if err != nil {
-		return decimal.Zero, fmt.Errorf("grpc get order state call: %v", err)
-	}
-
-	switch s := resp.ExecutionReportStatus; s {
-	case investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL:
-		return newDecimal(
-			resp.ExecutedOrderPrice.Units,
-			resp.ExecutedOrderPrice.Nano,
-		), nil
-
-	case investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW,
-		investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL:
-		return decimal.Zero, ErrOrderWaitExecution
-
-	case investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED:
-		return decimal.Zero, ErrOrderRejected
-
-	case investpb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED:
-		return decimal.Zero, ErrOrderCancelled
-
-	default:
-		return decimal.Zero, fmt.Errorf("unexpected order status: %d", s)
+		return nil, fmt.Errorf("grpc get order state call: %w", err)
 	}
+	return resp, nil
 }
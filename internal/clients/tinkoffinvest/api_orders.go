@@ -0,0 +1,71 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+type OrderType int
+
+const (
+	OrderTypeUnspecified OrderType = iota
+	OrderTypeLimit
+	OrderTypeMarket
+)
+
+func (t OrderType) String() string {
+	switch t {
+	case OrderTypeLimit:
+		return "limit"
+	case OrderTypeMarket:
+		return "market"
+	case OrderTypeUnspecified:
+	}
+	return "unspecified"
+}
+
+// ActiveOrder is an order waiting for execution.
+type ActiveOrder struct {
+	ID            OrderID
+	FIGI          FIGI
+	Type          OrderType
+	Direction     TradeDirection
+	LotsRequested int
+	LotsExecuted  int
+	// Price is the initial price of one instrument.
+	Price     decimal.Decimal
+	CreatedAt time.Time
+}
+
+// GetOrders returns active orders of the account.
+func (c *Client) GetOrders(ctx context.Context, accountID AccountID) ([]ActiveOrder, error) {
+	ctx = c.auth(ctx)
+
+	req := &investpb.GetOrdersRequest{
+		AccountId: accountID.S(),
+	}
+
+	var (
+		resp *investpb.GetOrdersResponse
+		err  error
+	)
+	if c.useSandbox {
+		resp, err = c.sandbox.GetSandboxOrders(ctx, req)
+	} else {
+		resp, err = c.orders.GetOrders(ctx, req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("grpc get orders call: %w", err)
+	}
+
+	orders := make([]ActiveOrder, len(resp.Orders))
+	for i, o := range resp.Orders {
+		orders[i] = adaptPbActiveOrder(o)
+	}
+	return orders, nil
+}
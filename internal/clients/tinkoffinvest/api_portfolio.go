@@ -9,17 +9,17 @@ import (
 	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
 )
 
-type instrumentType string
-
-const instrumentTypeShares instrumentType = "share"
-
 type Portfolio struct {
 	TotalSharesPrice decimal.Decimal
 	Shares           []PortfolioPosition
+	// Positions are of all instrument types, including shares and currencies.
+	Positions []PortfolioPosition
 }
 
 type PortfolioPosition struct {
-	FIGI     FIGI
+	FIGI FIGI
+	// Type is empty if unknown.
+	Type     InstrumentType
 	Quantity int
 	AvgPrice decimal.Decimal
 }
@@ -41,22 +41,27 @@ func (c *Client) GetPortfolio(ctx context.Context, accountID AccountID) (*Portfo
 		resp, err = c.operations.GetPortfolio(ctx, req)
 	}
 	if err != nil {
-		return nil, fmt.Errorf("grpc get portfolio call: %v", err)
+		return nil, fmt.Errorf("grpc get portfolio call: %w", err)
 	}
 
+	positions := make([]PortfolioPosition, 0, len(resp.Positions))
 	shares := make([]PortfolioPosition, 0, len(resp.Positions))
 	for _, p := range resp.Positions {
-		if instrumentType(p.InstrumentType) == instrumentTypeShares {
-			shares = append(shares, PortfolioPosition{
-				FIGI:     FIGI(p.Figi),
-				Quantity: int(adaptPbQuotationToDecimal(p.Quantity).IntPart()),
-				AvgPrice: newDecimal(p.AveragePositionPrice.Units, p.AveragePositionPrice.Nano),
-			})
+		pos := PortfolioPosition{
+			FIGI:     FIGI(p.Figi),
+			Type:     InstrumentType(p.InstrumentType),
+			Quantity: int(adaptPbQuotationToDecimal(p.Quantity).IntPart()),
+			AvgPrice: adaptPbMoneyValueToDecimal(p.AveragePositionPrice),
+		}
+		positions = append(positions, pos)
+		if pos.Type == InstrumentTypeShare {
+			shares = append(shares, pos)
 		}
 	}
 
 	return &Portfolio{
 		TotalSharesPrice: newDecimal(resp.TotalAmountShares.Units, resp.TotalAmountShares.Nano),
 		Shares:           shares,
+		Positions:        positions,
 	}, nil
 }
@@ -0,0 +1,50 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+type Positions struct {
+	// Money is available money by currency.
+	Money map[string]decimal.Decimal
+	// BlockedMoney is money reserved by active orders by currency.
+	BlockedMoney map[string]decimal.Decimal
+	Securities   []SecurityPosition
+}
+
+type SecurityPosition struct {
+	FIGI FIGI
+	// Balance is the amount of available instruments.
+	Balance int
+	// Blocked is the amount of instruments reserved by active orders.
+	Blocked int
+}
+
+// GetPositions returns money and securities of the account.
+func (c *Client) GetPositions(ctx context.Context, accountID AccountID) (*Positions, error) {
+	ctx = c.auth(ctx)
+
+	req := &investpb.PositionsRequest{
+		AccountId: accountID.S(),
+	}
+
+	var (
+		resp *investpb.PositionsResponse
+		err  error
+	)
+	if c.useSandbox {
+		resp, err = c.sandbox.GetSandboxPositions(ctx, req)
+	} else {
+		resp, err = c.operations.GetPositions(ctx, req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("grpc get positions call: %w", err)
+	}
+
+	return adaptPbPositions(resp), nil
+}
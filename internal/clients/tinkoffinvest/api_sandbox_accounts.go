@@ -0,0 +1,59 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+// OpenSandboxAccount opens new sandbox account with zero balance.
+func (c *Client) OpenSandboxAccount(ctx context.Context) (AccountID, error) {
+	resp, err := c.sandbox.OpenSandboxAccount(c.auth(ctx), new(investpb.OpenSandboxAccountRequest))
+	if err != nil {
+		return "", fmt.Errorf("grpc open sandbox account call: %w", err)
+	}
+	return AccountID(resp.AccountId), nil
+}
+
+// GetSandboxAccounts returns sandbox accounts of the token owner.
+func (c *Client) GetSandboxAccounts(ctx context.Context) ([]Account, error) {
+	resp, err := c.sandbox.GetSandboxAccounts(c.auth(ctx), new(investpb.GetAccountsRequest))
+	if err != nil {
+		return nil, fmt.Errorf("grpc get sandbox accounts call: %w", err)
+	}
+
+	accounts := make([]Account, len(resp.Accounts))
+	for i, a := range resp.Accounts {
+		accounts[i] = adaptPbAccount(a)
+	}
+	return accounts, nil
+}
+
+func (c *Client) CloseSandboxAccount(ctx context.Context, accountID AccountID) error {
+	_, err := c.sandbox.CloseSandboxAccount(c.auth(ctx), &investpb.CloseSandboxAccountRequest{
+		AccountId: accountID.S(),
+	})
+	if err != nil {
+		return fmt.Errorf("grpc close sandbox account call: %w", err)
+	}
+	return nil
+}
+
+// SandboxPayIn tops up the sandbox account with RUB and returns the new balance.
+func (c *Client) SandboxPayIn(ctx context.Context, accountID AccountID, amount decimal.Decimal) (decimal.Decimal, error) {
+	if !amount.IsPositive() {
+		return decimal.Zero, fmt.Errorf("invalid pay in amount: %s", amount)
+	}
+
+	resp, err := c.sandbox.SandboxPayIn(c.auth(ctx), &investpb.SandboxPayInRequest{
+		AccountId: accountID.S(),
+		Amount:    adaptDecimalToPbMoneyValue(amount, currencyRUB),
+	})
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("grpc sandbox pay in call: %w", err)
+	}
+	return adaptPbMoneyValueToDecimal(resp.Balance), nil
+}
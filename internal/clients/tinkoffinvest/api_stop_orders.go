@@ -0,0 +1,199 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+var ErrStopOrdersInSandbox = errors.New("stop orders are not supported in sandbox")
+
+type StopOrderType int
+
+const (
+	StopOrderTypeUnspecified StopOrderType = iota
+	StopOrderTypeTakeProfit
+	StopOrderTypeStopLoss
+	StopOrderTypeStopLimit
+)
+
+func (t StopOrderType) String() string {
+	switch t {
+	case StopOrderTypeTakeProfit:
+		return "take_profit"
+	case StopOrderTypeStopLoss:
+		return "stop_loss"
+	case StopOrderTypeStopLimit:
+		return "stop_limit"
+	case StopOrderTypeUnspecified:
+	}
+	return "unspecified"
+}
+
+type StopOrderExpiration int
+
+const (
+	// StopOrderGoodTillCancel orders live until the cancellation.
+	StopOrderGoodTillCancel StopOrderExpiration = iota
+	// StopOrderGoodTillDate orders are cancelled by exchange at StopOrderRequest.ExpireAt.
+	StopOrderGoodTillDate
+)
+
+type StopOrderRequest struct {
+	AccountID AccountID
+	FIGI      FIGI
+	Direction TradeDirection
+	Lots      int
+	// StopPrice is the activation price of one instrument.
+	StopPrice  decimal.Decimal
+	Expiration StopOrderExpiration
+	// ExpireAt is required for StopOrderGoodTillDate only.
+	ExpireAt time.Time
+}
+
+type StopLimitOrderRequest struct {
+	StopOrderRequest
+	// Price is the limit order price of one instrument after activation.
+	Price decimal.Decimal
+}
+
+type StopOrder struct {
+	ID        StopOrderID
+	FIGI      FIGI
+	Type      StopOrderType
+	Direction TradeDirection
+	Lots      int
+	Currency  string
+	// Price is zero for market orders after activation.
+	Price     decimal.Decimal
+	StopPrice decimal.Decimal
+	CreatedAt time.Time
+	// ExpireAt is zero for good-till-cancel orders.
+	ExpireAt time.Time
+}
+
+// PlaceStopLoss places the order that becomes the market order when the stop price is reached.
+func (c *Client) PlaceStopLoss(ctx context.Context, request StopOrderRequest) (StopOrderID, error) {
+	req, err := adaptStopOrderRequestToPb(request, investpb.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS)
+	if err != nil {
+		return "", err
+	}
+	return c.postStopOrder(ctx, req)
+}
+
+// PlaceTakeProfit places the order that becomes the market order when the stop price is reached
+// in the profitable direction.
+func (c *Client) PlaceTakeProfit(ctx context.Context, request StopOrderRequest) (StopOrderID, error) {
+	req, err := adaptStopOrderRequestToPb(request, investpb.StopOrderType_STOP_ORDER_TYPE_TAKE_PROFIT)
+	if err != nil {
+		return "", err
+	}
+	return c.postStopOrder(ctx, req)
+}
+
+// PlaceStopLimit places the order that becomes the limit order when the stop price is reached.
+func (c *Client) PlaceStopLimit(ctx context.Context, request StopLimitOrderRequest) (StopOrderID, error) {
+	if !request.Price.IsPositive() {
+		return "", errors.New("price must be positive for stop limit order")
+	}
+
+	req, err := adaptStopOrderRequestToPb(request.StopOrderRequest, investpb.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT)
+	if err != nil {
+		return "", err
+	}
+	req.Price = adaptDecimalToPbQuotation(request.Price)
+
+	return c.postStopOrder(ctx, req)
+}
+
+func (c *Client) postStopOrder(ctx context.Context, req *investpb.PostStopOrderRequest) (StopOrderID, error) {
+	if c.useSandbox {
+		return "", ErrStopOrdersInSandbox
+	}
+
+	resp, err := c.stopOrders.PostStopOrder(c.auth(ctx), req)
+	if err != nil {
+		return "", fmt.Errorf("grpc post stop order call: %w", err)
+	}
+	return StopOrderID(resp.StopOrderId), nil
+}
+
+// GetStopOrders returns active stop orders of the account.
+func (c *Client) GetStopOrders(ctx context.Context, accountID AccountID) ([]StopOrder, error) {
+	if c.useSandbox {
+		return nil, ErrStopOrdersInSandbox
+	}
+
+	resp, err := c.stopOrders.GetStopOrders(c.auth(ctx), &investpb.GetStopOrdersRequest{AccountId: accountID.S()})
+	if err != nil {
+		return nil, fmt.Errorf("grpc get stop orders call: %w", err)
+	}
+
+	orders := make([]StopOrder, len(resp.StopOrders))
+	for i, o := range resp.StopOrders {
+		orders[i] = adaptPbStopOrder(o)
+	}
+	return orders, nil
+}
+
+func (c *Client) CancelStopOrder(ctx context.Context, accountID AccountID, stopOrderID StopOrderID) error {
+	if c.useSandbox {
+		return ErrStopOrdersInSandbox
+	}
+
+	_, err := c.stopOrders.CancelStopOrder(c.auth(ctx), &investpb.CancelStopOrderRequest{
+		AccountId:   accountID.S(),
+		StopOrderId: stopOrderID.S(),
+	})
+	if err != nil {
+		return fmt.Errorf("grpc cancel stop order call: %w", err)
+	}
+	return nil
+}
+
+func adaptStopOrderRequestToPb(r StopOrderRequest, typ investpb.StopOrderType) (*investpb.PostStopOrderRequest, error) {
+	if r.Lots <= 0 {
+		return nil, errors.New("lots must be positive")
+	}
+	if !r.StopPrice.IsPositive() {
+		return nil, errors.New("stop price must be positive")
+	}
+
+	req := &investpb.PostStopOrderRequest{
+		Figi:          r.FIGI.S(),
+		Quantity:      int64(r.Lots),
+		StopPrice:     adaptDecimalToPbQuotation(r.StopPrice),
+		AccountId:     r.AccountID.S(),
+		StopOrderType: typ,
+	}
+
+	switch r.Direction {
+	case TradeDirectionBuy:
+		req.Direction = investpb.StopOrderDirection_STOP_ORDER_DIRECTION_BUY
+	case TradeDirectionSell:
+		req.Direction = investpb.StopOrderDirection_STOP_ORDER_DIRECTION_SELL
+	default:
+		return nil, errors.New("direction must be specified")
+	}
+
+	switch r.Expiration {
+	case StopOrderGoodTillCancel:
+		req.ExpirationType = investpb.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_CANCEL
+	case StopOrderGoodTillDate:
+		if r.ExpireAt.IsZero() {
+			return nil, errors.New("expiration time must be defined for good-till-date order")
+		}
+		req.ExpirationType = investpb.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_DATE
+		req.ExpireDate = timestamppb.New(r.ExpireAt)
+	default:
+		return nil, fmt.Errorf("unknown expiration: %d", r.Expiration)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,122 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+type TradeDirection int
+
+const (
+	TradeDirectionUnspecified TradeDirection = iota
+	TradeDirectionBuy
+	TradeDirectionSell
+)
+
+func (d TradeDirection) String() string {
+	switch d {
+	case TradeDirectionBuy:
+		return "buy"
+	case TradeDirectionSell:
+		return "sell"
+	case TradeDirectionUnspecified:
+	}
+	return "unspecified"
+}
+
+// Trade is an anonymous exchange trade (tape record).
+type Trade struct {
+	FIGI FIGI
+	// Direction is the aggressor side.
+	Direction TradeDirection
+	// Price is per one instrument, not per lot.
+	Price decimal.Decimal
+	Lots  int
+	Time  time.Time
+}
+
+// GetLastTrades returns anonymous trades for the [from, to) period.
+// Note that the API returns trades for the last hour only.
+func (c *Client) GetLastTrades(ctx context.Context, figi FIGI, from, to time.Time) ([]Trade, error) {
+	resp, err := c.marketData.GetLastTrades(c.auth(ctx), &investpb.GetLastTradesRequest{
+		Figi: figi.S(),
+		From: timestamppb.New(from),
+		To:   timestamppb.New(to),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("grpc get last trades call: %w", err)
+	}
+
+	result := make([]Trade, 0, len(resp.Trades))
+	for _, t := range resp.Trades {
+		result = append(result, adaptPbTrade(t))
+	}
+	return result, nil
+}
+
+// SubscribeForTrades streams anonymous trades of the instruments.
+// Trades are not dropped, the stream waits for the slow consumer once the buffer is full.
+func (c *Client) SubscribeForTrades(ctx context.Context, figis []FIGI) (<-chan Trade, error) {
+	instruments := make([]*investpb.TradeInstrument, len(figis))
+	requested := make([]string, len(figis))
+	for i, f := range figis {
+		instruments[i] = &investpb.TradeInstrument{Figi: f.S()}
+		requested[i] = f.S()
+	}
+
+	stream, logger, err := c.subscribeMarketData(ctx, &investpb.MarketDataRequest{
+		Payload: &investpb.MarketDataRequest_SubscribeTradesRequest{
+			SubscribeTradesRequest: &investpb.SubscribeTradesRequest{
+				SubscriptionAction: investpb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE,
+				Instruments:        instruments,
+			},
+		},
+	}, func(mdResp *investpb.MarketDataResponse) (string, error) {
+		tradesResp, ok := mdResp.Payload.(*investpb.MarketDataResponse_SubscribeTradesResponse)
+		if !ok {
+			return "", fmt.Errorf("unexpected response type: %T", mdResp.Payload)
+		}
+
+		resp := tradesResp.SubscribeTradesResponse
+
+		statuses := make(map[string]investpb.SubscriptionStatus, len(resp.TradeSubscriptions))
+		for _, s := range resp.TradeSubscriptions {
+			statuses[s.Figi] = s.SubscriptionStatus
+		}
+		if err := checkSubscriptionStatuses(resp.TrackingId, requested, statuses); err != nil {
+			return "", err
+		}
+		return resp.TrackingId, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Listen trades.
+
+	trades := make(chan Trade, mdEventsBufferSize)
+	go func() {
+		defer close(trades)
+
+		listenMarketData(stream, logger, func(resp *investpb.MarketDataResponse) bool {
+			v, ok := resp.Payload.(*investpb.MarketDataResponse_Trade)
+			if !ok {
+				return true
+			}
+
+			select {
+			case <-ctx.Done():
+				return false
+			case trades <- adaptPbTrade(v.Trade):
+			}
+			return true
+		})
+	}()
+	return trades, nil
+}
@@ -0,0 +1,48 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+type TradingSchedule struct {
+	Exchange string
+	Days     []TradingDay
+}
+
+// TradingDay describes the sessions of the exchange during the day.
+// All times are zero for non-trading days.
+type TradingDay struct {
+	Date         time.Time
+	IsTradingDay bool
+	// StartTime and EndTime are the main session boundaries.
+	StartTime time.Time
+	EndTime   time.Time
+	// EveningStartTime and EveningEndTime are zero if there is no evening session.
+	EveningStartTime time.Time
+	EveningEndTime   time.Time
+}
+
+// GetTradingSchedules returns schedules of the exchange for the time range.
+// Schedules of all exchanges are returned if the exchange is empty.
+func (c *Client) GetTradingSchedules(ctx context.Context, exchange string, from, to time.Time) ([]TradingSchedule, error) {
+	resp, err := c.instruments.TradingSchedules(c.auth(ctx), &investpb.TradingSchedulesRequest{
+		Exchange: exchange,
+		From:     timestamppb.New(from),
+		To:       timestamppb.New(to),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("grpc trading schedules call: %w", err)
+	}
+
+	schedules := make([]TradingSchedule, len(resp.Exchanges))
+	for i, s := range resp.Exchanges {
+		schedules[i] = adaptPbTradingSchedule(s)
+	}
+	return schedules, nil
+}
@@ -0,0 +1,149 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+// TradingStatusCode mirrors investpb.SecurityTradingStatus values.
+type TradingStatusCode int
+
+const (
+	TradingStatusUnspecified TradingStatusCode = iota
+	TradingStatusNotAvailableForTrading
+	TradingStatusOpeningPeriod
+	TradingStatusClosingPeriod
+	TradingStatusBreakInTrading
+	TradingStatusNormalTrading
+	TradingStatusClosingAuction
+	TradingStatusDarkPoolAuction
+	TradingStatusDiscreteAuction
+	TradingStatusOpeningAuctionPeriod
+	TradingStatusTradingAtClosingAuctionPrice
+	TradingStatusSessionAssigned
+	TradingStatusSessionClose
+	TradingStatusSessionOpen
+	TradingStatusDealerNormalTrading
+	TradingStatusDealerBreakInTrading
+	TradingStatusDealerNotAvailableForTrading
+)
+
+var tradingStatusCodeNames = map[TradingStatusCode]string{
+	TradingStatusUnspecified:                  "unspecified",
+	TradingStatusNotAvailableForTrading:       "not_available_for_trading",
+	TradingStatusOpeningPeriod:                "opening_period",
+	TradingStatusClosingPeriod:                "closing_period",
+	TradingStatusBreakInTrading:               "break_in_trading",
+	TradingStatusNormalTrading:                "normal_trading",
+	TradingStatusClosingAuction:               "closing_auction",
+	TradingStatusDarkPoolAuction:              "dark_pool_auction",
+	TradingStatusDiscreteAuction:              "discrete_auction",
+	TradingStatusOpeningAuctionPeriod:         "opening_auction_period",
+	TradingStatusTradingAtClosingAuctionPrice: "trading_at_closing_auction_price",
+	TradingStatusSessionAssigned:              "session_assigned",
+	TradingStatusSessionClose:                 "session_close",
+	TradingStatusSessionOpen:                  "session_open",
+	TradingStatusDealerNormalTrading:          "dealer_normal_trading",
+	TradingStatusDealerBreakInTrading:         "dealer_break_in_trading",
+	TradingStatusDealerNotAvailableForTrading: "dealer_not_available_for_trading",
+}
+
+func (c TradingStatusCode) String() string {
+	if n, ok := tradingStatusCodeNames[c]; ok {
+		return n
+	}
+	return fmt.Sprintf("TradingStatusCode(%d)", int(c))
+}
+
+type TradingStatus struct {
+	FIGI                 FIGI
+	Code                 TradingStatusCode
+	LimitOrderAvailable  bool
+	MarketOrderAvailable bool
+	// Time is zero for GetTradingStatus response.
+	Time time.Time
+}
+
+// IsNormalTrading reports whether the instrument is traded in the main exchange mode
+// (not an auction, halt or dealer-only trading).
+func (s TradingStatus) IsNormalTrading() bool {
+	return s.Code == TradingStatusNormalTrading
+}
+
+func (c *Client) GetTradingStatus(ctx context.Context, figi FIGI) (*TradingStatus, error) {
+	resp, err := c.marketData.GetTradingStatus(c.auth(ctx), &investpb.GetTradingStatusRequest{Figi: figi.S()})
+	if err != nil {
+		return nil, fmt.Errorf("grpc get trading status call: %w", err)
+	}
+
+	return &TradingStatus{
+		FIGI:                 FIGI(resp.Figi),
+		Code:                 TradingStatusCode(resp.TradingStatus),
+		LimitOrderAvailable:  resp.LimitOrderAvailableFlag,
+		MarketOrderAvailable: resp.MarketOrderAvailableFlag,
+	}, nil
+}
+
+// SubscribeForTradingStatuses streams the instruments trading status changes.
+func (c *Client) SubscribeForTradingStatuses(ctx context.Context, figis []FIGI) (<-chan TradingStatus, error) {
+	instruments := make([]*investpb.InfoInstrument, len(figis))
+	requested := make([]string, len(figis))
+	for i, f := range figis {
+		instruments[i] = &investpb.InfoInstrument{Figi: f.S()}
+		requested[i] = f.S()
+	}
+
+	stream, logger, err := c.subscribeMarketData(ctx, &investpb.MarketDataRequest{
+		Payload: &investpb.MarketDataRequest_SubscribeInfoRequest{
+			SubscribeInfoRequest: &investpb.SubscribeInfoRequest{
+				SubscriptionAction: investpb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE,
+				Instruments:        instruments,
+			},
+		},
+	}, func(mdResp *investpb.MarketDataResponse) (string, error) {
+		infoResp, ok := mdResp.Payload.(*investpb.MarketDataResponse_SubscribeInfoResponse)
+		if !ok {
+			return "", fmt.Errorf("unexpected response type: %T", mdResp.Payload)
+		}
+
+		resp := infoResp.SubscribeInfoResponse
+
+		statuses := make(map[string]investpb.SubscriptionStatus, len(resp.InfoSubscriptions))
+		for _, s := range resp.InfoSubscriptions {
+			statuses[s.Figi] = s.SubscriptionStatus
+		}
+		if err := checkSubscriptionStatuses(resp.TrackingId, requested, statuses); err != nil {
+			return "", err
+		}
+		return resp.TrackingId, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Listen statuses.
+
+	statuses := make(chan TradingStatus)
+	go func() {
+		defer close(statuses)
+
+		listenMarketData(stream, logger, func(resp *investpb.MarketDataResponse) bool {
+			v, ok := resp.Payload.(*investpb.MarketDataResponse_TradingStatus)
+			if !ok {
+				return true
+			}
+
+			// Status changes are rare and important, so do not drop them.
+			select {
+			case <-ctx.Done():
+				return false
+			case statuses <- adaptPbTradingStatus(v.TradingStatus):
+			}
+			return true
+		})
+	}()
+	return statuses, nil
+}
@@ -0,0 +1,45 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+type Tariff struct {
+	UnaryLimits  []UnaryLimit
+	StreamLimits []StreamLimit
+}
+
+// UnaryLimit is shared by all its methods.
+type UnaryLimit struct {
+	LimitPerMinute int
+	// Methods are full gRPC method names like "tinkoff.public.invest.api.contract.v1.UsersService/GetInfo".
+	Methods []string
+}
+
+// StreamLimit is the maximum amount of simultaneously opened streams of the methods.
+type StreamLimit struct {
+	Limit   int
+	Streams []string
+}
+
+func (c *Client) GetUserTariff(ctx context.Context) (*Tariff, error) {
+	resp, err := c.users.GetUserTariff(c.auth(ctx), new(investpb.GetUserTariffRequest))
+	if err != nil {
+		return nil, fmt.Errorf("grpc get user tariff call: %w", err)
+	}
+	return adaptPbTariff(resp), nil
+}
+
+// SetupRateLimits configures the client throttling according to the user tariff.
+// The client is not throttled until the first successful call.
+func (c *Client) SetupRateLimits(ctx context.Context) (*Tariff, error) {
+	tariff, err := c.GetUserTariff(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.limiter.configure(tariff)
+	return tariff, nil
+}
@@ -3,20 +3,15 @@ package tinkoffinvest
 import (
 	"context"
 	"errors"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
 
 	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
 )
 
-const (
-	codeNotEnoughAssetsForMarginTrade = "30042"
-	codePostOrderError                = "30049" // nolint:deadcode,varcheck
-)
-
 type Client struct {
 	token      string
 	appName    string
@@ -27,12 +22,28 @@ type Client struct {
 	marketDataStream investpb.MarketDataStreamServiceClient
 	operations       investpb.OperationsServiceClient
 	orders           investpb.OrdersServiceClient
+	ordersStream     investpb.OrdersStreamServiceClient
+	stopOrders       investpb.StopOrdersServiceClient
 	users            investpb.UsersServiceClient
 
 	sandbox investpb.SandboxServiceClient
+
+	limiter *rateLimiter
+
+	orderRetries      int
+	orderRetryBackoff time.Duration
+
+	fillsPollersMu sync.Mutex
+	fillsPollers   map[*orderFillsPoller]struct{}
 }
 
-func NewClient(cc grpc.ClientConnInterface, token string, appName string, useSandbox bool) (*Client, error) {
+func NewClient(
+	cc grpc.ClientConnInterface,
+	token string,
+	appName string,
+	useSandbox bool,
+	opts ...Option,
+) (*Client, error) {
 	if cc == nil {
 		return nil, errors.New("uninitialized grpc connection")
 	}
@@ -43,17 +54,31 @@ func NewClient(cc grpc.ClientConnInterface, token string, appName string, useSan
 		return nil, errors.New("application name must be defined")
 	}
 
+	o := newOptions(opts)
+
+	// Deadlines are applied after waiting for the rate limiter.
+	cc = &errorsConn{ClientConnInterface: cc}
+	cc = &deadlineConn{ClientConnInterface: cc, defaultTimeout: o.defaultTimeout, timeouts: o.timeouts}
+	limiter := newRateLimiter()
+	cc = &limitedConn{cc: cc, limiter: limiter}
+
 	return &Client{
-		token:            token,
-		appName:          appName,
-		useSandbox:       useSandbox,
-		instruments:      investpb.NewInstrumentsServiceClient(cc),
-		marketData:       investpb.NewMarketDataServiceClient(cc),
-		marketDataStream: investpb.NewMarketDataStreamServiceClient(cc),
-		operations:       investpb.NewOperationsServiceClient(cc),
-		orders:           investpb.NewOrdersServiceClient(cc),
-		users:            investpb.NewUsersServiceClient(cc),
-		sandbox:          investpb.NewSandboxServiceClient(cc),
+		token:             token,
+		appName:           appName,
+		useSandbox:        useSandbox,
+		instruments:       investpb.NewInstrumentsServiceClient(cc),
+		marketData:        investpb.NewMarketDataServiceClient(cc),
+		marketDataStream:  investpb.NewMarketDataStreamServiceClient(cc),
+		operations:        investpb.NewOperationsServiceClient(cc),
+		orders:            investpb.NewOrdersServiceClient(cc),
+		ordersStream:      investpb.NewOrdersStreamServiceClient(cc),
+		stopOrders:        investpb.NewStopOrdersServiceClient(cc),
+		users:             investpb.NewUsersServiceClient(cc),
+		sandbox:           investpb.NewSandboxServiceClient(cc),
+		limiter:           limiter,
+		orderRetries:      o.orderRetries,
+		orderRetryBackoff: o.orderRetryBackoff,
+		fillsPollers:      make(map[*orderFillsPoller]struct{}),
 	}, nil
 }
 
@@ -62,17 +87,3 @@ func (c *Client) auth(ctx context.Context) context.Context {
 		"authorization", "Bearer "+c.token,
 		"x-app-name", c.appName)
 }
-
-func isStatusError(err error, typpe codes.Code, code string) bool {
-	s, ok := status.FromError(err)
-	if !ok {
-		return false
-	}
-	if s.Code() != typpe {
-		return false
-	}
-	if code != "" {
-		return s.Message() == code
-	}
-	return true
-}
@@ -0,0 +1,33 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// deadlineConn sets the default deadline for unary calls without one.
+type deadlineConn struct {
+	grpc.ClientConnInterface
+	defaultTimeout time.Duration
+	timeouts       map[string]time.Duration // By method label.
+}
+
+func (c *deadlineConn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	if _, ok := ctx.Deadline(); !ok {
+		if timeout := c.timeout(method); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+	return c.ClientConnInterface.Invoke(ctx, method, args, reply, opts...)
+}
+
+func (c *deadlineConn) timeout(method string) time.Duration {
+	if t, ok := c.timeouts[methodLabel(method)]; ok {
+		return t
+	}
+	return c.defaultTimeout
+}
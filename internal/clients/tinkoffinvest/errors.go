@@ -0,0 +1,195 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	headerTrackingID = "x-tracking-id"
+	headerMessage    = "message"
+)
+
+// Kinds of API errors, use errors.Is to check them.
+var (
+	ErrInvalidToken          = errors.New("invalid token")
+	ErrPermissionDenied      = errors.New("permission denied")
+	ErrNotEnoughStocks       = errors.New("not enough stocks (shares or money)")
+	ErrInstrumentNotTradable = errors.New("instrument is not tradable")
+	ErrPriceOutOfLimits      = errors.New("price is out of limits")
+	ErrMarketClosed          = errors.New("market is closed")
+	ErrRateLimited           = errors.New("rate limit exceeded")
+	ErrNotFound              = errors.New("not found")
+	ErrInvalidRequest        = errors.New("invalid request")
+	// ErrUnavailable means the call has failed or timed out and could succeed if repeated.
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+// apiCodeKinds maps API error codes passed as status message to the error kinds.
+var apiCodeKinds = map[string]error{
+	"30034": ErrNotEnoughStocks,       // Not enough balance.
+	"30042": ErrNotEnoughStocks,       // Not enough assets for a margin trade.
+	"30052": ErrInstrumentNotTradable, // Instrument is forbidden for trading by API.
+	"30068": ErrMarketClosed,          // Only limit orders are available at the moment.
+	"30079": ErrInstrumentNotTradable, // Instrument is not available for trading.
+	"30092": ErrMarketClosed,          // Trading is not available on non-business days.
+	"40002": ErrPermissionDenied,      // Insufficient privileges.
+	"40003": ErrInvalidToken,          // Invalid or expired token.
+	"40004": ErrPermissionDenied,      // Orders are not available for the account.
+	"50002": ErrNotFound,              // Instrument not found.
+	"80001": ErrRateLimited,           // Concurrent streams limit exceeded.
+	"80002": ErrRateLimited,           // Requests limit exceeded.
+	"90002": ErrInstrumentNotTradable, // Instrument is for qualified investors only.
+	"90003": ErrPriceOutOfLimits,      // Order price is too high.
+}
+
+// grpcCodeKinds is used if API error code is unknown.
+var grpcCodeKinds = map[codes.Code]error{
+	codes.Unauthenticated:    ErrInvalidToken,
+	codes.PermissionDenied:   ErrPermissionDenied,
+	codes.ResourceExhausted:  ErrRateLimited,
+	codes.NotFound:           ErrNotFound,
+	codes.InvalidArgument:    ErrInvalidRequest,
+	codes.FailedPrecondition: ErrInvalidRequest,
+	codes.Unavailable:        ErrUnavailable,
+	codes.DeadlineExceeded:   ErrUnavailable,
+}
+
+// APIError keeps the details of failed API call. It unwraps to one of error kinds if known.
+type APIError struct {
+	Code codes.Code
+	// APICode is like "30042", empty for errors not from API (e.g. transport).
+	APICode string
+	// Message is raw description of the error.
+	Message    string
+	TrackingID string
+
+	kind   error
+	status *status.Status
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "api error: code = %s", e.Code)
+	if e.APICode != "" {
+		fmt.Fprintf(&b, " api_code = %s", e.APICode)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, " message = %q", e.Message)
+	}
+	if e.TrackingID != "" {
+		fmt.Fprintf(&b, " tracking_id = %s", e.TrackingID)
+	}
+	return b.String()
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// GRPCStatus allows status.Code and status.FromError to work with the error.
+func (e *APIError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// newAPIError adapts gRPC status error to APIError. Other errors are returned as is.
+func newAPIError(err error, mds ...metadata.MD) error {
+	if err == nil {
+		return nil
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	apiErr := &APIError{
+		Code:   s.Code(),
+		status: s,
+	}
+	if isAPICode(s.Message()) {
+		apiErr.APICode = s.Message()
+	} else {
+		apiErr.Message = s.Message()
+	}
+	for _, md := range mds {
+		if v := md.Get(headerTrackingID); len(v) > 0 && apiErr.TrackingID == "" {
+			apiErr.TrackingID = v[0]
+		}
+		if v := md.Get(headerMessage); len(v) > 0 && apiErr.APICode != "" {
+			apiErr.Message = v[0]
+		}
+	}
+
+	if kind, ok := apiCodeKinds[apiErr.APICode]; ok {
+		apiErr.kind = kind
+	} else {
+		apiErr.kind = grpcCodeKinds[apiErr.Code]
+	}
+	return apiErr
+}
+
+// isAPICode reports whether the status message is API error code like "30042".
+func isAPICode(s string) bool {
+	if len(s) != 5 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// errorsConn adapts errors of the calls to APIError.
+type errorsConn struct {
+	grpc.ClientConnInterface
+}
+
+func (c *errorsConn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	var header, trailer metadata.MD
+	err := c.ClientConnInterface.Invoke(ctx, method, args, reply, append(opts, grpc.Header(&header), grpc.Trailer(&trailer))...)
+	return newAPIError(err, header, trailer)
+}
+
+func (c *errorsConn) NewStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	stream, err := c.ClientConnInterface.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		return nil, newAPIError(err)
+	}
+	return &errorsStream{ClientStream: stream}, nil
+}
+
+type errorsStream struct {
+	grpc.ClientStream
+}
+
+func (s *errorsStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil || errors.Is(err, io.EOF) {
+		return err
+	}
+	return newAPIError(err)
+}
+
+func (s *errorsStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil || errors.Is(err, io.EOF) {
+		return err
+	}
+	return newAPIError(err, s.Trailer())
+}
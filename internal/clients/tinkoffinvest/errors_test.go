@@ -0,0 +1,102 @@
+package tinkoffinvest //nolint:testpackage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func Test_newAPIError(t *testing.T) {
+	cases := []struct {
+		name         string
+		err          error
+		md           metadata.MD
+		expectedKind error
+		expected     *APIError
+	}{
+		{
+			name: "known api code",
+			err:  status.Error(codes.InvalidArgument, "30042"),
+			md: metadata.Pairs(
+				headerTrackingID, "tracking-007",
+				headerMessage, "not enough assets for a margin trade",
+			),
+			expectedKind: ErrNotEnoughStocks,
+			expected: &APIError{
+				Code:       codes.InvalidArgument,
+				APICode:    "30042",
+				Message:    "not enough assets for a margin trade",
+				TrackingID: "tracking-007",
+			},
+		},
+		{
+			name:         "unknown api code",
+			err:          status.Error(codes.InvalidArgument, "30999"),
+			expectedKind: ErrInvalidRequest,
+			expected: &APIError{
+				Code:    codes.InvalidArgument,
+				APICode: "30999",
+			},
+		},
+		{
+			name:         "transport error",
+			err:          status.Error(codes.Unavailable, "connection refused"),
+			md:           metadata.Pairs(headerMessage, "ignored"),
+			expectedKind: ErrUnavailable,
+			expected: &APIError{
+				Code:    codes.Unavailable,
+				Message: "connection refused",
+			},
+		},
+		{
+			name: "unknown kind",
+			err:  status.Error(codes.Internal, "70001"),
+			expected: &APIError{
+				Code:    codes.Internal,
+				APICode: "70001",
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newAPIError(tt.err, tt.md)
+
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tt.expected.Code, apiErr.Code)
+			assert.Equal(t, tt.expected.APICode, apiErr.APICode)
+			assert.Equal(t, tt.expected.Message, apiErr.Message)
+			assert.Equal(t, tt.expected.TrackingID, apiErr.TrackingID)
+			assert.Equal(t, tt.expected.Code, status.Code(err))
+
+			wrapped := fmt.Errorf("grpc call: %w", err)
+			if tt.expectedKind != nil {
+				assert.ErrorIs(t, wrapped, tt.expectedKind)
+			} else {
+				assert.Nil(t, errors.Unwrap(err))
+			}
+		})
+	}
+}
+
+func Test_newAPIError_NotStatus(t *testing.T) {
+	assert.NoError(t, newAPIError(nil))
+	assert.Equal(t, context.Canceled, newAPIError(context.Canceled))
+}
+
+func TestAPIError_Error(t *testing.T) {
+	err := newAPIError(
+		status.Error(codes.InvalidArgument, "30079"),
+		metadata.Pairs(headerTrackingID, "tracking-007", headerMessage, "instrument is not available"),
+	)
+	assert.EqualError(t, err,
+		`api error: code = InvalidArgument api_code = 30079 message = "instrument is not available" tracking_id = tracking-007`)
+}
@@ -0,0 +1,48 @@
+package tinkoffinvest
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const subsystem = "tinkoff_invest"
+
+type l = prometheus.Labels
+
+var (
+	rateLimitWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "ratelimit_wait_seconds",
+		Help:      "Time spent waiting for the client-side rate limiter",
+		Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method"})
+
+	rateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "ratelimit_remaining",
+		Help:      "Remaining requests quota of the method within the current minute",
+	}, []string{"method"})
+
+	rateLimitExhausted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "ratelimit_exhausted_total",
+		Help:      "Total amount of calls rejected by API due to exhausted quota",
+	}, []string{"method"})
+
+	orderRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "order_placement_retries_total",
+		Help:      "Total amount of order placement retries due to transient errors",
+	})
+
+	streamsActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "streams_active",
+		Help:      "Amount of opened streams of the method",
+	}, []string{"method"})
+)
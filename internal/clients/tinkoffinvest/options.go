@@ -0,0 +1,52 @@
+package tinkoffinvest
+
+import "time"
+
+const (
+	defaultOrderRetries      = 3
+	defaultOrderRetryBackoff = 200 * time.Millisecond
+)
+
+type Option func(o *options)
+
+type options struct {
+	defaultTimeout    time.Duration
+	timeouts          map[string]time.Duration
+	orderRetries      int
+	orderRetryBackoff time.Duration
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		timeouts:          make(map[string]time.Duration),
+		orderRetries:      defaultOrderRetries,
+		orderRetryBackoff: defaultOrderRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithDefaultTimeout sets the deadline for unary calls made without one.
+func WithDefaultTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.defaultTimeout = d
+	}
+}
+
+// WithMethodTimeout overrides the default timeout of the method like "OrdersService/PostOrder".
+func WithMethodTimeout(method string, d time.Duration) Option {
+	return func(o *options) {
+		o.timeouts[method] = d
+	}
+}
+
+// WithOrderRetries sets the amount of order placement retries on transient errors. Zero disables retries.
+// The backoff grows linearly with attempts.
+func WithOrderRetries(retries int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.orderRetries = retries
+		o.orderRetryBackoff = backoff
+	}
+}
@@ -0,0 +1,343 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	headerRateLimitRemaining = "x-ratelimit-remaining"
+	headerRateLimitReset     = "x-ratelimit-reset"
+
+	// defaultRateLimitReset is used if API has exhausted the quota without telling the reset time.
+	defaultRateLimitReset = time.Minute
+)
+
+var ErrStreamLimitExceeded = errors.New("streams limit exceeded")
+
+// limitedConn throttles calls made over the underlying connection.
+type limitedConn struct {
+	cc      grpc.ClientConnInterface
+	limiter *rateLimiter
+}
+
+func (c *limitedConn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	m := methodLabel(method)
+	bucket := c.limiter.bucket(method)
+
+	if bucket != nil {
+		wait := bucket.reserve(time.Now())
+		rateLimitWait.With(l{"method": m}).Observe(wait.Seconds())
+
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				bucket.cancel()
+				return ctx.Err()
+			case <-t.C:
+			}
+		}
+	}
+
+	var header metadata.MD
+	err := c.cc.Invoke(ctx, method, args, reply, append(opts, grpc.Header(&header))...)
+
+	remaining, hasRemaining := headerInt(header, headerRateLimitRemaining)
+	reset, hasReset := headerInt(header, headerRateLimitReset)
+	resetAfter := defaultRateLimitReset
+	if hasReset {
+		resetAfter = time.Duration(reset) * time.Second
+	}
+
+	switch {
+	case hasRemaining:
+		rateLimitRemaining.With(l{"method": m}).Set(float64(remaining))
+	case bucket != nil:
+		rateLimitRemaining.With(l{"method": m}).Set(bucket.remaining(time.Now()))
+	}
+
+	exhausted := status.Code(err) == codes.ResourceExhausted
+	if exhausted {
+		rateLimitExhausted.With(l{"method": m}).Inc()
+		log.Warn().Str("method", m).Dur("reset_after", resetAfter).Msg("rate limit exceeded")
+	}
+	if bucket != nil && (exhausted || (hasRemaining && remaining == 0)) {
+		bucket.block(time.Now().Add(resetAfter))
+	}
+
+	return err
+}
+
+func (c *limitedConn) NewStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	limit := c.limiter.streamLimit(method)
+	if limit == nil {
+		return c.cc.NewStream(ctx, desc, method, opts...)
+	}
+
+	m := methodLabel(method)
+	if !limit.acquire() {
+		return nil, ErrStreamLimitExceeded
+	}
+	streamsActive.With(l{"method": m}).Inc()
+
+	var once sync.Once
+	released := make(chan struct{})
+	release := func() {
+		once.Do(func() {
+			limit.release()
+			streamsActive.With(l{"method": m}).Dec()
+			close(released)
+		})
+	}
+
+	stream, err := c.cc.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			release()
+		case <-released:
+		}
+	}()
+	return &limitedStream{ClientStream: stream, release: release}, nil
+}
+
+// limitedStream releases the stream limit on the stream end.
+type limitedStream struct {
+	grpc.ClientStream
+	release func()
+}
+
+func (s *limitedStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.release()
+	}
+	return err
+}
+
+// rateLimiter holds the limits of the user tariff. It is safe for concurrent use.
+// Streams opened before the configuration are not counted.
+type rateLimiter struct {
+	mu      sync.RWMutex
+	buckets map[string]*tokenBucket // By method, shared by methods of the same limit.
+	streams map[string]*streamLimit // By method, shared by methods of the same limit.
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		streams: make(map[string]*streamLimit),
+	}
+}
+
+func (rl *rateLimiter) configure(t *Tariff) {
+	buckets := make(map[string]*tokenBucket)
+	for _, ul := range t.UnaryLimits {
+		if ul.LimitPerMinute <= 0 {
+			continue
+		}
+
+		b := newTokenBucket(ul.LimitPerMinute, time.Now())
+		for _, m := range ul.Methods {
+			buckets[normalizeMethod(m)] = b
+		}
+	}
+
+	streams := make(map[string]*streamLimit)
+	for _, sl := range t.StreamLimits {
+		if sl.Limit <= 0 {
+			continue
+		}
+
+		s := &streamLimit{limit: sl.Limit}
+		for _, m := range sl.Streams {
+			streams[normalizeMethod(m)] = s
+		}
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.buckets = buckets
+	rl.streams = streams
+}
+
+func (rl *rateLimiter) bucket(method string) *tokenBucket {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return rl.buckets[normalizeMethod(method)]
+}
+
+func (rl *rateLimiter) streamLimit(method string) *streamLimit {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return rl.streams[normalizeMethod(method)]
+}
+
+// tokenBucket allows the limit of calls per minute with bursts up to the tenth of the limit.
+type tokenBucket struct {
+	mu           sync.Mutex
+	rate         float64 // Tokens per second.
+	burst        float64
+	tokens       float64 // Negative if there are reservations.
+	last         time.Time
+	blockedUntil time.Time
+}
+
+func newTokenBucket(limitPerMinute int, now time.Time) *tokenBucket {
+	burst := float64(limitPerMinute / 10)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rate:   float64(limitPerMinute) / 60.,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// reserve takes a token and returns the time to wait before the call.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	b.tokens--
+
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	if blocked := b.blockedUntil.Sub(now); blocked > wait {
+		wait = blocked
+	}
+	return wait
+}
+
+// cancel returns the reserved token.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// block prevents the calls until the moment.
+func (b *tokenBucket) block(until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+	if b.tokens > 0 {
+		b.tokens = 0
+	}
+}
+
+func (b *tokenBucket) remaining(now time.Time) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	if b.tokens < 0 {
+		return 0
+	}
+	return float64(int(b.tokens))
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		b.last = now
+	}
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+type streamLimit struct {
+	mu     sync.Mutex
+	limit  int
+	active int
+}
+
+func (s *streamLimit) acquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active >= s.limit {
+		return false
+	}
+	s.active++
+	return true
+}
+
+func (s *streamLimit) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active > 0 {
+		s.active--
+	}
+}
+
+// normalizeMethod trims the leading slash of gRPC method, because tariff methods are without it.
+func normalizeMethod(m string) string {
+	return strings.TrimPrefix(m, "/")
+}
+
+// methodLabel returns the short method name like "MarketDataService/GetOrderBook".
+func methodLabel(m string) string {
+	m = normalizeMethod(m)
+	if i := strings.LastIndex(m, "."); i >= 0 {
+		return m[i+1:]
+	}
+	return m
+}
+
+// headerInt parses the first number of the header value like "100" or "100, 100;w=60".
+func headerInt(md metadata.MD, key string) (int, bool) {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	v := values[0]
+	if i := strings.IndexAny(v, ",;"); i >= 0 {
+		v = v[:i]
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
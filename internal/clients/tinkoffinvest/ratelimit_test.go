@@ -0,0 +1,174 @@
+package tinkoffinvest //nolint:testpackage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Date(2022, 5, 20, 10, 0, 0, 0, time.UTC)
+	b := newTokenBucket(120, now) // 2 per second, burst 12.
+
+	for i := 0; i < 12; i++ {
+		assert.Zero(t, b.reserve(now), i)
+	}
+	assert.Equal(t, 500*time.Millisecond, b.reserve(now))
+	assert.Equal(t, time.Second, b.reserve(now))
+
+	b.cancel()
+	assert.Equal(t, time.Second, b.reserve(now))
+
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, float64(12), b.remaining(now)) // Refilled up to the burst.
+	assert.Zero(t, b.reserve(now))
+
+	b.block(now.Add(30 * time.Second))
+	assert.Equal(t, 30*time.Second, b.reserve(now))
+	assert.Equal(t, float64(0), b.remaining(now))
+}
+
+func TestTokenBucket_SmallLimit(t *testing.T) {
+	now := time.Date(2022, 5, 20, 10, 0, 0, 0, time.UTC)
+	b := newTokenBucket(6, now)
+
+	assert.Zero(t, b.reserve(now))
+	assert.Equal(t, 10*time.Second, b.reserve(now))
+}
+
+func Test_headerInt(t *testing.T) {
+	md := metadata.Pairs(
+		headerRateLimitRemaining, "99",
+		headerRateLimitReset, "30",
+		"x-ratelimit-limit", "100, 100;w=60",
+		"x-bad", "nan",
+	)
+
+	cases := []struct {
+		key      string
+		expected int
+		ok       bool
+	}{
+		{key: headerRateLimitRemaining, expected: 99, ok: true},
+		{key: headerRateLimitReset, expected: 30, ok: true},
+		{key: "x-ratelimit-limit", expected: 100, ok: true},
+		{key: "x-bad"},
+		{key: "x-unknown"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.key, func(t *testing.T) {
+			n, ok := headerInt(md, tt.key)
+			assert.Equal(t, tt.expected, n)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func Test_methodLabel(t *testing.T) {
+	assert.Equal(t, "MarketDataService/GetOrderBook",
+		methodLabel("/tinkoff.public.invest.api.contract.v1.MarketDataService/GetOrderBook"))
+	assert.Equal(t, "UsersService/GetInfo",
+		methodLabel("tinkoff.public.invest.api.contract.v1.UsersService/GetInfo"))
+}
+
+const (
+	testUnaryMethod  = "/tinkoff.public.invest.api.contract.v1.MarketDataService/GetOrderBook"
+	testStreamMethod = "/tinkoff.public.invest.api.contract.v1.MarketDataStreamService/MarketDataStream"
+)
+
+func TestLimitedConn(t *testing.T) {
+	limiter := newRateLimiter()
+	limiter.configure(&Tariff{
+		UnaryLimits: []UnaryLimit{{
+			LimitPerMinute: 600,
+			Methods:        []string{normalizeMethod(testUnaryMethod)},
+		}},
+		StreamLimits: []StreamLimit{{
+			Limit:   1,
+			Streams: []string{normalizeMethod(testStreamMethod)},
+		}},
+	})
+
+	cc := new(connStub)
+	conn := &limitedConn{cc: cc, limiter: limiter}
+
+	t.Run("exhausted quota blocks method", func(t *testing.T) {
+		cc.invokeErr = status.Error(codes.ResourceExhausted, "")
+		cc.header = metadata.Pairs(headerRateLimitReset, "60")
+		err := conn.Invoke(context.Background(), testUnaryMethod, nil, nil)
+		require.Error(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		cc.invokeErr = nil
+		err = conn.Invoke(ctx, testUnaryMethod, nil, nil)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, cc.invokes)
+	})
+
+	t.Run("not limited method", func(t *testing.T) {
+		err := conn.Invoke(context.Background(), "/UsersService/GetInfo", nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, cc.invokes)
+	})
+
+	t.Run("streams limit", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		_, err := conn.NewStream(ctx, nil, testStreamMethod)
+		require.NoError(t, err)
+
+		_, err = conn.NewStream(context.Background(), nil, testStreamMethod)
+		require.ErrorIs(t, err, ErrStreamLimitExceeded)
+
+		cancel()
+		assert.Eventually(t, func() bool {
+			s, err := conn.NewStream(context.Background(), nil, testStreamMethod)
+			if err != nil {
+				return false
+			}
+			_ = s.RecvMsg(nil) // Stub stream is ended at once.
+			return true
+		}, time.Second, 10*time.Millisecond)
+
+		_, err = conn.NewStream(context.Background(), nil, testStreamMethod)
+		require.NoError(t, err)
+	})
+}
+
+type connStub struct {
+	invokes   int
+	invokeErr error
+	header    metadata.MD
+}
+
+func (c *connStub) Invoke(_ context.Context, _ string, _, _ interface{}, opts ...grpc.CallOption) error {
+	c.invokes++
+	for _, o := range opts {
+		if h, ok := o.(grpc.HeaderCallOption); ok {
+			*h.HeaderAddr = c.header
+		}
+	}
+	return c.invokeErr
+}
+
+func (c *connStub) NewStream(context.Context, *grpc.StreamDesc, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamStub{}, nil
+}
+
+type streamStub struct {
+	grpc.ClientStream
+}
+
+func (streamStub) RecvMsg(interface{}) error {
+	return status.Error(codes.Canceled, "")
+}
@@ -0,0 +1,98 @@
+package tinkoffinvest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	investpb "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest/pb"
+)
+
+type marketDataStream = investpb.MarketDataStreamService_MarketDataStreamClient
+
+// subscriptionChecker validates the initial response of the market data stream
+// and returns its tracking id.
+type subscriptionChecker func(resp *investpb.MarketDataResponse) (trackingID string, err error)
+
+// subscribeMarketData opens a new market data stream, sends the subscription request into it
+// and validates the initial response with the check.
+func (c *Client) subscribeMarketData(
+	ctx context.Context,
+	req *investpb.MarketDataRequest,
+	check subscriptionChecker,
+) (marketDataStream, zerolog.Logger, error) {
+	stream, err := c.marketDataStream.MarketDataStream(c.auth(ctx))
+	if err != nil {
+		return nil, zerolog.Logger{}, fmt.Errorf("start grpc stream: %w", err)
+	}
+
+	// Send initial request.
+
+	if err := stream.Send(req); err != nil {
+		return nil, zerolog.Logger{}, fmt.Errorf("send initial request: %w", err)
+	}
+
+	// Receive and validate initial response.
+
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, zerolog.Logger{}, fmt.Errorf("recv initial response: %w", err)
+	}
+
+	trackingID, err := check(resp)
+	if err != nil {
+		return nil, zerolog.Logger{}, err
+	}
+
+	return stream, log.With().Str("tracking_id", trackingID).Logger(), nil
+}
+
+// listenMarketData receives stream messages until the stream error and passes them into handle.
+// Pings are handled automatically. The handle returns false to stop listening.
+func listenMarketData(
+	stream marketDataStream,
+	logger zerolog.Logger,
+	handle func(resp *investpb.MarketDataResponse) bool,
+) {
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if stream.Context().Err() == nil {
+				logger.Err(err).Msg("recv market data error")
+			}
+			return
+		}
+
+		if _, ok := resp.Payload.(*investpb.MarketDataResponse_Ping); ok {
+			logger.Debug().Msg("market data stream ping")
+			continue
+		}
+
+		if !handle(resp) {
+			return
+		}
+	}
+}
+
+func checkSubscriptionStatus(trackingID string, figi string, status investpb.SubscriptionStatus) error {
+	if status != investpb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
+		return fmt.Errorf("tid %v: figi: %v: unexpected subscription status: %v", trackingID, figi, status)
+	}
+	return nil
+}
+
+// checkSubscriptionStatuses checks that every requested figi is present in statuses and subscribed successfully.
+func checkSubscriptionStatuses(trackingID string, figis []string, statuses map[string]investpb.SubscriptionStatus) error {
+	for _, figi := range figis {
+		status, ok := statuses[figi]
+		if !ok {
+			return fmt.Errorf("tid %v: figi: %v: no response for requested instrument", trackingID, figi)
+		}
+		if err := checkSubscriptionStatus(trackingID, figi, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -10,3 +10,6 @@ func (id AccountID) S() string { return string(id) }
 
 type OrderID string          //
 func (id OrderID) S() string { return string(id) }
+
+type StopOrderID string          //
+func (id StopOrderID) S() string { return string(id) }
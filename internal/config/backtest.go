@@ -0,0 +1,56 @@
+package config
+
+import (
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+const defaultBacktestAccount = "backtest"
+
+// BacktestConfig describes the replay of the recorded market data through the strategies.
+type BacktestConfig struct {
+	Log LogConfig `toml:"log"`
+	// Data is the JSON lines file of the recorded order books, trades or candles.
+	// The relative path is resolved against the config directory.
+	Data string `toml:"data" validate:"required"`
+	// Account is the virtual account the strategies trade with, "backtest" by default.
+	Account string `toml:"account"`
+	// Balance is the initial money by currency, e.g. "rub".
+	Balance map[string]float64 `toml:"balance" validate:"required,dive,gte=0"`
+	// Commission is the part of the trade amount charged for every fill, e.g. 0.0005.
+	Commission float64 `toml:"commission" validate:"gte=0,lt=1"`
+	// AllowShort lets strategies sell the instruments not held.
+	AllowShort bool `toml:"allow_short"`
+	// Instruments replace the instruments info requested from the exchange.
+	Instruments []BacktestInstrument `toml:"instruments" validate:"required,dive"`
+	Strategies  StrategiesConfig     `toml:"strategies" validate:"required,dive"`
+}
+
+type BacktestInstrument struct {
+	FIGI string `toml:"figi" validate:"required"`
+	// Type is "share" by default, bond and futures prices are converted to money by Nominal and PointValue.
+	Type         string  `toml:"type" validate:"omitempty,oneof=share bond etf futures currency"`
+	Currency     string  `toml:"currency" validate:"required"`
+	StocksPerLot int     `toml:"stocks_per_lot" validate:"gt=0"`
+	MinPriceInc  float64 `toml:"min_price_inc" validate:"gt=0"`
+	// Nominal is the bond face value, its price is the percentage of it.
+	Nominal float64 `toml:"nominal" validate:"gte=0"`
+	// PointValue is the money cost of one futures price point.
+	PointValue float64 `toml:"point_value" validate:"gte=0"`
+}
+
+// ParseBacktest reads the backtest TOML file.
+func ParseBacktest(filename string) (cfg BacktestConfig, err error) {
+	if _, err = toml.DecodeFile(filename, &cfg); err != nil {
+		return cfg, err
+	}
+
+	if cfg.Account == "" {
+		cfg.Account = defaultBacktestAccount
+	}
+	if cfg.Data != "" && !filepath.IsAbs(cfg.Data) {
+		cfg.Data = filepath.Join(filepath.Dir(filename), cfg.Data)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,29 @@
+package config_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+)
+
+func TestParseBacktest(t *testing.T) {
+	path := filepath.Join(filepath.Dir(configExamplePath), "backtest.toml.example")
+
+	cfg, err := config.ParseBacktest(path)
+	require.NoError(t, err)
+	require.NoError(t, validator.New().Struct(cfg))
+
+	assert.Equal(t, "backtest", cfg.Account)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "..", "testdata", "backtest", "market-data.jsonl"), cfg.Data)
+	assert.FileExists(t, cfg.Data)
+	assert.True(t, cfg.AllowShort)
+	require.Len(t, cfg.Instruments, 1)
+	assert.Equal(t, 10, cfg.Instruments[0].StocksPerLot)
+	require.Len(t, cfg.Strategies, 2)
+	assert.Equal(t, "spread-parasite", cfg.Strategies[1].InstanceName())
+}
@@ -1,11 +1,22 @@
 package config
 
+import "time"
+
 type Config struct {
-	Log        LogConfig        `toml:"log"`
-	Metrics    MetricsConfig    `toml:"metrics"`
-	Account    AccountConfig    `toml:"account"`
-	Clients    ClientsConfig    `toml:"clients"`
-	Strategies StrategiesConfig `toml:"strategies"`
+	Log     LogConfig     `toml:"log"`
+	Metrics MetricsConfig `toml:"metrics"`
+	Account AccountConfig `toml:"account"`
+	// Accounts trade with their own strategies in one process.
+	// If empty, the single account of Account and Strategies sections is used.
+	Accounts       []TradingAccountConfig `toml:"accounts" validate:"dive"`
+	Clients        ClientsConfig          `toml:"clients"`
+	MarketData     MarketDataConfig       `toml:"market_data"`
+	Reconciliation ReconciliationConfig   `toml:"reconciliation"`
+	Sessions       SessionsConfig         `toml:"sessions"`
+	Reload         ReloadConfig           `toml:"reload"`
+	Risk           RiskConfig             `toml:"risk"`
+	Paper          PaperConfig            `toml:"paper"`
+	Strategies     StrategiesConfig       `toml:"strategies" validate:"dive"`
 }
 
 type LogConfig struct {
@@ -18,8 +29,28 @@ type MetricsConfig struct {
 }
 
 type AccountConfig struct {
-	Number  string `validate:"required"`
+	// Number is required if neither Accounts nor Bootstrap are set.
+	Number  string `toml:"number"`
 	Sandbox bool   `toml:"sandbox"`
+	// Bootstrap makes the robot to use existing or open new sandbox account if Number is empty.
+	Bootstrap bool `toml:"bootstrap"`
+	// BootstrapPayIn is the amount of RUB to top up the opened sandbox account with.
+	BootstrapPayIn float64 `toml:"bootstrap_pay_in" validate:"gte=0"`
+}
+
+type TradingAccountConfig struct {
+	// Number or Name identifies the account among the ones available for the token.
+	Number     string           `toml:"number" validate:"required_without=Name"`
+	Name       string           `toml:"name"`
+	Strategies StrategiesConfig `toml:"strategies" validate:"dive"`
+}
+
+// TradingAccounts returns the accounts to trade with.
+func (c Config) TradingAccounts() []TradingAccountConfig {
+	if len(c.Accounts) > 0 {
+		return c.Accounts
+	}
+	return []TradingAccountConfig{{Number: c.Account.Number, Strategies: c.Strategies}}
 }
 
 type ClientsConfig struct {
@@ -29,28 +60,92 @@ type ClientsConfig struct {
 type TinkoffInvestConfig struct {
 	Address string `toml:"address" validate:"required"`
 	AppName string `toml:"app_name" validate:"required"`
-	Token   string `toml:"token" validate:"required"`
+	Token   Secret `toml:"token" validate:"required"`
+	// TokenFile is read if Token is empty, e.g. for mounted secrets.
+	TokenFile string `toml:"token_file"`
+	// DefaultTimeout is the deadline of unary calls, no deadline if zero.
+	DefaultTimeout Duration `toml:"default_timeout" validate:"gte=0"`
+	// Timeouts override DefaultTimeout by methods.
+	Timeouts []MethodTimeout `toml:"timeouts" validate:"dive"`
+	// OrderRetries is the amount of order placement retries on transient errors, zero disables retries.
+	OrderRetries      int      `toml:"order_retries" validate:"gte=0"`
+	OrderRetryBackoff Duration `toml:"order_retry_backoff" validate:"gte=0"`
+}
+
+type MethodTimeout struct {
+	// Method is like "OrdersService/PostOrder".
+	Method  string   `toml:"method" validate:"required"`
+	Timeout Duration `toml:"timeout" validate:"gte=0"`
+}
+
+type MarketDataConfig struct {
+	// OrderBookBackpressure is applied to order book consumers that do not keep up with the stream.
+	// Coalesce by default.
+	OrderBookBackpressure string `toml:"order_book_backpressure" validate:"omitempty,oneof=drop coalesce block"`
 }
 
-type StrategiesConfig struct {
-	BullsAndBearsMonitoring BullsAndBearsMonitoringConfig `toml:"bulls_and_bears_monitoring"`
-	SpreadParasite          SpreadParasiteConfig          `toml:"spread_parasite"`
+type ReconciliationConfig struct {
+	// OrphanedOrders defines what to do with active orders of configured instruments at the start.
+	// Adopt by default.
+	OrphanedOrders string `toml:"orphaned_orders" validate:"omitempty,oneof=adopt cancel"`
 }
 
-type BullsAndBearsMonitoringConfig struct {
-	Enabled            bool `toml:"enabled"`
-	IgnoreInconsistent bool `toml:"ignore_inconsistent"`
-	Instruments        []struct {
-		FIGI             string  `toml:"figi" validate:"required"`
-		Depth            int     `toml:"depth" validate:"required,oneof=[1 10 20 30 40 50]"`
-		DominanceRatio   float64 `toml:"dominance_ratio" validate:"required,gt=1"`
-		ProfitPercentage float64 `toml:"profit_percentage" validate:"required,gt=0,lte=1"`
-	} `toml:"instruments" validate:"required,dive,min=1"`
+type SessionsConfig struct {
+	// PauseBeforeClose stops placing orders the time before the exchange session end.
+	PauseBeforeClose Duration `toml:"pause_before_close" validate:"gte=0"`
+	// CancelOrdersOnClose makes strategies to cancel resting orders when trading is paused.
+	CancelOrdersOnClose bool `toml:"cancel_orders_on_close"`
+}
+
+type ReloadConfig struct {
+	// WatchFile makes the robot reload the config on its file changes, besides SIGHUP.
+	WatchFile bool `toml:"watch_file"`
+	// WatchInterval is the period of file checks, 5s by default.
+	WatchInterval Duration `toml:"watch_interval" validate:"gte=0"`
+}
+
+// RiskConfig limits the orders of each account, zero disables the limit.
+type RiskConfig struct {
+	Enabled bool `toml:"enabled"`
+	// MaxPositionLots limits the position of instrument together with the open orders increasing it.
+	MaxPositionLots int `toml:"max_position_lots" validate:"gte=0"`
+	// MaxNotional limits the cost of positions and open orders in instruments currencies.
+	MaxNotional        float64 `toml:"max_notional" validate:"gte=0"`
+	MaxOpenOrders      int     `toml:"max_open_orders" validate:"gte=0"`
+	MaxOrdersPerMinute int     `toml:"max_orders_per_minute" validate:"gte=0"`
+	// MaxDailyLoss limits the realized loss since the start of the day (UTC).
+	MaxDailyLoss float64 `toml:"max_daily_loss" validate:"gte=0"`
+	// KillSwitchOn are the violated limits stopping the trading of all accounts until restart.
+	KillSwitchOn []string `toml:"kill_switch_on" validate:"dive,oneof=max_position max_notional max_open_orders max_orders_per_minute max_daily_loss"` //nolint:lll
+	// SyncInterval is the period of open orders sync, 1m by default.
+	SyncInterval Duration `toml:"sync_interval" validate:"gte=0"`
+}
+
+// PaperConfig makes the robot to fill the orders virtually by the live order books instead of placing them.
+type PaperConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Balance is the initial money of each account by currency, e.g. {rub = 1000000}.
+	Balance map[string]float64 `toml:"balance" validate:"dive,gte=0"`
+	// Commission is the part of the trade amount charged for every fill, e.g. 0.0005.
+	Commission float64 `toml:"commission" validate:"gte=0,lt=1"`
+	// Depth of the order books the orders are filled against, 20 by default.
+	Depth int `toml:"depth" validate:"omitempty,oneof=1 10 20 30 40 50"`
+	// AllowShort lets sell the instruments not held, opening the short positions.
+	AllowShort bool `toml:"allow_short"`
+}
+
+// Duration is time.Duration decoded from strings like "5m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
 }
 
-type SpreadParasiteConfig struct {
-	Enabled             bool     `toml:"enabled"`
-	IgnoreInconsistent  bool     `toml:"ignore_inconsistent"`
-	MinSpreadPercentage float64  `toml:"min_spread_percentage" validate:"required,gt=0,lte=1"`
-	Figis               []string `toml:"figis"`
+func (d Duration) D() time.Duration {
+	return time.Duration(d)
 }
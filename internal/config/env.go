@@ -0,0 +1,219 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the names of environment variables overriding the config.
+const EnvPrefix = "TRADING_ROBOT"
+
+// applyEnv overrides the config fields with environment variables named by the path of TOML keys,
+// e.g. TRADING_ROBOT_CLIENTS_TINKFOFF_INVEST_TOKEN. Elements of arrays of tables are addressed by index,
+// e.g. TRADING_ROBOT_ACCOUNTS_0_NUMBER, and must be present in the file, as well as the strategy parameters.
+// Arrays of values are comma-separated.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	return applyEnvToStruct(reflect.ValueOf(cfg).Elem(), EnvPrefix, lookup)
+}
+
+// envApplier is implemented by the config parts with keys unknown in advance.
+type envApplier interface {
+	applyEnv(prefix string, lookup func(string) (string, bool)) error
+}
+
+func applyEnvToStruct(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Tag.Get("toml") == "-" {
+			continue
+		}
+
+		if err := applyEnvToValue(v.Field(i), prefix+"_"+envKey(f), lookup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyEnvToValue(v reflect.Value, name string, lookup func(string) (string, bool)) error {
+	if a, ok := v.Addr().Interface().(envApplier); ok {
+		return a.applyEnv(name, lookup)
+	}
+
+	if _, ok := v.Addr().Interface().(encoding.TextUnmarshaler); !ok {
+		switch {
+		case v.Kind() == reflect.Struct:
+			return applyEnvToStruct(v, name, lookup)
+
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+			for i := 0; i < v.Len(); i++ {
+				if err := applyEnvToValue(v.Index(i), name+"_"+strconv.Itoa(i), lookup); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	s, ok := lookup(name)
+	if !ok {
+		return nil
+	}
+	if err := setFromString(v, s); err != nil {
+		return fmt.Errorf("env %s: %w", name, err)
+	}
+	return nil
+}
+
+func setFromString(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.String:
+		v.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+
+	case reflect.Slice:
+		var parts []string
+		if s = strings.TrimSpace(s); s != "" {
+			parts = strings.Split(s, ",")
+		}
+
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setFromString(slice.Index(i), strings.TrimSpace(p)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// envKey returns the part of variable name for the field, e.g. "TINKFOFF_INVEST".
+func envKey(f reflect.StructField) string {
+	key := f.Name
+	if tag, _, _ := strings.Cut(f.Tag.Get("toml"), ","); tag != "" {
+		key = tag
+	}
+	return envName(key)
+}
+
+func envName(key string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
+
+// applyEnv overrides the common keys and the parameters of the strategy instance,
+// e.g. TRADING_ROBOT_STRATEGIES_0_MIN_SPREAD_PERCENTAGE.
+func (c *StrategyConfig) applyEnv(prefix string, lookup func(string) (string, bool)) error {
+	if err := applyEnvToStruct(reflect.ValueOf(c).Elem(), prefix, lookup); err != nil {
+		return err
+	}
+	return applyEnvToParams(c.Params, prefix, lookup)
+}
+
+// applyEnvToParams overrides the parameters present in the file keeping their TOML types.
+func applyEnvToParams(params map[string]interface{}, prefix string, lookup func(string) (string, bool)) error {
+	for k, v := range params {
+		name := prefix + "_" + envName(k)
+
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if err := applyEnvToParams(v, name, lookup); err != nil {
+				return err
+			}
+
+		case []map[string]interface{}:
+			for i, t := range v {
+				if err := applyEnvToParams(t, name+"_"+strconv.Itoa(i), lookup); err != nil {
+					return err
+				}
+			}
+
+		default:
+			s, ok := lookup(name)
+			if !ok {
+				continue
+			}
+
+			parsed, err := parseParam(v, s)
+			if err != nil {
+				return fmt.Errorf("env %s: %w", name, err)
+			}
+			params[k] = parsed
+		}
+	}
+	return nil
+}
+
+// parseParam parses the string as the value of the same TOML type as current.
+func parseParam(current interface{}, s string) (interface{}, error) {
+	switch current := current.(type) {
+	case string:
+		return s, nil
+
+	case bool:
+		return strconv.ParseBool(s)
+
+	case int64:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+		// Integer in the file could be the value of float parameter.
+		return strconv.ParseFloat(s, 64)
+
+	case float64:
+		return strconv.ParseFloat(s, 64)
+
+	case []interface{}:
+		var elem interface{} = ""
+		if len(current) > 0 {
+			elem = current[0]
+		}
+
+		var parts []string
+		if s = strings.TrimSpace(s); s != "" {
+			parts = strings.Split(s, ",")
+		}
+
+		values := make([]interface{}, len(parts))
+		for i, p := range parts {
+			v, err := parseParam(elem, strings.TrimSpace(p))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", current)
+}
@@ -0,0 +1,138 @@
+package config_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+)
+
+const envTestConfig = `
+[log]
+level = "info"
+
+[account]
+number = "file-account"
+
+[[accounts]]
+number = "first"
+
+[clients.tinkfoff_invest]
+address = "localhost:7171"
+token = "file-token"
+
+[sessions]
+pause_before_close = "5m"
+
+[[strategies]]
+strategy = "spread-parasite"
+min_spread_percentage = 0.02
+figis = ["BBG0029SFXB3"]
+`
+
+func TestParse_EnvOverrides(t *testing.T) {
+	path := writeConfig(t, envTestConfig)
+
+	t.Setenv("TRADING_ROBOT_LOG_LEVEL", "debug")
+	t.Setenv("TRADING_ROBOT_ACCOUNT_SANDBOX", "true")
+	t.Setenv("TRADING_ROBOT_ACCOUNT_BOOTSTRAP_PAY_IN", "1000.5")
+	t.Setenv("TRADING_ROBOT_ACCOUNTS_0_NUMBER", "second")
+	t.Setenv("TRADING_ROBOT_CLIENTS_TINKFOFF_INVEST_TOKEN", "env-token")
+	t.Setenv("TRADING_ROBOT_CLIENTS_TINKFOFF_INVEST_ORDER_RETRIES", "5")
+	t.Setenv("TRADING_ROBOT_SESSIONS_PAUSE_BEFORE_CLOSE", "1m")
+	t.Setenv("TRADING_ROBOT_STRATEGIES_0_ENABLED", "false")
+	t.Setenv("TRADING_ROBOT_STRATEGIES_0_MIN_SPREAD_PERCENTAGE", "0.05")
+	t.Setenv("TRADING_ROBOT_STRATEGIES_0_FIGIS", "BBG004730N88, BBG000BN56Q9")
+
+	cfg, err := config.Parse(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.Equal(t, "file-account", cfg.Account.Number)
+	assert.True(t, cfg.Account.Sandbox)
+	assert.Equal(t, 1000.5, cfg.Account.BootstrapPayIn)
+	require.Len(t, cfg.Accounts, 1)
+	assert.Equal(t, "second", cfg.Accounts[0].Number)
+	assert.Equal(t, "env-token", cfg.Clients.TinkoffInvest.Token.Reveal())
+	assert.Equal(t, 5, cfg.Clients.TinkoffInvest.OrderRetries)
+	assert.Equal(t, time.Minute, cfg.Sessions.PauseBeforeClose.D())
+	require.Len(t, cfg.Strategies, 1)
+	assert.False(t, cfg.Strategies[0].Enabled)
+	assert.Equal(t, 0.05, cfg.Strategies[0].Params["min_spread_percentage"])
+	assert.Equal(t, []interface{}{"BBG004730N88", "BBG000BN56Q9"}, cfg.Strategies[0].Params["figis"])
+}
+
+func TestParse_InvalidEnv(t *testing.T) {
+	path := writeConfig(t, envTestConfig)
+	t.Setenv("TRADING_ROBOT_CLIENTS_TINKFOFF_INVEST_ORDER_RETRIES", "many")
+
+	_, err := config.Parse(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TRADING_ROBOT_CLIENTS_TINKFOFF_INVEST_ORDER_RETRIES")
+}
+
+func TestParse_InvalidStrategyParamEnv(t *testing.T) {
+	path := writeConfig(t, envTestConfig)
+	t.Setenv("TRADING_ROBOT_STRATEGIES_0_MIN_SPREAD_PERCENTAGE", "high")
+
+	_, err := config.Parse(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TRADING_ROBOT_STRATEGIES_0_MIN_SPREAD_PERCENTAGE")
+}
+
+func TestParse_TokenFile(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("secret-token\n"), 0o600))
+
+	path := writeConfig(t, `
+[clients.tinkfoff_invest]
+token_file = "`+tokenPath+`"
+`)
+
+	cfg, err := config.Parse(path)
+	require.NoError(t, err)
+	assert.Equal(t, "secret-token", cfg.Clients.TinkoffInvest.Token.Reveal())
+
+	t.Run("token has priority", func(t *testing.T) {
+		t.Setenv("TRADING_ROBOT_CLIENTS_TINKFOFF_INVEST_TOKEN", "env-token")
+
+		cfg, err := config.Parse(path)
+		require.NoError(t, err)
+		assert.Equal(t, "env-token", cfg.Clients.TinkoffInvest.Token.Reveal())
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("TRADING_ROBOT_CLIENTS_TINKFOFF_INVEST_TOKEN_FILE", filepath.Join(t.TempDir(), "unknown"))
+
+		_, err := config.Parse(path)
+		require.Error(t, err)
+	})
+}
+
+func TestSecret_Redaction(t *testing.T) {
+	var cfg config.Config
+	cfg.Clients.TinkoffInvest.Token = "t.very-secret"
+
+	assert.NotContains(t, fmt.Sprintf("%v", cfg), "very-secret")
+	assert.NotContains(t, fmt.Sprintf("%+v", cfg.Clients), "very-secret")
+
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "very-secret")
+	assert.Contains(t, string(data), "[REDACTED]")
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
@@ -1,8 +1,36 @@
 package config
 
-import "github.com/BurntSushi/toml"
+import (
+	"fmt"
+	"os"
+	"strings"
 
+	"github.com/BurntSushi/toml"
+)
+
+// Parse reads the TOML file and applies environment overrides (see EnvPrefix) and secret files.
 func Parse(filename string) (cfg Config, err error) {
-	_, err = toml.DecodeFile(filename, &cfg)
-	return
+	if _, err = toml.DecodeFile(filename, &cfg); err != nil {
+		return cfg, err
+	}
+
+	if err = applyEnv(&cfg, os.LookupEnv); err != nil {
+		return cfg, err
+	}
+
+	err = cfg.Clients.TinkoffInvest.readTokenFile()
+	return cfg, err
+}
+
+func (c *TinkoffInvestConfig) readTokenFile() error {
+	if c.Token != "" || c.TokenFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.TokenFile)
+	if err != nil {
+		return fmt.Errorf("read token file: %w", err)
+	}
+	c.Token = Secret(strings.TrimSpace(string(data)))
+	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -22,4 +23,11 @@ func TestParse(t *testing.T) {
 	cfg, err := config.Parse(configExamplePath)
 	require.NoError(t, err)
 	assert.NotEmpty(t, cfg.Log.Level)
+	require.Len(t, cfg.TradingAccounts(), 1)
+	assert.Equal(t, cfg.Strategies, cfg.TradingAccounts()[0].Strategies)
+	require.Len(t, cfg.Strategies, 2)
+	assert.Equal(t, "bulls-and-bears-monitoring", cfg.Strategies[0].InstanceName())
+	assert.Equal(t, 5*time.Minute, cfg.Sessions.PauseBeforeClose.D())
+	require.NotEmpty(t, cfg.Clients.TinkoffInvest.Timeouts)
+	assert.Equal(t, 5*time.Second, cfg.Clients.TinkoffInvest.Timeouts[0].Timeout.D())
 }
@@ -0,0 +1,22 @@
+package config
+
+const redacted = "[REDACTED]"
+
+// Secret is a string that is redacted when printed or marshalled, e.g. to logs.
+type Secret string
+
+// Reveal returns the secret value.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// StrategiesConfig declares the strategy instances, either as array of tables
+//
+//	[[strategies]]
+//	strategy = "spread-parasite"
+//	name = "shares"
+//
+// or as legacy tables named by strategies, e.g. [strategies.spread_parasite], disabled by default.
+type StrategiesConfig []StrategyConfig
+
+// StrategyConfig is the strategy instance. The keys besides the common ones are parameters
+// decoded by the strategy registered under the Strategy name.
+type StrategyConfig struct {
+	// Strategy is the registered strategy name, e.g. "spread-parasite".
+	Strategy string `toml:"strategy" validate:"required"`
+	// Name distinguishes the instances of the same strategy within the account, Strategy by default.
+	Name string `toml:"name"`
+	// Enabled is true by default.
+	Enabled bool           `toml:"enabled"`
+	Params  StrategyParams `toml:"-"`
+}
+
+// InstanceName returns the name of the instance unique within the account.
+func (c StrategyConfig) InstanceName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Strategy
+}
+
+// StrategyParams are the raw strategy parameters.
+type StrategyParams map[string]interface{}
+
+// Decode decodes the parameters into the struct with TOML tags, unknown parameters are rejected.
+func (p StrategyParams) Decode(v interface{}) error {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(map[string]interface{}(p)); err != nil {
+		return fmt.Errorf("encode params: %w", err)
+	}
+
+	md, err := toml.Decode(buf.String(), v)
+	if err != nil {
+		return err
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, k := range undecoded {
+			keys[i] = k.String()
+		}
+		return fmt.Errorf("unknown params: %s", strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+func (c *StrategiesConfig) UnmarshalTOML(data interface{}) error {
+	switch data := data.(type) {
+	case []map[string]interface{}:
+		strategies := make(StrategiesConfig, len(data))
+		for i, t := range data {
+			if err := strategies[i].fromTable(t, true); err != nil {
+				return fmt.Errorf("strategies[%d]: %w", i, err)
+			}
+		}
+		*c = strategies
+
+	case map[string]interface{}:
+		names := make([]string, 0, len(data))
+		for name := range data {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		strategies := make(StrategiesConfig, 0, len(data))
+		for _, name := range names {
+			t, ok := data[name].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("strategies.%s: table expected", name)
+			}
+
+			var s StrategyConfig
+			if err := s.fromTable(t, false); err != nil {
+				return fmt.Errorf("strategies.%s: %w", name, err)
+			}
+			if s.Strategy == "" {
+				s.Strategy = strings.ReplaceAll(name, "_", "-")
+			}
+			strategies = append(strategies, s)
+		}
+		*c = strategies
+
+	default:
+		return errors.New("strategies: array of tables expected")
+	}
+	return nil
+}
+
+func (c *StrategyConfig) fromTable(t map[string]interface{}, enabledByDefault bool) error {
+	c.Enabled = enabledByDefault
+	c.Params = make(StrategyParams, len(t))
+
+	for k, v := range t {
+		var ok bool
+		switch k {
+		case "strategy":
+			c.Strategy, ok = v.(string)
+		case "name":
+			c.Name, ok = v.(string)
+		case "enabled":
+			c.Enabled, ok = v.(bool)
+		default:
+			c.Params[k], ok = v, true
+		}
+		if !ok {
+			return fmt.Errorf("invalid %q type: %T", k, v)
+		}
+	}
+	return nil
+}
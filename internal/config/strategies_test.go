@@ -0,0 +1,85 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+)
+
+type testInstrument struct {
+	FIGI  string `toml:"figi"`
+	Depth int    `toml:"depth"`
+}
+
+type testParams struct {
+	Ratio       float64          `toml:"ratio"`
+	Instruments []testInstrument `toml:"instruments"`
+}
+
+func TestParse_Strategies(t *testing.T) {
+	path := writeConfig(t, `
+[[strategies]]
+strategy = "bulls-and-bears-monitoring"
+ratio = 2
+[[strategies.instruments]]
+figi = "BBG004730N88"
+depth = 10
+
+[[strategies]]
+strategy = "bulls-and-bears-monitoring"
+name = "bulls-2"
+enabled = false
+ratio = 3.5
+`)
+	t.Setenv("TRADING_ROBOT_STRATEGIES_0_INSTRUMENTS_0_DEPTH", "20")
+
+	cfg, err := config.Parse(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Strategies, 2)
+
+	first, second := cfg.Strategies[0], cfg.Strategies[1]
+	assert.Equal(t, "bulls-and-bears-monitoring", first.Strategy)
+	assert.Equal(t, "bulls-and-bears-monitoring", first.InstanceName())
+	assert.True(t, first.Enabled)
+	assert.Equal(t, "bulls-2", second.InstanceName())
+	assert.False(t, second.Enabled)
+
+	var params testParams
+	require.NoError(t, first.Params.Decode(&params))
+	assert.Equal(t, testParams{
+		Ratio:       2,
+		Instruments: []testInstrument{{FIGI: "BBG004730N88", Depth: 20}},
+	}, params)
+
+	t.Run("unknown params", func(t *testing.T) {
+		var params struct {
+			Ratio float64 `toml:"ratio"`
+		}
+		err := first.Params.Decode(&params)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "instruments")
+	})
+}
+
+func TestParse_LegacyStrategies(t *testing.T) {
+	path := writeConfig(t, `
+[strategies.spread_parasite]
+min_spread_percentage = 0.02
+
+[strategies.bulls_and_bears_monitoring]
+enabled = true
+`)
+
+	cfg, err := config.Parse(path)
+	require.NoError(t, err)
+
+	require.Len(t, cfg.Strategies, 2)
+	assert.Equal(t, "bulls-and-bears-monitoring", cfg.Strategies[0].Strategy)
+	assert.True(t, cfg.Strategies[0].Enabled)
+	assert.Equal(t, "spread-parasite", cfg.Strategies[1].Strategy)
+	assert.False(t, cfg.Strategies[1].Enabled, "legacy strategies are disabled by default")
+	assert.Equal(t, config.StrategyParams{"min_spread_percentage": 0.02}, cfg.Strategies[1].Params)
+}
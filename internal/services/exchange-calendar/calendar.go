@@ -0,0 +1,151 @@
+package exchangecalendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=mocks/calendar_generated.go -package exchangecalendarmocks SchedulesProvider
+
+const (
+	// horizon is the period of schedules fetched at once.
+	horizon = 7 * 24 * time.Hour
+	day     = 24 * time.Hour
+)
+
+var ErrNoSessions = errors.New("no trading sessions within the horizon")
+
+type SchedulesProvider interface {
+	GetTradingSchedules(ctx context.Context, exchange string, from, to time.Time) ([]tinkoffinvest.TradingSchedule, error)
+}
+
+type SessionKind string
+
+const (
+	SessionMain    SessionKind = "main"
+	SessionEvening SessionKind = "evening"
+)
+
+type Session struct {
+	Exchange string
+	Kind     SessionKind
+	Start    time.Time
+	End      time.Time
+}
+
+// IsActive reports whether the session is in progress at the moment.
+func (s Session) IsActive(at time.Time) bool {
+	return !at.Before(s.Start) && at.Before(s.End)
+}
+
+// Calendar caches trading schedules of exchanges. It is safe for concurrent use.
+type Calendar struct {
+	provider SchedulesProvider
+
+	mu        sync.Mutex
+	exchanges map[string]*schedule
+}
+
+type schedule struct {
+	from, to time.Time
+	days     []tinkoffinvest.TradingDay
+	sessions []Session // Sorted by start.
+}
+
+func New(provider SchedulesProvider) *Calendar {
+	return &Calendar{
+		provider:  provider,
+		exchanges: make(map[string]*schedule),
+	}
+}
+
+// IsTradingDay reports whether the exchange works at the day of the moment.
+func (c *Calendar) IsTradingDay(ctx context.Context, exchange string, at time.Time) (bool, error) {
+	s, err := c.schedule(ctx, exchange, at)
+	if err != nil {
+		return false, err
+	}
+
+	for _, d := range s.days {
+		if !at.Before(d.Date) && at.Before(d.Date.Add(day)) {
+			return d.IsTradingDay, nil
+		}
+	}
+	return false, nil
+}
+
+// CurrentOrNextSession returns the session in progress at the moment or the nearest next one.
+func (c *Calendar) CurrentOrNextSession(ctx context.Context, exchange string, at time.Time) (Session, error) {
+	s, err := c.schedule(ctx, exchange, at)
+	if err != nil {
+		return Session{}, err
+	}
+
+	for _, session := range s.sessions {
+		if at.Before(session.End) {
+			return session, nil
+		}
+	}
+	return Session{}, ErrNoSessions
+}
+
+// schedule returns the cached schedule covering at least a day after the moment.
+func (c *Calendar) schedule(ctx context.Context, exchange string, at time.Time) (*schedule, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.exchanges[exchange]; ok && !at.Before(s.from) && at.Add(day).Before(s.to) {
+		return s, nil
+	}
+
+	from := at.Truncate(day)
+	to := from.Add(horizon)
+
+	schedules, err := c.provider.GetTradingSchedules(ctx, exchange, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("get trading schedules of %q: %w", exchange, err)
+	}
+
+	s := &schedule{from: from, to: to}
+	for _, sch := range schedules {
+		if sch.Exchange != exchange {
+			continue
+		}
+
+		s.days = sch.Days
+		for _, d := range sch.Days {
+			if !d.IsTradingDay {
+				continue
+			}
+			if !d.StartTime.IsZero() && d.EndTime.After(d.StartTime) {
+				s.sessions = append(s.sessions, Session{
+					Exchange: exchange,
+					Kind:     SessionMain,
+					Start:    d.StartTime,
+					End:      d.EndTime,
+				})
+			}
+			if !d.EveningStartTime.IsZero() && d.EveningEndTime.After(d.EveningStartTime) {
+				s.sessions = append(s.sessions, Session{
+					Exchange: exchange,
+					Kind:     SessionEvening,
+					Start:    d.EveningStartTime,
+					End:      d.EveningEndTime,
+				})
+			}
+		}
+	}
+
+	sort.Slice(s.sessions, func(i, j int) bool {
+		return s.sessions[i].Start.Before(s.sessions[j].Start)
+	})
+
+	c.exchanges[exchange] = s
+	return s, nil
+}
@@ -0,0 +1,100 @@
+package exchangecalendar_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	exchangecalendar "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/exchange-calendar"
+	exchangecalendarmocks "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/exchange-calendar/mocks"
+)
+
+const exchange = "MOEX"
+
+var friday = time.Date(2022, 5, 20, 0, 0, 0, 0, time.UTC)
+
+func TestCalendar(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	provider := exchangecalendarmocks.NewMockSchedulesProvider(ctrl)
+	c := exchangecalendar.New(provider)
+
+	days := []tinkoffinvest.TradingDay{
+		{
+			Date:             friday,
+			IsTradingDay:     true,
+			StartTime:        friday.Add(7 * time.Hour),
+			EndTime:          friday.Add(15*time.Hour + 40*time.Minute),
+			EveningStartTime: friday.Add(16*time.Hour + 5*time.Minute),
+			EveningEndTime:   friday.Add(20*time.Hour + 50*time.Minute),
+		},
+		{Date: friday.Add(24 * time.Hour)},
+		{Date: friday.Add(2 * 24 * time.Hour)},
+		{
+			Date:         friday.Add(3 * 24 * time.Hour),
+			IsTradingDay: true,
+			StartTime:    friday.Add(3*24*time.Hour + 7*time.Hour),
+			EndTime:      friday.Add(3*24*time.Hour + 15*time.Hour + 40*time.Minute),
+		},
+	}
+
+	provider.EXPECT().GetTradingSchedules(gomock.Any(), exchange, friday, friday.Add(7*24*time.Hour)).
+		Return([]tinkoffinvest.TradingSchedule{{Exchange: exchange, Days: days}}, nil)
+
+	t.Run("main session", func(t *testing.T) {
+		at := friday.Add(10 * time.Hour)
+
+		s, err := c.CurrentOrNextSession(context.Background(), exchange, at)
+		require.NoError(t, err)
+		assert.Equal(t, exchangecalendar.SessionMain, s.Kind)
+		assert.True(t, s.IsActive(at))
+	})
+
+	t.Run("between sessions", func(t *testing.T) {
+		at := friday.Add(16 * time.Hour)
+
+		s, err := c.CurrentOrNextSession(context.Background(), exchange, at)
+		require.NoError(t, err)
+		assert.Equal(t, exchangecalendar.SessionEvening, s.Kind)
+		assert.False(t, s.IsActive(at))
+		assert.Equal(t, days[0].EveningStartTime, s.Start)
+	})
+
+	t.Run("weekend", func(t *testing.T) {
+		at := friday.Add(24*time.Hour + 12*time.Hour)
+
+		ok, err := c.IsTradingDay(context.Background(), exchange, at)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		s, err := c.CurrentOrNextSession(context.Background(), exchange, at)
+		require.NoError(t, err)
+		assert.Equal(t, days[3].StartTime, s.Start)
+	})
+
+	t.Run("refetch after horizon", func(t *testing.T) {
+		at := friday.Add(6*24*time.Hour + time.Hour)
+		from := friday.Add(6 * 24 * time.Hour)
+
+		provider.EXPECT().GetTradingSchedules(gomock.Any(), exchange, from, from.Add(7*24*time.Hour)).
+			Return(nil, errors.New("unavailable"))
+
+		_, err := c.CurrentOrNextSession(context.Background(), exchange, at)
+		require.Error(t, err)
+	})
+
+	t.Run("no sessions", func(t *testing.T) {
+		provider.EXPECT().GetTradingSchedules(gomock.Any(), "SPB", friday, friday.Add(7*24*time.Hour)).
+			Return(nil, nil)
+
+		_, err := c.CurrentOrNextSession(context.Background(), "SPB", friday)
+		require.ErrorIs(t, err, exchangecalendar.ErrNoSessions)
+	})
+}
@@ -0,0 +1,52 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: calendar.go
+
+// Package exchangecalendarmocks is a generated GoMock package.
+package exchangecalendarmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	tinkoffinvest "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockSchedulesProvider is a mock of SchedulesProvider interface.
+type MockSchedulesProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockSchedulesProviderMockRecorder
+}
+
+// MockSchedulesProviderMockRecorder is the mock recorder for MockSchedulesProvider.
+type MockSchedulesProviderMockRecorder struct {
+	mock *MockSchedulesProvider
+}
+
+// NewMockSchedulesProvider creates a new mock instance.
+func NewMockSchedulesProvider(ctrl *gomock.Controller) *MockSchedulesProvider {
+	mock := &MockSchedulesProvider{ctrl: ctrl}
+	mock.recorder = &MockSchedulesProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSchedulesProvider) EXPECT() *MockSchedulesProviderMockRecorder {
+	return m.recorder
+}
+
+// GetTradingSchedules mocks base method.
+func (m *MockSchedulesProvider) GetTradingSchedules(ctx context.Context, exchange string, from, to time.Time) ([]tinkoffinvest.TradingSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTradingSchedules", ctx, exchange, from, to)
+	ret0, _ := ret[0].([]tinkoffinvest.TradingSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTradingSchedules indicates an expected call of GetTradingSchedules.
+func (mr *MockSchedulesProviderMockRecorder) GetTradingSchedules(ctx, exchange, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTradingSchedules", reflect.TypeOf((*MockSchedulesProvider)(nil).GetTradingSchedules), ctx, exchange, from, to)
+}
@@ -0,0 +1,91 @@
+package marketdatahub
+
+import (
+	"sync"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+)
+
+// orderBookCoalescer keeps the newest unread change of every instrument
+// and delivers the instruments in order of their first unread change.
+type orderBookCoalescer struct {
+	out    chan tinkoffinvest.OrderBookChange
+	notify chan struct{}
+	done   chan struct{}
+
+	mu      sync.Mutex
+	pending map[tinkoffinvest.FIGI]tinkoffinvest.OrderBookChange
+	queue   []tinkoffinvest.FIGI
+}
+
+func newOrderBookCoalescer() *orderBookCoalescer {
+	return &orderBookCoalescer{
+		out:     make(chan tinkoffinvest.OrderBookChange),
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		pending: make(map[tinkoffinvest.FIGI]tinkoffinvest.OrderBookChange),
+	}
+}
+
+// push never blocks.
+func (c *orderBookCoalescer) push(change tinkoffinvest.OrderBookChange) {
+	c.mu.Lock()
+	if _, ok := c.pending[change.FIGI]; ok {
+		orderBookChangesSkipped.With(l{"figi": change.FIGI.S(), "reason": skipReasonCoalesced}).Inc()
+	} else {
+		c.queue = append(c.queue, change.FIGI)
+	}
+	c.pending[change.FIGI] = change
+	c.mu.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (c *orderBookCoalescer) pop() (tinkoffinvest.OrderBookChange, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.queue) == 0 {
+		return tinkoffinvest.OrderBookChange{}, false
+	}
+
+	figi := c.queue[0]
+	c.queue = c.queue[1:]
+
+	change := c.pending[figi]
+	delete(c.pending, figi)
+	return change, true
+}
+
+// run delivers pending changes until the close call. The out channel is closed on exit.
+func (c *orderBookCoalescer) run() {
+	defer close(c.out)
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.notify:
+		}
+
+		for {
+			change, ok := c.pop()
+			if !ok {
+				break
+			}
+
+			select {
+			case <-c.done:
+				return
+			case c.out <- change:
+			}
+		}
+	}
+}
+
+func (c *orderBookCoalescer) close() {
+	close(c.done)
+}
@@ -0,0 +1,605 @@
+package marketdatahub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=mocks/hub_generated.go -package marketdatahubmocks StreamOpener,Stream
+
+const (
+	defaultReconnectDelay = time.Second
+	maxReconnectDelay     = time.Minute
+)
+
+var errHubStopped = errors.New("market data hub is stopped")
+
+type StreamOpener interface {
+	OpenMarketDataStream(ctx context.Context) (tinkoffinvest.MarketDataStream, error)
+}
+
+// Stream is declared for mocks generation only.
+type Stream interface {
+	tinkoffinvest.MarketDataStream
+}
+
+// Hub owns the single market data stream and fans out its updates to any number of consumers.
+// Subscriptions are reference-counted: the instrument is unsubscribed when its last consumer leaves.
+// The broken stream is reopened with exponential backoff and resubscribed to the same instruments.
+// Consumer channels are closed on consumer's context cancellation or when the hub stops.
+type Hub struct {
+	reconnectDelay time.Duration
+	policy         BackpressurePolicy
+	opener         StreamOpener
+	logger         zerolog.Logger
+
+	mu           sync.Mutex
+	stopped      bool
+	stream       tinkoffinvest.MarketDataStream
+	streamCtx    context.Context //nolint:containedctx
+	consumers    map[topic]map[*consumer]struct{}
+	gapConsumers map[chan Gap]struct{}
+}
+
+// BackpressurePolicy defines what to do with order book changes the consumer does not keep up with.
+type BackpressurePolicy string
+
+const (
+	// BackpressureDrop drops the change if the consumer is busy.
+	BackpressureDrop BackpressurePolicy = "drop"
+	// BackpressureCoalesce keeps the newest unread change of every instrument.
+	BackpressureCoalesce BackpressurePolicy = "coalesce"
+	// BackpressureBlock stalls the whole stream until the consumer reads the change.
+	BackpressureBlock BackpressurePolicy = "block"
+)
+
+// Gap notifies that market data could be lost between LostAt and RestoredAt.
+// Consumers should drop the state built on the stream data.
+type Gap struct {
+	LostAt     time.Time
+	RestoredAt time.Time
+}
+
+type topicKind int
+
+const (
+	topicOrderBook topicKind = iota
+	topicCandles
+	topicTrades
+	topicLastPrice
+	topicTradingStatus
+)
+
+func (k topicKind) String() string {
+	switch k {
+	case topicOrderBook:
+		return "order_book"
+	case topicCandles:
+		return "candle"
+	case topicTrades:
+		return "trade"
+	case topicLastPrice:
+		return "last_price"
+	case topicTradingStatus:
+		return "trading_status"
+	}
+	return "unknown"
+}
+
+type topic struct {
+	kind topicKind
+	figi tinkoffinvest.FIGI
+	// param is order book depth or candle interval.
+	param int
+}
+
+type consumer struct {
+	topics []topic
+	// deliver must not block longer than the ctx lives.
+	deliver func(ctx context.Context, e tinkoffinvest.MarketDataEvent)
+	close   func()
+
+	// mu serializes the delivery with the closing, as events are delivered outside the hub lock.
+	mu     sync.Mutex
+	closed bool
+}
+
+func (c *consumer) send(ctx context.Context, e tinkoffinvest.MarketDataEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.deliver(ctx, e)
+	}
+}
+
+func (c *consumer) shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		c.close()
+	}
+}
+
+// New creates the hub. Zero values of reconnectDelay and policy mean defaults.
+func New(reconnectDelay time.Duration, policy BackpressurePolicy, opener StreamOpener) (*Hub, error) {
+	if reconnectDelay <= 0 {
+		reconnectDelay = defaultReconnectDelay
+	}
+
+	switch policy {
+	case "":
+		policy = BackpressureCoalesce
+	case BackpressureDrop, BackpressureCoalesce, BackpressureBlock:
+	default:
+		return nil, fmt.Errorf("unknown backpressure policy: %q", policy)
+	}
+
+	return &Hub{
+		reconnectDelay: reconnectDelay,
+		policy:         policy,
+		opener:         opener,
+		logger:         log.With().Str("service", "market-data-hub").Logger(),
+		consumers:      make(map[topic]map[*consumer]struct{}),
+		gapConsumers:   make(map[chan Gap]struct{}),
+	}, nil
+}
+
+// Run opens the stream, subscribes for the already requested instruments and dispatches updates.
+// Run reconnects until the ctx is cancelled.
+func (h *Hub) Run(ctx context.Context) error {
+	defer h.stop()
+
+	var (
+		delay     = h.reconnectDelay
+		connected bool
+		lostAt    time.Time
+	)
+
+	for {
+		streamCtx, cancel := context.WithCancel(ctx)
+
+		stream, err := h.connect(streamCtx)
+		if err == nil {
+			if connected {
+				h.notifyGap(Gap{LostAt: lostAt, RestoredAt: time.Now()})
+			}
+			connected = true
+			streamUp.Set(1)
+
+			var received bool
+			received, err = h.listen(streamCtx, stream)
+			if received {
+				delay = h.reconnectDelay
+			}
+			lostAt = time.Now()
+		}
+
+		h.detach()
+		cancel()
+		streamUp.Set(0)
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		reconnectsTotal.Inc()
+		h.logger.Err(err).Dur("delay", delay).Msg("market data stream is lost, reconnect")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+func (h *Hub) connect(ctx context.Context) (tinkoffinvest.MarketDataStream, error) {
+	stream, err := h.opener.OpenMarketDataStream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("open market data stream: %w", err)
+	}
+
+	if err := h.attach(ctx, stream); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// listen dispatches stream events until the stream closing.
+// Returns true if at least one event was received.
+func (h *Hub) listen(ctx context.Context, stream tinkoffinvest.MarketDataStream) (bool, error) {
+	var received bool
+	for e := range stream.Events() {
+		received = true
+		h.dispatch(ctx, e)
+	}
+
+	if err := stream.Err(); err != nil {
+		return received, fmt.Errorf("market data stream: %w", err)
+	}
+	return received, nil
+}
+
+// SubscribeForGaps allows to know about stream reconnections.
+// Only the last gap is kept if the consumer is busy.
+func (h *Hub) SubscribeForGaps(ctx context.Context) (<-chan Gap, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stopped {
+		return nil, errHubStopped
+	}
+
+	ch := make(chan Gap, 1)
+	h.gapConsumers[ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if _, ok := h.gapConsumers[ch]; ok {
+			delete(h.gapConsumers, ch)
+			close(ch)
+		}
+	}()
+	return ch, nil
+}
+
+func (h *Hub) notifyGap(g Gap) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.gapConsumers {
+		select {
+		case <-ch: // Drop the unprocessed gap.
+		default:
+		}
+		ch <- g
+	}
+}
+
+// SubscribeForOrderBookChanges delivers the changes according to the hub's backpressure policy.
+func (h *Hub) SubscribeForOrderBookChanges(
+	ctx context.Context,
+	reqs []tinkoffinvest.OrderBookRequest,
+) (<-chan tinkoffinvest.OrderBookChange, error) {
+	topics := make([]topic, len(reqs))
+	for i, r := range reqs {
+		topics[i] = topic{kind: topicOrderBook, figi: r.FIGI, param: r.Depth}
+	}
+
+	c := &consumer{topics: topics}
+
+	var out <-chan tinkoffinvest.OrderBookChange
+	switch h.policy {
+	case BackpressureCoalesce:
+		coalescer := newOrderBookCoalescer()
+		go coalescer.run()
+
+		out = coalescer.out
+		c.deliver = func(_ context.Context, e tinkoffinvest.MarketDataEvent) { coalescer.push(*e.OrderBook) }
+		c.close = coalescer.close
+
+	case BackpressureBlock:
+		ch := make(chan tinkoffinvest.OrderBookChange)
+		out = ch
+		c.deliver = func(streamCtx context.Context, e tinkoffinvest.MarketDataEvent) {
+			select {
+			case <-streamCtx.Done():
+			case <-ctx.Done():
+			case ch <- *e.OrderBook:
+			}
+		}
+		c.close = func() { close(ch) }
+
+	default:
+		ch := make(chan tinkoffinvest.OrderBookChange)
+		out = ch
+		c.deliver = func(_ context.Context, e tinkoffinvest.MarketDataEvent) {
+			select {
+			case ch <- *e.OrderBook:
+			default:
+				orderBookChangesSkipped.With(l{"figi": e.OrderBook.FIGI.S(), "reason": skipReasonDropped}).Inc()
+			}
+		}
+		c.close = func() { close(ch) }
+	}
+
+	if err := h.subscribe(ctx, c); err != nil {
+		c.shutdown()
+		return nil, err
+	}
+	return out, nil
+}
+
+func (h *Hub) SubscribeForCandles(
+	ctx context.Context,
+	reqs []tinkoffinvest.CandleRequest,
+) (<-chan tinkoffinvest.Candle, error) {
+	topics := make([]topic, len(reqs))
+	for i, r := range reqs {
+		topics[i] = topic{kind: topicCandles, figi: r.FIGI, param: int(r.Interval)}
+	}
+
+	ch := make(chan tinkoffinvest.Candle)
+	if err := h.subscribe(ctx, &consumer{
+		topics: topics,
+		deliver: func(_ context.Context, e tinkoffinvest.MarketDataEvent) {
+			select {
+			case ch <- *e.Candle:
+			default:
+				eventsDropped.With(l{"kind": topicCandles.String(), "figi": e.Candle.FIGI.S()}).Inc()
+			}
+		},
+		close: func() { close(ch) },
+	}); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (h *Hub) SubscribeForTrades(ctx context.Context, figis []tinkoffinvest.FIGI) (<-chan tinkoffinvest.Trade, error) {
+	ch := make(chan tinkoffinvest.Trade)
+	if err := h.subscribe(ctx, &consumer{
+		topics: figiTopics(topicTrades, figis),
+		deliver: func(_ context.Context, e tinkoffinvest.MarketDataEvent) {
+			select {
+			case ch <- *e.Trade:
+			default:
+				eventsDropped.With(l{"kind": topicTrades.String(), "figi": e.Trade.FIGI.S()}).Inc()
+			}
+		},
+		close: func() { close(ch) },
+	}); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (h *Hub) SubscribeForLastPrices(ctx context.Context, figis []tinkoffinvest.FIGI) (<-chan tinkoffinvest.LastPrice, error) {
+	ch := make(chan tinkoffinvest.LastPrice)
+	if err := h.subscribe(ctx, &consumer{
+		topics: figiTopics(topicLastPrice, figis),
+		deliver: func(_ context.Context, e tinkoffinvest.MarketDataEvent) {
+			select {
+			case ch <- *e.LastPrice:
+			default:
+				eventsDropped.With(l{"kind": topicLastPrice.String(), "figi": e.LastPrice.FIGI.S()}).Inc()
+			}
+		},
+		close: func() { close(ch) },
+	}); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (h *Hub) SubscribeForTradingStatuses(
+	ctx context.Context,
+	figis []tinkoffinvest.FIGI,
+) (<-chan tinkoffinvest.TradingStatus, error) {
+	// Status changes are rare and important, so the channel is buffered instead of dropping.
+	ch := make(chan tinkoffinvest.TradingStatus, len(figis))
+	if err := h.subscribe(ctx, &consumer{
+		topics: figiTopics(topicTradingStatus, figis),
+		deliver: func(_ context.Context, e tinkoffinvest.MarketDataEvent) {
+			select {
+			case ch <- *e.TradingStatus:
+			default:
+				eventsDropped.With(l{"kind": topicTradingStatus.String(), "figi": e.TradingStatus.FIGI.S()}).Inc()
+				h.logger.Warn().Str("figi", e.TradingStatus.FIGI.S()).Msg("trading status consumer is too slow")
+			}
+		},
+		close: func() { close(ch) },
+	}); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func figiTopics(kind topicKind, figis []tinkoffinvest.FIGI) []topic {
+	topics := make([]topic, len(figis))
+	for i, f := range figis {
+		topics[i] = topic{kind: kind, figi: f}
+	}
+	return topics
+}
+
+func (h *Hub) subscribe(ctx context.Context, c *consumer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stopped {
+		return errHubStopped
+	}
+
+	var newTopics []topic
+	for _, t := range c.topics {
+		cs, ok := h.consumers[t]
+		if !ok {
+			cs = make(map[*consumer]struct{})
+			h.consumers[t] = cs
+		}
+		if len(cs) == 0 {
+			newTopics = append(newTopics, t)
+		}
+		cs[c] = struct{}{}
+	}
+
+	if h.stream != nil && len(newTopics) > 0 {
+		// The failed stream is reopened and subscribed for all the topics having consumers.
+		if err := h.stream.Subscribe(topicsToSubscription(newTopics)); err != nil {
+			h.logger.Warn().Err(err).Msg("subscribe, wait for reconnection")
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		h.unsubscribe(c)
+	}()
+	return nil
+}
+
+func (h *Hub) unsubscribe(c *consumer) {
+	h.mu.Lock()
+	if h.stopped {
+		h.mu.Unlock()
+		return // Consumer is already closed.
+	}
+
+	unused := h.removeConsumer(c)
+	if h.stream != nil && h.streamCtx.Err() == nil && len(unused) > 0 {
+		if err := h.stream.Unsubscribe(topicsToSubscription(unused)); err != nil {
+			h.logger.Warn().Err(err).Msg("unsubscribe")
+		}
+	}
+	h.mu.Unlock()
+
+	// The consumer could be receiving the event being dispatched.
+	c.shutdown()
+}
+
+// removeConsumer forgets the consumer and returns topics without consumers.
+// Must be called under the lock.
+func (h *Hub) removeConsumer(c *consumer) []topic {
+	var unused []topic
+	for _, t := range c.topics {
+		cs := h.consumers[t]
+		if _, ok := cs[c]; !ok {
+			continue // Duplicated topic.
+		}
+
+		delete(cs, c)
+		if len(cs) == 0 {
+			delete(h.consumers, t)
+			unused = append(unused, t)
+		}
+	}
+	return unused
+}
+
+func (h *Hub) dispatch(ctx context.Context, e tinkoffinvest.MarketDataEvent) {
+	var t topic
+	switch {
+	case e.OrderBook != nil:
+		t = topic{kind: topicOrderBook, figi: e.OrderBook.FIGI, param: e.OrderBook.Depth}
+	case e.Candle != nil:
+		t = topic{kind: topicCandles, figi: e.Candle.FIGI, param: int(e.Candle.Interval)}
+	case e.Trade != nil:
+		t = topic{kind: topicTrades, figi: e.Trade.FIGI}
+	case e.LastPrice != nil:
+		t = topic{kind: topicLastPrice, figi: e.LastPrice.FIGI}
+	case e.TradingStatus != nil:
+		t = topic{kind: topicTradingStatus, figi: e.TradingStatus.FIGI}
+	default:
+		return
+	}
+
+	// Consumers are copied to deliver without the lock, so the blocking consumer does not stall the hub.
+	h.mu.Lock()
+	cs := make([]*consumer, 0, len(h.consumers[t]))
+	for c := range h.consumers[t] {
+		cs = append(cs, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range cs {
+		c.send(ctx, e)
+	}
+}
+
+// attach makes the stream current and subscribes it for all topics having consumers.
+func (h *Hub) attach(ctx context.Context, stream tinkoffinvest.MarketDataStream) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stream, h.streamCtx = stream, ctx
+	if sub := h.activeSubscription(); !sub.IsEmpty() {
+		if err := stream.Subscribe(sub); err != nil {
+			return fmt.Errorf("subscribe: %w", err)
+		}
+	}
+	return nil
+}
+
+func (h *Hub) detach() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stream, h.streamCtx = nil, nil
+}
+
+// activeSubscription builds the subscription for all topics having consumers.
+// Must be called under the lock.
+func (h *Hub) activeSubscription() tinkoffinvest.MarketDataSubscription {
+	topics := make([]topic, 0, len(h.consumers))
+	for t := range h.consumers {
+		topics = append(topics, t)
+	}
+	return topicsToSubscription(topics)
+}
+
+func (h *Hub) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stopped {
+		return
+	}
+	h.stopped = true
+
+	closed := make(map[*consumer]struct{})
+	for _, cs := range h.consumers {
+		for c := range cs {
+			if _, ok := closed[c]; !ok {
+				c.shutdown()
+				closed[c] = struct{}{}
+			}
+		}
+	}
+	h.consumers = nil
+
+	for ch := range h.gapConsumers {
+		close(ch)
+	}
+	h.gapConsumers = nil
+}
+
+func topicsToSubscription(topics []topic) tinkoffinvest.MarketDataSubscription {
+	var sub tinkoffinvest.MarketDataSubscription
+	for _, t := range topics {
+		switch t.kind {
+		case topicOrderBook:
+			sub.OrderBooks = append(sub.OrderBooks, tinkoffinvest.OrderBookRequest{FIGI: t.figi, Depth: t.param})
+		case topicCandles:
+			sub.Candles = append(sub.Candles, tinkoffinvest.CandleRequest{
+				FIGI:     t.figi,
+				Interval: tinkoffinvest.CandleInterval(t.param),
+			})
+		case topicTrades:
+			sub.Trades = append(sub.Trades, t.figi)
+		case topicLastPrice:
+			sub.LastPrices = append(sub.LastPrices, t.figi)
+		case topicTradingStatus:
+			sub.TradingStatuses = append(sub.TradingStatuses, t.figi)
+		}
+	}
+	return sub
+}
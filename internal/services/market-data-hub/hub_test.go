@@ -0,0 +1,269 @@
+package marketdatahub_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	marketdatahub "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/market-data-hub"
+	marketdatahubmocks "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/market-data-hub/mocks"
+)
+
+const (
+	figiA = tinkoffinvest.FIGI("BBG004730N88")
+	figiB = tinkoffinvest.FIGI("BBG000SR0YS4")
+	figiC = tinkoffinvest.FIGI("BBG0029SFXB3")
+)
+
+func TestHub(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	opener := marketdatahubmocks.NewMockStreamOpener(ctrl)
+	stream := marketdatahubmocks.NewMockStream(ctrl)
+	h, err := marketdatahub.New(10*time.Millisecond, marketdatahub.BackpressureDrop, opener)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Subscribe before the stream is opened.
+
+	ctx1, cancel1 := context.WithCancel(ctx)
+	defer cancel1()
+	statuses1, err := h.SubscribeForTradingStatuses(ctx1, []tinkoffinvest.FIGI{figiA})
+	require.NoError(t, err)
+
+	ctx2, cancel2 := context.WithCancel(ctx)
+	defer cancel2()
+	statuses2, err := h.SubscribeForTradingStatuses(ctx2, []tinkoffinvest.FIGI{figiA, figiB})
+	require.NoError(t, err)
+
+	// Run.
+
+	events := make(chan tinkoffinvest.MarketDataEvent)
+	opener.EXPECT().OpenMarketDataStream(gomock.Any()).Return(stream, nil)
+	stream.EXPECT().Events().Return(events)
+	stream.EXPECT().Subscribe(gomock.Any()).DoAndReturn(func(s tinkoffinvest.MarketDataSubscription) error {
+		assert.ElementsMatch(t, []tinkoffinvest.FIGI{figiA, figiB}, s.TradingStatuses)
+		assert.Empty(t, s.OrderBooks)
+		return nil
+	})
+
+	runErr := make(chan error)
+	go func() { runErr <- h.Run(ctx) }()
+
+	t.Run("fan out", func(t *testing.T) {
+		events <- tinkoffinvest.MarketDataEvent{
+			TradingStatus: &tinkoffinvest.TradingStatus{FIGI: figiA, Code: tinkoffinvest.TradingStatusBreakInTrading},
+		}
+		events <- tinkoffinvest.MarketDataEvent{
+			TradingStatus: &tinkoffinvest.TradingStatus{FIGI: figiB, Code: tinkoffinvest.TradingStatusNormalTrading},
+		}
+
+		assert.Equal(t, figiA, (<-statuses1).FIGI)
+		assert.Equal(t, figiA, (<-statuses2).FIGI)
+		assert.Equal(t, figiB, (<-statuses2).FIGI)
+	})
+
+	t.Run("no unsubscribe while topic has consumers", func(t *testing.T) {
+		cancel1()
+		_, ok := <-statuses1
+		assert.False(t, ok)
+	})
+
+	var orderBooks <-chan tinkoffinvest.OrderBookChange
+
+	t.Run("subscribe on the fly", func(t *testing.T) {
+		stream.EXPECT().Subscribe(tinkoffinvest.MarketDataSubscription{
+			OrderBooks: []tinkoffinvest.OrderBookRequest{{FIGI: figiC, Depth: 1}},
+		}).Return(nil)
+
+		orderBooks, err = h.SubscribeForOrderBookChanges(ctx, []tinkoffinvest.OrderBookRequest{{FIGI: figiC, Depth: 1}})
+		require.NoError(t, err)
+	})
+
+	var trades <-chan tinkoffinvest.Trade
+
+	t.Run("failed subscription is left to reconnection", func(t *testing.T) {
+		stream.EXPECT().Subscribe(tinkoffinvest.MarketDataSubscription{
+			Trades: []tinkoffinvest.FIGI{figiA},
+		}).Return(errors.New("send: broken pipe"))
+
+		trades, err = h.SubscribeForTrades(ctx, []tinkoffinvest.FIGI{figiA})
+		require.NoError(t, err)
+	})
+
+	t.Run("last consumer leaves", func(t *testing.T) {
+		stream.EXPECT().Unsubscribe(gomock.Any()).DoAndReturn(func(s tinkoffinvest.MarketDataSubscription) error {
+			assert.ElementsMatch(t, []tinkoffinvest.FIGI{figiA, figiB}, s.TradingStatuses)
+			return nil
+		})
+
+		cancel2()
+		_, ok := <-statuses2
+		assert.False(t, ok)
+	})
+
+	gaps, err := h.SubscribeForGaps(ctx)
+	require.NoError(t, err)
+
+	stream2 := marketdatahubmocks.NewMockStream(ctrl)
+	events2 := make(chan tinkoffinvest.MarketDataEvent)
+
+	t.Run("reconnect and resubscribe", func(t *testing.T) {
+
+		stream.EXPECT().Err().Return(errors.New("connection reset"))
+		opener.EXPECT().OpenMarketDataStream(gomock.Any()).Return(stream2, nil)
+		stream2.EXPECT().Subscribe(tinkoffinvest.MarketDataSubscription{
+			OrderBooks: []tinkoffinvest.OrderBookRequest{{FIGI: figiC, Depth: 1}},
+			Trades:     []tinkoffinvest.FIGI{figiA},
+		}).Return(nil)
+		stream2.EXPECT().Events().Return(events2)
+
+		lostAt := time.Now()
+		close(events)
+
+		gap := <-gaps
+		assert.False(t, gap.LostAt.Before(lostAt))
+		assert.True(t, gap.RestoredAt.After(gap.LostAt))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			change := <-orderBooks
+			assert.Equal(t, figiC, change.FIGI)
+		}()
+
+		require.Eventually(t, func() bool {
+			select {
+			case events2 <- tinkoffinvest.MarketDataEvent{
+				OrderBook: &tinkoffinvest.OrderBookChange{OrderBook: tinkoffinvest.OrderBook{FIGI: figiC}, Depth: 1},
+			}:
+			case <-done:
+				return true
+			}
+			return false
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		stream2.EXPECT().Err().Return(context.Canceled)
+		cancel()
+		close(events2) // The real stream is closed on context cancellation.
+
+		require.NoError(t, <-runErr)
+		_, ok := <-orderBooks
+		assert.False(t, ok)
+		_, ok = <-trades
+		assert.False(t, ok)
+		_, ok = <-gaps
+		assert.False(t, ok)
+
+		_, err := h.SubscribeForTrades(ctx, []tinkoffinvest.FIGI{figiA})
+		require.Error(t, err)
+	})
+}
+
+func TestHub_Backpressure(t *testing.T) {
+	run := func(t *testing.T, policy marketdatahub.BackpressurePolicy) (
+		*marketdatahub.Hub,
+		chan<- tinkoffinvest.MarketDataEvent,
+		<-chan tinkoffinvest.OrderBookChange,
+		func(),
+	) {
+		t.Helper()
+
+		ctrl := gomock.NewController(t)
+
+		opener := marketdatahubmocks.NewMockStreamOpener(ctrl)
+		stream := marketdatahubmocks.NewMockStream(ctrl)
+		h, err := marketdatahub.New(time.Millisecond, policy, opener)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		events := make(chan tinkoffinvest.MarketDataEvent)
+		opener.EXPECT().OpenMarketDataStream(gomock.Any()).Return(stream, nil)
+		stream.EXPECT().Events().Return(events)
+		stream.EXPECT().Subscribe(gomock.Any()).Return(nil)
+		stream.EXPECT().Err().Return(context.Canceled)
+
+		changes, err := h.SubscribeForOrderBookChanges(ctx, []tinkoffinvest.OrderBookRequest{
+			{FIGI: figiA, Depth: 1},
+			{FIGI: figiB, Depth: 1},
+		})
+		require.NoError(t, err)
+
+		runErr := make(chan error)
+		go func() { runErr <- h.Run(ctx) }()
+
+		return h, events, changes, func() {
+			cancel()
+			close(events)
+			require.NoError(t, <-runErr)
+			ctrl.Finish()
+		}
+	}
+
+	change := func(figi tinkoffinvest.FIGI, n int) tinkoffinvest.MarketDataEvent {
+		return tinkoffinvest.MarketDataEvent{OrderBook: &tinkoffinvest.OrderBookChange{
+			OrderBook: tinkoffinvest.OrderBook{FIGI: figi},
+			Depth:     1,
+			FormedAt:  time.Unix(int64(n), 0),
+		}}
+	}
+
+	t.Run("unknown policy", func(t *testing.T) {
+		_, err := marketdatahub.New(0, "lossy", nil)
+		require.Error(t, err)
+	})
+
+	t.Run("coalesce", func(t *testing.T) {
+		_, events, changes, stop := run(t, marketdatahub.BackpressureCoalesce)
+		defer stop()
+
+		// The stream is not blocked by the busy consumer.
+		for i := 1; i <= 100; i++ {
+			events <- change(figiA, i)
+		}
+		events <- change(figiB, 1)
+
+		// At most one stale change could be already taken for delivery.
+		latest := make(map[tinkoffinvest.FIGI]int64)
+		for i := 0; i < 3 && (latest[figiA] != 100 || latest[figiB] != 1); i++ {
+			c := <-changes
+			latest[c.FIGI] = c.FormedAt.Unix()
+		}
+		assert.Equal(t, map[tinkoffinvest.FIGI]int64{figiA: 100, figiB: 1}, latest)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		h, events, changes, stop := run(t, marketdatahub.BackpressureBlock)
+		defer stop()
+
+		events <- change(figiA, 1)
+
+		select {
+		case events <- change(figiB, 2):
+			t.Fatal("stream must be blocked by the busy consumer")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		// The hub is not locked by the busy consumer.
+		gapsCtx, cancelGaps := context.WithCancel(context.Background())
+		defer cancelGaps()
+		_, err := h.SubscribeForGaps(gapsCtx)
+		require.NoError(t, err)
+
+		assert.Equal(t, figiA, (<-changes).FIGI)
+		events <- change(figiB, 2)
+		assert.Equal(t, figiB, (<-changes).FIGI)
+	})
+}
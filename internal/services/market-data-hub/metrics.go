@@ -0,0 +1,45 @@
+package marketdatahub
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const subsystem = "market_data"
+
+const (
+	skipReasonDropped   = "dropped"
+	skipReasonCoalesced = "coalesced"
+)
+
+type l = prometheus.Labels
+
+var (
+	reconnectsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "reconnects_total",
+		Help:      "Total amount of market data stream reconnections",
+	})
+
+	streamUp = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "stream_up",
+		Help:      "Whether the market data stream is connected",
+	})
+
+	orderBookChangesSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "order_book_changes_skipped_total",
+		Help:      "Total amount of order book changes dropped or coalesced for slow consumers",
+	}, []string{"figi", "reason"})
+
+	eventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "events_dropped_total",
+		Help:      "Total amount of candles, trades, last prices and trading statuses dropped for slow consumers",
+	}, []string{"kind", "figi"})
+)
@@ -0,0 +1,130 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hub.go
+
+// Package marketdatahubmocks is a generated GoMock package.
+package marketdatahubmocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	tinkoffinvest "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockStreamOpener is a mock of StreamOpener interface.
+type MockStreamOpener struct {
+	ctrl     *gomock.Controller
+	recorder *MockStreamOpenerMockRecorder
+}
+
+// MockStreamOpenerMockRecorder is the mock recorder for MockStreamOpener.
+type MockStreamOpenerMockRecorder struct {
+	mock *MockStreamOpener
+}
+
+// NewMockStreamOpener creates a new mock instance.
+func NewMockStreamOpener(ctrl *gomock.Controller) *MockStreamOpener {
+	mock := &MockStreamOpener{ctrl: ctrl}
+	mock.recorder = &MockStreamOpenerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStreamOpener) EXPECT() *MockStreamOpenerMockRecorder {
+	return m.recorder
+}
+
+// OpenMarketDataStream mocks base method.
+func (m *MockStreamOpener) OpenMarketDataStream(ctx context.Context) (tinkoffinvest.MarketDataStream, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenMarketDataStream", ctx)
+	ret0, _ := ret[0].(tinkoffinvest.MarketDataStream)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OpenMarketDataStream indicates an expected call of OpenMarketDataStream.
+func (mr *MockStreamOpenerMockRecorder) OpenMarketDataStream(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenMarketDataStream", reflect.TypeOf((*MockStreamOpener)(nil).OpenMarketDataStream), ctx)
+}
+
+// MockStream is a mock of Stream interface.
+type MockStream struct {
+	ctrl     *gomock.Controller
+	recorder *MockStreamMockRecorder
+}
+
+// MockStreamMockRecorder is the mock recorder for MockStream.
+type MockStreamMockRecorder struct {
+	mock *MockStream
+}
+
+// NewMockStream creates a new mock instance.
+func NewMockStream(ctrl *gomock.Controller) *MockStream {
+	mock := &MockStream{ctrl: ctrl}
+	mock.recorder = &MockStreamMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStream) EXPECT() *MockStreamMockRecorder {
+	return m.recorder
+}
+
+// Err mocks base method.
+func (m *MockStream) Err() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Err")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Err indicates an expected call of Err.
+func (mr *MockStreamMockRecorder) Err() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Err", reflect.TypeOf((*MockStream)(nil).Err))
+}
+
+// Events mocks base method.
+func (m *MockStream) Events() <-chan tinkoffinvest.MarketDataEvent {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Events")
+	ret0, _ := ret[0].(<-chan tinkoffinvest.MarketDataEvent)
+	return ret0
+}
+
+// Events indicates an expected call of Events.
+func (mr *MockStreamMockRecorder) Events() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Events", reflect.TypeOf((*MockStream)(nil).Events))
+}
+
+// Subscribe mocks base method.
+func (m *MockStream) Subscribe(s tinkoffinvest.MarketDataSubscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockStreamMockRecorder) Subscribe(s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockStream)(nil).Subscribe), s)
+}
+
+// Unsubscribe mocks base method.
+func (m *MockStream) Unsubscribe(s tinkoffinvest.MarketDataSubscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unsubscribe", s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unsubscribe indicates an expected call of Unsubscribe.
+func (mr *MockStreamMockRecorder) Unsubscribe(s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unsubscribe", reflect.TypeOf((*MockStream)(nil).Unsubscribe), s)
+}
@@ -0,0 +1,145 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: reconciler.go
+
+// Package orderreconcilermocks is a generated GoMock package.
+package orderreconcilermocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	tinkoffinvest "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockClient is a mock of Client interface.
+type MockClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockClientMockRecorder
+}
+
+// MockClientMockRecorder is the mock recorder for MockClient.
+type MockClientMockRecorder struct {
+	mock *MockClient
+}
+
+// NewMockClient creates a new mock instance.
+func NewMockClient(ctrl *gomock.Controller) *MockClient {
+	mock := &MockClient{ctrl: ctrl}
+	mock.recorder = &MockClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClient) EXPECT() *MockClientMockRecorder {
+	return m.recorder
+}
+
+// CancelOrder mocks base method.
+func (m *MockClient) CancelOrder(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelOrder", ctx, accountID, orderID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelOrder indicates an expected call of CancelOrder.
+func (mr *MockClientMockRecorder) CancelOrder(ctx, accountID, orderID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelOrder", reflect.TypeOf((*MockClient)(nil).CancelOrder), ctx, accountID, orderID)
+}
+
+// GetOrders mocks base method.
+func (m *MockClient) GetOrders(ctx context.Context, accountID tinkoffinvest.AccountID) ([]tinkoffinvest.ActiveOrder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrders", ctx, accountID)
+	ret0, _ := ret[0].([]tinkoffinvest.ActiveOrder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrders indicates an expected call of GetOrders.
+func (mr *MockClientMockRecorder) GetOrders(ctx, accountID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockClient)(nil).GetOrders), ctx, accountID)
+}
+
+// GetPortfolio mocks base method.
+func (m *MockClient) GetPortfolio(ctx context.Context, accountID tinkoffinvest.AccountID) (*tinkoffinvest.Portfolio, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPortfolio", ctx, accountID)
+	ret0, _ := ret[0].(*tinkoffinvest.Portfolio)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPortfolio indicates an expected call of GetPortfolio.
+func (mr *MockClientMockRecorder) GetPortfolio(ctx, accountID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPortfolio", reflect.TypeOf((*MockClient)(nil).GetPortfolio), ctx, accountID)
+}
+
+// MockAdopter is a mock of Adopter interface.
+type MockAdopter struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdopterMockRecorder
+}
+
+// MockAdopterMockRecorder is the mock recorder for MockAdopter.
+type MockAdopterMockRecorder struct {
+	mock *MockAdopter
+}
+
+// NewMockAdopter creates a new mock instance.
+func NewMockAdopter(ctrl *gomock.Controller) *MockAdopter {
+	mock := &MockAdopter{ctrl: ctrl}
+	mock.recorder = &MockAdopterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdopter) EXPECT() *MockAdopterMockRecorder {
+	return m.recorder
+}
+
+// AdoptOrders mocks base method.
+func (m *MockAdopter) AdoptOrders(orders []tinkoffinvest.ActiveOrder) []tinkoffinvest.ActiveOrder {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdoptOrders", orders)
+	ret0, _ := ret[0].([]tinkoffinvest.ActiveOrder)
+	return ret0
+}
+
+// AdoptOrders indicates an expected call of AdoptOrders.
+func (mr *MockAdopterMockRecorder) AdoptOrders(orders interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdoptOrders", reflect.TypeOf((*MockAdopter)(nil).AdoptOrders), orders)
+}
+
+// FIGIs mocks base method.
+func (m *MockAdopter) FIGIs() []tinkoffinvest.FIGI {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FIGIs")
+	ret0, _ := ret[0].([]tinkoffinvest.FIGI)
+	return ret0
+}
+
+// FIGIs indicates an expected call of FIGIs.
+func (mr *MockAdopterMockRecorder) FIGIs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FIGIs", reflect.TypeOf((*MockAdopter)(nil).FIGIs))
+}
+
+// Name mocks base method.
+func (m *MockAdopter) Name() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Name")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Name indicates an expected call of Name.
+func (mr *MockAdopterMockRecorder) Name() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockAdopter)(nil).Name))
+}
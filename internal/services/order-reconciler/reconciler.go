@@ -0,0 +1,175 @@
+package orderreconciler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=mocks/reconciler_generated.go -package orderreconcilermocks Client,Adopter
+
+// Policy defines what to do with active orders left by the previous robot run.
+type Policy string
+
+const (
+	// PolicyAdopt passes the orders to strategies, the orders not adopted by anyone are cancelled.
+	PolicyAdopt Policy = "adopt"
+	// PolicyCancel cancels all the orders for configured instruments.
+	PolicyCancel Policy = "cancel"
+)
+
+type Client interface {
+	GetOrders(ctx context.Context, accountID tinkoffinvest.AccountID) ([]tinkoffinvest.ActiveOrder, error)
+	CancelOrder(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) error
+	GetPortfolio(ctx context.Context, accountID tinkoffinvest.AccountID) (*tinkoffinvest.Portfolio, error)
+}
+
+// Adopter is a strategy able to continue managing its orders after restart.
+type Adopter interface {
+	Name() string
+	// FIGIs returns instruments the strategy trades.
+	FIGIs() []tinkoffinvest.FIGI
+	// AdoptOrders takes the orders under control and returns the rest of them.
+	AdoptOrders(orders []tinkoffinvest.ActiveOrder) []tinkoffinvest.ActiveOrder
+}
+
+// Reconciler brings the active orders of the account in line with the strategies before their start.
+// Orders for instruments not configured in any strategy are left untouched.
+type Reconciler struct {
+	policy  Policy
+	account tinkoffinvest.AccountID
+	client  Client
+}
+
+func New(policy Policy, account tinkoffinvest.AccountID, client Client) (*Reconciler, error) {
+	switch policy {
+	case "":
+		policy = PolicyAdopt
+	case PolicyAdopt, PolicyCancel:
+	default:
+		return nil, fmt.Errorf("unknown reconciliation policy: %q", policy)
+	}
+
+	return &Reconciler{
+		policy:  policy,
+		account: account,
+		client:  client,
+	}, nil
+}
+
+type summary struct {
+	found     int
+	adopted   int
+	cancelled int
+	untouched int
+	failed    int
+}
+
+func (r *Reconciler) Reconcile(ctx context.Context, strategies []Adopter) error {
+	logger := log.With().
+		Str("service", "order-reconciler").
+		Str("account", r.account.S()).
+		Str("policy", string(r.policy)).
+		Logger()
+
+	orders, err := r.client.GetOrders(ctx, r.account)
+	if err != nil {
+		return fmt.Errorf("get orders: %w", err)
+	}
+
+	configured := make(map[tinkoffinvest.FIGI][]Adopter)
+	for _, s := range strategies {
+		for _, f := range s.FIGIs() {
+			configured[f] = append(configured[f], s)
+		}
+	}
+
+	var sum summary
+	sum.found = len(orders)
+
+	orphaned := make(map[tinkoffinvest.FIGI][]tinkoffinvest.ActiveOrder)
+	for _, o := range orders {
+		if _, ok := configured[o.FIGI]; !ok {
+			sum.untouched++
+			continue
+		}
+		orphaned[o.FIGI] = append(orphaned[o.FIGI], o)
+	}
+
+	for figi, orders := range orphaned {
+		if r.policy == PolicyAdopt {
+			for _, s := range configured[figi] {
+				rest := s.AdoptOrders(orders)
+				for _, o := range adoptedOrders(orders, rest) {
+					logger.Info().
+						Str("figi", figi.S()).
+						Str("order_id", o.ID.S()).
+						Str("strategy", s.Name()).
+						Msg("order adopted")
+				}
+				sum.adopted += len(orders) - len(rest)
+
+				if orders = rest; len(orders) == 0 {
+					break
+				}
+			}
+		}
+
+		for _, o := range orders {
+			if err := r.client.CancelOrder(ctx, r.account, o.ID); err != nil {
+				logger.Warn().Str("figi", figi.S()).Str("order_id", o.ID.S()).Err(err).Msg("cancel orphaned order")
+				sum.failed++
+				continue
+			}
+
+			logger.Info().
+				Str("figi", figi.S()).
+				Str("order_id", o.ID.S()).
+				Stringer("direction", o.Direction).
+				Str("price", o.Price.String()).
+				Msg("orphaned order cancelled")
+			sum.cancelled++
+		}
+	}
+
+	positions := make(map[string]int)
+	portfolio, err := r.client.GetPortfolio(ctx, r.account)
+	if err != nil {
+		logger.Err(err).Msg("cannot get portfolio")
+	} else {
+		for _, p := range portfolio.Positions {
+			if _, ok := configured[p.FIGI]; ok {
+				positions[p.FIGI.S()] = p.Quantity
+			}
+		}
+	}
+
+	logger.Info().
+		Int("found", sum.found).
+		Int("adopted", sum.adopted).
+		Int("cancelled", sum.cancelled).
+		Int("untouched", sum.untouched).
+		Int("failed", sum.failed).
+		Interface("positions", positions).
+		Msg("orders reconciled")
+
+	return nil
+}
+
+func adoptedOrders(all, rest []tinkoffinvest.ActiveOrder) []tinkoffinvest.ActiveOrder {
+	left := make(map[tinkoffinvest.OrderID]struct{}, len(rest))
+	for _, o := range rest {
+		left[o.ID] = struct{}{}
+	}
+
+	adopted := make([]tinkoffinvest.ActiveOrder, 0, len(all)-len(rest))
+	for _, o := range all {
+		if _, ok := left[o.ID]; !ok {
+			adopted = append(adopted, o)
+		}
+	}
+	return adopted
+}
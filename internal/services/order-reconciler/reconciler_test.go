@@ -0,0 +1,119 @@
+package orderreconciler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	orderreconciler "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/order-reconciler"
+	orderreconcilermocks "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/order-reconciler/mocks"
+)
+
+const (
+	accountID = tinkoffinvest.AccountID("account-zzz")
+	figi      = tinkoffinvest.FIGI("BBG004730N88")
+	alienFIGI = tinkoffinvest.FIGI("BBG000BN56Q9")
+)
+
+var (
+	sellOrder = tinkoffinvest.ActiveOrder{
+		ID:            "oid1",
+		FIGI:          figi,
+		Type:          tinkoffinvest.OrderTypeLimit,
+		Direction:     tinkoffinvest.TradeDirectionSell,
+		LotsRequested: 1,
+		Price:         decimal.RequireFromString("120.5"),
+	}
+	anotherSellOrder = tinkoffinvest.ActiveOrder{
+		ID:            "oid2",
+		FIGI:          figi,
+		Type:          tinkoffinvest.OrderTypeLimit,
+		Direction:     tinkoffinvest.TradeDirectionSell,
+		LotsRequested: 1,
+		Price:         decimal.RequireFromString("121"),
+	}
+	alienOrder = tinkoffinvest.ActiveOrder{
+		ID:            "oid3",
+		FIGI:          alienFIGI,
+		Type:          tinkoffinvest.OrderTypeLimit,
+		Direction:     tinkoffinvest.TradeDirectionBuy,
+		LotsRequested: 1,
+		Price:         decimal.RequireFromString("10"),
+	}
+)
+
+func TestNew(t *testing.T) {
+	_, err := orderreconciler.New("", accountID, nil)
+	require.NoError(t, err)
+
+	_, err = orderreconciler.New("ignore", accountID, nil)
+	require.Error(t, err)
+}
+
+func TestReconciler_Reconcile(t *testing.T) {
+	t.Run("adopt", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		client := orderreconcilermocks.NewMockClient(ctrl)
+		strategy := orderreconcilermocks.NewMockAdopter(ctrl)
+
+		r, err := orderreconciler.New(orderreconciler.PolicyAdopt, accountID, client)
+		require.NoError(t, err)
+
+		client.EXPECT().GetOrders(gomock.Any(), accountID).
+			Return([]tinkoffinvest.ActiveOrder{sellOrder, alienOrder, anotherSellOrder}, nil)
+		strategy.EXPECT().Name().Return("strategy").AnyTimes()
+		strategy.EXPECT().FIGIs().Return([]tinkoffinvest.FIGI{figi})
+		strategy.EXPECT().AdoptOrders([]tinkoffinvest.ActiveOrder{sellOrder, anotherSellOrder}).
+			Return([]tinkoffinvest.ActiveOrder{anotherSellOrder})
+		client.EXPECT().CancelOrder(gomock.Any(), accountID, anotherSellOrder.ID).Return(nil)
+		client.EXPECT().GetPortfolio(gomock.Any(), accountID).Return(new(tinkoffinvest.Portfolio), nil)
+
+		err = r.Reconcile(context.Background(), []orderreconciler.Adopter{strategy})
+		assert.NoError(t, err)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		client := orderreconcilermocks.NewMockClient(ctrl)
+		strategy := orderreconcilermocks.NewMockAdopter(ctrl)
+
+		r, err := orderreconciler.New(orderreconciler.PolicyCancel, accountID, client)
+		require.NoError(t, err)
+
+		client.EXPECT().GetOrders(gomock.Any(), accountID).
+			Return([]tinkoffinvest.ActiveOrder{sellOrder, alienOrder, anotherSellOrder}, nil)
+		strategy.EXPECT().Name().Return("strategy").AnyTimes()
+		strategy.EXPECT().FIGIs().Return([]tinkoffinvest.FIGI{figi})
+		client.EXPECT().CancelOrder(gomock.Any(), accountID, sellOrder.ID).Return(nil)
+		client.EXPECT().CancelOrder(gomock.Any(), accountID, anotherSellOrder.ID).Return(errors.New("unknown order"))
+		client.EXPECT().GetPortfolio(gomock.Any(), accountID).Return(nil, errors.New("unavailable"))
+
+		err = r.Reconcile(context.Background(), []orderreconciler.Adopter{strategy})
+		assert.NoError(t, err)
+	})
+
+	t.Run("get orders error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		client := orderreconcilermocks.NewMockClient(ctrl)
+
+		r, err := orderreconciler.New(orderreconciler.PolicyAdopt, accountID, client)
+		require.NoError(t, err)
+
+		client.EXPECT().GetOrders(gomock.Any(), accountID).Return(nil, errors.New("unavailable"))
+
+		err = r.Reconcile(context.Background(), nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,26 @@
+package papertrader
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const subsystem = "paper"
+
+type l = prometheus.Labels
+
+var (
+	fillsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "fills_total",
+		Help:      "Total amount of simulated order fills",
+	}, []string{"account_number", "direction"})
+
+	balance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "balance",
+		Help:      "Virtual money of the account including the blocked one",
+	}, []string{"account_number", "currency"})
+)
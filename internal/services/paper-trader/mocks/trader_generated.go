@@ -0,0 +1,173 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: trader.go
+
+// Package papertradermocks is a generated GoMock package.
+package papertradermocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	tinkoffinvest "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockClient is a mock of Client interface.
+type MockClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockClientMockRecorder
+}
+
+// MockClientMockRecorder is the mock recorder for MockClient.
+type MockClientMockRecorder struct {
+	mock *MockClient
+}
+
+// NewMockClient creates a new mock instance.
+func NewMockClient(ctrl *gomock.Controller) *MockClient {
+	mock := &MockClient{ctrl: ctrl}
+	mock.recorder = &MockClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClient) EXPECT() *MockClientMockRecorder {
+	return m.recorder
+}
+
+// GetLastPrices mocks base method.
+func (m *MockClient) GetLastPrices(ctx context.Context, figis []tinkoffinvest.FIGI) ([]tinkoffinvest.LastPrice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastPrices", ctx, figis)
+	ret0, _ := ret[0].([]tinkoffinvest.LastPrice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLastPrices indicates an expected call of GetLastPrices.
+func (mr *MockClientMockRecorder) GetLastPrices(ctx, figis interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastPrices", reflect.TypeOf((*MockClient)(nil).GetLastPrices), ctx, figis)
+}
+
+// GetOrderBook mocks base method.
+func (m *MockClient) GetOrderBook(ctx context.Context, req tinkoffinvest.OrderBookRequest) (*tinkoffinvest.OrderBookResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderBook", ctx, req)
+	ret0, _ := ret[0].(*tinkoffinvest.OrderBookResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderBook indicates an expected call of GetOrderBook.
+func (mr *MockClientMockRecorder) GetOrderBook(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderBook", reflect.TypeOf((*MockClient)(nil).GetOrderBook), ctx, req)
+}
+
+// GetTradeAvailableInstruments mocks base method.
+func (m *MockClient) GetTradeAvailableInstruments(ctx context.Context, typ tinkoffinvest.InstrumentType) ([]tinkoffinvest.Instrument, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTradeAvailableInstruments", ctx, typ)
+	ret0, _ := ret[0].([]tinkoffinvest.Instrument)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTradeAvailableInstruments indicates an expected call of GetTradeAvailableInstruments.
+func (mr *MockClientMockRecorder) GetTradeAvailableInstruments(ctx, typ interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTradeAvailableInstruments", reflect.TypeOf((*MockClient)(nil).GetTradeAvailableInstruments), ctx, typ)
+}
+
+// GetTradingStatus mocks base method.
+func (m *MockClient) GetTradingStatus(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.TradingStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTradingStatus", ctx, figi)
+	ret0, _ := ret[0].(*tinkoffinvest.TradingStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTradingStatus indicates an expected call of GetTradingStatus.
+func (mr *MockClientMockRecorder) GetTradingStatus(ctx, figi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTradingStatus", reflect.TypeOf((*MockClient)(nil).GetTradingStatus), ctx, figi)
+}
+
+// MockMarketData is a mock of MarketData interface.
+type MockMarketData struct {
+	ctrl     *gomock.Controller
+	recorder *MockMarketDataMockRecorder
+}
+
+// MockMarketDataMockRecorder is the mock recorder for MockMarketData.
+type MockMarketDataMockRecorder struct {
+	mock *MockMarketData
+}
+
+// NewMockMarketData creates a new mock instance.
+func NewMockMarketData(ctrl *gomock.Controller) *MockMarketData {
+	mock := &MockMarketData{ctrl: ctrl}
+	mock.recorder = &MockMarketDataMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMarketData) EXPECT() *MockMarketDataMockRecorder {
+	return m.recorder
+}
+
+// SubscribeForOrderBookChanges mocks base method.
+func (m *MockMarketData) SubscribeForOrderBookChanges(ctx context.Context, reqs []tinkoffinvest.OrderBookRequest) (<-chan tinkoffinvest.OrderBookChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeForOrderBookChanges", ctx, reqs)
+	ret0, _ := ret[0].(<-chan tinkoffinvest.OrderBookChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeForOrderBookChanges indicates an expected call of SubscribeForOrderBookChanges.
+func (mr *MockMarketDataMockRecorder) SubscribeForOrderBookChanges(ctx, reqs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeForOrderBookChanges", reflect.TypeOf((*MockMarketData)(nil).SubscribeForOrderBookChanges), ctx, reqs)
+}
+
+// MockToolsCache is a mock of ToolsCache interface.
+type MockToolsCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockToolsCacheMockRecorder
+}
+
+// MockToolsCacheMockRecorder is the mock recorder for MockToolsCache.
+type MockToolsCacheMockRecorder struct {
+	mock *MockToolsCache
+}
+
+// NewMockToolsCache creates a new mock instance.
+func NewMockToolsCache(ctrl *gomock.Controller) *MockToolsCache {
+	mock := &MockToolsCache{ctrl: ctrl}
+	mock.recorder = &MockToolsCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockToolsCache) EXPECT() *MockToolsCacheMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockToolsCache) Get(ctx context.Context, figi tinkoffinvest.FIGI) (toolscache.Tool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, figi)
+	ret0, _ := ret[0].(toolscache.Tool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockToolsCacheMockRecorder) Get(ctx, figi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockToolsCache)(nil).Get), ctx, figi)
+}
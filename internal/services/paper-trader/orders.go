@@ -0,0 +1,487 @@
+package papertrader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+)
+
+var errEmptyOrderBook = fmt.Errorf("%w: order book is empty", tinkoffinvest.ErrMarketClosed)
+
+// execution is the part of the order filled at one price.
+type execution struct {
+	price decimal.Decimal
+	lots  int
+}
+
+func (t *Trader) PlaceMarketSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return t.place(ctx, request, tinkoffinvest.TradeDirectionSell, tinkoffinvest.OrderTypeMarket)
+}
+
+func (t *Trader) PlaceMarketBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return t.place(ctx, request, tinkoffinvest.TradeDirectionBuy, tinkoffinvest.OrderTypeMarket)
+}
+
+func (t *Trader) PlaceLimitSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return t.place(ctx, request, tinkoffinvest.TradeDirectionSell, tinkoffinvest.OrderTypeLimit)
+}
+
+func (t *Trader) PlaceLimitBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return t.place(ctx, request, tinkoffinvest.TradeDirectionBuy, tinkoffinvest.OrderTypeLimit)
+}
+
+// CancelOrder cancels the active order and releases the money or instruments reserved by it.
+func (t *Trader) CancelOrder(_ context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	acc := t.account(accountID)
+	o, ok := acc.orders[orderID]
+	if !ok {
+		return fmt.Errorf("order %s: %w", orderID, tinkoffinvest.ErrNotFound)
+	}
+	if o.state.Status.IsFinal() {
+		return fmt.Errorf("order %s is %s: %w", orderID, o.state.Status, tinkoffinvest.ErrInvalidRequest)
+	}
+
+	acc.release(o, o.state.LotsLeft())
+	o.state.Status = tinkoffinvest.OrderStatusCancelled
+	return nil
+}
+
+func (t *Trader) place(
+	ctx context.Context,
+	request tinkoffinvest.PlaceOrderRequest,
+	direction tinkoffinvest.TradeDirection,
+	typ tinkoffinvest.OrderType,
+) (tinkoffinvest.OrderID, error) {
+	if request.Lots <= 0 {
+		return "", fmt.Errorf("%w: lots must be positive", tinkoffinvest.ErrInvalidRequest)
+	}
+	if typ == tinkoffinvest.OrderTypeLimit && !request.Price.IsPositive() {
+		return "", fmt.Errorf("%w: price must be positive", tinkoffinvest.ErrInvalidRequest)
+	}
+
+	tool, err := t.toolsCache.Get(ctx, request.FIGI)
+	if err != nil {
+		return "", fmt.Errorf("get tool %s: %w", request.FIGI, err)
+	}
+	if err := t.fetchOrderBook(ctx, request.FIGI); err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	acc := t.account(request.AccountID)
+	if request.IdempotencyKey != "" {
+		if id, ok := acc.keys[request.IdempotencyKey]; ok {
+			return id, nil
+		}
+	}
+
+	o := &order{
+		state: tinkoffinvest.OrderState{
+			FIGI:               request.FIGI,
+			Type:               typ,
+			Direction:          direction,
+			Status:             tinkoffinvest.OrderStatusNew,
+			LotsRequested:      request.Lots,
+			InitialPrice:       request.Price,
+			AveragePrice:       decimal.Zero,
+			ExecutedAmount:     decimal.Zero,
+			ExecutedCommission: decimal.Zero,
+			Currency:           tool.Currency,
+			CreatedAt:          t.opts.Now(),
+		},
+		account: request.AccountID,
+		tool:    tool,
+	}
+	p := acc.position(request.FIGI)
+	p.tool = tool
+
+	book := t.books[request.FIGI]
+	levels := copyLevels(book.Asks)
+	if direction == tinkoffinvest.TradeDirectionSell {
+		levels = copyLevels(book.Bids)
+	}
+
+	var executions []execution
+	if typ == tinkoffinvest.OrderTypeMarket {
+		if executions, err = t.admitMarket(acc, o, levels); err != nil {
+			return "", err
+		}
+	} else {
+		if err := t.admitLimit(acc, o, book); err != nil {
+			return "", err
+		}
+		executions = take(levels, o.state.LotsRequested, crossing(o))
+	}
+
+	o.state.ID = tinkoffinvest.OrderID(t.nextID("paper"))
+	o.seq = t.lastID
+	acc.orders[o.state.ID] = o
+	if request.IdempotencyKey != "" {
+		acc.keys[request.IdempotencyKey] = o.state.ID
+	}
+
+	for _, e := range executions {
+		t.fill(acc, o, e.price, e.lots)
+	}
+	return o.state.ID, nil
+}
+
+// admitMarket walks the book for all the lots of the order, the lots beyond the book depth
+// are filled at its worst price.
+func (t *Trader) admitMarket(acc *account, o *order, levels []tinkoffinvest.Order) ([]execution, error) {
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("%s: %w", o.state.FIGI, errEmptyOrderBook)
+	}
+
+	executions := take(levels, o.state.LotsRequested, func(decimal.Decimal) bool { return true })
+
+	var executed int
+	amount := decimal.Zero
+	for _, e := range executions {
+		executed += e.lots
+		amount = amount.Add(e.price.Mul(decimal.NewFromInt(int64(e.lots))))
+	}
+	if left := o.state.LotsRequested - executed; left > 0 {
+		worst := levels[len(levels)-1].Price
+		executions = append(executions, execution{price: worst, lots: left})
+		amount = amount.Add(worst.Mul(decimal.NewFromInt(int64(left))))
+	}
+
+	closing := acc.closingLots(o)
+	if err := t.checkOpening(acc, o, closing); err != nil {
+		return nil, err
+	}
+
+	// The opening part is paid at the average price of the order.
+	opening := decimal.NewFromInt(int64(o.state.LotsRequested - closing))
+	cost := t.withCommission(o.tool.Amount(amount, o.tool.StocksPerLot)).
+		Mul(opening).Div(decimal.NewFromInt(int64(o.state.LotsRequested)))
+	if err := acc.checkMoney(o.state.Currency, cost); err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+// admitLimit checks the order price and reserves the closed part of the position
+// and the money for the opening part.
+func (t *Trader) admitLimit(acc *account, o *order, book tinkoffinvest.OrderBook) error {
+	price := o.state.InitialPrice
+	if (book.LimitUp.IsPositive() && price.GreaterThan(book.LimitUp)) ||
+		(book.LimitDown.IsPositive() && price.LessThan(book.LimitDown)) {
+		return fmt.Errorf("%s: %s: %w", o.state.FIGI, price, tinkoffinvest.ErrPriceOutOfLimits)
+	}
+
+	closing := acc.closingLots(o)
+	if err := t.checkOpening(acc, o, closing); err != nil {
+		return err
+	}
+
+	perLot := t.withCommission(o.tool.Amount(price, o.tool.StocksPerLot))
+	blocked := perLot.Mul(decimal.NewFromInt(int64(o.state.LotsRequested - closing)))
+	if err := acc.checkMoney(o.state.Currency, blocked); err != nil {
+		return err
+	}
+
+	o.closingLots = closing
+	o.blockedPerLot = perLot
+	acc.blockedMoney[o.state.Currency] = acc.blockedMoney[o.state.Currency].Add(blocked)
+	p := acc.position(o.state.FIGI)
+	if o.state.Direction == tinkoffinvest.TradeDirectionBuy {
+		p.covering += closing * o.tool.StocksPerLot
+	} else {
+		p.blocked += closing * o.tool.StocksPerLot
+	}
+	return nil
+}
+
+// checkOpening rejects the sell opening a short position if it is not allowed.
+func (t *Trader) checkOpening(acc *account, o *order, closing int) error {
+	if o.state.Direction != tinkoffinvest.TradeDirectionSell || t.opts.AllowShort || closing == o.state.LotsRequested {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %s available, %d required",
+		tinkoffinvest.ErrNotEnoughStocks, closing*o.tool.StocksPerLot, o.state.FIGI, o.state.LotsRequested*o.tool.StocksPerLot)
+}
+
+// matchResting fills the active limit orders crossed by the book at their prices.
+// The book liquidity is shared by the orders in order of their placement.
+func (t *Trader) matchResting(book tinkoffinvest.OrderBook) {
+	var resting []*order
+	for _, acc := range t.accounts {
+		for _, o := range acc.orders {
+			if o.state.FIGI == book.FIGI && o.state.Type == tinkoffinvest.OrderTypeLimit && !o.state.Status.IsFinal() {
+				resting = append(resting, o)
+			}
+		}
+	}
+	if len(resting) == 0 {
+		return
+	}
+	sort.Slice(resting, func(i, j int) bool { return resting[i].seq < resting[j].seq })
+
+	asks, bids := copyLevels(book.Asks), copyLevels(book.Bids)
+	for _, o := range resting {
+		levels := asks
+		if o.state.Direction == tinkoffinvest.TradeDirectionSell {
+			levels = bids
+		}
+
+		var lots int
+		for _, e := range take(levels, o.state.LotsLeft(), crossing(o)) {
+			lots += e.lots
+		}
+		if lots > 0 {
+			t.fill(t.accounts[o.account], o, o.state.InitialPrice, lots)
+		}
+	}
+}
+
+// fill applies the execution to the order and the account, then publishes the fill.
+func (t *Trader) fill(acc *account, o *order, price decimal.Decimal, lots int) {
+	now := t.opts.Now()
+	qty := lots * o.tool.StocksPerLot
+	amount := o.tool.Amount(price, qty)
+	commission := amount.Mul(t.opts.Commission)
+	cur := o.state.Currency
+
+	acc.release(o, lots)
+	switch o.state.Direction {
+	case tinkoffinvest.TradeDirectionBuy:
+		acc.money[cur] = acc.money[cur].Sub(amount).Sub(commission)
+		acc.position(o.state.FIGI).add(qty, price)
+
+	case tinkoffinvest.TradeDirectionSell:
+		acc.money[cur] = acc.money[cur].Add(amount).Sub(commission)
+		acc.position(o.state.FIGI).add(-qty, price)
+
+	case tinkoffinvest.TradeDirectionUnspecified:
+	}
+
+	tradeID := t.nextID("trade")
+	// The average price is in price units, that differ from money for bonds and futures.
+	executed := decimal.NewFromInt(int64(o.state.LotsExecuted))
+	o.state.AveragePrice = o.state.AveragePrice.Mul(executed).Add(price.Mul(decimal.NewFromInt(int64(lots)))).
+		Div(executed.Add(decimal.NewFromInt(int64(lots))))
+	o.state.LotsExecuted += lots
+	o.state.ExecutedAmount = o.state.ExecutedAmount.Add(amount)
+	o.state.ExecutedCommission = o.state.ExecutedCommission.Add(commission)
+	o.state.Stages = append(o.state.Stages, tinkoffinvest.OrderStage{TradeID: tradeID, Price: price, Lots: lots})
+	o.state.Status = tinkoffinvest.OrderStatusPartiallyFilled
+	if o.state.LotsLeft() == 0 {
+		o.state.Status = tinkoffinvest.OrderStatusFilled
+	}
+
+	payment := amount
+	if o.state.Direction == tinkoffinvest.TradeDirectionBuy {
+		payment = amount.Neg()
+	}
+	acc.operations = append(acc.operations, tinkoffinvest.Operation{
+		ID:          tradeID,
+		Kind:        tinkoffinvest.OperationKindTrade,
+		Description: "paper " + o.state.Direction.String(),
+		FIGI:        o.state.FIGI,
+		Direction:   o.state.Direction,
+		Payment:     payment,
+		Currency:    cur,
+		Price:       price,
+		Quantity:    qty,
+		Time:        now,
+	})
+	if commission.IsPositive() {
+		acc.operations = append(acc.operations, tinkoffinvest.Operation{
+			ID:          tradeID + "-commission",
+			ParentID:    tradeID,
+			Kind:        tinkoffinvest.OperationKindCommission,
+			Description: "paper commission",
+			FIGI:        o.state.FIGI,
+			Payment:     commission.Neg(),
+			Currency:    cur,
+			Time:        now,
+		})
+	}
+
+	t.logger.Debug().
+		Str("account", o.account.S()).
+		Str("order_id", o.state.ID.S()).
+		Str("figi", o.state.FIGI.S()).
+		Str("direction", o.state.Direction.String()).
+		Str("price", price.String()).
+		Int("lots", lots).
+		Msg("paper order filled")
+
+	fillsTotal.With(l{"account_number": o.account.S(), "direction": o.state.Direction.String()}).Inc()
+	balance.With(l{"account_number": o.account.S(), "currency": cur}).Set(acc.money[cur].InexactFloat64())
+
+	t.publish(tinkoffinvest.OrderFill{
+		AccountID: o.account,
+		OrderID:   o.state.ID,
+		FIGI:      o.state.FIGI,
+		Direction: o.state.Direction,
+		Price:     price,
+		Lots:      lots,
+		Time:      now,
+	})
+}
+
+// fetchOrderBook gets the snapshot of the book not followed yet and starts following it.
+func (t *Trader) fetchOrderBook(ctx context.Context, figi tinkoffinvest.FIGI) error {
+	t.mu.Lock()
+	_, ok := t.books[figi]
+	t.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	resp, err := t.Client.GetOrderBook(ctx, tinkoffinvest.OrderBookRequest{FIGI: figi, Depth: t.opts.Depth})
+	if err != nil {
+		return fmt.Errorf("get order book %s: %w", figi, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.books[figi]; !ok {
+		t.books[figi] = resp.OrderBook
+	}
+	t.watch(figi)
+	return nil
+}
+
+func (t *Trader) withCommission(amount decimal.Decimal) decimal.Decimal {
+	return amount.Add(amount.Mul(t.opts.Commission))
+}
+
+func (a *account) position(figi tinkoffinvest.FIGI) *position {
+	p, ok := a.positions[figi]
+	if !ok {
+		p = &position{avgPrice: decimal.Zero}
+		a.positions[figi] = p
+	}
+	return p
+}
+
+func (a *account) checkMoney(currency string, amount decimal.Decimal) error {
+	if available := a.availableMoney(currency); available.LessThan(amount) {
+		return fmt.Errorf("%w: %s %s available, %s required", tinkoffinvest.ErrNotEnoughStocks, available, currency, amount)
+	}
+	return nil
+}
+
+// availableMoney is the money not blocked by orders and not held as the collateral of short positions.
+func (a *account) availableMoney(currency string) decimal.Decimal {
+	return a.money[currency].Sub(a.blockedMoney[currency]).Sub(a.collateral(currency))
+}
+
+// collateral is twice the value of short positions at their average price:
+// the sale proceeds and the same money of the account as the margin.
+func (a *account) collateral(currency string) decimal.Decimal {
+	total := decimal.Zero
+	for _, p := range a.positions {
+		if p.quantity < 0 && p.tool.Currency == currency {
+			total = total.Add(p.tool.Amount(p.avgPrice, -2*p.quantity))
+		}
+	}
+	return total
+}
+
+// closingLots returns the lots of the order closing the position not reserved by other orders yet.
+func (a *account) closingLots(o *order) int {
+	p := a.position(o.state.FIGI)
+	available := p.quantity - p.blocked
+	if o.state.Direction == tinkoffinvest.TradeDirectionBuy {
+		available = -p.quantity - p.covering
+	}
+	if lots := available / o.tool.StocksPerLot; lots < o.state.LotsRequested {
+		if lots < 0 {
+			return 0
+		}
+		return lots
+	}
+	return o.state.LotsRequested
+}
+
+// release frees the position and the money reserved by the lots of the limit order,
+// the closing lots are executed first.
+func (a *account) release(o *order, lots int) {
+	closing := lots
+	if closing > o.closingLots {
+		closing = o.closingLots
+	}
+	o.closingLots -= closing
+
+	p := a.position(o.state.FIGI)
+	if o.state.Direction == tinkoffinvest.TradeDirectionBuy {
+		p.covering -= closing * o.tool.StocksPerLot
+	} else {
+		p.blocked -= closing * o.tool.StocksPerLot
+	}
+
+	cur := o.state.Currency
+	a.blockedMoney[cur] = a.blockedMoney[cur].Sub(o.blockedPerLot.Mul(decimal.NewFromInt(int64(lots - closing))))
+}
+
+// add changes the position by the signed quantity of instruments traded at the price.
+func (p *position) add(qty int, price decimal.Decimal) {
+	switch {
+	case p.quantity == 0 || (p.quantity > 0) == (qty > 0):
+		p.avgPrice = p.avgPrice.Mul(decimal.NewFromInt(int64(abs(p.quantity)))).
+			Add(price.Mul(decimal.NewFromInt(int64(abs(qty))))).
+			Div(decimal.NewFromInt(int64(abs(p.quantity) + abs(qty))))
+	case abs(qty) == abs(p.quantity):
+		p.avgPrice = decimal.Zero
+	case abs(qty) > abs(p.quantity):
+		// The position is reversed.
+		p.avgPrice = price
+	}
+	p.quantity += qty
+}
+
+// crossing reports whether the price of the opposite side of the book crosses the limit order.
+func crossing(o *order) func(price decimal.Decimal) bool {
+	if o.state.Direction == tinkoffinvest.TradeDirectionBuy {
+		return func(price decimal.Decimal) bool { return price.LessThanOrEqual(o.state.InitialPrice) }
+	}
+	return func(price decimal.Decimal) bool { return price.GreaterThanOrEqual(o.state.InitialPrice) }
+}
+
+// take consumes up to lots from the levels while they are crossing, starting from the best one.
+func take(levels []tinkoffinvest.Order, lots int, crosses func(price decimal.Decimal) bool) []execution {
+	var executions []execution
+	for i := range levels {
+		if lots == 0 || !crosses(levels[i].Price) {
+			break
+		}
+		if levels[i].Lots == 0 {
+			continue
+		}
+
+		n := levels[i].Lots
+		if n > lots {
+			n = lots
+		}
+		levels[i].Lots -= n
+		lots -= n
+		executions = append(executions, execution{price: levels[i].Price, lots: n})
+	}
+	return executions
+}
+
+func copyLevels(levels []tinkoffinvest.Order) []tinkoffinvest.Order {
+	return append([]tinkoffinvest.Order(nil), levels...)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,439 @@
+// Package papertrader simulates orders against the live market data instead of sending them to the exchange.
+package papertrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=mocks/trader_generated.go -package papertradermocks Client,MarketData,ToolsCache
+
+const defaultDepth = 20
+
+// Client provides the market data and instruments, implemented by tinkoffinvest.Client.
+// No orders are sent via it.
+type Client interface {
+	GetTradeAvailableInstruments(ctx context.Context, typ tinkoffinvest.InstrumentType) ([]tinkoffinvest.Instrument, error)
+	GetOrderBook(ctx context.Context, req tinkoffinvest.OrderBookRequest) (*tinkoffinvest.OrderBookResponse, error)
+	GetTradingStatus(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.TradingStatus, error)
+	GetLastPrices(ctx context.Context, figis []tinkoffinvest.FIGI) ([]tinkoffinvest.LastPrice, error)
+}
+
+type MarketData interface {
+	SubscribeForOrderBookChanges(ctx context.Context, reqs []tinkoffinvest.OrderBookRequest) (<-chan tinkoffinvest.OrderBookChange, error) //nolint:lll
+}
+
+type ToolsCache interface {
+	Get(ctx context.Context, figi tinkoffinvest.FIGI) (toolscache.Tool, error)
+}
+
+type Options struct {
+	// Balance is the initial money of every account by currency, e.g. "rub".
+	Balance map[string]decimal.Decimal
+	// Commission is the part of the trade amount charged for every fill, e.g. 0.0005.
+	Commission decimal.Decimal
+	// Depth of the order books the orders are filled against, 20 by default.
+	Depth int
+	// Now is the clock of orders and fills, time.Now by default. The backtest replaces it with the virtual one.
+	Now func() time.Time
+	// AllowShort lets sell more instruments than held, opening the short position.
+	// The short position holds twice its value as the collateral: the sale proceeds and the margin.
+	AllowShort bool
+	// OnFill is called synchronously on every fill in addition to the subscribers.
+	// It is called under the trader lock, so it must not call the trader.
+	OnFill func(fill tinkoffinvest.OrderFill)
+}
+
+// Trader keeps virtual money and positions of the accounts and fills their orders by the streamed order books.
+// Market orders walk the book, resting limit orders are filled at their price when the book crosses them.
+// The market data and instruments are provided by the embedded Client.
+type Trader struct {
+	Client
+	marketData MarketData
+	toolsCache ToolsCache
+	opts       Options
+	logger     zerolog.Logger
+
+	mu          sync.Mutex
+	accounts    map[tinkoffinvest.AccountID]*account
+	books       map[tinkoffinvest.FIGI]tinkoffinvest.OrderBook
+	watched     map[tinkoffinvest.FIGI]struct{}
+	subscribers map[*subscriber]struct{}
+	lastID      int64
+	// watchedChanged makes Run to resubscribe for order books.
+	watchedChanged chan struct{}
+}
+
+type account struct {
+	// money includes the blocked one.
+	money        map[string]decimal.Decimal
+	blockedMoney map[string]decimal.Decimal
+	positions    map[tinkoffinvest.FIGI]*position
+	orders       map[tinkoffinvest.OrderID]*order
+	// keys are idempotency keys of placed orders.
+	keys       map[string]tinkoffinvest.OrderID
+	operations []tinkoffinvest.Operation
+}
+
+type position struct {
+	// tool converts prices of the position to money.
+	tool toolscache.Tool
+	// quantity of instruments includes the blocked ones, it is negative for the short position.
+	quantity int
+	// blocked are the held instruments being sold by limit orders.
+	blocked int
+	// covering are the short instruments being bought back by limit orders.
+	covering int
+	avgPrice decimal.Decimal
+}
+
+type order struct {
+	state   tinkoffinvest.OrderState
+	account tinkoffinvest.AccountID
+	tool    toolscache.Tool
+	// seq orders the orders by placement.
+	seq int64
+	// closingLots are the lots of the limit order closing the position, they reserve its instruments.
+	closingLots int
+	// blockedPerLot is the money blocked by the limit order for one lot opening the position.
+	blockedPerLot decimal.Decimal
+}
+
+type subscriber struct {
+	accounts map[tinkoffinvest.AccountID]struct{}
+	out      chan tinkoffinvest.OrderFill
+	notify   chan struct{}
+	// queue is guarded by Trader.mu, so fills are never sent under the lock.
+	queue []tinkoffinvest.OrderFill
+}
+
+func New(opts Options, client Client, marketData MarketData, toolsCache ToolsCache) *Trader {
+	if opts.Depth <= 0 {
+		opts.Depth = defaultDepth
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &Trader{
+		Client:         client,
+		marketData:     marketData,
+		toolsCache:     toolsCache,
+		opts:           opts,
+		logger:         log.With().Str("service", "paper-trader").Logger(),
+		accounts:       make(map[tinkoffinvest.AccountID]*account),
+		books:          make(map[tinkoffinvest.FIGI]tinkoffinvest.OrderBook),
+		watched:        make(map[tinkoffinvest.FIGI]struct{}),
+		subscribers:    make(map[*subscriber]struct{}),
+		watchedChanged: make(chan struct{}, 1),
+	}
+}
+
+// Run follows the order books of the instruments ordered via the trader and fills the resting orders.
+func (t *Trader) Run(ctx context.Context) error {
+	var (
+		books     <-chan tinkoffinvest.OrderBookChange
+		cancelSub = func() {}
+	)
+	defer func() { cancelSub() }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-t.watchedChanged:
+			t.mu.Lock()
+			reqs := make([]tinkoffinvest.OrderBookRequest, 0, len(t.watched))
+			for figi := range t.watched {
+				reqs = append(reqs, tinkoffinvest.OrderBookRequest{FIGI: figi, Depth: t.opts.Depth})
+			}
+			t.mu.Unlock()
+
+			cancelSub()
+			subCtx, cancel := context.WithCancel(ctx)
+			cancelSub = cancel
+
+			var err error
+			if books, err = t.marketData.SubscribeForOrderBookChanges(subCtx, reqs); err != nil {
+				return fmt.Errorf("subscribe for order books: %w", err)
+			}
+
+		case change, ok := <-books:
+			if !ok {
+				return errors.New("order books stream closed")
+			}
+			t.ApplyOrderBook(change.OrderBook)
+		}
+	}
+}
+
+// ApplyOrderBook updates the book of the instrument and fills the resting orders crossed by it.
+// It is called by Run for the streamed books, the backtest calls it for the replayed ones.
+func (t *Trader) ApplyOrderBook(book tinkoffinvest.OrderBook) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.books[book.FIGI] = book
+	t.matchResting(book)
+}
+
+// watch subscribes for the order book of the instrument if it is not followed yet.
+func (t *Trader) watch(figi tinkoffinvest.FIGI) {
+	if _, ok := t.watched[figi]; ok {
+		return
+	}
+	t.watched[figi] = struct{}{}
+
+	select {
+	case t.watchedChanged <- struct{}{}:
+	default:
+	}
+}
+
+// SubscribeForOrderFills streams the virtual fills of the accounts orders.
+func (t *Trader) SubscribeForOrderFills(ctx context.Context, accounts []tinkoffinvest.AccountID) (<-chan tinkoffinvest.OrderFill, error) { //nolint:lll
+	if len(accounts) == 0 {
+		return nil, errors.New("no accounts to subscribe")
+	}
+
+	s := &subscriber{
+		accounts: make(map[tinkoffinvest.AccountID]struct{}, len(accounts)),
+		out:      make(chan tinkoffinvest.OrderFill),
+		notify:   make(chan struct{}, 1),
+	}
+	for _, a := range accounts {
+		s.accounts[a] = struct{}{}
+	}
+
+	t.mu.Lock()
+	t.subscribers[s] = struct{}{}
+	t.mu.Unlock()
+
+	go func() {
+		defer close(s.out)
+		defer func() {
+			t.mu.Lock()
+			delete(t.subscribers, s)
+			t.mu.Unlock()
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.notify:
+			}
+
+			t.mu.Lock()
+			fills := s.queue
+			s.queue = nil
+			t.mu.Unlock()
+
+			for _, f := range fills {
+				select {
+				case <-ctx.Done():
+					return
+				case s.out <- f:
+				}
+			}
+		}
+	}()
+
+	return s.out, nil
+}
+
+func (t *Trader) publish(f tinkoffinvest.OrderFill) {
+	if t.opts.OnFill != nil {
+		t.opts.OnFill(f)
+	}
+
+	for s := range t.subscribers {
+		if _, ok := s.accounts[f.AccountID]; !ok {
+			continue
+		}
+
+		s.queue = append(s.queue, f)
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Trader) GetOrderState(
+	_ context.Context,
+	accountID tinkoffinvest.AccountID,
+	orderID tinkoffinvest.OrderID,
+) (*tinkoffinvest.OrderState, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	o, ok := t.account(accountID).orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("order %s: %w", orderID, tinkoffinvest.ErrNotFound)
+	}
+
+	state := o.state
+	state.Stages = append([]tinkoffinvest.OrderStage(nil), o.state.Stages...)
+	return &state, nil
+}
+
+// GetOrders returns the active orders of the account.
+func (t *Trader) GetOrders(_ context.Context, accountID tinkoffinvest.AccountID) ([]tinkoffinvest.ActiveOrder, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var orders []tinkoffinvest.ActiveOrder
+	for _, o := range t.account(accountID).orders {
+		if o.state.Status.IsFinal() {
+			continue
+		}
+
+		orders = append(orders, tinkoffinvest.ActiveOrder{
+			ID:            o.state.ID,
+			FIGI:          o.state.FIGI,
+			Type:          o.state.Type,
+			Direction:     o.state.Direction,
+			LotsRequested: o.state.LotsRequested,
+			LotsExecuted:  o.state.LotsExecuted,
+			Price:         o.state.InitialPrice,
+			CreatedAt:     o.state.CreatedAt,
+		})
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].CreatedAt.Before(orders[j].CreatedAt) })
+	return orders, nil
+}
+
+// GetPortfolio returns the virtual positions, TotalSharesPrice is estimated by the last known order books.
+// Short positions have negative quantity.
+func (t *Trader) GetPortfolio(_ context.Context, accountID tinkoffinvest.AccountID) (*tinkoffinvest.Portfolio, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	portfolio := &tinkoffinvest.Portfolio{TotalSharesPrice: decimal.Zero}
+	for figi, p := range t.account(accountID).positions {
+		if p.quantity == 0 {
+			continue
+		}
+
+		pos := tinkoffinvest.PortfolioPosition{
+			FIGI:     figi,
+			Type:     p.tool.Type,
+			Quantity: p.quantity,
+			AvgPrice: p.avgPrice,
+		}
+		portfolio.Positions = append(portfolio.Positions, pos)
+		if p.tool.Type != tinkoffinvest.InstrumentTypeShare {
+			continue
+		}
+		portfolio.Shares = append(portfolio.Shares, pos)
+
+		price := p.avgPrice
+		if mid, ok := midPrice(t.books[figi]); ok {
+			price = mid
+		}
+		portfolio.TotalSharesPrice = portfolio.TotalSharesPrice.Add(p.tool.Amount(price, p.quantity))
+	}
+	sort.Slice(portfolio.Shares, func(i, j int) bool { return portfolio.Shares[i].FIGI < portfolio.Shares[j].FIGI })
+	sort.Slice(portfolio.Positions, func(i, j int) bool { return portfolio.Positions[i].FIGI < portfolio.Positions[j].FIGI })
+	return portfolio, nil
+}
+
+// GetPositions returns the virtual money and securities of the account.
+// The collateral of short positions is reported as the blocked money.
+func (t *Trader) GetPositions(_ context.Context, accountID tinkoffinvest.AccountID) (*tinkoffinvest.Positions, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	acc := t.account(accountID)
+	positions := &tinkoffinvest.Positions{
+		Money:        make(map[string]decimal.Decimal, len(acc.money)),
+		BlockedMoney: make(map[string]decimal.Decimal, len(acc.blockedMoney)),
+	}
+	for cur, m := range acc.money {
+		blocked := acc.blockedMoney[cur].Add(acc.collateral(cur))
+		positions.Money[cur] = m.Sub(blocked)
+		if !blocked.IsZero() {
+			positions.BlockedMoney[cur] = blocked
+		}
+	}
+	for figi, p := range acc.positions {
+		if p.quantity == 0 {
+			continue
+		}
+		positions.Securities = append(positions.Securities, tinkoffinvest.SecurityPosition{
+			FIGI:    figi,
+			Balance: p.quantity - p.blocked,
+			Blocked: p.blocked,
+		})
+	}
+	sort.Slice(positions.Securities, func(i, j int) bool { return positions.Securities[i].FIGI < positions.Securities[j].FIGI })
+	return positions, nil
+}
+
+// GetOperations returns the virtual trades and commissions of the account.
+func (t *Trader) GetOperations(_ context.Context, request tinkoffinvest.OperationsRequest) ([]tinkoffinvest.Operation, error) {
+	to := request.To
+	if to.IsZero() {
+		to = t.opts.Now()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var operations []tinkoffinvest.Operation
+	for _, op := range t.account(request.AccountID).operations {
+		if op.Time.Before(request.From) || op.Time.After(to) {
+			continue
+		}
+		if request.FIGI != "" && op.FIGI != request.FIGI {
+			continue
+		}
+		operations = append(operations, op)
+	}
+	return operations, nil
+}
+
+func (t *Trader) account(id tinkoffinvest.AccountID) *account {
+	acc, ok := t.accounts[id]
+	if !ok {
+		acc = &account{
+			money:        make(map[string]decimal.Decimal, len(t.opts.Balance)),
+			blockedMoney: make(map[string]decimal.Decimal),
+			positions:    make(map[tinkoffinvest.FIGI]*position),
+			orders:       make(map[tinkoffinvest.OrderID]*order),
+			keys:         make(map[string]tinkoffinvest.OrderID),
+		}
+		for cur, m := range t.opts.Balance {
+			acc.money[cur] = m
+			balance.With(l{"account_number": id.S(), "currency": cur}).Set(m.InexactFloat64())
+		}
+		t.accounts[id] = acc
+	}
+	return acc
+}
+
+func (t *Trader) nextID(prefix string) string {
+	t.lastID++
+	return prefix + "-" + strconv.FormatInt(t.lastID, 10)
+}
+
+func midPrice(book tinkoffinvest.OrderBook) (decimal.Decimal, bool) {
+	if len(book.Bids) == 0 || len(book.Asks) == 0 {
+		return decimal.Zero, false
+	}
+	return book.Bids[0].Price.Add(book.Asks[0].Price).Div(decimal.NewFromInt(2)), true
+}
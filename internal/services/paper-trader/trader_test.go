@@ -0,0 +1,388 @@
+package papertrader_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	papertrader "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/paper-trader"
+	papertradermocks "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/paper-trader/mocks"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+)
+
+const (
+	accountID = tinkoffinvest.AccountID("account-zzz")
+	figi      = tinkoffinvest.FIGI("BBG004730N88")
+)
+
+type traderMocks struct {
+	client     *papertradermocks.MockClient
+	marketData *papertradermocks.MockMarketData
+}
+
+func newTrader(t *testing.T, opts papertrader.Options, book tinkoffinvest.OrderBook) (*papertrader.Trader, traderMocks) {
+	t.Helper()
+
+	tool := toolscache.Tool{FIGI: figi, Type: tinkoffinvest.InstrumentTypeShare, Currency: "rub", StocksPerLot: 10}
+	return newTraderOf(t, tool, opts, book)
+}
+
+func newTraderOf(
+	t *testing.T,
+	tool toolscache.Tool,
+	opts papertrader.Options,
+	book tinkoffinvest.OrderBook,
+) (*papertrader.Trader, traderMocks) {
+	t.Helper()
+
+	ctrl := gomock.NewController(t)
+	m := traderMocks{
+		client:     papertradermocks.NewMockClient(ctrl),
+		marketData: papertradermocks.NewMockMarketData(ctrl),
+	}
+
+	toolsCache := papertradermocks.NewMockToolsCache(ctrl)
+	toolsCache.EXPECT().Get(gomock.Any(), figi).Return(tool, nil).AnyTimes()
+
+	book.FIGI = figi
+	m.client.EXPECT().GetOrderBook(gomock.Any(), tinkoffinvest.OrderBookRequest{FIGI: figi, Depth: 20}).
+		Return(&tinkoffinvest.OrderBookResponse{OrderBook: book}, nil).MaxTimes(1)
+
+	return papertrader.New(opts, m.client, m.marketData, toolsCache), m
+}
+
+func rub(v int64) map[string]decimal.Decimal {
+	return map[string]decimal.Decimal{"rub": decimal.NewFromInt(v)}
+}
+
+func level(price string, lots int) tinkoffinvest.Order {
+	return tinkoffinvest.Order{Price: decimal.RequireFromString(price), Lots: lots}
+}
+
+func request(lots int, price string) tinkoffinvest.PlaceOrderRequest {
+	r := tinkoffinvest.PlaceOrderRequest{AccountID: accountID, FIGI: figi, Lots: lots}
+	if price != "" {
+		r.Price = decimal.RequireFromString(price)
+	}
+	return r
+}
+
+func requireMoney(t *testing.T, tr *papertrader.Trader, available, blocked string) {
+	t.Helper()
+
+	positions, err := tr.GetPositions(context.Background(), accountID)
+	require.NoError(t, err)
+	assert.Equal(t, available, positions.Money["rub"].String(), "available")
+	assert.Equal(t, blocked, positions.BlockedMoney["rub"].String(), "blocked")
+}
+
+func TestTrader_MarketOrders(t *testing.T) {
+	tr, _ := newTrader(t, papertrader.Options{Balance: rub(10000)}, tinkoffinvest.OrderBook{
+		Bids: []tinkoffinvest.Order{level("99", 5)},
+		Asks: []tinkoffinvest.Order{level("100", 2), level("101", 3)},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fills, err := tr.SubscribeForOrderFills(ctx, []tinkoffinvest.AccountID{accountID})
+	require.NoError(t, err)
+
+	// Walk the book: 2 lots at 100 and 2 lots at 101.
+	orderID, err := tr.PlaceMarketBuyOrder(ctx, request(4, ""))
+	require.NoError(t, err)
+
+	state, err := tr.GetOrderState(ctx, accountID, orderID)
+	require.NoError(t, err)
+	assert.Equal(t, tinkoffinvest.OrderStatusFilled, state.Status)
+	assert.Equal(t, 4, state.LotsExecuted)
+	assert.Equal(t, "100.5", state.AveragePrice.String())
+	assert.Equal(t, "4020", state.ExecutedAmount.String())
+	require.Len(t, state.Stages, 2)
+
+	for _, price := range []string{"100", "101"} {
+		f := <-fills
+		assert.Equal(t, orderID, f.OrderID)
+		assert.Equal(t, tinkoffinvest.TradeDirectionBuy, f.Direction)
+		assert.Equal(t, price, f.Price.String())
+		assert.Equal(t, 2, f.Lots)
+	}
+	requireMoney(t, tr, "5980", "0")
+
+	t.Run("beyond the book depth", func(t *testing.T) {
+		// 2 lots at 100 and 8 lots at the worst price 101 cost 10080.
+		_, err := tr.PlaceMarketBuyOrder(ctx, request(10, ""))
+		require.ErrorIs(t, err, tinkoffinvest.ErrNotEnoughStocks)
+	})
+
+	t.Run("sell", func(t *testing.T) {
+		_, err := tr.PlaceMarketSellOrder(ctx, request(3, ""))
+		require.NoError(t, err)
+		assert.Equal(t, "99", (<-fills).Price.String())
+		requireMoney(t, tr, "8950", "0")
+
+		portfolio, err := tr.GetPortfolio(ctx, accountID)
+		require.NoError(t, err)
+		require.Len(t, portfolio.Shares, 1)
+		assert.Equal(t, 10, portfolio.Shares[0].Quantity)
+		assert.Equal(t, "100.5", portfolio.Shares[0].AvgPrice.String())
+		assert.Equal(t, portfolio.Shares, portfolio.Positions)
+
+		_, err = tr.PlaceMarketSellOrder(ctx, request(2, ""))
+		require.ErrorIs(t, err, tinkoffinvest.ErrNotEnoughStocks)
+	})
+
+	t.Run("idempotency key", func(t *testing.T) {
+		r := request(1, "")
+		r.IdempotencyKey = "key"
+
+		id1, err := tr.PlaceMarketSellOrder(ctx, r)
+		require.NoError(t, err)
+		id2, err := tr.PlaceMarketSellOrder(ctx, r)
+		require.NoError(t, err)
+		assert.Equal(t, id1, id2)
+		<-fills
+
+		operations, err := tr.GetOperations(ctx, tinkoffinvest.OperationsRequest{AccountID: accountID})
+		require.NoError(t, err)
+		// Two stages of the buy and two sells, the repeated order is not placed.
+		assert.Len(t, operations, 4)
+	})
+}
+
+func TestTrader_MarketOrder_EmptyBook(t *testing.T) {
+	tr, _ := newTrader(t, papertrader.Options{Balance: rub(10000)}, tinkoffinvest.OrderBook{})
+
+	_, err := tr.PlaceMarketBuyOrder(context.Background(), request(1, ""))
+	require.ErrorIs(t, err, tinkoffinvest.ErrMarketClosed)
+}
+
+func TestTrader_LimitOrders(t *testing.T) {
+	tr, m := newTrader(t, papertrader.Options{
+		Balance:    rub(10000),
+		Commission: decimal.RequireFromString("0.01"),
+	}, tinkoffinvest.OrderBook{
+		Bids:      []tinkoffinvest.Order{level("96", 5)},
+		Asks:      []tinkoffinvest.Order{level("97", 1), level("100", 5)},
+		LimitUp:   decimal.NewFromInt(110),
+		LimitDown: decimal.NewFromInt(90),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	books := make(chan tinkoffinvest.OrderBookChange)
+	m.marketData.EXPECT().SubscribeForOrderBookChanges(gomock.Any(), []tinkoffinvest.OrderBookRequest{{FIGI: figi, Depth: 20}}).
+		Return(books, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, tr.Run(ctx))
+	}()
+
+	fills, err := tr.SubscribeForOrderFills(ctx, []tinkoffinvest.AccountID{accountID})
+	require.NoError(t, err)
+
+	// 1 lot is filled at once at 97, the rest is resting.
+	orderID, err := tr.PlaceLimitBuyOrder(ctx, request(4, "98"))
+	require.NoError(t, err)
+	assert.Equal(t, "97", (<-fills).Price.String())
+
+	// 10000 - 970 - 9.7 of commission, 2969.4 are blocked for 3 lots at 98 with commission.
+	requireMoney(t, tr, "6050.9", "2969.4")
+
+	books <- tinkoffinvest.OrderBookChange{OrderBook: tinkoffinvest.OrderBook{
+		FIGI: figi,
+		Asks: []tinkoffinvest.Order{level("97.5", 1), level("98", 1), level("99", 5)},
+	}}
+	f := <-fills
+	assert.Equal(t, orderID, f.OrderID)
+	assert.Equal(t, "98", f.Price.String())
+	assert.Equal(t, 2, f.Lots)
+
+	state, err := tr.GetOrderState(ctx, accountID, orderID)
+	require.NoError(t, err)
+	assert.Equal(t, tinkoffinvest.OrderStatusPartiallyFilled, state.Status)
+	assert.Equal(t, 3, state.LotsExecuted)
+
+	orders, err := tr.GetOrders(ctx, accountID)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, 3, orders[0].LotsExecuted)
+
+	t.Run("cancel releases blocked money", func(t *testing.T) {
+		require.NoError(t, tr.CancelOrder(ctx, accountID, orderID))
+		requireMoney(t, tr, "7040.7", "0")
+
+		err := tr.CancelOrder(ctx, accountID, orderID)
+		require.ErrorIs(t, err, tinkoffinvest.ErrInvalidRequest)
+	})
+
+	t.Run("sell is filled when the book crosses it", func(t *testing.T) {
+		sellID, err := tr.PlaceLimitSellOrder(ctx, request(3, "105"))
+		require.NoError(t, err)
+
+		_, err = tr.PlaceLimitSellOrder(ctx, request(1, "105"))
+		require.ErrorIs(t, err, tinkoffinvest.ErrNotEnoughStocks)
+
+		books <- tinkoffinvest.OrderBookChange{OrderBook: tinkoffinvest.OrderBook{
+			FIGI:      figi,
+			Bids:      []tinkoffinvest.Order{level("106", 10)},
+			LimitUp:   decimal.NewFromInt(110),
+			LimitDown: decimal.NewFromInt(90),
+		}}
+		f := <-fills
+		assert.Equal(t, sellID, f.OrderID)
+		assert.Equal(t, "105", f.Price.String())
+		assert.Equal(t, 3, f.Lots)
+
+		// 3 lots at 105 minus 1% of commission.
+		requireMoney(t, tr, "10159.2", "0")
+	})
+
+	t.Run("price out of limits", func(t *testing.T) {
+		_, err := tr.PlaceLimitBuyOrder(ctx, request(1, "111"))
+		require.ErrorIs(t, err, tinkoffinvest.ErrPriceOutOfLimits)
+	})
+
+	cancel()
+	<-done
+}
+
+func TestTrader_ShortPosition(t *testing.T) {
+	tr, m := newTrader(t, papertrader.Options{Balance: rub(10000), AllowShort: true}, tinkoffinvest.OrderBook{
+		Bids: []tinkoffinvest.Order{level("99", 5)},
+		Asks: []tinkoffinvest.Order{level("100", 5)},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	books := make(chan tinkoffinvest.OrderBookChange)
+	m.marketData.EXPECT().SubscribeForOrderBookChanges(gomock.Any(), []tinkoffinvest.OrderBookRequest{{FIGI: figi, Depth: 20}}).
+		Return(books, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, tr.Run(ctx))
+	}()
+
+	fills, err := tr.SubscribeForOrderFills(ctx, []tinkoffinvest.AccountID{accountID})
+	require.NoError(t, err)
+
+	_, err = tr.PlaceMarketSellOrder(ctx, request(2, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "99", (<-fills).Price.String())
+
+	// 10000 + 1980 of proceeds, twice the short value of 1980 is the collateral.
+	requireMoney(t, tr, "8020", "3960")
+
+	portfolio, err := tr.GetPortfolio(ctx, accountID)
+	require.NoError(t, err)
+	require.Len(t, portfolio.Positions, 1)
+	assert.Equal(t, tinkoffinvest.InstrumentTypeShare, portfolio.Positions[0].Type)
+	assert.Equal(t, -20, portfolio.Positions[0].Quantity)
+	assert.Equal(t, "99", portfolio.Positions[0].AvgPrice.String())
+
+	t.Run("not enough margin", func(t *testing.T) {
+		// 9 lots at 99 are 8910.
+		_, err := tr.PlaceMarketSellOrder(ctx, request(9, ""))
+		require.ErrorIs(t, err, tinkoffinvest.ErrNotEnoughStocks)
+	})
+
+	t.Run("buy beyond the short blocks money", func(t *testing.T) {
+		orderID, err := tr.PlaceLimitBuyOrder(ctx, request(2, "95"))
+		require.NoError(t, err)
+		requireMoney(t, tr, "8020", "3960")
+
+		extraID, err := tr.PlaceLimitBuyOrder(ctx, request(1, "95"))
+		require.NoError(t, err)
+		requireMoney(t, tr, "7070", "4910")
+		require.NoError(t, tr.CancelOrder(ctx, accountID, extraID))
+
+		books <- tinkoffinvest.OrderBookChange{OrderBook: tinkoffinvest.OrderBook{
+			FIGI: figi,
+			Asks: []tinkoffinvest.Order{level("95", 5)},
+		}}
+		f := <-fills
+		assert.Equal(t, orderID, f.OrderID)
+		assert.Equal(t, 2, f.Lots)
+
+		// The short is closed with 80 of profit.
+		requireMoney(t, tr, "10080", "0")
+
+		portfolio, err := tr.GetPortfolio(ctx, accountID)
+		require.NoError(t, err)
+		assert.Empty(t, portfolio.Positions)
+	})
+
+	cancel()
+	<-done
+}
+
+func TestTrader_Bond(t *testing.T) {
+	tool := toolscache.Tool{
+		FIGI:         figi,
+		Type:         tinkoffinvest.InstrumentTypeBond,
+		Currency:     "rub",
+		StocksPerLot: 1,
+		Nominal:      decimal.NewFromInt(1000),
+	}
+	tr, _ := newTraderOf(t, tool, papertrader.Options{Balance: rub(10000)}, tinkoffinvest.OrderBook{
+		Bids: []tinkoffinvest.Order{level("98", 5)},
+		Asks: []tinkoffinvest.Order{level("98.5", 5)},
+	})
+	ctx := context.Background()
+
+	// The price is the percentage of the nominal: 2 bonds * 985.
+	orderID, err := tr.PlaceMarketBuyOrder(ctx, request(2, ""))
+	require.NoError(t, err)
+
+	state, err := tr.GetOrderState(ctx, accountID, orderID)
+	require.NoError(t, err)
+	assert.Equal(t, "98.5", state.AveragePrice.String())
+	assert.Equal(t, "1970", state.ExecutedAmount.String())
+	requireMoney(t, tr, "8030", "0")
+
+	// 5 bonds * 980.
+	_, err = tr.PlaceLimitBuyOrder(ctx, request(5, "98"))
+	require.NoError(t, err)
+	requireMoney(t, tr, "3130", "4900")
+
+	_, err = tr.PlaceMarketSellOrder(ctx, request(2, ""))
+	require.NoError(t, err)
+	requireMoney(t, tr, "5090", "4900")
+
+	portfolio, err := tr.GetPortfolio(ctx, accountID)
+	require.NoError(t, err)
+	assert.Empty(t, portfolio.Positions)
+}
+
+func TestTrader_SubscribeForOrderFills_OtherAccount(t *testing.T) {
+	tr, _ := newTrader(t, papertrader.Options{Balance: rub(10000)}, tinkoffinvest.OrderBook{
+		Asks: []tinkoffinvest.Order{level("100", 2)},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fills, err := tr.SubscribeForOrderFills(ctx, []tinkoffinvest.AccountID{"other"})
+	require.NoError(t, err)
+
+	_, err = tr.PlaceMarketBuyOrder(ctx, request(1, ""))
+	require.NoError(t, err)
+
+	select {
+	case f := <-fills:
+		t.Fatalf("unexpected fill: %v", f)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
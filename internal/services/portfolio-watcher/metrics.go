@@ -12,8 +12,15 @@ var (
 		Namespace: "trading_robot",
 		Subsystem: subsystem,
 		Name:      "balance",
-		Help:      "Current account balance",
-	}, []string{"account_number"})
+		Help:      "Current available account balance",
+	}, []string{"account_number", "currency"})
+
+	blockedBalance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "blocked_balance",
+		Help:      "Account balance blocked by active orders",
+	}, []string{"account_number", "currency"})
 
 	sharesTotalPrice = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "trading_robot",
@@ -29,10 +36,31 @@ var (
 		Help:      "Portfolio share quantity",
 	}, []string{"account_number", "figi"})
 
+	shareBlocked = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "share_blocked",
+		Help:      "Portfolio share quantity blocked by active orders",
+	}, []string{"account_number", "figi"})
+
 	shareAvgPrice = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "trading_robot",
 		Subsystem: subsystem,
 		Name:      "share_avg_price",
 		Help:      "Portfolio share average price",
 	}, []string{"account_number", "figi"})
+
+	operationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "operations_total",
+		Help:      "Total amount of account operations by kind",
+	}, []string{"account_number", "kind"})
+
+	commissionsPaid = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "commissions_paid_total",
+		Help:      "Total commissions paid by strategy",
+	}, []string{"account_number", "strategy", "currency"})
 )
@@ -10,7 +10,6 @@ import (
 
 	tinkoffinvest "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
 	gomock "github.com/golang/mock/gomock"
-	decimal "github.com/shopspring/decimal"
 )
 
 // MockPortfolioDataProvider is a mock of PortfolioDataProvider interface.
@@ -36,19 +35,19 @@ func (m *MockPortfolioDataProvider) EXPECT() *MockPortfolioDataProviderMockRecor
 	return m.recorder
 }
 
-// GetBalance mocks base method.
-func (m *MockPortfolioDataProvider) GetBalance(ctx context.Context, accountID tinkoffinvest.AccountID) (decimal.Decimal, error) {
+// GetOperations mocks base method.
+func (m *MockPortfolioDataProvider) GetOperations(ctx context.Context, request tinkoffinvest.OperationsRequest) ([]tinkoffinvest.Operation, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBalance", ctx, accountID)
-	ret0, _ := ret[0].(decimal.Decimal)
+	ret := m.ctrl.Call(m, "GetOperations", ctx, request)
+	ret0, _ := ret[0].([]tinkoffinvest.Operation)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetBalance indicates an expected call of GetBalance.
-func (mr *MockPortfolioDataProviderMockRecorder) GetBalance(ctx, accountID interface{}) *gomock.Call {
+// GetOperations indicates an expected call of GetOperations.
+func (mr *MockPortfolioDataProviderMockRecorder) GetOperations(ctx, request interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockPortfolioDataProvider)(nil).GetBalance), ctx, accountID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOperations", reflect.TypeOf((*MockPortfolioDataProvider)(nil).GetOperations), ctx, request)
 }
 
 // GetPortfolio mocks base method.
@@ -65,3 +64,18 @@ func (mr *MockPortfolioDataProviderMockRecorder) GetPortfolio(ctx, accountID int
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPortfolio", reflect.TypeOf((*MockPortfolioDataProvider)(nil).GetPortfolio), ctx, accountID)
 }
+
+// GetPositions mocks base method.
+func (m *MockPortfolioDataProvider) GetPositions(ctx context.Context, accountID tinkoffinvest.AccountID) (*tinkoffinvest.Positions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPositions", ctx, accountID)
+	ret0, _ := ret[0].(*tinkoffinvest.Positions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPositions indicates an expected call of GetPositions.
+func (mr *MockPortfolioDataProviderMockRecorder) GetPositions(ctx, accountID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPositions", reflect.TypeOf((*MockPortfolioDataProvider)(nil).GetPositions), ctx, accountID)
+}
@@ -3,6 +3,7 @@ package portfoliowatcher
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -14,38 +15,64 @@ import (
 
 //go:generate mockgen -source=$GOFILE -destination=mocks/watcher_generated.go -package portfoliowatchermocks PortfolioDataProvider
 
-const defaultInterval = 5 * time.Second
+const (
+	defaultInterval = 5 * time.Second
+	// operationsLookBehind covers operations registered by broker with a delay.
+	operationsLookBehind = 10 * time.Minute
+	noStrategy           = "none"
+)
 
 type l = prometheus.Labels
 
 type PortfolioDataProvider interface {
-	GetBalance(ctx context.Context, accountID tinkoffinvest.AccountID) (decimal.Decimal, error)
+	GetPositions(ctx context.Context, accountID tinkoffinvest.AccountID) (*tinkoffinvest.Positions, error)
 	GetPortfolio(ctx context.Context, accountID tinkoffinvest.AccountID) (*tinkoffinvest.Portfolio, error)
+	GetOperations(ctx context.Context, request tinkoffinvest.OperationsRequest) ([]tinkoffinvest.Operation, error)
 }
 
+// Watcher monitors the account balance, portfolio and activity feed (operations history).
 type Watcher struct {
-	interval    time.Duration
-	account     tinkoffinvest.AccountID
-	prevBalance decimal.Decimal
-	provider    PortfolioDataProvider
+	interval     time.Duration
+	account      tinkoffinvest.AccountID
+	prevBalances map[string]decimal.Decimal
+	provider     PortfolioDataProvider
+
+	strategiesMu sync.Mutex
+	// strategies are used to attribute operations by instrument.
+	strategies map[tinkoffinvest.FIGI]string
+
+	activitySince  time.Time
+	seenOperations map[string]time.Time
 }
 
-func New(interval time.Duration, accountID tinkoffinvest.AccountID, provider PortfolioDataProvider) *Watcher {
+func New(
+	interval time.Duration,
+	accountID tinkoffinvest.AccountID,
+	strategies map[tinkoffinvest.FIGI]string,
+	provider PortfolioDataProvider,
+) *Watcher {
 	if interval <= 0 {
 		interval = defaultInterval
 	}
 	return &Watcher{
-		interval:    interval,
-		account:     accountID,
-		prevBalance: decimal.Zero,
-		provider:    provider,
+		interval:       interval,
+		account:        accountID,
+		prevBalances:   make(map[string]decimal.Decimal),
+		provider:       provider,
+		strategies:     strategies,
+		seenOperations: make(map[string]time.Time),
 	}
 }
 
 func (w *Watcher) Run(ctx context.Context) error {
+	w.activitySince = time.Now()
+
 	if err := w.fetchAndSetAccountInfo(ctx); err != nil {
 		log.Err(err).Msg("initial account info fetch")
 	}
+	if err := w.fetchActivity(ctx); err != nil {
+		log.Err(err).Msg("initial activity fetch")
+	}
 
 	for {
 		select {
@@ -56,38 +83,130 @@ func (w *Watcher) Run(ctx context.Context) error {
 			if err := w.fetchAndSetAccountInfo(ctx); err != nil {
 				log.Err(err).Msg("periodic account info fetch")
 			}
+			if err := w.fetchActivity(ctx); err != nil {
+				log.Err(err).Msg("periodic activity fetch")
+			}
 		}
 	}
 }
 
 func (w *Watcher) fetchAndSetAccountInfo(ctx context.Context) error {
-	balance, err := w.provider.GetBalance(ctx, w.account)
+	positions, err := w.provider.GetPositions(ctx, w.account)
 	if err != nil {
-		return fmt.Errorf("get balance: %v", err)
+		return fmt.Errorf("get positions: %w", err)
 	}
 
 	portfolio, err := w.provider.GetPortfolio(ctx, w.account)
 	if err != nil {
-		return fmt.Errorf("get portfolio: %v", err)
+		return fmt.Errorf("get portfolio: %w", err)
 	}
 
-	if !balance.Equal(w.prevBalance) {
-		w.prevBalance = balance
+	for currency, balance := range positions.Money {
+		if !balance.Equal(w.prevBalances[currency]) {
+			w.prevBalances[currency] = balance
+
+			log.Info().
+				Str("service", "portfolio-watcher").
+				Str("account", w.account.S()).
+				Str("currency", currency).
+				Float64("balance", balance.InexactFloat64()).
+				Float64("blocked", positions.BlockedMoney[currency].InexactFloat64()).
+				Msg("new account balance")
+		}
 
-		log.Info().
-			Str("service", "portfolio-watcher").
-			Str("account", w.account.S()).
-			Float64("balance", balance.InexactFloat64()).
-			Msg("new account balance")
+		currentBalance.With(l{"account_number": w.account.S(), "currency": currency}).Set(balance.InexactFloat64())
+	}
+	for currency, blocked := range positions.BlockedMoney {
+		blockedBalance.With(l{"account_number": w.account.S(), "currency": currency}).Set(blocked.InexactFloat64())
 	}
 
-	currentBalance.With(l{"account_number": w.account.S()}).Set(balance.InexactFloat64())
-	sharesTotalPrice.With(l{"account_number": w.account.S()}).Set(portfolio.TotalSharesPrice.InexactFloat64())
+	for _, s := range positions.Securities {
+		shareQuantity.With(l{"account_number": w.account.S(), "figi": s.FIGI.S()}).Set(float64(s.Balance + s.Blocked))
+		shareBlocked.With(l{"account_number": w.account.S(), "figi": s.FIGI.S()}).Set(float64(s.Blocked))
+	}
 
+	sharesTotalPrice.With(l{"account_number": w.account.S()}).Set(portfolio.TotalSharesPrice.InexactFloat64())
 	for _, share := range portfolio.Shares {
-		shareQuantity.With(l{"account_number": w.account.S(), "figi": share.FIGI.S()}).Set(float64(share.Quantity))
 		shareAvgPrice.With(l{"account_number": w.account.S(), "figi": share.FIGI.S()}).Set(share.AvgPrice.InexactFloat64())
 	}
 
 	return nil
 }
+
+// fetchActivity logs the operations executed since the previous fetch and collects commissions per strategy.
+func (w *Watcher) fetchActivity(ctx context.Context) error {
+	now := time.Now()
+	from := w.activitySince.Add(-operationsLookBehind)
+
+	operations, err := w.provider.GetOperations(ctx, tinkoffinvest.OperationsRequest{
+		AccountID: w.account,
+		From:      from,
+		To:        now,
+	})
+	if err != nil {
+		return fmt.Errorf("get operations: %w", err)
+	}
+
+	for _, op := range operations {
+		if _, ok := w.seenOperations[op.ID]; ok {
+			continue
+		}
+		w.seenOperations[op.ID] = op.Time
+
+		strategy := w.strategyOf(op.FIGI)
+
+		log.Info().
+			Str("service", "portfolio-watcher").
+			Str("account", w.account.S()).
+			Str("operation_id", op.ID).
+			Stringer("kind", op.Kind).
+			Str("description", op.Description).
+			Str("figi", op.FIGI.S()).
+			Str("strategy", strategy).
+			Str("payment", op.Payment.String()).
+			Str("currency", op.Currency).
+			Int("quantity", op.Quantity).
+			Time("operation_time", op.Time).
+			Msg("account activity")
+
+		operationsTotal.With(l{"account_number": w.account.S(), "kind": op.Kind.String()}).Inc()
+
+		if op.Kind == tinkoffinvest.OperationKindCommission {
+			if paid := op.Payment.Neg(); paid.IsPositive() {
+				commissionsPaid.With(l{
+					"account_number": w.account.S(),
+					"strategy":       strategy,
+					"currency":       op.Currency,
+				}).Add(paid.InexactFloat64())
+			}
+		}
+	}
+
+	for id, t := range w.seenOperations {
+		if t.Before(from) {
+			delete(w.seenOperations, id)
+		}
+	}
+	w.activitySince = now
+
+	return nil
+}
+
+// SetStrategies replaces the instruments attribution of the running watcher.
+// Unlike the new watcher, it keeps the seen operations, so they are not collected twice.
+func (w *Watcher) SetStrategies(strategies map[tinkoffinvest.FIGI]string) {
+	w.strategiesMu.Lock()
+	defer w.strategiesMu.Unlock()
+
+	w.strategies = strategies
+}
+
+func (w *Watcher) strategyOf(figi tinkoffinvest.FIGI) string {
+	w.strategiesMu.Lock()
+	defer w.strategiesMu.Unlock()
+
+	if s, ok := w.strategies[figi]; ok {
+		return s
+	}
+	return noStrategy
+}
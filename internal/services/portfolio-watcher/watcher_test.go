@@ -7,6 +7,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
 
 	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
 	portfoliowatcher "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/portfolio-watcher"
@@ -20,12 +21,26 @@ func TestWatcher(t *testing.T) {
 	defer ctrl.Finish()
 
 	provider := portfoliowatchermocks.NewMockPortfolioDataProvider(ctrl)
-	w := portfoliowatcher.New(time.Second, accountID, provider)
+	w := portfoliowatcher.New(time.Second, accountID, map[tinkoffinvest.FIGI]string{
+		"BBG004S68473": "spread-parasite",
+	}, provider)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
 	defer cancel()
 
-	provider.EXPECT().GetBalance(gomock.Any(), accountID).Return(decimal.RequireFromString("100000"), nil).Times(2)
+	provider.EXPECT().GetPositions(gomock.Any(), accountID).Return(&tinkoffinvest.Positions{
+		Money: map[string]decimal.Decimal{
+			"rub": decimal.RequireFromString("100000"),
+			"usd": decimal.RequireFromString("100"),
+		},
+		BlockedMoney: map[string]decimal.Decimal{
+			"rub": decimal.RequireFromString("1000"),
+		},
+		Securities: []tinkoffinvest.SecurityPosition{
+			{FIGI: "BBG004S68473", Balance: 9, Blocked: 1},
+			{FIGI: "BBG000NL6ZD9", Balance: 23},
+		},
+	}, nil).Times(2)
 	provider.EXPECT().GetPortfolio(gomock.Any(), accountID).Return(&tinkoffinvest.Portfolio{
 		TotalSharesPrice: decimal.RequireFromString("99000"),
 		Shares: []tinkoffinvest.PortfolioPosition{
@@ -42,6 +57,38 @@ func TestWatcher(t *testing.T) {
 		},
 	}, nil).Times(2)
 
+	trade := tinkoffinvest.Operation{
+		ID:        "op-1",
+		Kind:      tinkoffinvest.OperationKindTrade,
+		FIGI:      "BBG004S68473",
+		Direction: tinkoffinvest.TradeDirectionBuy,
+		Payment:   decimal.RequireFromString("-1000"),
+		Currency:  "rub",
+		Price:     decimal.RequireFromString("100"),
+		Quantity:  10,
+		Time:      time.Now(),
+	}
+	commission := tinkoffinvest.Operation{
+		ID:       "op-2",
+		ParentID: "op-1",
+		Kind:     tinkoffinvest.OperationKindCommission,
+		FIGI:     "BBG004S68473",
+		Payment:  decimal.RequireFromString("-0.5"),
+		Currency: "rub",
+		Time:     time.Now(),
+	}
+	gomock.InOrder(
+		provider.EXPECT().GetOperations(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req tinkoffinvest.OperationsRequest) ([]tinkoffinvest.Operation, error) {
+				assert.Equal(t, accountID, req.AccountID)
+				assert.True(t, req.From.Before(req.To))
+				return []tinkoffinvest.Operation{trade}, nil
+			}),
+		// The trade is returned again due to look behind, but must be logged once.
+		provider.EXPECT().GetOperations(gomock.Any(), gomock.Any()).
+			Return([]tinkoffinvest.Operation{trade, commission}, nil),
+	)
+
 	done := make(chan struct{})
 	go func() {
 		defer close(done)
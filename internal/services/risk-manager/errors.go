@@ -0,0 +1,45 @@
+package riskmanager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+)
+
+// ErrRejected is matched by all the rejections of the risk manager, use errors.As with *RejectionError for details.
+var ErrRejected = errors.New("order rejected by risk manager")
+
+// Reason is the violated limit.
+type Reason string
+
+const (
+	ReasonMaxPosition        Reason = "max_position"
+	ReasonMaxNotional        Reason = "max_notional"
+	ReasonMaxOpenOrders      Reason = "max_open_orders"
+	ReasonMaxOrdersPerMinute Reason = "max_orders_per_minute"
+	ReasonMaxDailyLoss       Reason = "max_daily_loss"
+	// ReasonKillSwitch rejects all orders after the kill switch is tripped.
+	ReasonKillSwitch Reason = "kill_switch"
+)
+
+// RejectionError is returned instead of placing the order violating the limits.
+type RejectionError struct {
+	Reason  Reason
+	Account tinkoffinvest.AccountID
+	FIGI    tinkoffinvest.FIGI
+	// Value is the value of the limited parameter if the order were placed, e.g. "12" lots.
+	Value string
+	Limit string
+}
+
+func (e *RejectionError) Error() string {
+	if e.Reason == ReasonKillSwitch {
+		return fmt.Sprintf("%v: %s: %s", ErrRejected, e.Reason, e.FIGI)
+	}
+	return fmt.Sprintf("%v: %s: %s: %s exceeds %s", ErrRejected, e.Reason, e.FIGI, e.Value, e.Limit)
+}
+
+func (e *RejectionError) Unwrap() error {
+	return ErrRejected
+}
@@ -0,0 +1,681 @@
+package riskmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+)
+
+//go:generate mockgen -source=$GOFILE -destination=mocks/manager_generated.go -package riskmanagermocks Client,ToolsCache
+
+const (
+	defaultSyncInterval = time.Minute
+	ordersRateWindow    = time.Minute
+)
+
+type l = prometheus.Labels
+
+// Client is decorated by Manager, implemented by tinkoffinvest.Client.
+type Client interface {
+	GetTradeAvailableInstruments(ctx context.Context, typ tinkoffinvest.InstrumentType) ([]tinkoffinvest.Instrument, error)
+	GetOrderBook(ctx context.Context, req tinkoffinvest.OrderBookRequest) (*tinkoffinvest.OrderBookResponse, error)
+	GetTradingStatus(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.TradingStatus, error)
+	GetLastPrices(ctx context.Context, figis []tinkoffinvest.FIGI) ([]tinkoffinvest.LastPrice, error)
+
+	GetPortfolio(ctx context.Context, accountID tinkoffinvest.AccountID) (*tinkoffinvest.Portfolio, error)
+	GetOrders(ctx context.Context, accountID tinkoffinvest.AccountID) ([]tinkoffinvest.ActiveOrder, error)
+	GetOrderState(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) (*tinkoffinvest.OrderState, error) //nolint:lll
+	SubscribeForOrderFills(ctx context.Context, accounts []tinkoffinvest.AccountID) (<-chan tinkoffinvest.OrderFill, error)
+	CancelOrder(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) error
+
+	PlaceMarketSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
+	PlaceMarketBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
+	PlaceLimitSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
+	PlaceLimitBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
+}
+
+type ToolsCache interface {
+	Get(ctx context.Context, figi tinkoffinvest.FIGI) (toolscache.Tool, error)
+}
+
+// Limits are applied to each account, zero value disables the limit.
+type Limits struct {
+	// MaxPositionLots limits the position of instrument together with the open orders increasing it.
+	MaxPositionLots int
+	// MaxNotional limits the cost of positions and open orders of the account in instruments currencies.
+	MaxNotional        decimal.Decimal
+	MaxOpenOrders      int
+	MaxOrdersPerMinute int
+	// MaxDailyLoss stops placing orders increasing the positions when the realized loss
+	// since the start of the day (UTC) reaches it. The positions still can be closed.
+	MaxDailyLoss decimal.Decimal
+	// KillSwitchOn are the rejection reasons tripping the kill switch,
+	// which stops placing any orders of all accounts until restart.
+	KillSwitchOn []Reason
+}
+
+// Manager checks orders against the limits before placing them via the decorated Client.
+// Positions and realized loss are tracked by order fills of the accounts passed to Track.
+type Manager struct {
+	Client
+	toolsCache   ToolsCache
+	syncInterval time.Duration
+	logger       zerolog.Logger
+
+	mu       sync.Mutex
+	limits   Limits
+	killed   bool
+	accounts map[tinkoffinvest.AccountID]*accountState
+	// prices are the last known prices of one instrument, used to estimate the notional.
+	prices map[tinkoffinvest.FIGI]decimal.Decimal
+	// tools convert prices to money according to the instrument type.
+	tools   map[tinkoffinvest.FIGI]toolscache.Tool
+	tracked []tinkoffinvest.AccountID
+	// trackedChanged makes Run to resubscribe for order fills.
+	trackedChanged chan struct{}
+}
+
+type accountState struct {
+	positions map[tinkoffinvest.FIGI]*position
+	orders    map[tinkoffinvest.OrderID]*openOrder
+	// pending are the orders being placed.
+	pending map[*openOrder]struct{}
+	// placedAt are the times of orders placed during the last ordersRateWindow.
+	placedAt    []time.Time
+	day         time.Time
+	realizedPnL decimal.Decimal
+	synced      bool
+}
+
+type position struct {
+	lots     int
+	avgPrice decimal.Decimal
+}
+
+type openOrder struct {
+	figi      tinkoffinvest.FIGI
+	direction tinkoffinvest.TradeDirection
+	// lots are not executed yet.
+	lots     int
+	placedAt time.Time
+}
+
+func New(limits Limits, syncInterval time.Duration, client Client, toolsCache ToolsCache) *Manager {
+	if syncInterval <= 0 {
+		syncInterval = defaultSyncInterval
+	}
+	killSwitch.Set(0)
+
+	return &Manager{
+		Client:         client,
+		toolsCache:     toolsCache,
+		syncInterval:   syncInterval,
+		logger:         log.With().Str("service", "risk-manager").Logger(),
+		limits:         limits,
+		accounts:       make(map[tinkoffinvest.AccountID]*accountState),
+		prices:         make(map[tinkoffinvest.FIGI]decimal.Decimal),
+		tools:          make(map[tinkoffinvest.FIGI]toolscache.Tool),
+		trackedChanged: make(chan struct{}, 1),
+	}
+}
+
+// SetLimits applies new limits to the next orders. The tripped kill switch stays tripped.
+func (m *Manager) SetLimits(limits Limits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.limits = limits
+}
+
+// Track sets the accounts to sync positions, open orders and order fills for.
+func (m *Manager) Track(accounts []tinkoffinvest.AccountID) {
+	m.mu.Lock()
+	m.tracked = append([]tinkoffinvest.AccountID(nil), accounts...)
+	m.mu.Unlock()
+
+	select {
+	case m.trackedChanged <- struct{}{}:
+	default:
+	}
+}
+
+// Run follows the order fills of tracked accounts and periodically syncs their open orders.
+func (m *Manager) Run(ctx context.Context) error {
+	var (
+		accounts  []tinkoffinvest.AccountID
+		fills     <-chan tinkoffinvest.OrderFill
+		cancelSub = func() {}
+	)
+	defer func() { cancelSub() }()
+
+	ticker := time.NewTicker(m.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-m.trackedChanged:
+			m.mu.Lock()
+			accounts = m.tracked
+			m.mu.Unlock()
+
+			cancelSub()
+			fills = nil
+			if len(accounts) == 0 {
+				continue
+			}
+
+			subCtx, cancel := context.WithCancel(ctx)
+			cancelSub = cancel
+
+			var err error
+			if fills, err = m.Client.SubscribeForOrderFills(subCtx, accounts); err != nil {
+				return fmt.Errorf("subscribe for order fills: %w", err)
+			}
+
+			for _, acc := range accounts {
+				if m.isSynced(acc) {
+					continue
+				}
+				if err := m.sync(ctx, acc, true); err != nil {
+					m.logger.Err(err).Str("account", acc.S()).Msg("initial sync")
+				}
+			}
+
+		case f, ok := <-fills:
+			if !ok {
+				return errors.New("order fills stream closed")
+			}
+			m.applyFill(ctx, f)
+
+		case <-ticker.C:
+			for _, acc := range accounts {
+				if err := m.sync(ctx, acc, false); err != nil {
+					m.logger.Err(err).Str("account", acc.S()).Msg("periodic sync")
+				}
+			}
+		}
+	}
+}
+
+func (m *Manager) PlaceMarketSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return m.place(ctx, request, tinkoffinvest.TradeDirectionSell, true, m.Client.PlaceMarketSellOrder)
+}
+
+func (m *Manager) PlaceMarketBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return m.place(ctx, request, tinkoffinvest.TradeDirectionBuy, true, m.Client.PlaceMarketBuyOrder)
+}
+
+func (m *Manager) PlaceLimitSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return m.place(ctx, request, tinkoffinvest.TradeDirectionSell, false, m.Client.PlaceLimitSellOrder)
+}
+
+func (m *Manager) PlaceLimitBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) { //nolint:lll
+	return m.place(ctx, request, tinkoffinvest.TradeDirectionBuy, false, m.Client.PlaceLimitBuyOrder)
+}
+
+func (m *Manager) CancelOrder(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) error {
+	if err := m.Client.CancelOrder(ctx, accountID, orderID); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := m.account(accountID)
+	delete(st.orders, orderID)
+	m.collectExposure(accountID, st)
+	return nil
+}
+
+func (m *Manager) place(
+	ctx context.Context,
+	request tinkoffinvest.PlaceOrderRequest,
+	direction tinkoffinvest.TradeDirection,
+	isMarket bool,
+	place func(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error),
+) (tinkoffinvest.OrderID, error) {
+	if err := m.fetchTool(ctx, request.FIGI); err != nil {
+		return "", err
+	}
+
+	price := request.Price
+	if isMarket {
+		var err error
+		if price, err = m.marketPrice(ctx, request.FIGI); err != nil {
+			return "", err
+		}
+	}
+
+	o := &openOrder{
+		figi:      request.FIGI,
+		direction: direction,
+		lots:      request.Lots,
+		placedAt:  time.Now(),
+	}
+	if err := m.reserve(request.AccountID, o, price); err != nil {
+		return "", err
+	}
+
+	orderID, err := place(ctx, request)
+	m.confirm(request.AccountID, o, orderID, err)
+	return orderID, err
+}
+
+// marketPrice returns the last price of the instrument or the last known one if it cannot be fetched.
+func (m *Manager) marketPrice(ctx context.Context, figi tinkoffinvest.FIGI) (decimal.Decimal, error) {
+	prices, err := m.Client.GetLastPrices(ctx, []tinkoffinvest.FIGI{figi})
+	if err == nil && len(prices) > 0 && prices[0].Price.IsPositive() {
+		return prices[0].Price, nil
+	}
+
+	m.mu.Lock()
+	price, ok := m.prices[figi]
+	m.mu.Unlock()
+
+	if !ok {
+		if err == nil {
+			err = errors.New("no last price")
+		}
+		return decimal.Zero, fmt.Errorf("get last price of %s: %w", figi, err)
+	}
+	return price, nil
+}
+
+func (m *Manager) fetchTool(ctx context.Context, figi tinkoffinvest.FIGI) error {
+	m.mu.Lock()
+	_, ok := m.tools[figi]
+	m.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	tool, err := m.toolsCache.Get(ctx, figi)
+	if err != nil {
+		return fmt.Errorf("get tool %s: %w", figi, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tools[figi] = tool
+	return nil
+}
+
+// reserve checks the order and counts it as open until confirm.
+func (m *Manager) reserve(account tinkoffinvest.AccountID, o *openOrder, price decimal.Decimal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if price.IsPositive() {
+		m.prices[o.figi] = price
+	}
+
+	st := m.account(account)
+	st.rollDay(o.placedAt)
+	st.pruneRate(o.placedAt)
+
+	if err := m.check(account, st, o); err != nil {
+		var rejection *RejectionError
+		if errors.As(err, &rejection) {
+			rejectionsTotal.With(l{"account_number": account.S(), "reason": string(rejection.Reason)}).Inc()
+			if m.tripsKillSwitch(rejection.Reason) {
+				m.tripKillSwitch(rejection)
+			}
+		}
+		return err
+	}
+
+	st.pending[o] = struct{}{}
+	st.placedAt = append(st.placedAt, o.placedAt)
+	m.collectExposure(account, st)
+	return nil
+}
+
+func (m *Manager) check(account tinkoffinvest.AccountID, st *accountState, o *openOrder) error {
+	reject := func(reason Reason, value, limit string) error {
+		return &RejectionError{Reason: reason, Account: account, FIGI: o.figi, Value: value, Limit: limit}
+	}
+
+	if m.killed {
+		return reject(ReasonKillSwitch, "", "")
+	}
+
+	before, after := st.exposureLots(o.figi, nil), st.exposureLots(o.figi, o)
+	// The order reducing the risk is not rejected by the daily loss to let the positions be closed.
+	reducesRisk := after <= before
+
+	if lim := m.limits.MaxDailyLoss; lim.IsPositive() && !reducesRisk {
+		if loss := st.realizedPnL.Neg(); loss.GreaterThanOrEqual(lim) {
+			return reject(ReasonMaxDailyLoss, loss.String(), lim.String())
+		}
+	}
+
+	if lim := m.limits.MaxOrdersPerMinute; lim > 0 && len(st.placedAt) >= lim {
+		return reject(ReasonMaxOrdersPerMinute, fmt.Sprint(len(st.placedAt)+1), fmt.Sprint(lim))
+	}
+
+	if lim := m.limits.MaxOpenOrders; lim > 0 {
+		if open := len(st.orders) + len(st.pending); open >= lim {
+			return reject(ReasonMaxOpenOrders, fmt.Sprint(open+1), fmt.Sprint(lim))
+		}
+	}
+
+	if reducesRisk {
+		return nil
+	}
+
+	if lim := m.limits.MaxPositionLots; lim > 0 && after > lim {
+		return reject(ReasonMaxPosition, fmt.Sprint(after), fmt.Sprint(lim))
+	}
+
+	if lim := m.limits.MaxNotional; lim.IsPositive() {
+		notional := m.notional(st).Add(m.cost(o.figi, after-before))
+		if notional.GreaterThan(lim) {
+			return reject(ReasonMaxNotional, notional.String(), lim.String())
+		}
+	}
+	return nil
+}
+
+func (m *Manager) tripsKillSwitch(reason Reason) bool {
+	for _, r := range m.limits.KillSwitchOn {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) tripKillSwitch(cause *RejectionError) {
+	m.killed = true
+	killSwitch.Set(1)
+	m.logger.Error().Err(cause).Msg("kill switch is tripped, orders are not placed until restart")
+}
+
+func (m *Manager) confirm(account tinkoffinvest.AccountID, o *openOrder, orderID tinkoffinvest.OrderID, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := m.account(account)
+	delete(st.pending, o)
+	if _, ok := st.orders[orderID]; err == nil && !ok {
+		st.orders[orderID] = o
+	}
+	m.collectExposure(account, st)
+}
+
+func (m *Manager) applyFill(ctx context.Context, f tinkoffinvest.OrderFill) {
+	if err := m.fetchTool(ctx, f.FIGI); err != nil {
+		m.logger.Err(err).Str("order_id", f.OrderID.S()).Msg("cannot apply order fill")
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prices[f.FIGI] = f.Price
+
+	st := m.account(f.AccountID)
+	st.rollDay(time.Now())
+
+	lots := f.Lots
+	if f.Direction == tinkoffinvest.TradeDirectionSell {
+		lots = -lots
+	}
+	realized := st.position(f.FIGI).apply(lots, f.Price)
+	tool := m.tools[f.FIGI]
+	st.realizedPnL = st.realizedPnL.Add(tool.Amount(realized, tool.StocksPerLot))
+
+	if o, ok := st.orders[f.OrderID]; ok {
+		if o.lots -= f.Lots; o.lots <= 0 {
+			delete(st.orders, f.OrderID)
+		}
+	}
+
+	dailyRealizedPnL.With(l{"account_number": f.AccountID.S()}).Set(st.realizedPnL.InexactFloat64())
+	m.collectExposure(f.AccountID, st)
+}
+
+// sync replaces the open orders and, optionally, positions of the account with the actual ones.
+func (m *Manager) sync(ctx context.Context, account tinkoffinvest.AccountID, withPositions bool) error {
+	startedAt := time.Now()
+
+	orders, err := m.Client.GetOrders(ctx, account)
+	if err != nil {
+		return fmt.Errorf("get orders: %w", err)
+	}
+	for _, o := range orders {
+		if err := m.fetchTool(ctx, o.FIGI); err != nil {
+			return err
+		}
+	}
+
+	var portfolio *tinkoffinvest.Portfolio
+	if withPositions {
+		if portfolio, err = m.Client.GetPortfolio(ctx, account); err != nil {
+			return fmt.Errorf("get portfolio: %w", err)
+		}
+		for _, p := range portfolio.Positions {
+			if err := m.fetchTool(ctx, p.FIGI); err != nil {
+				if p.Type == tinkoffinvest.InstrumentTypeCurrency {
+					// The money of the account, like RUB000UTSTOM, is not always a tradable instrument.
+					m.logger.Debug().Err(err).Str("figi", p.FIGI.S()).Msg("skip currency position")
+					continue
+				}
+				return err
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := m.account(account)
+
+	actual := make(map[tinkoffinvest.OrderID]*openOrder, len(orders))
+	for _, o := range orders {
+		actual[o.ID] = &openOrder{
+			figi:      o.FIGI,
+			direction: o.Direction,
+			lots:      o.LotsRequested - o.LotsExecuted,
+			placedAt:  o.CreatedAt,
+		}
+		m.setPriceIfUnknown(o.FIGI, o.Price)
+	}
+	for id, o := range st.orders {
+		// Placed after the request of orders.
+		if _, ok := actual[id]; !ok && o.placedAt.After(startedAt) {
+			actual[id] = o
+		}
+	}
+	st.orders = actual
+
+	if portfolio != nil {
+		st.positions = make(map[tinkoffinvest.FIGI]*position, len(portfolio.Positions))
+		for _, p := range portfolio.Positions {
+			lotSize := m.tools[p.FIGI].StocksPerLot
+			if lotSize <= 0 {
+				continue
+			}
+			st.positions[p.FIGI] = &position{lots: p.Quantity / lotSize, avgPrice: p.AvgPrice}
+			m.setPriceIfUnknown(p.FIGI, p.AvgPrice)
+		}
+		st.synced = true
+	}
+
+	m.collectExposure(account, st)
+	return nil
+}
+
+func (m *Manager) isSynced(account tinkoffinvest.AccountID) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.account(account).synced
+}
+
+func (m *Manager) setPriceIfUnknown(figi tinkoffinvest.FIGI, price decimal.Decimal) {
+	if _, ok := m.prices[figi]; !ok && price.IsPositive() {
+		m.prices[figi] = price
+	}
+}
+
+func (m *Manager) account(account tinkoffinvest.AccountID) *accountState {
+	st, ok := m.accounts[account]
+	if !ok {
+		st = &accountState{
+			positions: make(map[tinkoffinvest.FIGI]*position),
+			orders:    make(map[tinkoffinvest.OrderID]*openOrder),
+			pending:   make(map[*openOrder]struct{}),
+		}
+		m.accounts[account] = st
+	}
+	return st
+}
+
+// notional is the cost of the positions and open orders increasing them.
+func (m *Manager) notional(st *accountState) decimal.Decimal {
+	figis := make(map[tinkoffinvest.FIGI]struct{}, len(st.positions))
+	for f := range st.positions {
+		figis[f] = struct{}{}
+	}
+	st.eachOrder(func(o *openOrder) { figis[o.figi] = struct{}{} })
+
+	total := decimal.Zero
+	for f := range figis {
+		total = total.Add(m.cost(f, st.exposureLots(f, nil)))
+	}
+	return total
+}
+
+func (m *Manager) cost(figi tinkoffinvest.FIGI, lots int) decimal.Decimal {
+	tool := m.tools[figi]
+	return tool.Amount(m.prices[figi], lots*tool.StocksPerLot)
+}
+
+func (m *Manager) collectExposure(account tinkoffinvest.AccountID, st *accountState) {
+	exposure.With(l{"account_number": account.S()}).Set(m.notional(st).InexactFloat64())
+}
+
+func (st *accountState) position(figi tinkoffinvest.FIGI) *position {
+	p, ok := st.positions[figi]
+	if !ok {
+		p = new(position)
+		st.positions[figi] = p
+	}
+	return p
+}
+
+// exposureLots returns the absolute position of the instrument if all the open orders
+// in one of directions are executed, together with the extra order if not nil.
+func (st *accountState) exposureLots(figi tinkoffinvest.FIGI, extra *openOrder) int {
+	var pos int
+	if p, ok := st.positions[figi]; ok {
+		pos = p.lots
+	}
+
+	long, short := pos, pos
+	add := func(o *openOrder) {
+		if o.figi != figi {
+			return
+		}
+		if o.direction == tinkoffinvest.TradeDirectionBuy {
+			long += o.lots
+		} else {
+			short -= o.lots
+		}
+	}
+	st.eachOrder(add)
+	if extra != nil {
+		add(extra)
+	}
+
+	if -short > long {
+		return -short
+	}
+	if long < 0 {
+		return -long
+	}
+	return long
+}
+
+func (st *accountState) eachOrder(f func(o *openOrder)) {
+	for _, o := range st.orders {
+		f(o)
+	}
+	for o := range st.pending {
+		f(o)
+	}
+}
+
+// rollDay resets the realized PnL at the start of the day. UTC midnight is between the exchange sessions.
+func (st *accountState) rollDay(now time.Time) {
+	day := now.UTC().Truncate(24 * time.Hour)
+	if !st.day.Equal(day) {
+		st.day = day
+		st.realizedPnL = decimal.Zero
+	}
+}
+
+func (st *accountState) pruneRate(now time.Time) {
+	i := 0
+	for i < len(st.placedAt) && now.Sub(st.placedAt[i]) >= ordersRateWindow {
+		i++
+	}
+	st.placedAt = st.placedAt[i:]
+}
+
+// apply changes the position by signed lots and returns the realized PnL per one instrument of the lot.
+func (p *position) apply(lots int, price decimal.Decimal) decimal.Decimal {
+	if lots == 0 {
+		return decimal.Zero
+	}
+
+	if p.lots == 0 || (p.lots > 0) == (lots > 0) {
+		total := decimal.NewFromInt(int64(abs(p.lots) + abs(lots)))
+		p.avgPrice = p.avgPrice.Mul(decimal.NewFromInt(int64(abs(p.lots)))).
+			Add(price.Mul(decimal.NewFromInt(int64(abs(lots))))).
+			Div(total)
+		p.lots += lots
+		return decimal.Zero
+	}
+
+	closed := abs(lots)
+	if abs(p.lots) < closed {
+		closed = abs(p.lots)
+	}
+	realized := price.Sub(p.avgPrice).Mul(decimal.NewFromInt(int64(closed)))
+	if p.lots < 0 {
+		realized = realized.Neg()
+	}
+
+	p.lots += lots
+	switch {
+	case p.lots == 0:
+		p.avgPrice = decimal.Zero
+	case (p.lots > 0) == (lots > 0):
+		// The position is reversed.
+		p.avgPrice = price
+	}
+	return realized
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,287 @@
+package riskmanager_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	riskmanager "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/risk-manager"
+	riskmanagermocks "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/risk-manager/mocks"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+)
+
+const (
+	accountID = tinkoffinvest.AccountID("account-zzz")
+	figi      = tinkoffinvest.FIGI("BBG004730N88")
+	rubFIGI   = tinkoffinvest.FIGI("RUB000UTSTOM")
+	siFIGI    = tinkoffinvest.FIGI("FUTSI0622000")
+)
+
+func newManager(t *testing.T, limits riskmanager.Limits) (*riskmanager.Manager, *riskmanagermocks.MockClient) {
+	t.Helper()
+
+	ctrl := gomock.NewController(t)
+	client := riskmanagermocks.NewMockClient(ctrl)
+	toolsCache := riskmanagermocks.NewMockToolsCache(ctrl)
+	toolsCache.EXPECT().Get(gomock.Any(), figi).Return(toolscache.Tool{FIGI: figi, StocksPerLot: 10}, nil).AnyTimes()
+	toolsCache.EXPECT().Get(gomock.Any(), siFIGI).Return(toolscache.Tool{
+		FIGI:         siFIGI,
+		Type:         tinkoffinvest.InstrumentTypeFutures,
+		StocksPerLot: 1,
+		PointValue:   decimal.NewFromInt(20),
+	}, nil).AnyTimes()
+	toolsCache.EXPECT().Get(gomock.Any(), rubFIGI).Return(toolscache.Tool{}, errors.New("not found")).AnyTimes()
+
+	return riskmanager.New(limits, time.Hour, client, toolsCache), client
+}
+
+func limitOrder(lots int, price string) tinkoffinvest.PlaceOrderRequest {
+	return tinkoffinvest.PlaceOrderRequest{
+		AccountID: accountID,
+		FIGI:      figi,
+		Lots:      lots,
+		Price:     decimal.RequireFromString(price),
+	}
+}
+
+func requireRejection(t *testing.T, err error, reason riskmanager.Reason) {
+	t.Helper()
+
+	require.ErrorIs(t, err, riskmanager.ErrRejected)
+
+	var rejection *riskmanager.RejectionError
+	require.ErrorAs(t, err, &rejection)
+	assert.Equal(t, reason, rejection.Reason)
+	assert.Equal(t, accountID, rejection.Account)
+	assert.Equal(t, figi, rejection.FIGI)
+}
+
+func TestManager_MaxPosition(t *testing.T) {
+	m, client := newManager(t, riskmanager.Limits{MaxPositionLots: 2})
+	ctx := context.Background()
+
+	client.EXPECT().PlaceLimitBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-1"), nil)
+	client.EXPECT().PlaceLimitBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-2"), nil)
+
+	_, err := m.PlaceLimitBuyOrder(ctx, limitOrder(1, "100"))
+	require.NoError(t, err)
+	_, err = m.PlaceLimitBuyOrder(ctx, limitOrder(1, "100"))
+	require.NoError(t, err)
+
+	_, err = m.PlaceLimitBuyOrder(ctx, limitOrder(1, "100"))
+	requireRejection(t, err, riskmanager.ReasonMaxPosition)
+
+	t.Run("order reducing the risk", func(t *testing.T) {
+		client.EXPECT().PlaceLimitSellOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-3"), nil)
+
+		_, err := m.PlaceLimitSellOrder(ctx, limitOrder(2, "110"))
+		require.NoError(t, err)
+	})
+
+	t.Run("failed order is not counted", func(t *testing.T) {
+		client.EXPECT().CancelOrder(gomock.Any(), accountID, tinkoffinvest.OrderID("order-1")).Return(nil)
+		require.NoError(t, m.CancelOrder(ctx, accountID, "order-1"))
+
+		client.EXPECT().PlaceLimitBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID(""), tinkoffinvest.ErrMarketClosed) //nolint:lll
+		_, err := m.PlaceLimitBuyOrder(ctx, limitOrder(1, "100"))
+		require.ErrorIs(t, err, tinkoffinvest.ErrMarketClosed)
+
+		client.EXPECT().PlaceLimitBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-4"), nil)
+		_, err = m.PlaceLimitBuyOrder(ctx, limitOrder(1, "100"))
+		require.NoError(t, err)
+	})
+}
+
+func TestManager_MaxNotional(t *testing.T) {
+	m, client := newManager(t, riskmanager.Limits{MaxNotional: decimal.NewFromInt(2500)})
+	ctx := context.Background()
+
+	client.EXPECT().GetLastPrices(gomock.Any(), []tinkoffinvest.FIGI{figi}).Return([]tinkoffinvest.LastPrice{
+		{FIGI: figi, Price: decimal.NewFromInt(100)},
+	}, nil).Times(2)
+	client.EXPECT().PlaceMarketBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-1"), nil)
+
+	// 2 lots * 10 stocks * 100.
+	_, err := m.PlaceMarketBuyOrder(ctx, tinkoffinvest.PlaceOrderRequest{AccountID: accountID, FIGI: figi, Lots: 2})
+	require.NoError(t, err)
+
+	_, err = m.PlaceMarketBuyOrder(ctx, tinkoffinvest.PlaceOrderRequest{AccountID: accountID, FIGI: figi, Lots: 1})
+	requireRejection(t, err, riskmanager.ReasonMaxNotional)
+
+	t.Run("price is unknown", func(t *testing.T) {
+		m, client := newManager(t, riskmanager.Limits{MaxNotional: decimal.NewFromInt(2500)})
+		client.EXPECT().GetLastPrices(gomock.Any(), gomock.Any()).Return(nil, tinkoffinvest.ErrUnavailable)
+
+		_, err := m.PlaceMarketBuyOrder(ctx, tinkoffinvest.PlaceOrderRequest{AccountID: accountID, FIGI: figi, Lots: 1})
+		require.ErrorIs(t, err, tinkoffinvest.ErrUnavailable)
+	})
+
+	t.Run("futures", func(t *testing.T) {
+		m, client := newManager(t, riskmanager.Limits{MaxNotional: decimal.NewFromInt(2500)})
+		client.EXPECT().GetLastPrices(gomock.Any(), []tinkoffinvest.FIGI{siFIGI}).Return([]tinkoffinvest.LastPrice{
+			{FIGI: siFIGI, Price: decimal.NewFromInt(100)},
+		}, nil).Times(2)
+		client.EXPECT().PlaceMarketBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-1"), nil)
+
+		// 1 lot * 100 points * 20 per point.
+		_, err := m.PlaceMarketBuyOrder(ctx, tinkoffinvest.PlaceOrderRequest{AccountID: accountID, FIGI: siFIGI, Lots: 1})
+		require.NoError(t, err)
+
+		_, err = m.PlaceMarketBuyOrder(ctx, tinkoffinvest.PlaceOrderRequest{AccountID: accountID, FIGI: siFIGI, Lots: 1})
+		var rejection *riskmanager.RejectionError
+		require.ErrorAs(t, err, &rejection)
+		assert.Equal(t, riskmanager.ReasonMaxNotional, rejection.Reason)
+	})
+}
+
+func TestManager_MaxOpenOrders(t *testing.T) {
+	m, client := newManager(t, riskmanager.Limits{MaxOpenOrders: 2})
+	ctx := context.Background()
+
+	client.EXPECT().PlaceLimitBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-1"), nil)
+	client.EXPECT().PlaceLimitSellOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-2"), nil)
+
+	_, err := m.PlaceLimitBuyOrder(ctx, limitOrder(1, "100"))
+	require.NoError(t, err)
+	_, err = m.PlaceLimitSellOrder(ctx, limitOrder(1, "120"))
+	require.NoError(t, err)
+
+	_, err = m.PlaceLimitBuyOrder(ctx, limitOrder(1, "100"))
+	requireRejection(t, err, riskmanager.ReasonMaxOpenOrders)
+
+	client.EXPECT().CancelOrder(gomock.Any(), accountID, tinkoffinvest.OrderID("order-1")).Return(nil)
+	require.NoError(t, m.CancelOrder(ctx, accountID, "order-1"))
+
+	client.EXPECT().PlaceLimitBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-3"), nil)
+	_, err = m.PlaceLimitBuyOrder(ctx, limitOrder(1, "100"))
+	require.NoError(t, err)
+}
+
+func TestManager_MaxOrdersPerMinute(t *testing.T) {
+	m, client := newManager(t, riskmanager.Limits{MaxOrdersPerMinute: 2})
+	ctx := context.Background()
+
+	client.EXPECT().PlaceLimitBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-1"), nil)
+	client.EXPECT().CancelOrder(gomock.Any(), accountID, tinkoffinvest.OrderID("order-1")).Return(nil)
+	client.EXPECT().PlaceLimitBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-2"), nil)
+
+	_, err := m.PlaceLimitBuyOrder(ctx, limitOrder(1, "100"))
+	require.NoError(t, err)
+	require.NoError(t, m.CancelOrder(ctx, accountID, "order-1"))
+	_, err = m.PlaceLimitBuyOrder(ctx, limitOrder(1, "100"))
+	require.NoError(t, err)
+
+	_, err = m.PlaceLimitBuyOrder(ctx, limitOrder(1, "100"))
+	requireRejection(t, err, riskmanager.ReasonMaxOrdersPerMinute)
+}
+
+func TestManager_MaxDailyLoss(t *testing.T) {
+	m, client := newManager(t, riskmanager.Limits{MaxDailyLoss: decimal.NewFromInt(100)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fills := make(chan tinkoffinvest.OrderFill)
+	client.EXPECT().SubscribeForOrderFills(gomock.Any(), []tinkoffinvest.AccountID{accountID}).Return(fills, nil)
+	client.EXPECT().GetOrders(gomock.Any(), accountID).Return(nil, nil)
+	client.EXPECT().GetPortfolio(gomock.Any(), accountID).Return(&tinkoffinvest.Portfolio{
+		Positions: []tinkoffinvest.PortfolioPosition{
+			{FIGI: figi, Type: tinkoffinvest.InstrumentTypeBond, Quantity: 20, AvgPrice: decimal.NewFromInt(100)},
+			{FIGI: rubFIGI, Type: tinkoffinvest.InstrumentTypeCurrency, Quantity: 1000},
+		},
+	}, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, m.Run(ctx))
+	}()
+	m.Track([]tinkoffinvest.AccountID{accountID})
+
+	// Sell one of two bond lots from portfolio, the loss is 1 lot * 10 bonds * (100 - 90).
+	fills <- tinkoffinvest.OrderFill{AccountID: accountID, FIGI: figi, Direction: tinkoffinvest.TradeDirectionSell, Price: decimal.NewFromInt(90), Lots: 1} //nolint:lll
+
+	// Wait for the fill to be applied. The failed orders are not counted as open.
+	client.EXPECT().PlaceLimitBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID(""), tinkoffinvest.ErrMarketClosed).AnyTimes() //nolint:lll
+	require.Eventually(t, func() bool {
+		_, err := m.PlaceLimitBuyOrder(ctx, limitOrder(1, "90"))
+		return errors.Is(err, riskmanager.ErrRejected)
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := m.PlaceLimitBuyOrder(ctx, limitOrder(1, "90"))
+	requireRejection(t, err, riskmanager.ReasonMaxDailyLoss)
+
+	t.Run("position can be closed", func(t *testing.T) {
+		client.EXPECT().PlaceLimitSellOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-1"), nil)
+
+		_, err := m.PlaceLimitSellOrder(ctx, limitOrder(1, "90"))
+		require.NoError(t, err)
+	})
+
+	t.Run("position cannot be reversed", func(t *testing.T) {
+		_, err := m.PlaceLimitSellOrder(ctx, limitOrder(2, "90"))
+		requireRejection(t, err, riskmanager.ReasonMaxDailyLoss)
+	})
+
+	cancel()
+	<-done
+}
+
+func TestManager_MaxDailyLossAndKillSwitch(t *testing.T) {
+	m, client := newManager(t, riskmanager.Limits{
+		MaxDailyLoss: decimal.NewFromInt(100),
+		KillSwitchOn: []riskmanager.Reason{riskmanager.ReasonMaxDailyLoss},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fills := make(chan tinkoffinvest.OrderFill)
+	client.EXPECT().SubscribeForOrderFills(gomock.Any(), []tinkoffinvest.AccountID{accountID}).Return(fills, nil)
+	client.EXPECT().GetOrders(gomock.Any(), accountID).Return(nil, nil)
+	client.EXPECT().GetPortfolio(gomock.Any(), accountID).Return(&tinkoffinvest.Portfolio{
+		Positions: []tinkoffinvest.PortfolioPosition{
+			{FIGI: figi, Type: tinkoffinvest.InstrumentTypeShare, Quantity: 10, AvgPrice: decimal.NewFromInt(100)},
+		},
+	}, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, m.Run(ctx))
+	}()
+	m.Track([]tinkoffinvest.AccountID{accountID})
+
+	// Sell the lot bought at 100 from portfolio and buy it back, the loss is 1 lot * 10 stocks * (100 - 95).
+	fills <- tinkoffinvest.OrderFill{AccountID: accountID, FIGI: figi, Direction: tinkoffinvest.TradeDirectionSell, Price: decimal.NewFromInt(95), Lots: 1} //nolint:lll
+	fills <- tinkoffinvest.OrderFill{AccountID: accountID, FIGI: figi, Direction: tinkoffinvest.TradeDirectionBuy, Price: decimal.NewFromInt(100), Lots: 1} //nolint:lll
+	// The loss of the second fill is 1 lot * 10 stocks * (95 - 100).
+	fills <- tinkoffinvest.OrderFill{AccountID: accountID, FIGI: figi, Direction: tinkoffinvest.TradeDirectionSell, Price: decimal.NewFromInt(95), Lots: 1} //nolint:lll
+
+	// Wait for the last fill to be applied.
+	client.EXPECT().PlaceLimitBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("order-1"), nil).AnyTimes()
+	require.Eventually(t, func() bool {
+		_, err := m.PlaceLimitBuyOrder(ctx, limitOrder(1, "95"))
+		return errors.Is(err, riskmanager.ErrRejected)
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := m.PlaceLimitBuyOrder(ctx, limitOrder(1, "95"))
+	requireRejection(t, err, riskmanager.ReasonKillSwitch)
+
+	t.Run("kill switch is not reset by limits", func(t *testing.T) {
+		m.SetLimits(riskmanager.Limits{})
+
+		_, err := m.PlaceLimitSellOrder(ctx, limitOrder(1, "95"))
+		requireRejection(t, err, riskmanager.ReasonKillSwitch)
+	})
+
+	cancel()
+	<-done
+}
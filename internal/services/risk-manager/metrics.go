@@ -0,0 +1,38 @@
+package riskmanager
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const subsystem = "risk"
+
+var (
+	rejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "rejections_total",
+		Help:      "Total amount of orders rejected by risk manager",
+	}, []string{"account_number", "reason"})
+
+	killSwitch = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "kill_switch",
+		Help:      "Kill switch is tripped and orders are not placed",
+	})
+
+	exposure = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "notional_exposure",
+		Help:      "Notional exposure of positions and open orders",
+	}, []string{"account_number"})
+
+	dailyRealizedPnL = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "trading_robot",
+		Subsystem: subsystem,
+		Name:      "daily_realized_pnl",
+		Help:      "Realized profit and loss since the start of the day (UTC)",
+	}, []string{"account_number"})
+)
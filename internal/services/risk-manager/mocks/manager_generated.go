@@ -0,0 +1,269 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: manager.go
+
+// Package riskmanagermocks is a generated GoMock package.
+package riskmanagermocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	tinkoffinvest "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockClient is a mock of Client interface.
+type MockClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockClientMockRecorder
+}
+
+// MockClientMockRecorder is the mock recorder for MockClient.
+type MockClientMockRecorder struct {
+	mock *MockClient
+}
+
+// NewMockClient creates a new mock instance.
+func NewMockClient(ctrl *gomock.Controller) *MockClient {
+	mock := &MockClient{ctrl: ctrl}
+	mock.recorder = &MockClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClient) EXPECT() *MockClientMockRecorder {
+	return m.recorder
+}
+
+// CancelOrder mocks base method.
+func (m *MockClient) CancelOrder(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelOrder", ctx, accountID, orderID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelOrder indicates an expected call of CancelOrder.
+func (mr *MockClientMockRecorder) CancelOrder(ctx, accountID, orderID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelOrder", reflect.TypeOf((*MockClient)(nil).CancelOrder), ctx, accountID, orderID)
+}
+
+// GetLastPrices mocks base method.
+func (m *MockClient) GetLastPrices(ctx context.Context, figis []tinkoffinvest.FIGI) ([]tinkoffinvest.LastPrice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastPrices", ctx, figis)
+	ret0, _ := ret[0].([]tinkoffinvest.LastPrice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLastPrices indicates an expected call of GetLastPrices.
+func (mr *MockClientMockRecorder) GetLastPrices(ctx, figis interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastPrices", reflect.TypeOf((*MockClient)(nil).GetLastPrices), ctx, figis)
+}
+
+// GetOrderBook mocks base method.
+func (m *MockClient) GetOrderBook(ctx context.Context, req tinkoffinvest.OrderBookRequest) (*tinkoffinvest.OrderBookResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderBook", ctx, req)
+	ret0, _ := ret[0].(*tinkoffinvest.OrderBookResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderBook indicates an expected call of GetOrderBook.
+func (mr *MockClientMockRecorder) GetOrderBook(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderBook", reflect.TypeOf((*MockClient)(nil).GetOrderBook), ctx, req)
+}
+
+// GetOrderState mocks base method.
+func (m *MockClient) GetOrderState(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) (*tinkoffinvest.OrderState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderState", ctx, accountID, orderID)
+	ret0, _ := ret[0].(*tinkoffinvest.OrderState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderState indicates an expected call of GetOrderState.
+func (mr *MockClientMockRecorder) GetOrderState(ctx, accountID, orderID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderState", reflect.TypeOf((*MockClient)(nil).GetOrderState), ctx, accountID, orderID)
+}
+
+// GetOrders mocks base method.
+func (m *MockClient) GetOrders(ctx context.Context, accountID tinkoffinvest.AccountID) ([]tinkoffinvest.ActiveOrder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrders", ctx, accountID)
+	ret0, _ := ret[0].([]tinkoffinvest.ActiveOrder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrders indicates an expected call of GetOrders.
+func (mr *MockClientMockRecorder) GetOrders(ctx, accountID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockClient)(nil).GetOrders), ctx, accountID)
+}
+
+// GetPortfolio mocks base method.
+func (m *MockClient) GetPortfolio(ctx context.Context, accountID tinkoffinvest.AccountID) (*tinkoffinvest.Portfolio, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPortfolio", ctx, accountID)
+	ret0, _ := ret[0].(*tinkoffinvest.Portfolio)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPortfolio indicates an expected call of GetPortfolio.
+func (mr *MockClientMockRecorder) GetPortfolio(ctx, accountID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPortfolio", reflect.TypeOf((*MockClient)(nil).GetPortfolio), ctx, accountID)
+}
+
+// GetTradeAvailableInstruments mocks base method.
+func (m *MockClient) GetTradeAvailableInstruments(ctx context.Context, typ tinkoffinvest.InstrumentType) ([]tinkoffinvest.Instrument, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTradeAvailableInstruments", ctx, typ)
+	ret0, _ := ret[0].([]tinkoffinvest.Instrument)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTradeAvailableInstruments indicates an expected call of GetTradeAvailableInstruments.
+func (mr *MockClientMockRecorder) GetTradeAvailableInstruments(ctx, typ interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTradeAvailableInstruments", reflect.TypeOf((*MockClient)(nil).GetTradeAvailableInstruments), ctx, typ)
+}
+
+// GetTradingStatus mocks base method.
+func (m *MockClient) GetTradingStatus(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.TradingStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTradingStatus", ctx, figi)
+	ret0, _ := ret[0].(*tinkoffinvest.TradingStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTradingStatus indicates an expected call of GetTradingStatus.
+func (mr *MockClientMockRecorder) GetTradingStatus(ctx, figi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTradingStatus", reflect.TypeOf((*MockClient)(nil).GetTradingStatus), ctx, figi)
+}
+
+// PlaceLimitBuyOrder mocks base method.
+func (m *MockClient) PlaceLimitBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PlaceLimitBuyOrder", ctx, request)
+	ret0, _ := ret[0].(tinkoffinvest.OrderID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PlaceLimitBuyOrder indicates an expected call of PlaceLimitBuyOrder.
+func (mr *MockClientMockRecorder) PlaceLimitBuyOrder(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlaceLimitBuyOrder", reflect.TypeOf((*MockClient)(nil).PlaceLimitBuyOrder), ctx, request)
+}
+
+// PlaceLimitSellOrder mocks base method.
+func (m *MockClient) PlaceLimitSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PlaceLimitSellOrder", ctx, request)
+	ret0, _ := ret[0].(tinkoffinvest.OrderID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PlaceLimitSellOrder indicates an expected call of PlaceLimitSellOrder.
+func (mr *MockClientMockRecorder) PlaceLimitSellOrder(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlaceLimitSellOrder", reflect.TypeOf((*MockClient)(nil).PlaceLimitSellOrder), ctx, request)
+}
+
+// PlaceMarketBuyOrder mocks base method.
+func (m *MockClient) PlaceMarketBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PlaceMarketBuyOrder", ctx, request)
+	ret0, _ := ret[0].(tinkoffinvest.OrderID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PlaceMarketBuyOrder indicates an expected call of PlaceMarketBuyOrder.
+func (mr *MockClientMockRecorder) PlaceMarketBuyOrder(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlaceMarketBuyOrder", reflect.TypeOf((*MockClient)(nil).PlaceMarketBuyOrder), ctx, request)
+}
+
+// PlaceMarketSellOrder mocks base method.
+func (m *MockClient) PlaceMarketSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PlaceMarketSellOrder", ctx, request)
+	ret0, _ := ret[0].(tinkoffinvest.OrderID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PlaceMarketSellOrder indicates an expected call of PlaceMarketSellOrder.
+func (mr *MockClientMockRecorder) PlaceMarketSellOrder(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlaceMarketSellOrder", reflect.TypeOf((*MockClient)(nil).PlaceMarketSellOrder), ctx, request)
+}
+
+// SubscribeForOrderFills mocks base method.
+func (m *MockClient) SubscribeForOrderFills(ctx context.Context, accounts []tinkoffinvest.AccountID) (<-chan tinkoffinvest.OrderFill, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeForOrderFills", ctx, accounts)
+	ret0, _ := ret[0].(<-chan tinkoffinvest.OrderFill)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeForOrderFills indicates an expected call of SubscribeForOrderFills.
+func (mr *MockClientMockRecorder) SubscribeForOrderFills(ctx, accounts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeForOrderFills", reflect.TypeOf((*MockClient)(nil).SubscribeForOrderFills), ctx, accounts)
+}
+
+// MockToolsCache is a mock of ToolsCache interface.
+type MockToolsCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockToolsCacheMockRecorder
+}
+
+// MockToolsCacheMockRecorder is the mock recorder for MockToolsCache.
+type MockToolsCacheMockRecorder struct {
+	mock *MockToolsCache
+}
+
+// NewMockToolsCache creates a new mock instance.
+func NewMockToolsCache(ctrl *gomock.Controller) *MockToolsCache {
+	mock := &MockToolsCache{ctrl: ctrl}
+	mock.recorder = &MockToolsCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockToolsCache) EXPECT() *MockToolsCacheMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockToolsCache) Get(ctx context.Context, figi tinkoffinvest.FIGI) (toolscache.Tool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, figi)
+	ret0, _ := ret[0].(toolscache.Tool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockToolsCacheMockRecorder) Get(ctx, figi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockToolsCache)(nil).Get), ctx, figi)
+}
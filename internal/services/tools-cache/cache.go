@@ -4,32 +4,67 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/shopspring/decimal"
 
 	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
 )
 
-//go:generate mockgen -source=$GOFILE -destination=mocks/cache_generated.go -package toolscachemocks SharesProvider
+//go:generate mockgen -source=$GOFILE -destination=mocks/cache_generated.go -package toolscachemocks InstrumentsProvider
 
-type SharesProvider interface {
-	GetShareByFIGI(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.Instrument, error)
+type InstrumentsProvider interface {
+	GetInstrumentByFIGI(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.Instrument, error)
 }
 
 // Cache implements quite simple instruments cache.
 type Cache struct {
 	mu       *sync.Mutex
 	tools    map[tinkoffinvest.FIGI]Tool
-	provider SharesProvider
+	provider InstrumentsProvider
 }
 
 type Tool struct {
 	FIGI         tinkoffinvest.FIGI
+	Type         tinkoffinvest.InstrumentType
+	Exchange     string
+	Currency     string
 	StocksPerLot int
 	MinPriceInc  decimal.Decimal
+	// Nominal is specified for bonds and currencies.
+	Nominal decimal.Decimal
+	// PointValue is specified for futures only.
+	PointValue decimal.Decimal
+	// ExpirationDate is specified for futures only.
+	ExpirationDate time.Time
 }
 
-func New(p SharesProvider) *Cache {
+// IsExpired reports whether the futures contract is expired.
+func (t Tool) IsExpired(now time.Time) bool {
+	return !t.ExpirationDate.IsZero() && !now.Before(t.ExpirationDate)
+}
+
+// Amount converts the price of one instrument and the quantity of instruments to money in Currency.
+// Futures prices are in points and bond prices are in percents of the nominal,
+// the price is used as is if the point value or the nominal is unknown.
+func (t Tool) Amount(price decimal.Decimal, quantity int) decimal.Decimal {
+	amount := price.Mul(decimal.NewFromInt(int64(quantity)))
+
+	switch t.Type {
+	case tinkoffinvest.InstrumentTypeFutures:
+		if t.PointValue.IsPositive() {
+			return amount.Mul(t.PointValue)
+		}
+	case tinkoffinvest.InstrumentTypeBond:
+		if t.Nominal.IsPositive() {
+			return amount.Mul(t.Nominal).Div(decimal.NewFromInt(100))
+		}
+	case tinkoffinvest.InstrumentTypeShare, tinkoffinvest.InstrumentTypeETF, tinkoffinvest.InstrumentTypeCurrency:
+	}
+	return amount
+}
+
+func New(p InstrumentsProvider) *Cache {
 	return &Cache{
 		tools:    make(map[tinkoffinvest.FIGI]Tool),
 		mu:       new(sync.Mutex),
@@ -45,15 +80,21 @@ func (c *Cache) Get(ctx context.Context, figi tinkoffinvest.FIGI) (Tool, error)
 		return t, nil
 	}
 
-	share, err := c.provider.GetShareByFIGI(ctx, figi)
+	instrument, err := c.provider.GetInstrumentByFIGI(ctx, figi)
 	if err != nil {
-		return Tool{}, fmt.Errorf("get share by figi: %v", err)
+		return Tool{}, fmt.Errorf("get instrument by figi: %w", err)
 	}
 
 	tool := Tool{
-		FIGI:         share.FIGI,
-		StocksPerLot: share.Lot,
-		MinPriceInc:  share.MinPriceIncrement,
+		FIGI:           instrument.FIGI,
+		Type:           instrument.Type,
+		Exchange:       instrument.Exchange,
+		Currency:       instrument.Currency,
+		StocksPerLot:   instrument.Lot,
+		MinPriceInc:    instrument.MinPriceIncrement,
+		Nominal:        instrument.Nominal,
+		PointValue:     instrument.PointValue,
+		ExpirationDate: instrument.ExpirationDate,
 	}
 	c.tools[figi] = tool
 	return tool, nil
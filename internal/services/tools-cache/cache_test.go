@@ -3,6 +3,7 @@ package toolscache_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/shopspring/decimal"
@@ -18,21 +19,25 @@ func TestCache(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	investClient := toolscachemocks.NewMockSharesProvider(ctrl)
+	investClient := toolscachemocks.NewMockInstrumentsProvider(ctrl)
 	c := toolscache.New(investClient)
 
 	const f1, f2 = tinkoffinvest.FIGI("f1"), tinkoffinvest.FIGI("f2")
 
 	t.Run("no extra api call", func(t *testing.T) {
-		investClient.EXPECT().GetShareByFIGI(gomock.Any(), f1).Return(
+		investClient.EXPECT().GetInstrumentByFIGI(gomock.Any(), f1).Return(
 			&tinkoffinvest.Instrument{
 				FIGI:              f1,
+				Type:              tinkoffinvest.InstrumentTypeShare,
+				Currency:          "rub",
 				Lot:               10,
 				MinPriceIncrement: decimal.RequireFromString("100.10"),
 			}, nil)
 
 		expf1Tool := toolscache.Tool{
 			FIGI:         f1,
+			Type:         tinkoffinvest.InstrumentTypeShare,
+			Currency:     "rub",
 			StocksPerLot: 10,
 			MinPriceInc:  decimal.RequireFromString("100.10"),
 		}
@@ -47,17 +52,25 @@ func TestCache(t *testing.T) {
 	})
 
 	t.Run("fetch unknown figi", func(t *testing.T) {
-		investClient.EXPECT().GetShareByFIGI(gomock.Any(), f2).Return(
+		investClient.EXPECT().GetInstrumentByFIGI(gomock.Any(), f2).Return(
 			&tinkoffinvest.Instrument{
 				FIGI:              f2,
+				Type:              tinkoffinvest.InstrumentTypeFutures,
+				Currency:          "rub",
 				Lot:               20,
 				MinPriceIncrement: decimal.RequireFromString("200.20"),
+				PointValue:        decimal.RequireFromString("0.5"),
+				ExpirationDate:    time.Unix(1, 0),
 			}, nil)
 
 		expf2Tool := toolscache.Tool{
-			FIGI:         f2,
-			StocksPerLot: 20,
-			MinPriceInc:  decimal.RequireFromString("200.20"),
+			FIGI:           f2,
+			Type:           tinkoffinvest.InstrumentTypeFutures,
+			Currency:       "rub",
+			StocksPerLot:   20,
+			MinPriceInc:    decimal.RequireFromString("200.20"),
+			PointValue:     decimal.RequireFromString("0.5"),
+			ExpirationDate: time.Unix(1, 0),
 		}
 
 		tool, err := c.Get(context.Background(), f2)
@@ -72,8 +85,59 @@ func TestCache(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, toolscache.Tool{
 			FIGI:         f1,
+			Type:         tinkoffinvest.InstrumentTypeShare,
+			Currency:     "rub",
 			StocksPerLot: 10,
 			MinPriceInc:  decimal.RequireFromString("100.10"),
 		}, tool)
 	})
 }
+
+func TestTool_IsExpired(t *testing.T) {
+	now := time.Now()
+
+	assert.False(t, toolscache.Tool{}.IsExpired(now))
+	assert.False(t, toolscache.Tool{ExpirationDate: now.Add(time.Hour)}.IsExpired(now))
+	assert.True(t, toolscache.Tool{ExpirationDate: now}.IsExpired(now))
+}
+
+func TestTool_Amount(t *testing.T) {
+	cases := []struct {
+		name     string
+		tool     toolscache.Tool
+		price    string
+		expected string
+	}{
+		{
+			name:     "share",
+			tool:     toolscache.Tool{Type: tinkoffinvest.InstrumentTypeShare},
+			price:    "125.5",
+			expected: "1255",
+		},
+		{
+			name:     "bond",
+			tool:     toolscache.Tool{Type: tinkoffinvest.InstrumentTypeBond, Nominal: decimal.NewFromInt(1000)},
+			price:    "98.5",
+			expected: "9850",
+		},
+		{
+			name:     "futures",
+			tool:     toolscache.Tool{Type: tinkoffinvest.InstrumentTypeFutures, PointValue: decimal.RequireFromString("0.5")},
+			price:    "120000",
+			expected: "600000",
+		},
+		{
+			name:     "futures without point value",
+			tool:     toolscache.Tool{Type: tinkoffinvest.InstrumentTypeFutures},
+			price:    "120000",
+			expected: "1200000",
+		},
+	}
+
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.tool.Amount(decimal.RequireFromString(tt.price), 10).String())
+		})
+	}
+}
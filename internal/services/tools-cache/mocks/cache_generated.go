@@ -12,40 +12,40 @@ import (
 	gomock "github.com/golang/mock/gomock"
 )
 
-// MockSharesProvider is a mock of SharesProvider interface.
-type MockSharesProvider struct {
+// MockInstrumentsProvider is a mock of InstrumentsProvider interface.
+type MockInstrumentsProvider struct {
 	ctrl     *gomock.Controller
-	recorder *MockSharesProviderMockRecorder
+	recorder *MockInstrumentsProviderMockRecorder
 }
 
-// MockSharesProviderMockRecorder is the mock recorder for MockSharesProvider.
-type MockSharesProviderMockRecorder struct {
-	mock *MockSharesProvider
+// MockInstrumentsProviderMockRecorder is the mock recorder for MockInstrumentsProvider.
+type MockInstrumentsProviderMockRecorder struct {
+	mock *MockInstrumentsProvider
 }
 
-// NewMockSharesProvider creates a new mock instance.
-func NewMockSharesProvider(ctrl *gomock.Controller) *MockSharesProvider {
-	mock := &MockSharesProvider{ctrl: ctrl}
-	mock.recorder = &MockSharesProviderMockRecorder{mock}
+// NewMockInstrumentsProvider creates a new mock instance.
+func NewMockInstrumentsProvider(ctrl *gomock.Controller) *MockInstrumentsProvider {
+	mock := &MockInstrumentsProvider{ctrl: ctrl}
+	mock.recorder = &MockInstrumentsProviderMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockSharesProvider) EXPECT() *MockSharesProviderMockRecorder {
+func (m *MockInstrumentsProvider) EXPECT() *MockInstrumentsProviderMockRecorder {
 	return m.recorder
 }
 
-// GetShareByFIGI mocks base method.
-func (m *MockSharesProvider) GetShareByFIGI(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.Instrument, error) {
+// GetInstrumentByFIGI mocks base method.
+func (m *MockInstrumentsProvider) GetInstrumentByFIGI(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.Instrument, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetShareByFIGI", ctx, figi)
+	ret := m.ctrl.Call(m, "GetInstrumentByFIGI", ctx, figi)
 	ret0, _ := ret[0].(*tinkoffinvest.Instrument)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetShareByFIGI indicates an expected call of GetShareByFIGI.
-func (mr *MockSharesProviderMockRecorder) GetShareByFIGI(ctx, figi interface{}) *gomock.Call {
+// GetInstrumentByFIGI indicates an expected call of GetInstrumentByFIGI.
+func (mr *MockInstrumentsProviderMockRecorder) GetInstrumentByFIGI(ctx, figi interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShareByFIGI", reflect.TypeOf((*MockSharesProvider)(nil).GetShareByFIGI), ctx, figi)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstrumentByFIGI", reflect.TypeOf((*MockInstrumentsProvider)(nil).GetInstrumentByFIGI), ctx, figi)
 }
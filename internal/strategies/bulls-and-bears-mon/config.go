@@ -0,0 +1,81 @@
+package bullsbearsmon
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/registry"
+)
+
+// Name is the strategy name in the config.
+const Name = "bulls-and-bears-monitoring"
+
+func init() {
+	registry.Register(Name, registry.Definition{
+		DecodeConfig: decodeConfig,
+		New:          newFromConfig,
+		Reconfigure:  reconfigureFromConfig,
+	})
+}
+
+// Config is the strategy parameters in the config.
+type Config struct {
+	IgnoreInconsistent bool               `toml:"ignore_inconsistent"`
+	Instruments        []InstrumentConfig `toml:"instruments" validate:"min=1,dive"`
+}
+
+type InstrumentConfig struct {
+	FIGI             string  `toml:"figi" validate:"required"`
+	Depth            int     `toml:"depth" validate:"required,oneof=1 10 20 30 40 50"`
+	DominanceRatio   float64 `toml:"dominance_ratio" validate:"required,gt=1"`
+	ProfitPercentage float64 `toml:"profit_percentage" validate:"required,gt=0,lte=1"`
+}
+
+// ToolConfigs returns the configs of the traded instruments.
+func (c Config) ToolConfigs() []ToolConfig {
+	tools := make([]ToolConfig, len(c.Instruments))
+	for i, ins := range c.Instruments {
+		tools[i] = ToolConfig{
+			FIGI:             tinkoffinvest.FIGI(ins.FIGI),
+			Depth:            ins.Depth,
+			DominanceRatio:   ins.DominanceRatio,
+			ProfitPercentage: ins.ProfitPercentage,
+		}
+	}
+	return tools
+}
+
+func decodeConfig(decode func(v interface{}) error) (interface{}, error) {
+	var cfg Config
+	if err := decode(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validator.New().Struct(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func newFromConfig(instance registry.Instance, deps registry.Deps) (registry.Strategy, error) {
+	cfg := instance.Config.(Config) //nolint:forcetypeassert // Decoded by decodeConfig.
+	return newStrategy(
+		instance.Name,
+		instance.Account,
+		cfg.IgnoreInconsistent,
+		cfg.ToolConfigs(),
+		deps.Orders,
+		deps.MarketData,
+		deps.ToolsCache,
+		deps.Calendar,
+		deps.SessionOpts,
+		deps.Clock,
+	)
+}
+
+func reconfigureFromConfig(ctx context.Context, s registry.Strategy, instance registry.Instance) error {
+	cfg := instance.Config.(Config) //nolint:forcetypeassert // Decoded by decodeConfig.
+
+	return s.(*Strategy).Reconfigure(ctx, cfg.IgnoreInconsistent, cfg.ToolConfigs()) //nolint:forcetypeassert
+}
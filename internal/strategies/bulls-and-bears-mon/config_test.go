@@ -0,0 +1,58 @@
+package bullsbearsmon_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+	bullsbearsmon "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/bulls-and-bears-mon"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/registry"
+)
+
+func TestConfig(t *testing.T) {
+	instrument := func(depth int64) map[string]interface{} {
+		return map[string]interface{}{
+			"figi":              figi.S(),
+			"depth":             depth,
+			"dominance_ratio":   int64(2),
+			"profit_percentage": 0.01,
+		}
+	}
+
+	instances, err := registry.Decode(accountID, config.StrategiesConfig{{
+		Strategy: bullsbearsmon.Name,
+		Name:     "sber",
+		Enabled:  true,
+		Params: config.StrategyParams{
+			"instruments": []map[string]interface{}{instrument(10)},
+		},
+	}})
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+
+	cfg := instances[0].Config.(bullsbearsmon.Config)
+	assert.Equal(t, []bullsbearsmon.ToolConfig{{
+		FIGI:             figi,
+		Depth:            10,
+		DominanceRatio:   2,
+		ProfitPercentage: 0.01,
+	}}, cfg.ToolConfigs())
+
+	s, err := instances[0].New(registry.Deps{})
+	require.NoError(t, err)
+	assert.Equal(t, "sber", s.Name())
+
+	for name, params := range map[string]config.StrategyParams{
+		"no instruments": {},
+		"invalid depth":  {"instruments": []map[string]interface{}{instrument(15)}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := registry.Decode(accountID, config.StrategiesConfig{
+				{Strategy: bullsbearsmon.Name, Enabled: true, Params: params},
+			})
+			require.Error(t, err)
+		})
+	}
+}
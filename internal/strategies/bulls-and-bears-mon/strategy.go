@@ -12,11 +12,13 @@ import (
 	"github.com/shopspring/decimal"
 
 	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	exchangecalendar "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/exchange-calendar"
+	marketdatahub "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/market-data-hub"
 	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
 	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/common"
 )
 
-//go:generate mockgen -source=$GOFILE -destination=mocks/strategy_generated.go -package bullsbearsmonmocks OrderPlacer,ToolsCache
+//go:generate mockgen -source=$GOFILE -destination=mocks/strategy_generated.go -package bullsbearsmonmocks OrderPlacer,MarketDataProvider,ExchangeCalendar,ToolsCache
 
 const (
 	applyingTimeout = 3 * time.Second
@@ -26,8 +28,10 @@ const (
 type l = prometheus.Labels
 
 type OrderPlacer interface {
-	SubscribeForOrderBookChanges(ctx context.Context, reqs []tinkoffinvest.OrderBookRequest) (<-chan tinkoffinvest.OrderBookChange, error) //nolint:lll
-	WaitForOrderExecution(ctx context.Context, _ tinkoffinvest.AccountID, _ tinkoffinvest.OrderID) (decimal.Decimal, error)
+	SubscribeForOrderFills(ctx context.Context, accounts []tinkoffinvest.AccountID) (<-chan tinkoffinvest.OrderFill, error)
+	CancelOrder(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) error
+
+	GetTradingStatus(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.TradingStatus, error)
 
 	PlaceMarketSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
 	PlaceMarketBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
@@ -35,6 +39,18 @@ type OrderPlacer interface {
 	PlaceLimitBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
 }
 
+// MarketDataProvider is implemented by marketdatahub.Hub.
+type MarketDataProvider interface {
+	SubscribeForOrderBookChanges(ctx context.Context, reqs []tinkoffinvest.OrderBookRequest) (<-chan tinkoffinvest.OrderBookChange, error) //nolint:lll
+	SubscribeForTradingStatuses(ctx context.Context, figis []tinkoffinvest.FIGI) (<-chan tinkoffinvest.TradingStatus, error)
+	SubscribeForGaps(ctx context.Context) (<-chan marketdatahub.Gap, error)
+}
+
+// ExchangeCalendar is implemented by exchangecalendar.Calendar.
+type ExchangeCalendar interface {
+	CurrentOrNextSession(ctx context.Context, exchange string, at time.Time) (exchangecalendar.Session, error)
+}
+
 type ToolsCache interface {
 	Get(ctx context.Context, figi tinkoffinvest.FIGI) (toolscache.Tool, error)
 }
@@ -44,13 +60,46 @@ type ToolsCache interface {
 // then the robot buys the instrument at the market price, otherwise it sells,
 // immediately placing an order in the opposite direction, but with a certain percentage of profit.
 type Strategy struct {
+	name               string
 	account            tinkoffinvest.AccountID
 	ignoreInconsistent bool
 	toolConfigs        map[tinkoffinvest.FIGI]ToolConfig
 
 	orderPlacer OrderPlacer
+	marketData  MarketDataProvider
 	toolsCache  ToolsCache
+	calendar    ExchangeCalendar
+	sessionOpts common.SessionOptions
+	clock       common.Clock
 	logger      zerolog.Logger
+
+	tradingStatuses common.TradingStatuses
+	// exchanges are fetched with tool configs.
+	exchanges map[tinkoffinvest.FIGI]string
+	sessions  *common.TradingSessions
+	// marketOrders are waiting for execution to place the counter limit orders.
+	marketOrders map[tinkoffinvest.OrderID]*marketOrder
+	// limitOrders are the counter orders waiting for execution.
+	limitOrders map[tinkoffinvest.OrderID]*limitOrder
+	// reconfigs are applied by Run loop.
+	reconfigs chan func()
+}
+
+type marketOrder struct {
+	conf      ToolConfig
+	direction tinkoffinvest.TradeDirection
+	// priceLimit is the order book limit up for buy and limit down for sell at the moment of placing.
+	// Zero means the limit is unknown, e.g. in the books synthesized by the backtest.
+	priceLimit decimal.Decimal
+
+	executedLots int
+	executedCost decimal.Decimal
+}
+
+type limitOrder struct {
+	figi         tinkoffinvest.FIGI
+	lots         int
+	executedLots int
 }
 
 type ToolConfig struct {
@@ -70,64 +119,291 @@ func New(
 	ignoreInconsistent bool,
 	tools []ToolConfig,
 	orderPlacer OrderPlacer,
+	marketData MarketDataProvider,
 	toolsCache ToolsCache,
+	calendar ExchangeCalendar,
+	sessionOpts common.SessionOptions,
 ) (*Strategy, error) {
-	confs := make(map[tinkoffinvest.FIGI]ToolConfig, len(tools))
-	for _, t := range tools {
-		if _, ok := confs[t.FIGI]; ok {
-			return nil, fmt.Errorf("duplicated tool: %s", t.FIGI)
-		}
+	return newStrategy(
+		Name,
+		account,
+		ignoreInconsistent,
+		tools,
+		orderPlacer,
+		marketData,
+		toolsCache,
+		calendar,
+		sessionOpts,
+		common.WallClock{},
+	)
+}
 
-		confs[t.FIGI] = t
+func newStrategy(
+	name string,
+	account tinkoffinvest.AccountID,
+	ignoreInconsistent bool,
+	tools []ToolConfig,
+	orderPlacer OrderPlacer,
+	marketData MarketDataProvider,
+	toolsCache ToolsCache,
+	calendar ExchangeCalendar,
+	sessionOpts common.SessionOptions,
+	clock common.Clock,
+) (*Strategy, error) {
+	confs, err := toolConfigsByFIGI(tools)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range confs {
 		configuredDominanceRatio.With(l{"figi": t.FIGI.S()}).Set(t.DominanceRatio)
 	}
 
 	s := &Strategy{
+		name:               name,
 		account:            account,
 		ignoreInconsistent: ignoreInconsistent,
 		toolConfigs:        confs,
 		orderPlacer:        orderPlacer,
+		marketData:         marketData,
 		toolsCache:         toolsCache,
+		calendar:           calendar,
+		sessionOpts:        sessionOpts,
+		clock:              common.ClockOrWall(clock),
+		exchanges:          make(map[tinkoffinvest.FIGI]string),
+		marketOrders:       make(map[tinkoffinvest.OrderID]*marketOrder),
+		limitOrders:        make(map[tinkoffinvest.OrderID]*limitOrder),
+		reconfigs:          make(chan func()),
 	}
-	s.logger = log.With().Str("strategy", s.Name()).Logger()
+	s.logger = log.With().Str("strategy", s.Name()).Str("account", account.S()).Logger()
+	s.sessions = common.NewTradingSessions(calendar, sessionOpts, nil)
 
 	return s, nil
 }
 
+func toolConfigsByFIGI(tools []ToolConfig) (map[tinkoffinvest.FIGI]ToolConfig, error) {
+	confs := make(map[tinkoffinvest.FIGI]ToolConfig, len(tools))
+	for _, t := range tools {
+		if _, ok := confs[t.FIGI]; ok {
+			return nil, fmt.Errorf("duplicated tool: %s", t.FIGI)
+		}
+		confs[t.FIGI] = t
+	}
+	return confs, nil
+}
+
 func (s *Strategy) Name() string {
-	return "bulls-and-bears-monitoring"
+	return s.name
+}
+
+// Reconfigure applies new dominance ratios and profit percentages to the running strategy.
+// Changing the instruments or their depths requires restart, common.ErrRestartRequired is returned then.
+// The parameters are checked and applied by Run loop, because it owns the tool configs.
+func (s *Strategy) Reconfigure(ctx context.Context, ignoreInconsistent bool, tools []ToolConfig) error {
+	confs, err := toolConfigsByFIGI(tools)
+	if err != nil {
+		return err
+	}
+
+	result := make(chan error, 1)
+	apply := func() {
+		result <- s.reconfigure(ignoreInconsistent, confs)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case s.reconfigs <- apply:
+		return <-result
+	}
+}
+
+// reconfigure must be called by Run loop.
+func (s *Strategy) reconfigure(ignoreInconsistent bool, confs map[tinkoffinvest.FIGI]ToolConfig) error {
+	if len(confs) != len(s.toolConfigs) {
+		return common.ErrRestartRequired
+	}
+	for f, t := range confs {
+		if current, ok := s.toolConfigs[f]; !ok || current.Depth != t.Depth {
+			return common.ErrRestartRequired
+		}
+	}
+
+	s.ignoreInconsistent = ignoreInconsistent
+	for f, t := range confs {
+		current := s.toolConfigs[f]
+		current.DominanceRatio = t.DominanceRatio
+		current.ProfitPercentage = t.ProfitPercentage
+		s.toolConfigs[f] = current
+
+		configuredDominanceRatio.With(l{"figi": f.S()}).Set(t.DominanceRatio)
+	}
+	s.logger.Info().Msg("strategy reconfigured")
+	return nil
+}
+
+// FIGIs returns configured instruments.
+func (s *Strategy) FIGIs() []tinkoffinvest.FIGI {
+	figis := make([]tinkoffinvest.FIGI, 0, len(s.toolConfigs))
+	for f := range s.toolConfigs {
+		figis = append(figis, f)
+	}
+	return figis
+}
+
+// AdoptOrders takes the counter limit orders of configured instruments.
+// Market orders are not adopted, because their counter order limits are unknown.
+// Must be called before Run.
+func (s *Strategy) AdoptOrders(orders []tinkoffinvest.ActiveOrder) []tinkoffinvest.ActiveOrder {
+	var rest []tinkoffinvest.ActiveOrder
+	for _, o := range orders {
+		if _, ok := s.toolConfigs[o.FIGI]; !ok || o.Type != tinkoffinvest.OrderTypeLimit {
+			rest = append(rest, o)
+			continue
+		}
+
+		s.limitOrders[o.ID] = &limitOrder{
+			figi:         o.FIGI,
+			lots:         o.LotsRequested,
+			executedLots: o.LotsExecuted,
+		}
+	}
+	return rest
+}
+
+// Prepare fetches the tool configs, trading statuses and sessions of the instruments.
+// It is called by Run, the backtest calls it before replaying the market data through Apply.
+func (s *Strategy) Prepare(ctx context.Context) error {
+	if err := s.fetchToolConfigs(ctx); err != nil {
+		return fmt.Errorf("fetch tool configs: %w", err)
+	}
+
+	tradingStatuses, err := common.FetchTradingStatuses(ctx, s.orderPlacer, s.FIGIs())
+	if err != nil {
+		return fmt.Errorf("fetch trading statuses: %w", err)
+	}
+	s.tradingStatuses = tradingStatuses
+
+	s.sessions = common.NewTradingSessions(s.calendar, s.sessionOpts, s.exchanges)
+	s.updateSessions(ctx)
+
+	return nil
 }
 
 // Run starts order book monitoring and calls Apply on every new change.
 func (s *Strategy) Run(ctx context.Context) error {
-	if err := s.fetchToolConfigs(ctx); err != nil {
-		return fmt.Errorf("fetch tool configs: %v", err)
+	if err := s.Prepare(ctx); err != nil {
+		return err
 	}
 
 	reqs := make([]tinkoffinvest.OrderBookRequest, 0, len(s.toolConfigs))
+	figis := make([]tinkoffinvest.FIGI, 0, len(s.toolConfigs))
 	for _, t := range s.toolConfigs {
 		reqs = append(reqs, tinkoffinvest.OrderBookRequest{
 			FIGI:  t.FIGI,
 			Depth: t.Depth,
 		})
+		figis = append(figis, t.FIGI)
+	}
+
+	sessionsTimer := time.NewTimer(s.untilSessionsUpdate())
+	defer sessionsTimer.Stop()
+
+	gaps, err := s.marketData.SubscribeForGaps(ctx)
+	if err != nil {
+		return fmt.Errorf("subscribe for gaps: %w", err)
+	}
+
+	statuses, err := s.marketData.SubscribeForTradingStatuses(ctx, figis)
+	if err != nil {
+		return fmt.Errorf("subscribe for trading statuses: %w", err)
 	}
 
-	changes, err := s.orderPlacer.SubscribeForOrderBookChanges(ctx, reqs)
+	fills, err := s.orderPlacer.SubscribeForOrderFills(ctx, []tinkoffinvest.AccountID{s.account})
 	if err != nil {
-		return fmt.Errorf("subscribe for order book changes: %v", err)
+		return fmt.Errorf("subscribe for order fills: %w", err)
+	}
+
+	changes, err := s.marketData.SubscribeForOrderBookChanges(ctx, reqs)
+	if err != nil {
+		return fmt.Errorf("subscribe for order book changes: %w", err)
 	}
 
 	for {
+		// No reason to wake up while all the sessions are closed.
+		var idle <-chan time.Time
+		if s.sessions.AnyOpen(s.clock.Now()) {
+			idle = time.After(5 * time.Second)
+		}
+
 		select {
 		case <-ctx.Done():
 			return nil
 
-		case <-time.After(5 * time.Second):
+		case <-idle:
 			s.logger.Debug().Msg("no order book changes due to period")
 
+		case apply := <-s.reconfigs:
+			apply()
+
+		case <-sessionsTimer.C:
+			s.updateSessions(ctx)
+			sessionsTimer.Reset(s.untilSessionsUpdate())
+
+		case status, ok := <-statuses:
+			if !ok {
+				s.logger.Warn().Msg("trading statuses stream closed, keep the last known statuses")
+				statuses = nil
+				continue
+			}
+
+			s.logger.Info().
+				Str("figi", status.FIGI.S()).
+				Stringer("trading_status", status.Code).
+				Msg("trading status change")
+			s.tradingStatuses.Set(status)
+
+		case gap, ok := <-gaps:
+			if !ok {
+				gaps = nil
+				continue
+			}
+
+			s.logger.Warn().
+				Time("lost_at", gap.LostAt).
+				Time("restored_at", gap.RestoredAt).
+				Msg("market data gap, refresh trading statuses")
+
+			// Status changes could be missed during the gap.
+			tradingStatuses, err := common.FetchTradingStatuses(ctx, s.orderPlacer, figis)
+			if err != nil {
+				s.logger.Err(err).Msg("cannot refresh trading statuses, keep the last known statuses")
+				continue
+			}
+			s.tradingStatuses = tradingStatuses
+
+		case fill, ok := <-fills:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return errors.New("order fills stream closed")
+			}
+
+			func() {
+				ctx, cancel := context.WithTimeout(ctx, applyingTimeout)
+				defer cancel()
+
+				if err := s.ApplyFill(ctx, fill); err != nil {
+					s.logger.Err(err).Msg("cannot apply order fill")
+				}
+			}()
+
 		case change, ok := <-changes:
 			if !ok {
-				return nil
+				if ctx.Err() != nil {
+					return nil
+				}
+				return errors.New("order book changes stream closed")
 			}
 
 			func() {
@@ -148,7 +424,7 @@ func (s *Strategy) fetchToolConfigs(ctx context.Context) error {
 	for i, t := range s.toolConfigs {
 		tool, err := s.toolsCache.Get(ctx, t.FIGI)
 		if err != nil {
-			return fmt.Errorf("get cached tool %v: %v", t.FIGI, err)
+			return fmt.Errorf("get cached tool %v: %w", t.FIGI, err)
 		}
 		if tool.MinPriceInc.IsZero() {
 			return fmt.Errorf("tool %v: zero min price increment", tool.FIGI)
@@ -156,6 +432,10 @@ func (s *Strategy) fetchToolConfigs(ctx context.Context) error {
 		if tool.StocksPerLot <= 0 {
 			return fmt.Errorf("tool %v: invalid stocks per lot amount", tool.FIGI)
 		}
+		if tool.IsExpired(s.clock.Now()) {
+			return fmt.Errorf("tool %v: expired at %v", tool.FIGI, tool.ExpirationDate)
+		}
+		s.exchanges[tool.FIGI] = tool.Exchange
 
 		t.stocksPerLot = tool.StocksPerLot
 		t.minPriceInc = tool.MinPriceInc
@@ -164,6 +444,45 @@ func (s *Strategy) fetchToolConfigs(ctx context.Context) error {
 	return nil
 }
 
+// updateSessions pauses trading of instruments before their session close and resumes it at open.
+func (s *Strategy) updateSessions(ctx context.Context) {
+	paused, resumed, err := s.sessions.Update(ctx, s.clock.Now())
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("cannot update trading sessions, consider instruments traded")
+	}
+
+	for _, f := range paused {
+		s.logger.Info().Str("figi", f.S()).Msg("pause trading before session close")
+		if s.sessions.CancelOrdersOnClose() {
+			s.cancelOrders(ctx, f)
+		}
+	}
+	for _, f := range resumed {
+		s.logger.Info().Str("figi", f.S()).Msg("resume trading on session open")
+	}
+}
+
+func (s *Strategy) untilSessionsUpdate() time.Duration {
+	now := s.clock.Now()
+	return s.sessions.NextUpdate(now).Sub(now)
+}
+
+// cancelOrders cancels counter limit orders of the instrument.
+func (s *Strategy) cancelOrders(ctx context.Context, figi tinkoffinvest.FIGI) {
+	for id, o := range s.limitOrders {
+		if o.figi != figi {
+			continue
+		}
+
+		if err := s.orderPlacer.CancelOrder(ctx, s.account, id); err != nil {
+			s.logger.Warn().Str("order_id", id.S()).Err(err).Msg("cancel order")
+			continue
+		}
+		s.logger.Info().Str("figi", figi.S()).Str("order_id", id.S()).Msg("cancel order on session close")
+		delete(s.limitOrders, id)
+	}
+}
+
 // Apply applies Strategy to the next order book change.
 func (s *Strategy) Apply(ctx context.Context, change tinkoffinvest.OrderBookChange) error {
 	logger := s.logger.With().Str("figi", change.FIGI.S()).Logger()
@@ -178,6 +497,18 @@ func (s *Strategy) Apply(ctx context.Context, change tinkoffinvest.OrderBookChan
 		return fmt.Errorf("not found config for tool %q", change.FIGI)
 	}
 
+	if !s.tradingStatuses.IsNormalTrading(change.FIGI) {
+		logger.Debug().
+			Stringer("trading_status", s.tradingStatuses.Code(change.FIGI)).
+			Msg("ignore order book change: no normal trading")
+		return nil
+	}
+
+	if !s.sessions.IsOpen(change.FIGI, s.clock.Now()) {
+		logger.Debug().Msg("ignore order book change: trading session is closed")
+		return nil
+	}
+
 	buys := tinkoffinvest.CountLots(change.Bids)  // Bulls.
 	sells := tinkoffinvest.CountLots(change.Asks) // Bears.
 
@@ -220,51 +551,20 @@ func (s *Strategy) placeBuySellPair(
 		Lots:      lotsInTrade,
 	})
 	if err != nil {
-		if errors.Is(err, tinkoffinvest.ErrNotEnoughStocks) {
+		if common.IsOrderRefused(err) {
+			logger.Warn().Err(err).Msg("order refused")
 			return nil
 		}
-		return fmt.Errorf("place market buy order: %v", err)
+		return fmt.Errorf("place market buy order: %w", err)
 	}
 
-	executedPrice, err := s.orderPlacer.WaitForOrderExecution(ctx, s.account, orderID)
-	if err != nil {
-		return fmt.Errorf("wait for market order %s execution: %v", orderID, err)
-	}
-	p := executedPrice.Div(decimal.NewFromInt(int64(conf.stocksPerLot) * lotsInTrade))
-
-	common.CollectOrderPrice(p.InexactFloat64(), s.Name(), conf.FIGI, common.OrderTypeMarketBuy)
-	logger.Info().
-		Str("share_price", p.String()).
-		Str("order_id", orderID.S()).
-		Msg("buy lots by market")
-
-	p = common.RoundToMinPriceIncrement(
-		p.Mul(decimal.NewFromFloat(1.+conf.ProfitPercentage)),
-		conf.minPriceInc,
-	)
-	if p.GreaterThan(limitUp) {
-		return nil
-	}
+	logger.Info().Str("order_id", orderID.S()).Msg("place market buy order")
 
-	orderID, err = s.orderPlacer.PlaceLimitSellOrder(ctx, tinkoffinvest.PlaceOrderRequest{
-		AccountID: s.account,
-		FIGI:      conf.FIGI,
-		Lots:      lotsInTrade,
-		Price:     p,
-	})
-	if err != nil {
-		if errors.Is(err, tinkoffinvest.ErrNotEnoughStocks) {
-			return nil
-		}
-		return fmt.Errorf("place limit sell order: %v", err)
+	s.marketOrders[orderID] = &marketOrder{
+		conf:       conf,
+		direction:  tinkoffinvest.TradeDirectionBuy,
+		priceLimit: limitUp,
 	}
-
-	common.CollectOrderPrice(p.InexactFloat64(), s.Name(), conf.FIGI, common.OrderTypeLimitSell)
-	logger.Info().
-		Str("price", p.String()).
-		Str("order_id", orderID.S()).
-		Msg("place limit sell order")
-
 	return nil
 }
 
@@ -280,50 +580,135 @@ func (s *Strategy) placeSellBuyPair(
 		Lots:      lotsInTrade,
 	})
 	if err != nil {
-		if errors.Is(err, tinkoffinvest.ErrNotEnoughStocks) {
+		if common.IsOrderRefused(err) {
+			logger.Warn().Err(err).Msg("order refused")
 			return nil
 		}
-		return fmt.Errorf("place market sell order: %v", err)
+		return fmt.Errorf("place market sell order: %w", err)
 	}
 
-	executedPrice, err := s.orderPlacer.WaitForOrderExecution(ctx, s.account, orderID)
-	if err != nil {
-		return fmt.Errorf("wait for market order %s execution: %v", orderID, err)
+	logger.Info().Str("order_id", orderID.S()).Msg("place market sell order")
+
+	s.marketOrders[orderID] = &marketOrder{
+		conf:       conf,
+		direction:  tinkoffinvest.TradeDirectionSell,
+		priceLimit: limitDown,
 	}
-	p := executedPrice.Div(decimal.NewFromInt(int64(conf.stocksPerLot) * lotsInTrade))
+	return nil
+}
 
-	common.CollectOrderPrice(p.InexactFloat64(), s.Name(), conf.FIGI, common.OrderTypeMarketSell)
-	logger.Info().
-		Str("share_price", p.String()).
-		Str("order_id", orderID.S()).
-		Msg("sell lots by market")
+// ApplyFill places the counter limit order when the market order is executed completely.
+func (s *Strategy) ApplyFill(ctx context.Context, fill tinkoffinvest.OrderFill) error {
+	if lOrder, ok := s.limitOrders[fill.OrderID]; ok {
+		s.logger.Info().
+			Str("figi", lOrder.figi.S()).
+			Str("order_id", fill.OrderID.S()).
+			Stringer("direction", fill.Direction).
+			Str("price", fill.Price.String()).
+			Int("lots", fill.Lots).
+			Msg("counter order fill")
+
+		if lOrder.executedLots += fill.Lots; lOrder.executedLots >= lOrder.lots {
+			delete(s.limitOrders, fill.OrderID)
+		}
+		return nil
+	}
 
-	p = common.RoundToMinPriceIncrement(
-		p.Mul(decimal.NewFromFloat(1.-conf.ProfitPercentage)),
-		conf.minPriceInc,
-	)
-	if p.LessThan(limitDown) {
+	mOrder, ok := s.marketOrders[fill.OrderID]
+	if !ok {
+		return nil // Order of someone else.
+	}
+
+	mOrder.executedLots += fill.Lots
+	mOrder.executedCost = mOrder.executedCost.Add(fill.Price.Mul(decimal.NewFromInt(int64(fill.Lots))))
+	if mOrder.executedLots < lotsInTrade {
 		return nil
 	}
+	delete(s.marketOrders, fill.OrderID)
+
+	conf := mOrder.conf
+	logger := s.logger.With().Str("figi", conf.FIGI.S()).Logger()
+
+	// Average price of one share.
+	p := mOrder.executedCost.Div(decimal.NewFromInt(int64(mOrder.executedLots)))
+
+	switch mOrder.direction {
+	case tinkoffinvest.TradeDirectionBuy:
+		common.CollectOrderPrice(p.InexactFloat64(), s.Name(), conf.FIGI, common.OrderTypeMarketBuy)
+		logger.Info().
+			Str("share_price", p.String()).
+			Str("order_id", fill.OrderID.S()).
+			Msg("buy lots by market")
+
+		p = common.RoundToMinPriceIncrement(
+			p.Mul(decimal.NewFromFloat(1.+conf.ProfitPercentage)),
+			conf.minPriceInc,
+		)
+		if mOrder.priceLimit.IsPositive() && p.GreaterThan(mOrder.priceLimit) {
+			return nil
+		}
 
-	orderID, err = s.orderPlacer.PlaceLimitBuyOrder(ctx, tinkoffinvest.PlaceOrderRequest{
-		AccountID: s.account,
-		FIGI:      conf.FIGI,
-		Lots:      lotsInTrade,
-		Price:     p,
-	})
-	if err != nil {
-		if errors.Is(err, tinkoffinvest.ErrNotEnoughStocks) {
+		orderID, err := s.orderPlacer.PlaceLimitSellOrder(ctx, tinkoffinvest.PlaceOrderRequest{
+			AccountID: s.account,
+			FIGI:      conf.FIGI,
+			Lots:      lotsInTrade,
+			Price:     p,
+		})
+		if err != nil {
+			if common.IsOrderRefused(err) {
+				logger.Warn().Err(err).Msg("order refused")
+				return nil
+			}
+			return fmt.Errorf("place limit sell order: %w", err)
+		}
+
+		common.CollectOrderPrice(p.InexactFloat64(), s.Name(), conf.FIGI, common.OrderTypeLimitSell)
+		logger.Info().
+			Str("price", p.String()).
+			Str("order_id", orderID.S()).
+			Msg("place limit sell order")
+
+		s.limitOrders[orderID] = &limitOrder{figi: conf.FIGI, lots: lotsInTrade}
+
+	case tinkoffinvest.TradeDirectionSell:
+		common.CollectOrderPrice(p.InexactFloat64(), s.Name(), conf.FIGI, common.OrderTypeMarketSell)
+		logger.Info().
+			Str("share_price", p.String()).
+			Str("order_id", fill.OrderID.S()).
+			Msg("sell lots by market")
+
+		p = common.RoundToMinPriceIncrement(
+			p.Mul(decimal.NewFromFloat(1.-conf.ProfitPercentage)),
+			conf.minPriceInc,
+		)
+		if mOrder.priceLimit.IsPositive() && p.LessThan(mOrder.priceLimit) {
 			return nil
 		}
-		return fmt.Errorf("place limit buy order: %v", err)
-	}
 
-	common.CollectOrderPrice(p.InexactFloat64(), s.Name(), conf.FIGI, common.OrderTypeLimitBuy)
-	logger.Info().
-		Str("price", p.String()).
-		Str("order_id", orderID.S()).
-		Msg("place limit buy order")
+		orderID, err := s.orderPlacer.PlaceLimitBuyOrder(ctx, tinkoffinvest.PlaceOrderRequest{
+			AccountID: s.account,
+			FIGI:      conf.FIGI,
+			Lots:      lotsInTrade,
+			Price:     p,
+		})
+		if err != nil {
+			if common.IsOrderRefused(err) {
+				logger.Warn().Err(err).Msg("order refused")
+				return nil
+			}
+			return fmt.Errorf("place limit buy order: %w", err)
+		}
+
+		common.CollectOrderPrice(p.InexactFloat64(), s.Name(), conf.FIGI, common.OrderTypeLimitBuy)
+		logger.Info().
+			Str("price", p.String()).
+			Str("order_id", orderID.S()).
+			Msg("place limit buy order")
+
+		s.limitOrders[orderID] = &limitOrder{figi: conf.FIGI, lots: lotsInTrade}
+
+	case tinkoffinvest.TradeDirectionUnspecified:
+	}
 
 	return nil
 }
@@ -10,9 +10,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	marketdatahub "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/market-data-hub"
 	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
 	bullsbearsmon "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/bulls-and-bears-mon"
 	bullsbearsmonmocks "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/bulls-and-bears-mon/mocks"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/common"
 )
 
 const (
@@ -32,6 +34,7 @@ func TestStrategy(t *testing.T) {
 	defer ctrl.Finish()
 
 	orderPlacer := bullsbearsmonmocks.NewMockOrderPlacer(ctrl)
+	marketData := bullsbearsmonmocks.NewMockMarketDataProvider(ctrl)
 	toolsCache := bullsbearsmonmocks.NewMockToolsCache(ctrl)
 
 	tConfigs := []bullsbearsmon.ToolConfig{
@@ -43,7 +46,8 @@ func TestStrategy(t *testing.T) {
 		},
 	}
 
-	s, err := bullsbearsmon.New(accountID, false, tConfigs, orderPlacer, toolsCache)
+	s, err := bullsbearsmon.New(accountID, false, tConfigs, orderPlacer, marketData, toolsCache,
+		bullsbearsmonmocks.NewMockExchangeCalendar(ctrl), common.SessionOptions{})
 	require.NoError(t, err)
 
 	// Run strategy.
@@ -57,8 +61,22 @@ func TestStrategy(t *testing.T) {
 		MinPriceInc:  d("0.01"),
 	}, nil)
 
+	orderPlacer.EXPECT().GetTradingStatus(gomock.Any(), figi).Return(&tinkoffinvest.TradingStatus{
+		FIGI: figi,
+		Code: tinkoffinvest.TradingStatusNormalTrading,
+	}, nil)
+
+	gaps := make(chan marketdatahub.Gap)
+	marketData.EXPECT().SubscribeForGaps(gomock.Any()).Return(gaps, nil)
+
+	statuses := make(chan tinkoffinvest.TradingStatus)
+	marketData.EXPECT().SubscribeForTradingStatuses(gomock.Any(), []tinkoffinvest.FIGI{figi}).Return(statuses, nil)
+
+	fills := make(chan tinkoffinvest.OrderFill)
+	orderPlacer.EXPECT().SubscribeForOrderFills(gomock.Any(), []tinkoffinvest.AccountID{accountID}).Return(fills, nil)
+
 	changes := make(chan tinkoffinvest.OrderBookChange)
-	orderPlacer.EXPECT().SubscribeForOrderBookChanges(gomock.Any(), []tinkoffinvest.OrderBookRequest{{
+	marketData.EXPECT().SubscribeForOrderBookChanges(gomock.Any(), []tinkoffinvest.OrderBookRequest{{
 		FIGI:  figi,
 		Depth: depth,
 	}}).Return(changes, nil)
@@ -99,9 +117,6 @@ func TestStrategy(t *testing.T) {
 			Lots:      1,
 		}).Return(oid1, nil)
 
-		price := d("120.810000000").Mul(decimal.NewFromInt(stocksPerLot))
-		orderPlacer.EXPECT().WaitForOrderExecution(gomock.Any(), accountID, oid1).Return(price, nil)
-
 		oid2 := tinkoffinvest.OrderID("order-2")
 		orderPlacer.EXPECT().PlaceLimitSellOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
 			AccountID: accountID,
@@ -127,6 +142,16 @@ func TestStrategy(t *testing.T) {
 			IsConsistent: true,
 			FormedAt:     time.Now(),
 		}
+
+		fills <- tinkoffinvest.OrderFill{
+			AccountID: accountID,
+			OrderID:   oid1,
+			FIGI:      figi,
+			Direction: tinkoffinvest.TradeDirectionBuy,
+			Price:     d("120.810000000"),
+			Lots:      1,
+			Time:      time.Now(),
+		}
 	})
 
 	t.Run("sells are more than buys", func(t *testing.T) {
@@ -137,9 +162,6 @@ func TestStrategy(t *testing.T) {
 			Lots:      1,
 		}).Return(oid3, nil)
 
-		price := d("120.340000000").Mul(decimal.NewFromInt(stocksPerLot))
-		orderPlacer.EXPECT().WaitForOrderExecution(gomock.Any(), accountID, oid3).Return(price, nil)
-
 		oid4 := tinkoffinvest.OrderID("order-4")
 		orderPlacer.EXPECT().PlaceLimitBuyOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
 			AccountID: accountID,
@@ -165,10 +187,201 @@ func TestStrategy(t *testing.T) {
 			IsConsistent: true,
 			FormedAt:     time.Now(),
 		}
+
+		fills <- tinkoffinvest.OrderFill{
+			AccountID: accountID,
+			OrderID:   oid3,
+			FIGI:      figi,
+			Direction: tinkoffinvest.TradeDirectionSell,
+			Price:     d("120.340000000"),
+			Lots:      1,
+			Time:      time.Now(),
+		}
+	})
+
+	t.Run("no trading during auction", func(t *testing.T) {
+		statuses <- tinkoffinvest.TradingStatus{
+			FIGI: figi,
+			Code: tinkoffinvest.TradingStatusClosingAuction,
+			Time: time.Now(),
+		}
+
+		changes <- tinkoffinvest.OrderBookChange{
+			OrderBook: tinkoffinvest.OrderBook{
+				FIGI: figi,
+				Bids: []tinkoffinvest.Order{{
+					Price: d("120.330000000"),
+					Lots:  551,
+				}},
+				Asks: []tinkoffinvest.Order{{
+					Price: d("120.800000000"),
+					Lots:  100,
+				}},
+				LimitUp:   d("150.200000000"),
+				LimitDown: d("90.100000000"),
+			},
+			IsConsistent: true,
+			FormedAt:     time.Now(),
+		}
 	})
 
+	t.Run("trading statuses are refreshed after gap", func(t *testing.T) {
+		orderPlacer.EXPECT().GetTradingStatus(gomock.Any(), figi).Return(&tinkoffinvest.TradingStatus{
+			FIGI: figi,
+			Code: tinkoffinvest.TradingStatusNormalTrading,
+		}, nil)
+
+		gaps <- marketdatahub.Gap{LostAt: time.Now().Add(-time.Minute), RestoredAt: time.Now()}
+
+		oid1 := tinkoffinvest.OrderID("order-5")
+		orderPlacer.EXPECT().PlaceMarketBuyOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
+			AccountID: accountID,
+			FIGI:      figi,
+			Lots:      1,
+		}).Return(oid1, nil)
+
+		oid2 := tinkoffinvest.OrderID("order-6")
+		orderPlacer.EXPECT().PlaceLimitSellOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
+			AccountID: accountID,
+			FIGI:      figi,
+			Lots:      1,
+			Price:     d("122.02"),
+		}).Return(oid2, nil)
+
+		changes <- tinkoffinvest.OrderBookChange{
+			OrderBook: tinkoffinvest.OrderBook{
+				FIGI: figi,
+				Bids: []tinkoffinvest.Order{{
+					Price: d("120.330000000"),
+					Lots:  551,
+				}},
+				Asks: []tinkoffinvest.Order{{
+					Price: d("120.800000000"),
+					Lots:  100,
+				}},
+				LimitUp:   d("150.200000000"),
+				LimitDown: d("90.100000000"),
+			},
+			IsConsistent: true,
+			FormedAt:     time.Now(),
+		}
+
+		fills <- tinkoffinvest.OrderFill{
+			AccountID: accountID,
+			OrderID:   oid1,
+			FIGI:      figi,
+			Direction: tinkoffinvest.TradeDirectionBuy,
+			Price:     d("120.810000000"),
+			Lots:      1,
+			Time:      time.Now(),
+		}
+	})
+
+	t.Run("reconfigured dominance ratio", func(t *testing.T) {
+		err := s.Reconfigure(ctx, false, []bullsbearsmon.ToolConfig{{
+			FIGI:             figi,
+			Depth:            depth,
+			DominanceRatio:   1.5,
+			ProfitPercentage: profitPercentage,
+		}})
+		require.NoError(t, err)
+
+		orderPlacer.EXPECT().PlaceMarketBuyOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
+			AccountID: accountID,
+			FIGI:      figi,
+			Lots:      1,
+		}).Return(tinkoffinvest.OrderID("order-7"), nil)
+
+		changes <- tinkoffinvest.OrderBookChange{
+			OrderBook: tinkoffinvest.OrderBook{
+				FIGI: figi,
+				Bids: []tinkoffinvest.Order{{
+					Price: d("120.330000000"),
+					Lots:  200,
+				}},
+				Asks: []tinkoffinvest.Order{{
+					Price: d("120.800000000"),
+					Lots:  100,
+				}},
+				LimitUp:   d("150.200000000"),
+				LimitDown: d("90.100000000"),
+			},
+			IsConsistent: true,
+			FormedAt:     time.Now(),
+		}
+	})
+
+	for name, tools := range map[string][]bullsbearsmon.ToolConfig{
+		"restart required for other depth": {
+			{FIGI: figi, Depth: depth + 10, DominanceRatio: 2, ProfitPercentage: 0.01},
+		},
+		"restart required for other figi": {
+			{FIGI: "BBG000BN56Q9", Depth: depth, DominanceRatio: 2, ProfitPercentage: 0.01},
+		},
+		"restart required for new figi": {
+			{FIGI: figi, Depth: depth, DominanceRatio: 2, ProfitPercentage: 0.01},
+			{FIGI: "BBG000BN56Q9", Depth: depth, DominanceRatio: 2, ProfitPercentage: 0.01},
+		},
+	} {
+		tools := tools
+		t.Run(name, func(t *testing.T) {
+			err := s.Reconfigure(ctx, false, tools)
+			require.ErrorIs(t, err, common.ErrRestartRequired)
+		})
+	}
+
 	// Shutdown.
 
 	cancel()
 	<-done
 }
+
+func TestStrategy_Reconfigure(t *testing.T) {
+	s, err := bullsbearsmon.New(accountID, false, []bullsbearsmon.ToolConfig{
+		{FIGI: figi, Depth: 10, DominanceRatio: 2, ProfitPercentage: 0.01},
+	}, nil, nil, nil, nil, common.SessionOptions{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("duplicated figi", func(t *testing.T) {
+		err := s.Reconfigure(ctx, false, []bullsbearsmon.ToolConfig{
+			{FIGI: figi, Depth: 10, DominanceRatio: 3, ProfitPercentage: 0.01},
+			{FIGI: figi, Depth: 10, DominanceRatio: 4, ProfitPercentage: 0.01},
+		})
+		require.Error(t, err)
+		require.NotErrorIs(t, err, common.ErrRestartRequired)
+	})
+
+	t.Run("strategy is not running", func(t *testing.T) {
+		err := s.Reconfigure(ctx, true, []bullsbearsmon.ToolConfig{
+			{FIGI: figi, Depth: 10, DominanceRatio: 3, ProfitPercentage: 0.02},
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestStrategy_AdoptOrders(t *testing.T) {
+	s, err := bullsbearsmon.New(accountID, false, []bullsbearsmon.ToolConfig{
+		{FIGI: figi, Depth: 10, DominanceRatio: 2, ProfitPercentage: 0.01},
+	}, nil, nil, nil, nil, common.SessionOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []tinkoffinvest.FIGI{figi}, s.FIGIs())
+
+	takeProfit := tinkoffinvest.ActiveOrder{
+		ID:            "oid1",
+		FIGI:          figi,
+		Type:          tinkoffinvest.OrderTypeLimit,
+		Direction:     tinkoffinvest.TradeDirectionSell,
+		LotsRequested: 1,
+		Price:         d("120.5"),
+	}
+	market := takeProfit
+	market.ID, market.Type = "oid2", tinkoffinvest.OrderTypeMarket
+	alien := takeProfit
+	alien.ID, alien.FIGI = "oid3", "BBG000BN56Q9"
+
+	rest := s.AdoptOrders([]tinkoffinvest.ActiveOrder{takeProfit, market, alien})
+	require.Equal(t, []tinkoffinvest.ActiveOrder{market, alien}, rest)
+}
@@ -0,0 +1,23 @@
+package common
+
+import "time"
+
+// Clock tells the current time to strategies. The backtest replaces the wall clock with the virtual one.
+type Clock interface {
+	Now() time.Time
+}
+
+// WallClock is the real time clock.
+type WallClock struct{}
+
+func (WallClock) Now() time.Time {
+	return time.Now()
+}
+
+// ClockOrWall returns the wall clock if c is nil.
+func ClockOrWall(c Clock) Clock {
+	if c == nil {
+		return WallClock{}
+	}
+	return c
+}
@@ -0,0 +1,21 @@
+package common
+
+import (
+	"errors"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	riskmanager "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/risk-manager"
+)
+
+// ErrRestartRequired means that the parameters cannot be applied to the running strategy.
+var ErrRestartRequired = errors.New("strategy restart required")
+
+// IsOrderRefused reports whether the order is refused due to the market or account state or risk limits,
+// so the strategy should skip the opportunity instead of failing.
+func IsOrderRefused(err error) bool {
+	return errors.Is(err, tinkoffinvest.ErrNotEnoughStocks) ||
+		errors.Is(err, tinkoffinvest.ErrInstrumentNotTradable) ||
+		errors.Is(err, tinkoffinvest.ErrPriceOutOfLimits) ||
+		errors.Is(err, tinkoffinvest.ErrMarketClosed) ||
+		errors.Is(err, riskmanager.ErrRejected)
+}
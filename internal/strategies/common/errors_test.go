@@ -0,0 +1,31 @@
+package common_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/common"
+)
+
+func TestIsOrderRefused(t *testing.T) {
+	for _, err := range []error{
+		tinkoffinvest.ErrNotEnoughStocks,
+		tinkoffinvest.ErrInstrumentNotTradable,
+		tinkoffinvest.ErrPriceOutOfLimits,
+		fmt.Errorf("place order: %w", tinkoffinvest.ErrMarketClosed),
+	} {
+		assert.True(t, common.IsOrderRefused(err), err)
+	}
+
+	for _, err := range []error{
+		tinkoffinvest.ErrUnavailable,
+		tinkoffinvest.ErrInvalidToken,
+		errors.New("unknown"),
+	} {
+		assert.False(t, common.IsOrderRefused(err), err)
+	}
+}
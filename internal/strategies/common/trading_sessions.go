@@ -0,0 +1,131 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	exchangecalendar "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/exchange-calendar"
+)
+
+// sessionsRefreshInterval limits the time between refreshes, e.g. after the calendar errors.
+const sessionsRefreshInterval = time.Hour
+
+// ExchangeCalendar is implemented by exchangecalendar.Calendar.
+type ExchangeCalendar interface {
+	CurrentOrNextSession(ctx context.Context, exchange string, at time.Time) (exchangecalendar.Session, error)
+}
+
+type SessionOptions struct {
+	// PauseBeforeClose stops trading the time before the session end.
+	PauseBeforeClose time.Duration
+	// CancelOrdersOnClose makes strategy to cancel resting orders when trading is paused.
+	CancelOrdersOnClose bool
+}
+
+// TradingSessions tracks whether the instruments exchanges are open for trading.
+// Instruments with unknown exchange or session are considered as traded.
+// It is not safe for concurrent use.
+type TradingSessions struct {
+	calendar ExchangeCalendar
+	opts     SessionOptions
+
+	exchanges map[tinkoffinvest.FIGI]string
+	sessions  map[string]exchangecalendar.Session
+	open      map[tinkoffinvest.FIGI]bool
+}
+
+func NewTradingSessions(
+	calendar ExchangeCalendar,
+	opts SessionOptions,
+	exchanges map[tinkoffinvest.FIGI]string,
+) *TradingSessions {
+	open := make(map[tinkoffinvest.FIGI]bool, len(exchanges))
+	for f := range exchanges {
+		open[f] = true
+	}
+
+	return &TradingSessions{
+		calendar:  calendar,
+		opts:      opts,
+		exchanges: exchanges,
+		sessions:  make(map[string]exchangecalendar.Session),
+		open:      open,
+	}
+}
+
+// Update refreshes the sessions and returns instruments that have been paused or resumed since the last update.
+// Sessions of exchanges failed to fetch are forgotten, so their instruments are considered as traded.
+func (ts *TradingSessions) Update(ctx context.Context, now time.Time) (paused, resumed []tinkoffinvest.FIGI, err error) {
+	for _, exchange := range ts.exchanges {
+		if exchange == "" {
+			continue
+		}
+		if s, ok := ts.sessions[exchange]; ok && now.Before(s.End) {
+			continue
+		}
+
+		s, calErr := ts.calendar.CurrentOrNextSession(ctx, exchange, now)
+		if calErr != nil {
+			delete(ts.sessions, exchange)
+			err = fmt.Errorf("get session of %q: %w", exchange, calErr)
+			continue
+		}
+		ts.sessions[exchange] = s
+	}
+
+	for f, wasOpen := range ts.open {
+		isOpen := ts.IsOpen(f, now)
+		switch {
+		case wasOpen && !isOpen:
+			paused = append(paused, f)
+		case !wasOpen && isOpen:
+			resumed = append(resumed, f)
+		}
+		ts.open[f] = isOpen
+	}
+
+	return paused, resumed, err
+}
+
+// IsOpen reports whether it is reasonable to place orders for the instrument.
+func (ts *TradingSessions) IsOpen(figi tinkoffinvest.FIGI, now time.Time) bool {
+	s, ok := ts.sessions[ts.exchanges[figi]]
+	if !ok {
+		return true
+	}
+	return !now.Before(s.Start) && now.Before(s.End.Add(-ts.opts.PauseBeforeClose))
+}
+
+// AnyOpen reports whether at least one instrument is traded.
+func (ts *TradingSessions) AnyOpen(now time.Time) bool {
+	if len(ts.exchanges) == 0 {
+		return true
+	}
+
+	for f := range ts.exchanges {
+		if ts.IsOpen(f, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// CancelOrdersOnClose reports whether resting orders of paused instruments should be cancelled.
+func (ts *TradingSessions) CancelOrdersOnClose() bool {
+	return ts.opts.CancelOrdersOnClose
+}
+
+// NextUpdate returns the nearest moment when Update should be called.
+func (ts *TradingSessions) NextUpdate(now time.Time) time.Time {
+	next := now.Add(sessionsRefreshInterval)
+	for _, s := range ts.sessions {
+		for _, t := range []time.Time{s.Start, s.End.Add(-ts.opts.PauseBeforeClose), s.End} {
+			if t.After(now) && t.Before(next) {
+				next = t
+			}
+		}
+	}
+	return next
+}
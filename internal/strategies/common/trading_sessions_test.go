@@ -0,0 +1,87 @@
+package common_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	exchangecalendar "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/exchange-calendar"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/common"
+)
+
+type calendarStub map[string]exchangecalendar.Session
+
+func (c calendarStub) CurrentOrNextSession(_ context.Context, exchange string, _ time.Time) (exchangecalendar.Session, error) { //nolint:lll
+	s, ok := c[exchange]
+	if !ok {
+		return exchangecalendar.Session{}, errors.New("unknown exchange")
+	}
+	return s, nil
+}
+
+func TestTradingSessions(t *testing.T) {
+	const f1, f2, f3 = tinkoffinvest.FIGI("f1"), tinkoffinvest.FIGI("f2"), tinkoffinvest.FIGI("f3")
+
+	start := time.Date(2022, 5, 20, 7, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+
+	calendar := calendarStub{
+		"MOEX": {Exchange: "MOEX", Kind: exchangecalendar.SessionMain, Start: start, End: end},
+	}
+	sessions := common.NewTradingSessions(calendar, common.SessionOptions{
+		PauseBeforeClose:    10 * time.Minute,
+		CancelOrdersOnClose: true,
+	}, map[tinkoffinvest.FIGI]string{
+		f1: "MOEX",
+		f2: "SPB", // Unknown for calendar.
+		f3: "",
+	})
+	assert.True(t, sessions.CancelOrdersOnClose())
+
+	t.Run("before open", func(t *testing.T) {
+		now := start.Add(-time.Hour)
+
+		paused, resumed, err := sessions.Update(context.Background(), now)
+		require.Error(t, err)
+		assert.Equal(t, []tinkoffinvest.FIGI{f1}, paused)
+		assert.Empty(t, resumed)
+
+		assert.False(t, sessions.IsOpen(f1, now))
+		assert.True(t, sessions.IsOpen(f2, now))
+		assert.True(t, sessions.IsOpen(f3, now))
+		assert.Equal(t, start, sessions.NextUpdate(now))
+	})
+
+	t.Run("open", func(t *testing.T) {
+		now := start
+
+		paused, resumed, _ := sessions.Update(context.Background(), now)
+		assert.Empty(t, paused)
+		assert.Equal(t, []tinkoffinvest.FIGI{f1}, resumed)
+		assert.True(t, sessions.IsOpen(f1, now))
+		assert.Equal(t, now.Add(time.Hour), sessions.NextUpdate(now))
+	})
+
+	t.Run("pause before close", func(t *testing.T) {
+		now := end.Add(-5 * time.Minute)
+
+		paused, resumed, _ := sessions.Update(context.Background(), now)
+		assert.Equal(t, []tinkoffinvest.FIGI{f1}, paused)
+		assert.Empty(t, resumed)
+		assert.False(t, sessions.IsOpen(f1, now))
+		assert.Equal(t, end, sessions.NextUpdate(now))
+	})
+
+	t.Run("all closed", func(t *testing.T) {
+		s := common.NewTradingSessions(calendar, common.SessionOptions{}, map[tinkoffinvest.FIGI]string{f1: "MOEX"})
+		_, _, err := s.Update(context.Background(), end)
+		require.NoError(t, err)
+		assert.False(t, s.AnyOpen(end))
+		assert.True(t, s.AnyOpen(start))
+	})
+}
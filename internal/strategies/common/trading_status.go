@@ -0,0 +1,49 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+)
+
+type TradingStatusProvider interface {
+	GetTradingStatus(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.TradingStatus, error)
+}
+
+// TradingStatuses keeps the last known trading status of every instrument.
+// It is not safe for concurrent use.
+type TradingStatuses map[tinkoffinvest.FIGI]tinkoffinvest.TradingStatus
+
+// FetchTradingStatuses requests the current trading statuses of the instruments.
+func FetchTradingStatuses(
+	ctx context.Context,
+	provider TradingStatusProvider,
+	figis []tinkoffinvest.FIGI,
+) (TradingStatuses, error) {
+	statuses := make(TradingStatuses, len(figis))
+	for _, f := range figis {
+		s, err := provider.GetTradingStatus(ctx, f)
+		if err != nil {
+			return nil, fmt.Errorf("get trading status of %v: %w", f, err)
+		}
+		statuses[f] = *s
+	}
+	return statuses, nil
+}
+
+func (ts TradingStatuses) Set(s tinkoffinvest.TradingStatus) {
+	ts[s.FIGI] = s
+}
+
+// IsNormalTrading reports whether it is reasonable to place orders for the instrument.
+// Instruments with unknown status are considered as normally traded.
+func (ts TradingStatuses) IsNormalTrading(figi tinkoffinvest.FIGI) bool {
+	s, ok := ts[figi]
+	return !ok || s.IsNormalTrading()
+}
+
+// Code returns the last known status code of the instrument.
+func (ts TradingStatuses) Code(figi tinkoffinvest.FIGI) tinkoffinvest.TradingStatusCode {
+	return ts[figi].Code
+}
@@ -0,0 +1,26 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/common"
+)
+
+func TestTradingStatuses(t *testing.T) {
+	const f1, f2 = tinkoffinvest.FIGI("f1"), tinkoffinvest.FIGI("f2")
+
+	statuses := make(common.TradingStatuses)
+	assert.True(t, statuses.IsNormalTrading(f1), "unknown status must not block trading")
+
+	statuses.Set(tinkoffinvest.TradingStatus{FIGI: f1, Code: tinkoffinvest.TradingStatusNormalTrading})
+	statuses.Set(tinkoffinvest.TradingStatus{FIGI: f2, Code: tinkoffinvest.TradingStatusOpeningAuctionPeriod})
+	assert.True(t, statuses.IsNormalTrading(f1))
+	assert.False(t, statuses.IsNormalTrading(f2))
+	assert.Equal(t, tinkoffinvest.TradingStatusOpeningAuctionPeriod, statuses.Code(f2))
+
+	statuses.Set(tinkoffinvest.TradingStatus{FIGI: f1, Code: tinkoffinvest.TradingStatusDealerNormalTrading})
+	assert.False(t, statuses.IsNormalTrading(f1))
+}
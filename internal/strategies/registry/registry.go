@@ -0,0 +1,193 @@
+// Package registry keeps the strategies available to the robot.
+// Strategy packages register themselves on init, so the robot builds whatever strategies the config declares.
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+	marketdatahub "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/market-data-hub"
+	orderreconciler "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/order-reconciler"
+	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/common"
+)
+
+var (
+	ErrUnknownStrategy  = errors.New("unknown strategy")
+	ErrDuplicatedName   = errors.New("duplicated strategy name")
+	errInvalidStrategy  = errors.New("invalid strategy registration")
+	errDoubleRegistered = errors.New("strategy is already registered")
+)
+
+type Strategy interface {
+	orderreconciler.Adopter
+	Run(ctx context.Context) error
+}
+
+// OrderPlacer is implemented by tinkoffinvest.Client and decorators over it, e.g. riskmanager.Manager.
+type OrderPlacer interface {
+	GetTradeAvailableInstruments(ctx context.Context, typ tinkoffinvest.InstrumentType) ([]tinkoffinvest.Instrument, error)
+	GetOrderBook(ctx context.Context, req tinkoffinvest.OrderBookRequest) (*tinkoffinvest.OrderBookResponse, error)
+	GetTradingStatus(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.TradingStatus, error)
+
+	SubscribeForOrderFills(ctx context.Context, accounts []tinkoffinvest.AccountID) (<-chan tinkoffinvest.OrderFill, error)
+	CancelOrder(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) error
+	GetOrderState(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) (*tinkoffinvest.OrderState, error) //nolint:lll
+
+	PlaceMarketSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
+	PlaceMarketBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
+	PlaceLimitSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
+	PlaceLimitBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
+}
+
+// MarketData is implemented by marketdatahub.Hub.
+type MarketData interface {
+	SubscribeForOrderBookChanges(ctx context.Context, reqs []tinkoffinvest.OrderBookRequest) (<-chan tinkoffinvest.OrderBookChange, error) //nolint:lll
+	SubscribeForTradingStatuses(ctx context.Context, figis []tinkoffinvest.FIGI) (<-chan tinkoffinvest.TradingStatus, error)
+	SubscribeForGaps(ctx context.Context) (<-chan marketdatahub.Gap, error)
+}
+
+// ToolsCache is implemented by toolscache.Cache.
+type ToolsCache interface {
+	Get(ctx context.Context, figi tinkoffinvest.FIGI) (toolscache.Tool, error)
+}
+
+// Deps are shared by strategies of all accounts.
+type Deps struct {
+	// Orders are placed by strategies via it.
+	Orders      OrderPlacer
+	MarketData  MarketData
+	ToolsCache  ToolsCache
+	Calendar    common.ExchangeCalendar
+	SessionOpts common.SessionOptions
+	// Clock is the wall clock if nil.
+	Clock common.Clock
+}
+
+// ConfigDecoder decodes the strategy parameters with decode and validates them.
+type ConfigDecoder func(decode func(v interface{}) error) (interface{}, error)
+
+// Factory creates the strategy of the instance with the config returned by ConfigDecoder.
+type Factory func(instance Instance, deps Deps) (Strategy, error)
+
+// Reconfigurer applies the config of the instance to the running strategy created by Factory.
+// common.ErrRestartRequired is returned if it cannot be done without restart.
+type Reconfigurer func(ctx context.Context, s Strategy, instance Instance) error
+
+// Definition describes the strategy for the registry.
+type Definition struct {
+	DecodeConfig ConfigDecoder
+	New          Factory
+	// Reconfigure is optional, the strategy is restarted on every config change without it.
+	Reconfigure Reconfigurer
+}
+
+var (
+	mu          sync.RWMutex
+	definitions = make(map[string]Definition)
+)
+
+// Register makes the strategy available by the name. It panics if the name is already taken,
+// because it is a programming error.
+func Register(name string, d Definition) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if name == "" || d.DecodeConfig == nil || d.New == nil {
+		panic(fmt.Errorf("%w: %q", errInvalidStrategy, name))
+	}
+	if _, ok := definitions[name]; ok {
+		panic(fmt.Errorf("%w: %q", errDoubleRegistered, name))
+	}
+	definitions[name] = d
+}
+
+// Names returns the registered strategies.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(definitions))
+	for n := range definitions {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookup(name string) (Definition, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	d, ok := definitions[name]
+	return d, ok
+}
+
+// Instance is the strategy declared in the config for the account.
+type Instance struct {
+	Account tinkoffinvest.AccountID
+	// Name is unique within the account.
+	Name     string
+	Strategy string
+	// Config is returned by ConfigDecoder of the strategy.
+	Config interface{}
+}
+
+// Decode returns the enabled instances declared for the account.
+func Decode(account tinkoffinvest.AccountID, cfgs config.StrategiesConfig) ([]Instance, error) {
+	instances := make([]Instance, 0, len(cfgs))
+	names := make(map[string]struct{}, len(cfgs))
+
+	for _, c := range cfgs {
+		if !c.Enabled {
+			continue
+		}
+
+		name := c.InstanceName()
+		if _, ok := names[name]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicatedName, name)
+		}
+		names[name] = struct{}{}
+
+		d, ok := lookup(c.Strategy)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownStrategy, c.Strategy)
+		}
+
+		cfg, err := d.DecodeConfig(c.Params.Decode)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s: %w", name, err)
+		}
+
+		instances = append(instances, Instance{
+			Account:  account,
+			Name:     name,
+			Strategy: c.Strategy,
+			Config:   cfg,
+		})
+	}
+	return instances, nil
+}
+
+// New creates the strategy of the instance.
+func (i Instance) New(deps Deps) (Strategy, error) {
+	d, ok := lookup(i.Strategy)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStrategy, i.Strategy)
+	}
+	return d.New(i, deps)
+}
+
+// Reconfigure applies the config of the instance to the running strategy created for the instance of the same strategy.
+func (i Instance) Reconfigure(ctx context.Context, s Strategy) error {
+	d, ok := lookup(i.Strategy)
+	if !ok || d.Reconfigure == nil {
+		return common.ErrRestartRequired
+	}
+	return d.Reconfigure(ctx, s, i)
+}
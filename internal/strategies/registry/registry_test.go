@@ -0,0 +1,151 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/common"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/registry"
+)
+
+const (
+	accountID = tinkoffinvest.AccountID("account-xxx")
+
+	testStrategy       = "test-strategy"
+	staticTestStrategy = "static-test-strategy"
+)
+
+var errInvalidRatio = errors.New("invalid ratio")
+
+type testConfig struct {
+	Ratio float64 `toml:"ratio"`
+}
+
+type strategyStub struct {
+	name  string
+	ratio float64
+}
+
+func (s *strategyStub) Name() string                { return s.name }
+func (s *strategyStub) FIGIs() []tinkoffinvest.FIGI { return nil }
+func (s *strategyStub) AdoptOrders(o []tinkoffinvest.ActiveOrder) []tinkoffinvest.ActiveOrder {
+	return o
+}                                                     //nolint:lll
+func (s *strategyStub) Run(ctx context.Context) error { <-ctx.Done(); return nil }
+
+func decodeTestConfig(decode func(v interface{}) error) (interface{}, error) {
+	var cfg testConfig
+	if err := decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Ratio <= 0 {
+		return nil, errInvalidRatio
+	}
+	return cfg, nil
+}
+
+func newTestStrategy(instance registry.Instance, _ registry.Deps) (registry.Strategy, error) {
+	return &strategyStub{name: instance.Name, ratio: instance.Config.(testConfig).Ratio}, nil
+}
+
+func init() {
+	registry.Register(testStrategy, registry.Definition{
+		DecodeConfig: decodeTestConfig,
+		New:          newTestStrategy,
+		Reconfigure: func(_ context.Context, s registry.Strategy, instance registry.Instance) error {
+			s.(*strategyStub).ratio = instance.Config.(testConfig).Ratio
+			return nil
+		},
+	})
+	registry.Register(staticTestStrategy, registry.Definition{
+		DecodeConfig: decodeTestConfig,
+		New:          newTestStrategy,
+	})
+}
+
+func TestRegister(t *testing.T) {
+	assert.Subset(t, registry.Names(), []string{staticTestStrategy, testStrategy})
+
+	assert.Panics(t, func() {
+		registry.Register(testStrategy, registry.Definition{DecodeConfig: decodeTestConfig, New: newTestStrategy})
+	})
+	assert.Panics(t, func() {
+		registry.Register("incomplete-strategy", registry.Definition{DecodeConfig: decodeTestConfig})
+	})
+}
+
+func TestDecode(t *testing.T) {
+	instances, err := registry.Decode(accountID, config.StrategiesConfig{
+		{Strategy: testStrategy, Enabled: true, Params: config.StrategyParams{"ratio": 2.0}},
+		{Strategy: testStrategy, Name: "second", Enabled: true, Params: config.StrategyParams{"ratio": int64(3)}},
+		{Strategy: testStrategy, Name: "disabled", Params: config.StrategyParams{"ratio": 4.0}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []registry.Instance{
+		{Account: accountID, Name: testStrategy, Strategy: testStrategy, Config: testConfig{Ratio: 2}},
+		{Account: accountID, Name: "second", Strategy: testStrategy, Config: testConfig{Ratio: 3}},
+	}, instances)
+
+	s, err := instances[1].New(registry.Deps{})
+	require.NoError(t, err)
+	assert.Equal(t, "second", s.Name())
+
+	t.Run("reconfigure", func(t *testing.T) {
+		inst := instances[1]
+		inst.Config = testConfig{Ratio: 5}
+
+		require.NoError(t, inst.Reconfigure(context.Background(), s))
+		assert.Equal(t, 5.0, s.(*strategyStub).ratio)
+	})
+
+	t.Run("reconfigure is not supported", func(t *testing.T) {
+		instances, err := registry.Decode(accountID, config.StrategiesConfig{
+			{Strategy: staticTestStrategy, Enabled: true, Params: config.StrategyParams{"ratio": 2.0}},
+		})
+		require.NoError(t, err)
+
+		err = instances[0].Reconfigure(context.Background(), s)
+		require.ErrorIs(t, err, common.ErrRestartRequired)
+	})
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg         config.StrategiesConfig
+		expectedErr error
+	}{
+		"unknown strategy": {
+			cfg:         config.StrategiesConfig{{Strategy: "unknown", Enabled: true}},
+			expectedErr: registry.ErrUnknownStrategy,
+		},
+		"duplicated name": {
+			cfg: config.StrategiesConfig{
+				{Strategy: testStrategy, Enabled: true, Params: config.StrategyParams{"ratio": 2.0}},
+				{Strategy: testStrategy, Enabled: true, Params: config.StrategyParams{"ratio": 3.0}},
+			},
+			expectedErr: registry.ErrDuplicatedName,
+		},
+		"invalid params": {
+			cfg:         config.StrategiesConfig{{Strategy: testStrategy, Enabled: true}},
+			expectedErr: errInvalidRatio,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := registry.Decode(accountID, tc.cfg)
+			require.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+
+	t.Run("unknown params", func(t *testing.T) {
+		_, err := registry.Decode(accountID, config.StrategiesConfig{
+			{Strategy: testStrategy, Enabled: true, Params: config.StrategyParams{"ratio": 2.0, "depth": int64(10)}},
+		})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,84 @@
+package spreadparasite
+
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/registry"
+)
+
+// Name is the strategy name in the config.
+const Name = "spread-parasite"
+
+func init() {
+	registry.Register(Name, registry.Definition{
+		DecodeConfig: decodeConfig,
+		New:          newFromConfig,
+		Reconfigure:  reconfigureFromConfig,
+	})
+}
+
+// Config is the strategy parameters in the config.
+type Config struct {
+	IgnoreInconsistent  bool     `toml:"ignore_inconsistent"`
+	MinSpreadPercentage float64  `toml:"min_spread_percentage" validate:"required,gte=0,lte=1"`
+	Figis               []string `toml:"figis"`
+	// InstrumentTypes are used to choose instruments automatically if Figis are empty.
+	// Shares by default.
+	InstrumentTypes []string `toml:"instrument_types" validate:"dive,oneof=share bond etf futures currency"`
+}
+
+// Instruments returns the configured FIGIs and instrument types.
+func (c Config) Instruments() ([]tinkoffinvest.FIGI, []tinkoffinvest.InstrumentType) {
+	figis := make([]tinkoffinvest.FIGI, len(c.Figis))
+	for i, f := range c.Figis {
+		figis[i] = tinkoffinvest.FIGI(f)
+	}
+
+	instrumentTypes := make([]tinkoffinvest.InstrumentType, len(c.InstrumentTypes))
+	for i, t := range c.InstrumentTypes {
+		instrumentTypes[i] = tinkoffinvest.InstrumentType(t)
+	}
+	return figis, instrumentTypes
+}
+
+func decodeConfig(decode func(v interface{}) error) (interface{}, error) {
+	var cfg Config
+	if err := decode(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validator.New().Struct(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func newFromConfig(instance registry.Instance, deps registry.Deps) (registry.Strategy, error) {
+	cfg := instance.Config.(Config) //nolint:forcetypeassert // Decoded by decodeConfig.
+	figis, instrumentTypes := cfg.Instruments()
+
+	return newStrategy(
+		instance.Name,
+		instance.Account,
+		cfg.IgnoreInconsistent,
+		cfg.MinSpreadPercentage,
+		figis,
+		instrumentTypes,
+		deps.Orders,
+		deps.MarketData,
+		deps.ToolsCache,
+		deps.Calendar,
+		deps.SessionOpts,
+		deps.Clock,
+	)
+}
+
+func reconfigureFromConfig(ctx context.Context, s registry.Strategy, instance registry.Instance) error {
+	cfg := instance.Config.(Config) //nolint:forcetypeassert // Decoded by decodeConfig.
+	figis, instrumentTypes := cfg.Instruments()
+
+	return s.(*Strategy).Reconfigure( //nolint:forcetypeassert
+		ctx, cfg.IgnoreInconsistent, cfg.MinSpreadPercentage, figis, instrumentTypes)
+}
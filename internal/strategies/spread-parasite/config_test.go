@@ -0,0 +1,64 @@
+package spreadparasite_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/config"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/registry"
+	spreadparasite "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/spread-parasite"
+)
+
+func TestConfig(t *testing.T) {
+	instances, err := registry.Decode(accountID, config.StrategiesConfig{
+		{
+			Strategy: spreadparasite.Name,
+			Name:     "shares",
+			Enabled:  true,
+			Params: config.StrategyParams{
+				"min_spread_percentage": 0.02,
+				"figis":                 []interface{}{"BBG004730N88"},
+			},
+		},
+		{
+			Strategy: spreadparasite.Name,
+			Name:     "etfs",
+			Enabled:  true,
+			Params: config.StrategyParams{
+				"min_spread_percentage": 0.05,
+				"instrument_types":      []interface{}{"etf"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+
+	figis, instrumentTypes := instances[0].Config.(spreadparasite.Config).Instruments()
+	assert.Equal(t, []tinkoffinvest.FIGI{"BBG004730N88"}, figis)
+	assert.Empty(t, instrumentTypes)
+
+	figis, instrumentTypes = instances[1].Config.(spreadparasite.Config).Instruments()
+	assert.Empty(t, figis)
+	assert.Equal(t, []tinkoffinvest.InstrumentType{tinkoffinvest.InstrumentTypeETF}, instrumentTypes)
+
+	for _, inst := range instances {
+		s, err := inst.New(registry.Deps{})
+		require.NoError(t, err)
+		assert.Equal(t, inst.Name, s.Name())
+	}
+
+	for name, params := range map[string]config.StrategyParams{
+		"no spread":               {},
+		"invalid instrument type": {"min_spread_percentage": 0.02, "instrument_types": []interface{}{"stock"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := registry.Decode(accountID, config.StrategiesConfig{
+				{Strategy: spreadparasite.Name, Enabled: true, Params: params},
+			})
+			require.Error(t, err)
+		})
+	}
+}
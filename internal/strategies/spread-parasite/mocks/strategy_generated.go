@@ -7,11 +7,13 @@ package spreadparasitemocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	tinkoffinvest "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	exchangecalendar "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/exchange-calendar"
+	marketdatahub "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/market-data-hub"
 	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
 	gomock "github.com/golang/mock/gomock"
-	decimal "github.com/shopspring/decimal"
 )
 
 // MockOrderPlacer is a mock of OrderPlacer interface.
@@ -67,33 +69,48 @@ func (mr *MockOrderPlacerMockRecorder) GetOrderBook(ctx, req interface{}) *gomoc
 }
 
 // GetOrderState mocks base method.
-func (m *MockOrderPlacer) GetOrderState(ctx context.Context, arg1 tinkoffinvest.AccountID, arg2 tinkoffinvest.OrderID) (decimal.Decimal, error) {
+func (m *MockOrderPlacer) GetOrderState(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) (*tinkoffinvest.OrderState, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrderState", ctx, arg1, arg2)
-	ret0, _ := ret[0].(decimal.Decimal)
+	ret := m.ctrl.Call(m, "GetOrderState", ctx, accountID, orderID)
+	ret0, _ := ret[0].(*tinkoffinvest.OrderState)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrderState indicates an expected call of GetOrderState.
-func (mr *MockOrderPlacerMockRecorder) GetOrderState(ctx, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockOrderPlacerMockRecorder) GetOrderState(ctx, accountID, orderID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderState", reflect.TypeOf((*MockOrderPlacer)(nil).GetOrderState), ctx, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderState", reflect.TypeOf((*MockOrderPlacer)(nil).GetOrderState), ctx, accountID, orderID)
 }
 
-// GetTradeAvailableShares mocks base method.
-func (m *MockOrderPlacer) GetTradeAvailableShares(ctx context.Context) ([]tinkoffinvest.Instrument, error) {
+// GetTradeAvailableInstruments mocks base method.
+func (m *MockOrderPlacer) GetTradeAvailableInstruments(ctx context.Context, typ tinkoffinvest.InstrumentType) ([]tinkoffinvest.Instrument, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTradeAvailableShares", ctx)
+	ret := m.ctrl.Call(m, "GetTradeAvailableInstruments", ctx, typ)
 	ret0, _ := ret[0].([]tinkoffinvest.Instrument)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetTradeAvailableShares indicates an expected call of GetTradeAvailableShares.
-func (mr *MockOrderPlacerMockRecorder) GetTradeAvailableShares(ctx interface{}) *gomock.Call {
+// GetTradeAvailableInstruments indicates an expected call of GetTradeAvailableInstruments.
+func (mr *MockOrderPlacerMockRecorder) GetTradeAvailableInstruments(ctx, typ interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTradeAvailableShares", reflect.TypeOf((*MockOrderPlacer)(nil).GetTradeAvailableShares), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTradeAvailableInstruments", reflect.TypeOf((*MockOrderPlacer)(nil).GetTradeAvailableInstruments), ctx, typ)
+}
+
+// GetTradingStatus mocks base method.
+func (m *MockOrderPlacer) GetTradingStatus(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.TradingStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTradingStatus", ctx, figi)
+	ret0, _ := ret[0].(*tinkoffinvest.TradingStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTradingStatus indicates an expected call of GetTradingStatus.
+func (mr *MockOrderPlacerMockRecorder) GetTradingStatus(ctx, figi interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTradingStatus", reflect.TypeOf((*MockOrderPlacer)(nil).GetTradingStatus), ctx, figi)
 }
 
 // PlaceLimitBuyOrder mocks base method.
@@ -126,8 +143,61 @@ func (mr *MockOrderPlacerMockRecorder) PlaceLimitSellOrder(ctx, request interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlaceLimitSellOrder", reflect.TypeOf((*MockOrderPlacer)(nil).PlaceLimitSellOrder), ctx, request)
 }
 
+// SubscribeForOrderFills mocks base method.
+func (m *MockOrderPlacer) SubscribeForOrderFills(ctx context.Context, accounts []tinkoffinvest.AccountID) (<-chan tinkoffinvest.OrderFill, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeForOrderFills", ctx, accounts)
+	ret0, _ := ret[0].(<-chan tinkoffinvest.OrderFill)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeForOrderFills indicates an expected call of SubscribeForOrderFills.
+func (mr *MockOrderPlacerMockRecorder) SubscribeForOrderFills(ctx, accounts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeForOrderFills", reflect.TypeOf((*MockOrderPlacer)(nil).SubscribeForOrderFills), ctx, accounts)
+}
+
+// MockMarketDataProvider is a mock of MarketDataProvider interface.
+type MockMarketDataProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockMarketDataProviderMockRecorder
+}
+
+// MockMarketDataProviderMockRecorder is the mock recorder for MockMarketDataProvider.
+type MockMarketDataProviderMockRecorder struct {
+	mock *MockMarketDataProvider
+}
+
+// NewMockMarketDataProvider creates a new mock instance.
+func NewMockMarketDataProvider(ctrl *gomock.Controller) *MockMarketDataProvider {
+	mock := &MockMarketDataProvider{ctrl: ctrl}
+	mock.recorder = &MockMarketDataProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMarketDataProvider) EXPECT() *MockMarketDataProviderMockRecorder {
+	return m.recorder
+}
+
+// SubscribeForGaps mocks base method.
+func (m *MockMarketDataProvider) SubscribeForGaps(ctx context.Context) (<-chan marketdatahub.Gap, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeForGaps", ctx)
+	ret0, _ := ret[0].(<-chan marketdatahub.Gap)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeForGaps indicates an expected call of SubscribeForGaps.
+func (mr *MockMarketDataProviderMockRecorder) SubscribeForGaps(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeForGaps", reflect.TypeOf((*MockMarketDataProvider)(nil).SubscribeForGaps), ctx)
+}
+
 // SubscribeForOrderBookChanges mocks base method.
-func (m *MockOrderPlacer) SubscribeForOrderBookChanges(ctx context.Context, reqs []tinkoffinvest.OrderBookRequest) (<-chan tinkoffinvest.OrderBookChange, error) {
+func (m *MockMarketDataProvider) SubscribeForOrderBookChanges(ctx context.Context, reqs []tinkoffinvest.OrderBookRequest) (<-chan tinkoffinvest.OrderBookChange, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "SubscribeForOrderBookChanges", ctx, reqs)
 	ret0, _ := ret[0].(<-chan tinkoffinvest.OrderBookChange)
@@ -136,9 +206,62 @@ func (m *MockOrderPlacer) SubscribeForOrderBookChanges(ctx context.Context, reqs
 }
 
 // SubscribeForOrderBookChanges indicates an expected call of SubscribeForOrderBookChanges.
-func (mr *MockOrderPlacerMockRecorder) SubscribeForOrderBookChanges(ctx, reqs interface{}) *gomock.Call {
+func (mr *MockMarketDataProviderMockRecorder) SubscribeForOrderBookChanges(ctx, reqs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeForOrderBookChanges", reflect.TypeOf((*MockMarketDataProvider)(nil).SubscribeForOrderBookChanges), ctx, reqs)
+}
+
+// SubscribeForTradingStatuses mocks base method.
+func (m *MockMarketDataProvider) SubscribeForTradingStatuses(ctx context.Context, figis []tinkoffinvest.FIGI) (<-chan tinkoffinvest.TradingStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeForTradingStatuses", ctx, figis)
+	ret0, _ := ret[0].(<-chan tinkoffinvest.TradingStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeForTradingStatuses indicates an expected call of SubscribeForTradingStatuses.
+func (mr *MockMarketDataProviderMockRecorder) SubscribeForTradingStatuses(ctx, figis interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeForTradingStatuses", reflect.TypeOf((*MockMarketDataProvider)(nil).SubscribeForTradingStatuses), ctx, figis)
+}
+
+// MockExchangeCalendar is a mock of ExchangeCalendar interface.
+type MockExchangeCalendar struct {
+	ctrl     *gomock.Controller
+	recorder *MockExchangeCalendarMockRecorder
+}
+
+// MockExchangeCalendarMockRecorder is the mock recorder for MockExchangeCalendar.
+type MockExchangeCalendarMockRecorder struct {
+	mock *MockExchangeCalendar
+}
+
+// NewMockExchangeCalendar creates a new mock instance.
+func NewMockExchangeCalendar(ctrl *gomock.Controller) *MockExchangeCalendar {
+	mock := &MockExchangeCalendar{ctrl: ctrl}
+	mock.recorder = &MockExchangeCalendarMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExchangeCalendar) EXPECT() *MockExchangeCalendarMockRecorder {
+	return m.recorder
+}
+
+// CurrentOrNextSession mocks base method.
+func (m *MockExchangeCalendar) CurrentOrNextSession(ctx context.Context, exchange string, at time.Time) (exchangecalendar.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CurrentOrNextSession", ctx, exchange, at)
+	ret0, _ := ret[0].(exchangecalendar.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CurrentOrNextSession indicates an expected call of CurrentOrNextSession.
+func (mr *MockExchangeCalendarMockRecorder) CurrentOrNextSession(ctx, exchange, at interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeForOrderBookChanges", reflect.TypeOf((*MockOrderPlacer)(nil).SubscribeForOrderBookChanges), ctx, reqs)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CurrentOrNextSession", reflect.TypeOf((*MockExchangeCalendar)(nil).CurrentOrNextSession), ctx, exchange, at)
 }
 
 // MockToolsCache is a mock of ToolsCache interface.
@@ -12,11 +12,13 @@ import (
 	"github.com/shopspring/decimal"
 
 	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	exchangecalendar "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/exchange-calendar"
+	marketdatahub "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/market-data-hub"
 	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
 	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/common"
 )
 
-//go:generate mockgen -source=$GOFILE -destination=mocks/strategy_generated.go -package spreadparasitemocks OrderPlacer,ToolsCache
+//go:generate mockgen -source=$GOFILE -destination=mocks/strategy_generated.go -package spreadparasitemocks OrderPlacer,MarketDataProvider,ExchangeCalendar,ToolsCache
 
 const (
 	applyingTimeout = 3 * time.Second
@@ -29,17 +31,31 @@ const (
 type l = prometheus.Labels
 
 type OrderPlacer interface {
-	SubscribeForOrderBookChanges(ctx context.Context, reqs []tinkoffinvest.OrderBookRequest) (<-chan tinkoffinvest.OrderBookChange, error) //nolint:lll
-	GetTradeAvailableShares(ctx context.Context) ([]tinkoffinvest.Instrument, error)
+	GetTradeAvailableInstruments(ctx context.Context, typ tinkoffinvest.InstrumentType) ([]tinkoffinvest.Instrument, error)
 	GetOrderBook(ctx context.Context, req tinkoffinvest.OrderBookRequest) (*tinkoffinvest.OrderBookResponse, error)
 
-	GetOrderState(ctx context.Context, _ tinkoffinvest.AccountID, _ tinkoffinvest.OrderID) (decimal.Decimal, error)
+	SubscribeForOrderFills(ctx context.Context, accounts []tinkoffinvest.AccountID) (<-chan tinkoffinvest.OrderFill, error)
 	CancelOrder(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) error
+	GetOrderState(ctx context.Context, accountID tinkoffinvest.AccountID, orderID tinkoffinvest.OrderID) (*tinkoffinvest.OrderState, error) //nolint:lll
+
+	GetTradingStatus(ctx context.Context, figi tinkoffinvest.FIGI) (*tinkoffinvest.TradingStatus, error)
 
 	PlaceLimitSellOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
 	PlaceLimitBuyOrder(ctx context.Context, request tinkoffinvest.PlaceOrderRequest) (tinkoffinvest.OrderID, error)
 }
 
+// MarketDataProvider is implemented by marketdatahub.Hub.
+type MarketDataProvider interface {
+	SubscribeForOrderBookChanges(ctx context.Context, reqs []tinkoffinvest.OrderBookRequest) (<-chan tinkoffinvest.OrderBookChange, error) //nolint:lll
+	SubscribeForTradingStatuses(ctx context.Context, figis []tinkoffinvest.FIGI) (<-chan tinkoffinvest.TradingStatus, error)
+	SubscribeForGaps(ctx context.Context) (<-chan marketdatahub.Gap, error)
+}
+
+// ExchangeCalendar is implemented by exchangecalendar.Calendar.
+type ExchangeCalendar interface {
+	CurrentOrNextSession(ctx context.Context, exchange string, at time.Time) (exchangecalendar.Session, error)
+}
+
 type ToolsCache interface {
 	Get(ctx context.Context, figi tinkoffinvest.FIGI) (toolscache.Tool, error)
 }
@@ -47,17 +63,38 @@ type ToolsCache interface {
 // Strategy consists in placing two counter orders at the spread border
 // with their further adjustment.
 type Strategy struct {
+	name                string
 	account             tinkoffinvest.AccountID
 	ignoreInconsistent  bool
 	figis               []tinkoffinvest.FIGI
+	instrumentTypes     []tinkoffinvest.InstrumentType
 	minSpreadPercentage float64
 
 	orderPlacer OrderPlacer
+	marketData  MarketDataProvider
 	toolsCache  ToolsCache
+	calendar    ExchangeCalendar
+	sessionOpts common.SessionOptions
+	clock       common.Clock
 	logger      zerolog.Logger
 
-	orders      map[tinkoffinvest.FIGI]*ordersPair
-	toolConfigs map[tinkoffinvest.FIGI]toolConfig
+	orders          map[tinkoffinvest.FIGI]*ordersPair
+	toolConfigs     map[tinkoffinvest.FIGI]toolConfig
+	tradingStatuses common.TradingStatuses
+	// exchanges are fetched with tool configs.
+	exchanges map[tinkoffinvest.FIGI]string
+	sessions  *common.TradingSessions
+	// configuredFigis are empty if the instruments are chosen automatically.
+	configuredFigis []tinkoffinvest.FIGI
+	// reconfigs are applied by Run loop.
+	reconfigs chan func(ctx context.Context)
+	// screenings are the instruments chosen again after the spread change.
+	screenings chan screening
+}
+
+type screening struct {
+	minSpreadPercentage float64
+	figis               []tinkoffinvest.FIGI
 }
 
 type ordersPair struct {
@@ -71,8 +108,10 @@ type toolConfig struct {
 }
 
 type order struct {
-	id    tinkoffinvest.OrderID
-	price decimal.Decimal
+	id           tinkoffinvest.OrderID
+	price        decimal.Decimal
+	lots         int
+	executedLots int
 }
 
 func New(
@@ -80,33 +119,208 @@ func New(
 	ignoreInconsistent bool,
 	minSpreadPercentage float64,
 	figis []tinkoffinvest.FIGI,
+	instrumentTypes []tinkoffinvest.InstrumentType,
 	orderPlacer OrderPlacer,
+	marketData MarketDataProvider,
 	toolsCache ToolsCache,
+	calendar ExchangeCalendar,
+	sessionOpts common.SessionOptions,
 ) (*Strategy, error) {
+	return newStrategy(
+		Name,
+		account,
+		ignoreInconsistent,
+		minSpreadPercentage,
+		figis,
+		instrumentTypes,
+		orderPlacer,
+		marketData,
+		toolsCache,
+		calendar,
+		sessionOpts,
+		common.WallClock{},
+	)
+}
+
+func newStrategy(
+	name string,
+	account tinkoffinvest.AccountID,
+	ignoreInconsistent bool,
+	minSpreadPercentage float64,
+	figis []tinkoffinvest.FIGI,
+	instrumentTypes []tinkoffinvest.InstrumentType,
+	orderPlacer OrderPlacer,
+	marketData MarketDataProvider,
+	toolsCache ToolsCache,
+	calendar ExchangeCalendar,
+	sessionOpts common.SessionOptions,
+	clock common.Clock,
+) (*Strategy, error) {
+	if len(instrumentTypes) == 0 {
+		instrumentTypes = []tinkoffinvest.InstrumentType{tinkoffinvest.InstrumentTypeShare}
+	}
+
 	s := &Strategy{
+		name:                name,
 		account:             account,
 		ignoreInconsistent:  ignoreInconsistent,
 		figis:               figis,
+		instrumentTypes:     instrumentTypes,
 		minSpreadPercentage: minSpreadPercentage,
 		orderPlacer:         orderPlacer,
+		marketData:          marketData,
 		toolsCache:          toolsCache,
+		calendar:            calendar,
+		sessionOpts:         sessionOpts,
+		clock:               common.ClockOrWall(clock),
+		exchanges:           make(map[tinkoffinvest.FIGI]string),
 		orders:              make(map[tinkoffinvest.FIGI]*ordersPair),
 		toolConfigs:         make(map[tinkoffinvest.FIGI]toolConfig),
+		configuredFigis:     figis,
+		reconfigs:           make(chan func(ctx context.Context)),
+		screenings:          make(chan screening),
 	}
-	s.logger = log.With().Str("strategy", s.Name()).Logger()
+	s.logger = log.With().Str("strategy", s.Name()).Str("account", account.S()).Logger()
+	s.sessions = common.NewTradingSessions(calendar, sessionOpts, nil)
 
 	return s, nil
 }
 
 func (s *Strategy) Name() string {
-	return "spread-parasite"
+	return s.name
 }
 
-func (s *Strategy) Run(ctx context.Context) error {
+// Reconfigure applies new parameters to the running strategy.
+// Changing the instruments or their types requires restart, common.ErrRestartRequired is returned then.
+// The automatically chosen instruments are chosen again by the new spread in background.
+func (s *Strategy) Reconfigure(
+	ctx context.Context,
+	ignoreInconsistent bool,
+	minSpreadPercentage float64,
+	figis []tinkoffinvest.FIGI,
+	instrumentTypes []tinkoffinvest.InstrumentType,
+) error {
+	if len(instrumentTypes) == 0 {
+		instrumentTypes = []tinkoffinvest.InstrumentType{tinkoffinvest.InstrumentTypeShare}
+	}
+
+	if !equalFIGIs(figis, s.configuredFigis) {
+		return common.ErrRestartRequired
+	}
+	if len(figis) == 0 && !equalInstrumentTypes(instrumentTypes, s.instrumentTypes) {
+		return common.ErrRestartRequired
+	}
+
+	apply := func(ctx context.Context) {
+		s.ignoreInconsistent = ignoreInconsistent
+		if minSpreadPercentage != s.minSpreadPercentage {
+			s.minSpreadPercentage = minSpreadPercentage
+			if len(s.configuredFigis) > 0 {
+				s.logger.Warn().
+					Float64("min_spread_percentage", minSpreadPercentage).
+					Msg("spread is used to choose the instruments only, it has no effect on the configured ones")
+			} else {
+				go s.screen(ctx, minSpreadPercentage)
+			}
+		}
+		s.logger.Info().Msg("strategy reconfigured")
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case s.reconfigs <- apply:
+		return nil
+	}
+}
+
+func equalFIGIs(a, b []tinkoffinvest.FIGI) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalFIGISets(a, b []tinkoffinvest.FIGI) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[tinkoffinvest.FIGI]struct{}, len(a))
+	for _, f := range a {
+		set[f] = struct{}{}
+	}
+	for _, f := range b {
+		if _, ok := set[f]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func equalInstrumentTypes(a, b []tinkoffinvest.InstrumentType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// FIGIs returns configured instruments.
+// It is empty before Run if the instruments are chosen automatically.
+func (s *Strategy) FIGIs() []tinkoffinvest.FIGI {
+	return s.figis
+}
+
+// AdoptOrders takes one limit order for each side of the instrument pair.
+// Must be called before Run.
+func (s *Strategy) AdoptOrders(orders []tinkoffinvest.ActiveOrder) []tinkoffinvest.ActiveOrder {
+	var rest []tinkoffinvest.ActiveOrder
+	for _, o := range orders {
+		if o.Type != tinkoffinvest.OrderTypeLimit || o.LotsRequested != lotsInTrade {
+			rest = append(rest, o)
+			continue
+		}
+
+		pair, ok := s.orders[o.FIGI]
+		if !ok {
+			pair = new(ordersPair)
+			s.orders[o.FIGI] = pair
+		}
+
+		var side *order
+		switch o.Direction {
+		case tinkoffinvest.TradeDirectionSell:
+			side = &pair.toSell
+		case tinkoffinvest.TradeDirectionBuy:
+			side = &pair.toBuy
+		case tinkoffinvest.TradeDirectionUnspecified:
+		}
+		if side == nil || side.id != "" {
+			rest = append(rest, o)
+			continue
+		}
+
+		*side = order{id: o.ID, price: o.Price, lots: o.LotsRequested, executedLots: o.LotsExecuted}
+	}
+	return rest
+}
+
+// Prepare chooses the instruments if they are not configured and fetches their configs, trading statuses and sessions.
+// It is called by Run, the backtest calls it before replaying the market data through Apply.
+func (s *Strategy) Prepare(ctx context.Context) error {
 	if len(s.figis) == 0 {
-		figis, err := s.grepFigisWithEnoughSpread(ctx)
+		figis, err := s.grepFigisWithEnoughSpread(ctx, s.minSpreadPercentage)
 		if err != nil {
-			return fmt.Errorf("grep figis: %v", err)
+			return fmt.Errorf("grep figis: %w", err)
 		}
 
 		s.logger.Debug().Msgf("found figis: %#v", figis)
@@ -114,31 +328,145 @@ func (s *Strategy) Run(ctx context.Context) error {
 	}
 
 	if err := s.fetchToolConfigs(ctx, s.figis); err != nil {
-		return fmt.Errorf("fetch tool configs: %v", err)
+		return fmt.Errorf("fetch tool configs: %w", err)
 	}
 
-	reqs := make([]tinkoffinvest.OrderBookRequest, len(s.figis))
-	for i, f := range s.figis {
-		reqs[i] = tinkoffinvest.OrderBookRequest{FIGI: f, Depth: orderBookDepth}
-		s.orders[f] = new(ordersPair)
+	for _, f := range s.figis {
+		if _, ok := s.orders[f]; !ok { // Could be adopted.
+			s.orders[f] = new(ordersPair)
+		}
+	}
+
+	tradingStatuses, err := common.FetchTradingStatuses(ctx, s.orderPlacer, s.figis)
+	if err != nil {
+		return fmt.Errorf("fetch trading statuses: %w", err)
+	}
+	s.tradingStatuses = tradingStatuses
+
+	s.sessions = common.NewTradingSessions(s.calendar, s.sessionOpts, s.exchanges)
+	s.updateSessions(ctx)
+
+	return nil
+}
+
+func (s *Strategy) Run(ctx context.Context) error {
+	if err := s.Prepare(ctx); err != nil {
+		return err
+	}
+
+	sessionsTimer := time.NewTimer(s.untilSessionsUpdate())
+	defer sessionsTimer.Stop()
+
+	gaps, err := s.marketData.SubscribeForGaps(ctx)
+	if err != nil {
+		return fmt.Errorf("subscribe for gaps: %w", err)
+	}
+
+	fills, err := s.orderPlacer.SubscribeForOrderFills(ctx, []tinkoffinvest.AccountID{s.account})
+	if err != nil {
+		return fmt.Errorf("subscribe for order fills: %w", err)
 	}
 
-	changes, err := s.orderPlacer.SubscribeForOrderBookChanges(ctx, reqs)
+	// The instruments streams are resubscribed when the instruments are chosen again.
+	streams, err := s.subscribe(ctx)
 	if err != nil {
-		return fmt.Errorf("subscribe for order book changes: %v", err)
+		return err
 	}
+	defer func() { streams.cancel() }()
+	statuses, changes := streams.statuses, streams.changes
 
 	for {
+		// No reason to wake up while all the sessions are closed.
+		var idle <-chan time.Time
+		if s.sessions.AnyOpen(s.clock.Now()) {
+			idle = time.After(5 * time.Second)
+		}
+
 		select {
 		case <-ctx.Done():
 			return nil
 
-		case <-time.After(5 * time.Second):
+		case <-idle:
 			s.logger.Debug().Msg("no order book changes due to period")
 
+		case apply := <-s.reconfigs:
+			apply(ctx)
+
+		case sc := <-s.screenings:
+			if sc.minSpreadPercentage != s.minSpreadPercentage {
+				continue // The spread has been changed again, wait for the next screening.
+			}
+			if equalFIGISets(sc.figis, s.figis) {
+				s.logger.Info().Msg("instruments are not changed by the new spread")
+				continue
+			}
+			if err := s.replaceFigis(ctx, sc.figis); err != nil {
+				s.logger.Err(err).Msg("cannot replace instruments, keep the current ones")
+				continue
+			}
+
+			streams.cancel()
+			if streams, err = s.subscribe(ctx); err != nil {
+				return err
+			}
+			statuses, changes = streams.statuses, streams.changes
+
+			if !sessionsTimer.Stop() {
+				<-sessionsTimer.C
+			}
+			sessionsTimer.Reset(s.untilSessionsUpdate())
+
+		case <-sessionsTimer.C:
+			s.updateSessions(ctx)
+			sessionsTimer.Reset(s.untilSessionsUpdate())
+
+		case status, ok := <-statuses:
+			if !ok {
+				s.logger.Warn().Msg("trading statuses stream closed, keep the last known statuses")
+				statuses = nil
+				continue
+			}
+
+			s.logger.Info().
+				Str("figi", status.FIGI.S()).
+				Stringer("trading_status", status.Code).
+				Msg("trading status change")
+			s.tradingStatuses.Set(status)
+
+		case gap, ok := <-gaps:
+			if !ok {
+				gaps = nil
+				continue
+			}
+
+			s.logger.Warn().
+				Time("lost_at", gap.LostAt).
+				Time("restored_at", gap.RestoredAt).
+				Msg("market data gap, refresh trading statuses")
+
+			// Status changes could be missed during the gap.
+			tradingStatuses, err := common.FetchTradingStatuses(ctx, s.orderPlacer, s.figis)
+			if err != nil {
+				s.logger.Err(err).Msg("cannot refresh trading statuses, keep the last known statuses")
+				continue
+			}
+			s.tradingStatuses = tradingStatuses
+
+		case fill, ok := <-fills:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return errors.New("order fills stream closed")
+			}
+			_ = s.ApplyFill(ctx, fill)
+
 		case change, ok := <-changes:
 			if !ok {
-				return nil
+				if ctx.Err() != nil {
+					return nil
+				}
+				return errors.New("order book changes stream closed")
 			}
 
 			func() {
@@ -153,14 +481,112 @@ func (s *Strategy) Run(ctx context.Context) error {
 	}
 }
 
-func (s *Strategy) grepFigisWithEnoughSpread(ctx context.Context) ([]tinkoffinvest.FIGI, error) {
-	tools, err := s.orderPlacer.GetTradeAvailableShares(ctx)
+// instrumentStreams are the market data of the current instruments, cancel unsubscribes from them.
+type instrumentStreams struct {
+	statuses <-chan tinkoffinvest.TradingStatus
+	changes  <-chan tinkoffinvest.OrderBookChange
+	cancel   context.CancelFunc
+}
+
+// subscribe subscribes for trading statuses and order book changes of the current instruments.
+func (s *Strategy) subscribe(ctx context.Context) (instrumentStreams, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	statuses, err := s.marketData.SubscribeForTradingStatuses(ctx, s.figis)
+	if err != nil {
+		cancel()
+		return instrumentStreams{}, fmt.Errorf("subscribe for trading statuses: %w", err)
+	}
+
+	reqs := make([]tinkoffinvest.OrderBookRequest, len(s.figis))
+	for i, f := range s.figis {
+		reqs[i] = tinkoffinvest.OrderBookRequest{FIGI: f, Depth: orderBookDepth}
+	}
+	changes, err := s.marketData.SubscribeForOrderBookChanges(ctx, reqs)
+	if err != nil {
+		cancel()
+		return instrumentStreams{}, fmt.Errorf("subscribe for order book changes: %w", err)
+	}
+	return instrumentStreams{statuses: statuses, changes: changes, cancel: cancel}, nil
+}
+
+// screen chooses the instruments by the spread outside Run loop and passes them to it.
+func (s *Strategy) screen(ctx context.Context, minSpreadPercentage float64) {
+	s.logger.Info().Float64("min_spread_percentage", minSpreadPercentage).Msg("choose instruments again")
+
+	figis, err := s.grepFigisWithEnoughSpread(ctx, minSpreadPercentage)
+	if err != nil {
+		s.logger.Err(err).Msg("cannot choose instruments, keep the current ones")
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+	case s.screenings <- screening{minSpreadPercentage: minSpreadPercentage, figis: figis}:
+	}
+}
+
+// replaceFigis cancels the orders of the instruments not chosen anymore and starts trading the new ones.
+func (s *Strategy) replaceFigis(ctx context.Context, figis []tinkoffinvest.FIGI) error {
+	current := make(map[tinkoffinvest.FIGI]struct{}, len(s.figis))
+	for _, f := range s.figis {
+		current[f] = struct{}{}
+	}
+	chosen := make(map[tinkoffinvest.FIGI]struct{}, len(figis))
+	var added []tinkoffinvest.FIGI
+	for _, f := range figis {
+		chosen[f] = struct{}{}
+		if _, ok := current[f]; !ok {
+			added = append(added, f)
+		}
+	}
+
+	if err := s.fetchToolConfigs(ctx, added); err != nil {
+		return fmt.Errorf("fetch tool configs: %w", err)
+	}
+	tradingStatuses, err := common.FetchTradingStatuses(ctx, s.orderPlacer, figis)
 	if err != nil {
-		return nil, fmt.Errorf("get available instruments: %v", err)
+		return fmt.Errorf("fetch trading statuses: %w", err)
+	}
+
+	for _, f := range s.figis {
+		if _, ok := chosen[f]; ok {
+			continue
+		}
+		s.cancelOrders(ctx, f)
+		delete(s.orders, f)
+		delete(s.toolConfigs, f)
+		delete(s.exchanges, f)
+	}
+	for _, f := range added {
+		s.orders[f] = new(ordersPair)
+	}
+
+	s.logger.Info().Msgf("instruments are chosen again: %#v", figis)
+	s.figis = figis
+	s.tradingStatuses = tradingStatuses
+	s.sessions = common.NewTradingSessions(s.calendar, s.sessionOpts, s.exchanges)
+	s.updateSessions(ctx)
+	return nil
+}
+
+func (s *Strategy) grepFigisWithEnoughSpread(ctx context.Context, minSpreadPercentage float64) ([]tinkoffinvest.FIGI, error) {
+	var tools []tinkoffinvest.Instrument
+	for _, typ := range s.instrumentTypes {
+		instruments, err := s.orderPlacer.GetTradeAvailableInstruments(ctx, typ)
+		if err != nil {
+			return nil, fmt.Errorf("get available instruments of type %q: %w", typ, err)
+		}
+		tools = append(tools, instruments...)
 	}
 
 	figis := make([]tinkoffinvest.FIGI, 0, len(tools))
 	for _, t := range tools {
+		// Order book requests are throttled by the client according to the tariff.
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+
 		logger := s.logger.With().Str("figi", t.FIGI.S()).Logger()
 
 		orderBook, err := s.orderPlacer.GetOrderBook(ctx, tinkoffinvest.OrderBookRequest{
@@ -179,18 +605,15 @@ func (s *Strategy) grepFigisWithEnoughSpread(ctx context.Context) ([]tinkoffinve
 			Float64("spread", spread).
 			Msg("spread")
 
-		if spread >= s.minSpreadPercentage {
+		if spread >= minSpreadPercentage {
 			figis = append(figis, t.FIGI)
 		}
-
-		select {
-		case <-ctx.Done():
-			return nil, nil
-		case <-time.After(400 * time.Millisecond):
-		}
 	}
 
-	return figis[:maxTools], nil
+	if len(figis) > maxTools {
+		figis = figis[:maxTools]
+	}
+	return figis, nil
 }
 
 func (s *Strategy) fetchToolConfigs(ctx context.Context, figis []tinkoffinvest.FIGI) error {
@@ -199,7 +622,7 @@ func (s *Strategy) fetchToolConfigs(ctx context.Context, figis []tinkoffinvest.F
 	for _, f := range figis {
 		tool, err := s.toolsCache.Get(ctx, f)
 		if err != nil {
-			return fmt.Errorf("get cached tool %v: %v", f, err)
+			return fmt.Errorf("get cached tool %v: %w", f, err)
 		}
 		if tool.MinPriceInc.IsZero() {
 			return fmt.Errorf("tool %v: zero min price increment", tool.FIGI)
@@ -207,6 +630,10 @@ func (s *Strategy) fetchToolConfigs(ctx context.Context, figis []tinkoffinvest.F
 		if tool.StocksPerLot <= 0 {
 			return fmt.Errorf("tool %v: invalid stocks per lot amount", tool.FIGI)
 		}
+		if tool.IsExpired(s.clock.Now()) {
+			return fmt.Errorf("tool %v: expired at %v", tool.FIGI, tool.ExpirationDate)
+		}
+		s.exchanges[tool.FIGI] = tool.Exchange
 
 		s.toolConfigs[f] = toolConfig{
 			stocksPerLot: tool.StocksPerLot,
@@ -216,6 +643,117 @@ func (s *Strategy) fetchToolConfigs(ctx context.Context, figis []tinkoffinvest.F
 	return nil
 }
 
+// updateSessions pauses trading of instruments before their session close and resumes it at open.
+func (s *Strategy) updateSessions(ctx context.Context) {
+	paused, resumed, err := s.sessions.Update(ctx, s.clock.Now())
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("cannot update trading sessions, consider instruments traded")
+	}
+
+	for _, f := range paused {
+		s.logger.Info().Str("figi", f.S()).Msg("pause trading before session close")
+		if s.sessions.CancelOrdersOnClose() {
+			s.cancelOrders(ctx, f)
+		}
+	}
+	for _, f := range resumed {
+		s.logger.Info().Str("figi", f.S()).Msg("resume trading on session open")
+	}
+}
+
+func (s *Strategy) untilSessionsUpdate() time.Duration {
+	now := s.clock.Now()
+	return s.sessions.NextUpdate(now).Sub(now)
+}
+
+// cancelOrders cancels resting orders of the instrument, so new ones are placed after the resume.
+func (s *Strategy) cancelOrders(ctx context.Context, figi tinkoffinvest.FIGI) {
+	pair, ok := s.orders[figi]
+	if !ok {
+		return
+	}
+
+	for _, o := range []*order{&pair.toSell, &pair.toBuy} {
+		if o.id == "" {
+			continue
+		}
+
+		if err := s.orderPlacer.CancelOrder(ctx, s.account, o.id); err != nil {
+			s.logger.Warn().Str("order_id", o.id.S()).Err(err).Msg("cancel order")
+			continue
+		}
+		s.logger.Info().Str("figi", figi.S()).Str("order_id", o.id.S()).Msg("cancel order on session close")
+		*o = order{}
+	}
+}
+
+// cancelForReplace cancels the order and returns the lots to place instead of it.
+// Zero means that the order is (or could be) still active or has been filled, so it must not be replaced.
+// The filled order is forgotten, as its fill could be missed, so the next order book change places the new one.
+func (s *Strategy) cancelForReplace(ctx context.Context, o *order, logger zerolog.Logger) int {
+	if o.id == "" {
+		return lotsInTrade
+	}
+
+	cancelErr := s.orderPlacer.CancelOrder(ctx, s.account, o.id)
+	if cancelErr == nil && o.executedLots == 0 {
+		return lotsInTrade
+	}
+	if cancelErr != nil {
+		logger.Warn().Str("order_id", o.id.S()).Err(cancelErr).Msg("cancel order")
+	}
+
+	// The order could be executed partially or completely before the cancellation.
+	state, err := s.orderPlacer.GetOrderState(ctx, s.account, o.id)
+	if err != nil {
+		logger.Warn().Str("order_id", o.id.S()).Err(err).Msg("get order state")
+		return 0
+	}
+
+	switch state.Status {
+	case tinkoffinvest.OrderStatusCancelled, tinkoffinvest.OrderStatusRejected:
+		return state.LotsLeft()
+	case tinkoffinvest.OrderStatusFilled:
+		logger.Info().
+			Str("order_id", o.id.S()).
+			Int("lots", o.lots-o.executedLots).
+			Msg("order is found filled")
+		*o = order{}
+	case tinkoffinvest.OrderStatusUnspecified,
+		tinkoffinvest.OrderStatusNew,
+		tinkoffinvest.OrderStatusPartiallyFilled:
+	}
+	return 0
+}
+
+// ApplyFill forgets the completely executed order, so the next order book change places the new one.
+// It never fails, the error is returned for the same signature with other strategies.
+func (s *Strategy) ApplyFill(_ context.Context, fill tinkoffinvest.OrderFill) error {
+	pair, ok := s.orders[fill.FIGI]
+	if !ok {
+		return nil
+	}
+
+	for _, o := range []*order{&pair.toSell, &pair.toBuy} {
+		if o.id == "" || o.id != fill.OrderID {
+			continue
+		}
+
+		s.logger.Info().
+			Str("figi", fill.FIGI.S()).
+			Str("order_id", fill.OrderID.S()).
+			Stringer("direction", fill.Direction).
+			Str("price", fill.Price.String()).
+			Int("lots", fill.Lots).
+			Msg("order fill")
+
+		if o.executedLots += fill.Lots; o.executedLots >= o.lots {
+			*o = order{}
+		}
+	}
+	return nil
+}
+
 func (s *Strategy) Apply(ctx context.Context, change tinkoffinvest.OrderBookChange) error {
 	logger := s.logger.With().Str("figi", change.FIGI.S()).Logger()
 
@@ -229,14 +767,26 @@ func (s *Strategy) Apply(ctx context.Context, change tinkoffinvest.OrderBookChan
 		return fmt.Errorf("not found config for tool %q", change.FIGI)
 	}
 
+	if !s.tradingStatuses.IsNormalTrading(change.FIGI) {
+		logger.Debug().
+			Stringer("trading_status", s.tradingStatuses.Code(change.FIGI)).
+			Msg("ignore order book change: no normal trading")
+		return nil
+	}
+
+	if !s.sessions.IsOpen(change.FIGI, s.clock.Now()) {
+		logger.Debug().Msg("ignore order book change: trading session is closed")
+		return nil
+	}
+
 	pair := s.orders[change.FIGI]
 
 	if err := s.correctSellOrder(ctx, pair, change, conf, logger); err != nil {
-		return fmt.Errorf("correct sell order: %s: %v", change.FIGI, err)
+		return fmt.Errorf("correct sell order: %s: %w", change.FIGI, err)
 	}
 
 	if err := s.correctBuyOrder(ctx, pair, change, conf, logger); err != nil {
-		return fmt.Errorf("correct buy order: %s: %v", change.FIGI, err)
+		return fmt.Errorf("correct buy order: %s: %w", change.FIGI, err)
 	}
 
 	return nil
@@ -252,13 +802,6 @@ func (s *Strategy) correctSellOrder(
 	bestPrice := tinkoffinvest.BestPriceForBuy(change.OrderBook)
 	bestPriceGauge.With(l{"best_price_type": bestPriceTypeToBuy, "figi": change.FIGI.S()}).Set(bestPrice.InexactFloat64())
 
-	if orderID := pair.toSell.id; orderID != "" {
-		_, err := s.orderPlacer.GetOrderState(ctx, s.account, orderID)
-		if !errors.Is(err, tinkoffinvest.ErrOrderWaitExecution) {
-			pair.toSell = order{}
-		}
-	}
-
 	currentPrice := pair.toSell.price
 	if ok := currentPrice.IsZero() || currentPrice.GreaterThan(bestPrice); !ok {
 		return nil
@@ -266,23 +809,23 @@ func (s *Strategy) correctSellOrder(
 
 	bestPrice = bestPrice.Sub(conf.minPriceInc)
 
-	if orderID := pair.toSell.id; orderID != "" {
-		if err := s.orderPlacer.CancelOrder(ctx, s.account, orderID); err != nil {
-			s.logger.Warn().Str("order_id", orderID.S()).Err(err).Msg("cancel order")
-		}
+	lots := s.cancelForReplace(ctx, &pair.toSell, logger)
+	if lots == 0 {
+		return nil
 	}
 
 	orderID, err := s.orderPlacer.PlaceLimitSellOrder(ctx, tinkoffinvest.PlaceOrderRequest{
 		AccountID: s.account,
 		FIGI:      change.FIGI,
-		Lots:      lotsInTrade,
+		Lots:      lots,
 		Price:     bestPrice,
 	})
 	if err != nil {
-		if errors.Is(err, tinkoffinvest.ErrNotEnoughStocks) {
+		if common.IsOrderRefused(err) {
+			logger.Warn().Err(err).Msg("order refused")
 			return nil
 		}
-		return fmt.Errorf("place limit sell order: %v", err)
+		return fmt.Errorf("place limit sell order: %w", err)
 	}
 
 	common.CollectOrderPrice(bestPrice.InexactFloat64(), s.Name(), change.FIGI, common.OrderTypeLimitSell)
@@ -291,7 +834,7 @@ func (s *Strategy) correctSellOrder(
 		Str("order_id", orderID.S()).
 		Msg("place limit sell order")
 
-	pair.toSell = order{id: orderID, price: bestPrice}
+	pair.toSell = order{id: orderID, price: bestPrice, lots: lots}
 	return nil
 }
 
@@ -305,13 +848,6 @@ func (s *Strategy) correctBuyOrder(
 	bestPrice := tinkoffinvest.BestPriceForSell(change.OrderBook)
 	bestPriceGauge.With(l{"best_price_type": bestPriceTypeToSell, "figi": change.FIGI.S()}).Set(bestPrice.InexactFloat64())
 
-	if orderID := pair.toBuy.id; orderID != "" {
-		_, err := s.orderPlacer.GetOrderState(ctx, s.account, orderID)
-		if !errors.Is(err, tinkoffinvest.ErrOrderWaitExecution) {
-			pair.toBuy = order{}
-		}
-	}
-
 	currentPrice := pair.toBuy.price
 	if ok := currentPrice.IsZero() || currentPrice.LessThan(bestPrice); !ok {
 		return nil
@@ -319,23 +855,23 @@ func (s *Strategy) correctBuyOrder(
 
 	bestPrice = bestPrice.Add(conf.minPriceInc)
 
-	if orderID := pair.toBuy.id; orderID != "" {
-		if err := s.orderPlacer.CancelOrder(ctx, s.account, orderID); err != nil {
-			s.logger.Warn().Str("order_id", orderID.S()).Err(err).Msg("cancel order")
-		}
+	lots := s.cancelForReplace(ctx, &pair.toBuy, logger)
+	if lots == 0 {
+		return nil
 	}
 
 	orderID, err := s.orderPlacer.PlaceLimitBuyOrder(ctx, tinkoffinvest.PlaceOrderRequest{
 		AccountID: s.account,
 		FIGI:      change.FIGI,
-		Lots:      lotsInTrade,
+		Lots:      lots,
 		Price:     bestPrice,
 	})
 	if err != nil {
-		if errors.Is(err, tinkoffinvest.ErrNotEnoughStocks) {
+		if common.IsOrderRefused(err) {
+			logger.Warn().Err(err).Msg("order refused")
 			return nil
 		}
-		return fmt.Errorf("place limit buy order: %v", err)
+		return fmt.Errorf("place limit buy order: %w", err)
 	}
 
 	common.CollectOrderPrice(bestPrice.InexactFloat64(), s.Name(), change.FIGI, common.OrderTypeLimitBuy)
@@ -344,6 +880,6 @@ func (s *Strategy) correctBuyOrder(
 		Str("order_id", orderID.S()).
 		Msg("place limit buy order")
 
-	pair.toBuy = order{id: orderID, price: bestPrice}
+	pair.toBuy = order{id: orderID, price: bestPrice, lots: lots}
 	return nil
 }
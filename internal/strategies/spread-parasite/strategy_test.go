@@ -2,15 +2,20 @@ package spreadparasite_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/clients/tinkoffinvest"
+	exchangecalendar "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/exchange-calendar"
+	marketdatahub "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/market-data-hub"
 	toolscache "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/services/tools-cache"
+	"github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/common"
 	spreadparasite "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/spread-parasite"
 	spreadparasitemocks "github.com/Antonboom/tinkoff-invest-robot-contest-2022/internal/strategies/spread-parasite/mocks"
 )
@@ -34,9 +39,12 @@ func TestStrategy(t *testing.T) {
 	defer ctrl.Finish()
 
 	orderPlacer := spreadparasitemocks.NewMockOrderPlacer(ctrl)
+	marketData := spreadparasitemocks.NewMockMarketDataProvider(ctrl)
 	toolsCache := spreadparasitemocks.NewMockToolsCache(ctrl)
+	calendar := spreadparasitemocks.NewMockExchangeCalendar(ctrl)
 
-	s, err := spreadparasite.New(accountID, false, minSpreadPercentage, figis, orderPlacer, toolsCache)
+	s, err := spreadparasite.New(accountID, false, minSpreadPercentage, figis, nil,
+		orderPlacer, marketData, toolsCache, calendar, common.SessionOptions{})
 	require.NoError(t, err)
 
 	// Run strategy.
@@ -56,8 +64,24 @@ func TestStrategy(t *testing.T) {
 		MinPriceInc:  d("5"),
 	}, nil)
 
+	for _, f := range figis {
+		orderPlacer.EXPECT().GetTradingStatus(gomock.Any(), f).Return(&tinkoffinvest.TradingStatus{
+			FIGI: f,
+			Code: tinkoffinvest.TradingStatusNormalTrading,
+		}, nil)
+	}
+
+	gaps := make(chan marketdatahub.Gap)
+	marketData.EXPECT().SubscribeForGaps(gomock.Any()).Return(gaps, nil)
+
+	statuses := make(chan tinkoffinvest.TradingStatus)
+	marketData.EXPECT().SubscribeForTradingStatuses(gomock.Any(), figis).Return(statuses, nil)
+
+	fills := make(chan tinkoffinvest.OrderFill)
+	orderPlacer.EXPECT().SubscribeForOrderFills(gomock.Any(), []tinkoffinvest.AccountID{accountID}).Return(fills, nil)
+
 	changes := make(chan tinkoffinvest.OrderBookChange)
-	orderPlacer.EXPECT().SubscribeForOrderBookChanges(gomock.Any(), []tinkoffinvest.OrderBookRequest{
+	marketData.EXPECT().SubscribeForOrderBookChanges(gomock.Any(), []tinkoffinvest.OrderBookRequest{
 		{FIGI: figis[0], Depth: 1},
 		{FIGI: figis[1], Depth: 1},
 	}).Return(changes, nil)
@@ -108,9 +132,6 @@ func TestStrategy(t *testing.T) {
 	oid3 := tinkoffinvest.OrderID("oid3")
 
 	t.Run("new best price for buy", func(t *testing.T) {
-		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid1).Return(decimal.Zero, tinkoffinvest.ErrOrderWaitExecution)
-		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid2).Return(decimal.Zero, tinkoffinvest.ErrOrderWaitExecution)
-
 		orderPlacer.EXPECT().CancelOrder(gomock.Any(), accountID, oid2).Return(nil)
 		orderPlacer.EXPECT().PlaceLimitBuyOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
 			AccountID: accountID,
@@ -139,9 +160,6 @@ func TestStrategy(t *testing.T) {
 	oid4 := tinkoffinvest.OrderID("oid4")
 
 	t.Run("new best price for sell", func(t *testing.T) {
-		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid1).Return(decimal.Zero, tinkoffinvest.ErrOrderWaitExecution)
-		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid3).Return(decimal.Zero, tinkoffinvest.ErrOrderWaitExecution)
-
 		orderPlacer.EXPECT().CancelOrder(gomock.Any(), accountID, oid1).Return(nil)
 		orderPlacer.EXPECT().PlaceLimitSellOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
 			AccountID: accountID,
@@ -168,9 +186,6 @@ func TestStrategy(t *testing.T) {
 	})
 
 	t.Run("no new prices and current orders is alive", func(t *testing.T) {
-		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid3).Return(decimal.Zero, tinkoffinvest.ErrOrderWaitExecution)
-		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid4).Return(decimal.Zero, tinkoffinvest.ErrOrderWaitExecution)
-
 		changes <- tinkoffinvest.OrderBookChange{
 			OrderBook: tinkoffinvest.OrderBook{
 				FIGI: figis[0],
@@ -192,8 +207,24 @@ func TestStrategy(t *testing.T) {
 	oid6 := tinkoffinvest.OrderID("oid6")
 
 	t.Run("no new prices and current orders executed", func(t *testing.T) {
-		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid3).Return(d("120.350000000"), nil)
-		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid4).Return(d("120.780000000"), nil)
+		fills <- tinkoffinvest.OrderFill{
+			AccountID: accountID,
+			OrderID:   oid3,
+			FIGI:      figis[0],
+			Direction: tinkoffinvest.TradeDirectionBuy,
+			Price:     d("120.360000000"),
+			Lots:      1,
+			Time:      time.Now(),
+		}
+		fills <- tinkoffinvest.OrderFill{
+			AccountID: accountID,
+			OrderID:   oid4,
+			FIGI:      figis[0],
+			Direction: tinkoffinvest.TradeDirectionSell,
+			Price:     d("120.770000000"),
+			Lots:      1,
+			Time:      time.Now(),
+		}
 
 		orderPlacer.EXPECT().PlaceLimitBuyOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
 			AccountID: accountID,
@@ -230,32 +261,31 @@ func TestStrategy(t *testing.T) {
 	oid8 := tinkoffinvest.OrderID("oid8")
 
 	t.Run("new best price", func(t *testing.T) {
-		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid5).Return(decimal.Zero, tinkoffinvest.ErrOrderRejected)
-		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid6).Return(decimal.Zero, tinkoffinvest.ErrOrderRejected)
-
+		orderPlacer.EXPECT().CancelOrder(gomock.Any(), accountID, oid5).Return(nil)
 		orderPlacer.EXPECT().PlaceLimitBuyOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
 			AccountID: accountID,
 			FIGI:      figis[0],
 			Lots:      1,
-			Price:     d("120.370000000"),
+			Price:     d("120.380000000"),
 		}).Return(oid7, nil)
 
+		orderPlacer.EXPECT().CancelOrder(gomock.Any(), accountID, oid6).Return(nil)
 		orderPlacer.EXPECT().PlaceLimitSellOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
 			AccountID: accountID,
 			FIGI:      figis[0],
 			Lots:      1,
-			Price:     d("120.760000000"),
+			Price:     d("120.750000000"),
 		}).Return(oid8, nil)
 
 		changes <- tinkoffinvest.OrderBookChange{
 			OrderBook: tinkoffinvest.OrderBook{
 				FIGI: figis[0],
 				Bids: []tinkoffinvest.Order{{
-					Price: d("120.360000000"),
+					Price: d("120.370000000"),
 					Lots:  15,
 				}},
 				Asks: []tinkoffinvest.Order{{
-					Price: d("120.770000000"),
+					Price: d("120.760000000"),
 					Lots:  15,
 				}},
 			},
@@ -299,8 +329,418 @@ func TestStrategy(t *testing.T) {
 		}
 	})
 
+	oid11 := tinkoffinvest.OrderID("oid11")
+
+	t.Run("order state is checked if cancel failed", func(t *testing.T) {
+		orderPlacer.EXPECT().CancelOrder(gomock.Any(), accountID, oid9).Return(errors.New("unexpected"))
+		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid9).Return(&tinkoffinvest.OrderState{
+			ID:            oid9,
+			FIGI:          figis[1],
+			Status:        tinkoffinvest.OrderStatusCancelled,
+			LotsRequested: 1,
+		}, nil)
+		orderPlacer.EXPECT().PlaceLimitSellOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
+			AccountID: accountID,
+			FIGI:      figis[1],
+			Lots:      1,
+			Price:     d("85"),
+		}).Return(oid11, nil)
+
+		// Filled order must not be replaced, but is forgotten.
+		orderPlacer.EXPECT().CancelOrder(gomock.Any(), accountID, oid10).Return(errors.New("unexpected"))
+		orderPlacer.EXPECT().GetOrderState(gomock.Any(), accountID, oid10).Return(&tinkoffinvest.OrderState{
+			ID:            oid10,
+			FIGI:          figis[1],
+			Status:        tinkoffinvest.OrderStatusFilled,
+			LotsRequested: 1,
+			LotsExecuted:  1,
+		}, nil)
+
+		changes <- tinkoffinvest.OrderBookChange{
+			OrderBook: tinkoffinvest.OrderBook{
+				FIGI: figis[1],
+				Bids: []tinkoffinvest.Order{{
+					Price: d("70"),
+					Lots:  55,
+				}},
+				Asks: []tinkoffinvest.Order{{
+					Price: d("90"),
+					Lots:  66,
+				}},
+			},
+			IsConsistent: true,
+			FormedAt:     time.Now(),
+		}
+	})
+
+	oid12 := tinkoffinvest.OrderID("oid12")
+
+	t.Run("filled order is replaced by next change", func(t *testing.T) {
+		orderPlacer.EXPECT().PlaceLimitBuyOrder(gomock.Any(), tinkoffinvest.PlaceOrderRequest{
+			AccountID: accountID,
+			FIGI:      figis[1],
+			Lots:      1,
+			Price:     d("75"),
+		}).Return(oid12, nil)
+
+		changes <- tinkoffinvest.OrderBookChange{
+			OrderBook: tinkoffinvest.OrderBook{
+				FIGI: figis[1],
+				Bids: []tinkoffinvest.Order{{
+					Price: d("70"),
+					Lots:  55,
+				}},
+				Asks: []tinkoffinvest.Order{{
+					Price: d("90"),
+					Lots:  66,
+				}},
+			},
+			IsConsistent: true,
+			FormedAt:     time.Now(),
+		}
+	})
+
+	t.Run("trading break", func(t *testing.T) {
+		statuses <- tinkoffinvest.TradingStatus{
+			FIGI: figis[1],
+			Code: tinkoffinvest.TradingStatusBreakInTrading,
+			Time: time.Now(),
+		}
+
+		changes <- tinkoffinvest.OrderBookChange{
+			OrderBook: tinkoffinvest.OrderBook{
+				FIGI: figis[1],
+				Bids: []tinkoffinvest.Order{{
+					Price: d("70"),
+					Lots:  55,
+				}},
+				Asks: []tinkoffinvest.Order{{
+					Price: d("90"),
+					Lots:  66,
+				}},
+			},
+			IsConsistent: true,
+			FormedAt:     time.Now(),
+		}
+	})
+
+	t.Run("trading statuses are refreshed after gap", func(t *testing.T) {
+		orderPlacer.EXPECT().GetTradingStatus(gomock.Any(), figis[0]).Return(&tinkoffinvest.TradingStatus{
+			FIGI: figis[0],
+			Code: tinkoffinvest.TradingStatusNormalTrading,
+		}, nil)
+		orderPlacer.EXPECT().GetTradingStatus(gomock.Any(), figis[1]).Return(&tinkoffinvest.TradingStatus{
+			FIGI: figis[1],
+			Code: tinkoffinvest.TradingStatusNotAvailableForTrading,
+		}, nil)
+
+		gaps <- marketdatahub.Gap{LostAt: time.Now().Add(-time.Minute), RestoredAt: time.Now()}
+
+		changes <- tinkoffinvest.OrderBookChange{
+			OrderBook: tinkoffinvest.OrderBook{
+				FIGI: figis[1],
+				Bids: []tinkoffinvest.Order{{
+					Price: d("70"),
+					Lots:  55,
+				}},
+				Asks: []tinkoffinvest.Order{{
+					Price: d("90"),
+					Lots:  66,
+				}},
+			},
+			IsConsistent: true,
+			FormedAt:     time.Now(),
+		}
+	})
+
 	// Shutdown.
 
 	cancel()
 	<-done
 }
+
+func TestStrategy_TradingSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orderPlacer := spreadparasitemocks.NewMockOrderPlacer(ctrl)
+	marketData := spreadparasitemocks.NewMockMarketDataProvider(ctrl)
+	toolsCache := spreadparasitemocks.NewMockToolsCache(ctrl)
+	calendar := spreadparasitemocks.NewMockExchangeCalendar(ctrl)
+
+	const pauseBeforeClose = time.Minute
+	figi := figis[0]
+
+	s, err := spreadparasite.New(accountID, false, minSpreadPercentage, []tinkoffinvest.FIGI{figi}, nil,
+		orderPlacer, marketData, toolsCache, calendar, common.SessionOptions{
+			PauseBeforeClose:    pauseBeforeClose,
+			CancelOrdersOnClose: true,
+		})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	toolsCache.EXPECT().Get(gomock.Any(), figi).Return(toolscache.Tool{
+		FIGI:         figi,
+		Exchange:     "MOEX",
+		StocksPerLot: stocksPerLot,
+		MinPriceInc:  d("0.01"),
+	}, nil)
+	orderPlacer.EXPECT().GetTradingStatus(gomock.Any(), figi).Return(&tinkoffinvest.TradingStatus{
+		FIGI: figi,
+		Code: tinkoffinvest.TradingStatusNormalTrading,
+	}, nil)
+
+	// The pause starts soon after the strategy start.
+	closeAt := time.Now().Add(pauseBeforeClose + 300*time.Millisecond)
+	calendar.EXPECT().CurrentOrNextSession(gomock.Any(), "MOEX", gomock.Any()).Return(exchangecalendar.Session{
+		Exchange: "MOEX",
+		Kind:     exchangecalendar.SessionMain,
+		Start:    time.Now().Add(-time.Hour),
+		End:      closeAt,
+	}, nil)
+
+	marketData.EXPECT().SubscribeForGaps(gomock.Any()).Return(make(chan marketdatahub.Gap), nil)
+	marketData.EXPECT().SubscribeForTradingStatuses(gomock.Any(), gomock.Any()).
+		Return(make(chan tinkoffinvest.TradingStatus), nil)
+	orderPlacer.EXPECT().SubscribeForOrderFills(gomock.Any(), gomock.Any()).
+		Return(make(chan tinkoffinvest.OrderFill), nil)
+	changes := make(chan tinkoffinvest.OrderBookChange)
+	marketData.EXPECT().SubscribeForOrderBookChanges(gomock.Any(), gomock.Any()).Return(changes, nil)
+
+	// All the expectations are set before the run, because the session timer calls the mocks concurrently.
+	orderPlacer.EXPECT().PlaceLimitSellOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("oid1"), nil)
+	orderPlacer.EXPECT().PlaceLimitBuyOrder(gomock.Any(), gomock.Any()).Return(tinkoffinvest.OrderID("oid2"), nil)
+
+	cancelled := make(chan struct{}, 2)
+	orderPlacer.EXPECT().CancelOrder(gomock.Any(), accountID, tinkoffinvest.OrderID("oid1")).
+		DoAndReturn(func(context.Context, tinkoffinvest.AccountID, tinkoffinvest.OrderID) error {
+			cancelled <- struct{}{}
+			return nil
+		})
+	orderPlacer.EXPECT().CancelOrder(gomock.Any(), accountID, tinkoffinvest.OrderID("oid2")).
+		DoAndReturn(func(context.Context, tinkoffinvest.AccountID, tinkoffinvest.OrderID) error {
+			cancelled <- struct{}{}
+			return nil
+		})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.Run(ctx)
+	}()
+
+	change := tinkoffinvest.OrderBookChange{
+		OrderBook: tinkoffinvest.OrderBook{
+			FIGI: figi,
+			Bids: []tinkoffinvest.Order{{Price: d("120.33"), Lots: 12}},
+			Asks: []tinkoffinvest.Order{{Price: d("120.80"), Lots: 1}},
+		},
+		IsConsistent: true,
+		FormedAt:     time.Now(),
+	}
+
+	t.Run("orders are placed during session", func(t *testing.T) {
+		changes <- change
+	})
+
+	t.Run("orders are cancelled before close", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			select {
+			case <-cancelled:
+			case <-time.After(3 * time.Second):
+				t.Fatal("orders are not cancelled")
+			}
+		}
+	})
+
+	t.Run("no orders during pause", func(t *testing.T) {
+		changes <- change
+	})
+
+	cancel()
+	<-done
+}
+
+func TestStrategy_AdoptOrders(t *testing.T) {
+	s, err := spreadparasite.New(accountID, false, minSpreadPercentage, figis, nil, nil, nil, nil, nil, common.SessionOptions{})
+	require.NoError(t, err)
+	require.Equal(t, figis, s.FIGIs())
+
+	sell := tinkoffinvest.ActiveOrder{
+		ID:            "oid1",
+		FIGI:          figis[0],
+		Type:          tinkoffinvest.OrderTypeLimit,
+		Direction:     tinkoffinvest.TradeDirectionSell,
+		LotsRequested: 1,
+		Price:         d("120.5"),
+	}
+	buy := tinkoffinvest.ActiveOrder{
+		ID:            "oid2",
+		FIGI:          figis[0],
+		Type:          tinkoffinvest.OrderTypeLimit,
+		Direction:     tinkoffinvest.TradeDirectionBuy,
+		LotsRequested: 1,
+		Price:         d("120.1"),
+	}
+	extraSell := sell
+	extraSell.ID = "oid3"
+	market := buy
+	market.ID, market.Type = "oid4", tinkoffinvest.OrderTypeMarket
+	bigBuy := buy
+	bigBuy.ID, bigBuy.FIGI, bigBuy.LotsRequested = "oid5", figis[1], 3
+
+	rest := s.AdoptOrders([]tinkoffinvest.ActiveOrder{sell, extraSell, market, buy, bigBuy})
+	require.Equal(t, []tinkoffinvest.ActiveOrder{extraSell, market, bigBuy}, rest)
+}
+
+func TestStrategy_Reconfigure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("configured figis", func(t *testing.T) {
+		s, err := spreadparasite.New(accountID, false, minSpreadPercentage, figis, nil,
+			nil, nil, nil, nil, common.SessionOptions{})
+		require.NoError(t, err)
+
+		err = s.Reconfigure(ctx, false, minSpreadPercentage, figis[:1], nil)
+		require.ErrorIs(t, err, common.ErrRestartRequired)
+
+		// Spread is not used to choose the configured instruments.
+		err = s.Reconfigure(ctx, true, 0.05, figis, []tinkoffinvest.InstrumentType{tinkoffinvest.InstrumentTypeETF})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("automatically chosen figis", func(t *testing.T) {
+		s, err := spreadparasite.New(accountID, false, minSpreadPercentage, nil, nil,
+			nil, nil, nil, nil, common.SessionOptions{})
+		require.NoError(t, err)
+
+		err = s.Reconfigure(ctx, false, minSpreadPercentage, nil, []tinkoffinvest.InstrumentType{tinkoffinvest.InstrumentTypeETF})
+		require.ErrorIs(t, err, common.ErrRestartRequired)
+
+		err = s.Reconfigure(ctx, true, 0.05, nil, []tinkoffinvest.InstrumentType{tinkoffinvest.InstrumentTypeShare})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestStrategy_Reconfigure_MinSpread(t *testing.T) {
+	type mocks struct {
+		orderPlacer *spreadparasitemocks.MockOrderPlacer
+		marketData  *spreadparasitemocks.MockMarketDataProvider
+		toolsCache  *spreadparasitemocks.MockToolsCache
+	}
+
+	newMocks := func(t *testing.T) mocks {
+		t.Helper()
+
+		ctrl := gomock.NewController(t)
+		m := mocks{
+			orderPlacer: spreadparasitemocks.NewMockOrderPlacer(ctrl),
+			marketData:  spreadparasitemocks.NewMockMarketDataProvider(ctrl),
+			toolsCache:  spreadparasitemocks.NewMockToolsCache(ctrl),
+		}
+		for _, f := range figis {
+			m.toolsCache.EXPECT().Get(gomock.Any(), f).
+				Return(toolscache.Tool{FIGI: f, StocksPerLot: stocksPerLot, MinPriceInc: d("0.01")}, nil).MaxTimes(1)
+			m.orderPlacer.EXPECT().GetTradingStatus(gomock.Any(), f).
+				Return(&tinkoffinvest.TradingStatus{FIGI: f, Code: tinkoffinvest.TradingStatusNormalTrading}, nil).AnyTimes()
+		}
+		m.marketData.EXPECT().SubscribeForGaps(gomock.Any()).Return(make(chan marketdatahub.Gap), nil)
+		m.orderPlacer.EXPECT().SubscribeForOrderFills(gomock.Any(), []tinkoffinvest.AccountID{accountID}).
+			Return(make(chan tinkoffinvest.OrderFill), nil)
+		return m
+	}
+
+	expectSubscriptions := func(m mocks, figis []tinkoffinvest.FIGI, subscribed chan struct{}) {
+		reqs := make([]tinkoffinvest.OrderBookRequest, len(figis))
+		for i, f := range figis {
+			reqs[i] = tinkoffinvest.OrderBookRequest{FIGI: f, Depth: 1}
+		}
+
+		m.marketData.EXPECT().SubscribeForTradingStatuses(gomock.Any(), figis).Return(make(chan tinkoffinvest.TradingStatus), nil)
+		m.marketData.EXPECT().SubscribeForOrderBookChanges(gomock.Any(), reqs).DoAndReturn(
+			func(context.Context, []tinkoffinvest.OrderBookRequest) (<-chan tinkoffinvest.OrderBookChange, error) {
+				close(subscribed)
+				return make(chan tinkoffinvest.OrderBookChange), nil
+			})
+	}
+
+	run := func(t *testing.T, s *spreadparasite.Strategy) (context.Context, func()) {
+		t.Helper()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			assert.NoError(t, s.Run(ctx))
+		}()
+		return ctx, func() {
+			cancel()
+			<-done
+		}
+	}
+
+	waitFor := func(t *testing.T, ch chan struct{}) {
+		t.Helper()
+
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+
+	t.Run("automatically chosen figis are chosen again", func(t *testing.T) {
+		m := newMocks(t)
+
+		// The spreads are 5 % and 1 %.
+		m.orderPlacer.EXPECT().GetTradeAvailableInstruments(gomock.Any(), tinkoffinvest.InstrumentTypeShare).
+			Return([]tinkoffinvest.Instrument{{FIGI: figis[0]}, {FIGI: figis[1]}}, nil).Times(2)
+		for f, bid := range map[tinkoffinvest.FIGI]string{figis[0]: "95", figis[1]: "99"} {
+			m.orderPlacer.EXPECT().GetOrderBook(gomock.Any(), tinkoffinvest.OrderBookRequest{FIGI: f, Depth: 1}).
+				Return(&tinkoffinvest.OrderBookResponse{OrderBook: tinkoffinvest.OrderBook{
+					FIGI: f,
+					Bids: []tinkoffinvest.Order{{Price: d(bid), Lots: 1}},
+					Asks: []tinkoffinvest.Order{{Price: d("100"), Lots: 1}},
+				}}, nil).Times(2)
+		}
+
+		subscribed, resubscribed := make(chan struct{}), make(chan struct{})
+		expectSubscriptions(m, figis[:1], subscribed)
+		expectSubscriptions(m, figis, resubscribed)
+
+		s, err := spreadparasite.New(accountID, false, minSpreadPercentage, nil, nil,
+			m.orderPlacer, m.marketData, m.toolsCache, nil, common.SessionOptions{})
+		require.NoError(t, err)
+
+		ctx, stop := run(t, s)
+		defer stop()
+		waitFor(t, subscribed)
+
+		require.NoError(t, s.Reconfigure(ctx, false, 0.005, nil, nil))
+		waitFor(t, resubscribed)
+		require.Equal(t, figis, s.FIGIs())
+	})
+
+	t.Run("spread has no effect on configured figis", func(t *testing.T) {
+		m := newMocks(t)
+
+		subscribed := make(chan struct{})
+		expectSubscriptions(m, figis[:1], subscribed)
+
+		s, err := spreadparasite.New(accountID, false, minSpreadPercentage, figis[:1], nil,
+			m.orderPlacer, m.marketData, m.toolsCache, nil, common.SessionOptions{})
+		require.NoError(t, err)
+
+		ctx, stop := run(t, s)
+		defer stop()
+		waitFor(t, subscribed)
+
+		// The second call waits for the first one to be applied. No instruments are chosen.
+		require.NoError(t, s.Reconfigure(ctx, false, 0.005, figis[:1], nil))
+		require.NoError(t, s.Reconfigure(ctx, false, 0.005, figis[:1], nil))
+		require.Equal(t, figis[:1], s.FIGIs())
+	})
+}